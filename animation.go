@@ -0,0 +1,516 @@
+package glitch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/png"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/unitoftime/flow/glm"
+)
+
+// The duration of frames that don't have one, like the frames of a TexturePacker sheet. Aseprite uses the same default
+const DefaultFrameDuration = 100 * time.Millisecond
+
+// The order that the frames of an AnimationTag are played in
+type LoopMode uint8
+
+const (
+	LoopForward         LoopMode = iota // From the first frame to the last
+	LoopReverse                         // From the last frame to the first
+	LoopPingPong                        // Forward and then back again, without repeating the frames at either end
+	LoopPingPongReverse                 // Like LoopPingPong, but starting from the last frame
+)
+
+type AnimationFrame struct {
+	Bounds   Rect // The frame's rectangle in the texture, in pixels
+	Duration time.Duration
+
+	// How far the center of the frame is from the center of the untrimmed image. Sheets that trim the transparent
+	// edges off of frames would otherwise shift the sprite around as it animates
+	Offset Vec2
+}
+
+// A named range of frames that can be played, like "walk" or "attack"
+type AnimationTag struct {
+	Name     string
+	From, To int // The first and last frame, inclusive
+	Mode     LoopMode
+	Repeat   int // The number of times the tag plays before it stops. Zero loops forever
+}
+
+// A notification that an AnimatedSprite has reached a frame that has events on it
+type AnimationEvent struct {
+	Name  string
+	Frame int
+}
+
+// The frames of a sprite sheet and the tags that split them into animations
+type Animation struct {
+	Texture *Texture
+	Frames  []AnimationFrame
+	Tags    []AnimationTag
+	Events  map[int][]string // The names of the events fired when each frame is reached
+}
+
+// Creates an animation that plays every frame in order, with each frame the same size and duration, ie a horizontal strip
+func NewAnimationStrip(texture *Texture, bounds Rect, frames int, duration time.Duration) *Animation {
+	anim := &Animation{
+		Texture: texture,
+		Events:  make(map[int][]string),
+	}
+	w := bounds.W() / float64(frames)
+	for i := 0; i < frames; i++ {
+		anim.Frames = append(anim.Frames, AnimationFrame{
+			Bounds:   glm.R(bounds.Min.X+float64(i)*w, bounds.Min.Y, bounds.Min.X+float64(i+1)*w, bounds.Max.Y),
+			Duration: duration,
+		})
+	}
+	return anim
+}
+
+// Returns the tag with the name
+func (a *Animation) Tag(name string) (AnimationTag, bool) {
+	for _, tag := range a.Tags {
+		if tag.Name == name {
+			return tag, true
+		}
+	}
+	return AnimationTag{}, false
+}
+
+// Fires an event whenever an AnimatedSprite reaches the frame
+func (a *Animation) AddEvent(frame int, name string) {
+	if a.Events == nil {
+		a.Events = make(map[int][]string)
+	}
+	a.Events[frame] = append(a.Events[frame], name)
+}
+
+// --------------------------------------------------------------------------------
+
+// Both Aseprite and TexturePacker export this layout, with the frames either as an array or as an object keyed by filename
+type sheetJSON struct {
+	Frames json.RawMessage `json:"frames"`
+	Meta   struct {
+		Image     string         `json:"image"`
+		FrameTags []sheetTagJSON `json:"frameTags"` // Aseprite
+	} `json:"meta"`
+	Animations map[string][]string `json:"animations"` // TexturePacker's Pixi and Phaser exports
+}
+
+type sheetFrameJSON struct {
+	Filename         string        `json:"filename"`
+	Frame            sheetRectJSON `json:"frame"`
+	Rotated          bool          `json:"rotated"`
+	Trimmed          bool          `json:"trimmed"`
+	SpriteSourceSize sheetRectJSON `json:"spriteSourceSize"`
+	SourceSize       struct {
+		W int `json:"w"`
+		H int `json:"h"`
+	} `json:"sourceSize"`
+	Duration int `json:"duration"` // Milliseconds
+}
+
+type sheetRectJSON struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type sheetTagJSON struct {
+	Name      string    `json:"name"`
+	From      int       `json:"from"`
+	To        int       `json:"to"`
+	Direction string    `json:"direction"`
+	Repeat    sheetUint `json:"repeat"`
+}
+
+// Aseprite writes the repeat count as a string
+type sheetUint int
+
+func (u *sheetUint) UnmarshalJSON(b []byte) error {
+	s := string(bytes.Trim(b, `"`))
+	if s == "" || s == "null" {
+		*u = 0
+		return nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*u = sheetUint(v)
+	return nil
+}
+
+// Parses a sprite sheet exported by Aseprite or TexturePacker. The frames index into texture, which is the sheet's image.
+//
+// Aseprite frame tags become the animation's tags, with their directions and repeat counts. Tags that cover a single
+// frame also fire an event with the tag's name when that frame is reached. TexturePacker "animations" become forward looping tags
+func ParseAnimationJSON(data []byte, texture *Texture) (*Animation, error) {
+	var sheet sheetJSON
+	if err := json.Unmarshal(data, &sheet); err != nil {
+		return nil, err
+	}
+	frames, err := decodeSheetFrames(sheet.Frames)
+	if err != nil {
+		return nil, err
+	}
+
+	anim := &Animation{
+		Texture: texture,
+		Events:  make(map[int][]string),
+	}
+	byName := make(map[string]int)
+	for i, f := range frames {
+		if f.Rotated {
+			return nil, fmt.Errorf("glitch: sprite sheet frame %q is rotated, which isn't supported", f.Filename)
+		}
+		frame := AnimationFrame{
+			Bounds:   glm.R(float64(f.Frame.X), float64(f.Frame.Y), float64(f.Frame.X+f.Frame.W), float64(f.Frame.Y+f.Frame.H)),
+			Duration: time.Duration(f.Duration) * time.Millisecond,
+		}
+		if frame.Duration <= 0 {
+			frame.Duration = DefaultFrameDuration
+		}
+		if f.Trimmed {
+			// Sprites are drawn with y up, but sheets measure y down
+			src := f.SpriteSourceSize
+			frame.Offset = Vec2{
+				float64(src.X) + float64(src.W)/2 - float64(f.SourceSize.W)/2,
+				float64(f.SourceSize.H)/2 - (float64(src.Y) + float64(src.H)/2),
+			}
+		}
+		anim.Frames = append(anim.Frames, frame)
+		byName[f.Filename] = i
+	}
+
+	for _, t := range sheet.Meta.FrameTags {
+		if t.From < 0 || t.To >= len(anim.Frames) || t.From > t.To {
+			return nil, fmt.Errorf("glitch: sprite sheet tag %q has an invalid frame range %d-%d", t.Name, t.From, t.To)
+		}
+		tag := AnimationTag{
+			Name:   t.Name,
+			From:   t.From,
+			To:     t.To,
+			Repeat: int(t.Repeat),
+		}
+		switch t.Direction {
+		case "", "forward":
+			tag.Mode = LoopForward
+		case "reverse":
+			tag.Mode = LoopReverse
+		case "pingpong":
+			tag.Mode = LoopPingPong
+		case "pingpong_reverse":
+			tag.Mode = LoopPingPongReverse
+		default:
+			return nil, fmt.Errorf("glitch: sprite sheet tag %q has an unknown direction %q", t.Name, t.Direction)
+		}
+		anim.Tags = append(anim.Tags, tag)
+		if t.From == t.To {
+			anim.AddEvent(t.From, t.Name)
+		}
+	}
+
+	names := make([]string, 0, len(sheet.Animations))
+	for name := range sheet.Animations {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		// The frames of these tags aren't necessarily next to each other, so they are appended as a copy
+		filenames := sheet.Animations[name]
+		from := len(anim.Frames)
+		for _, filename := range filenames {
+			i, ok := byName[filename]
+			if !ok {
+				return nil, fmt.Errorf("glitch: sprite sheet animation %q uses frame %q, which doesn't exist", name, filename)
+			}
+			anim.Frames = append(anim.Frames, anim.Frames[i])
+		}
+		if len(filenames) > 0 {
+			anim.Tags = append(anim.Tags, AnimationTag{Name: name, From: from, To: len(anim.Frames) - 1})
+		}
+	}
+
+	return anim, nil
+}
+
+// Keeps the frames in the order they were written, because decoding an object into a map would lose it
+func decodeSheetFrames(raw json.RawMessage) ([]sheetFrameJSON, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	if raw[0] == '[' {
+		var frames []sheetFrameJSON
+		err := json.Unmarshal(raw, &frames)
+		return frames, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	var frames []sheetFrameJSON
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var frame sheetFrameJSON
+		if err := dec.Decode(&frame); err != nil {
+			return nil, err
+		}
+		frame.Filename = key.(string)
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// Loads a sprite sheet's JSON and the image that it names, which is found relative to the JSON file
+func LoadAnimationFS(fsys fs.FS, name string) (*Animation, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	var sheet sheetJSON
+	if err := json.Unmarshal(data, &sheet); err != nil {
+		return nil, err
+	}
+	if sheet.Meta.Image == "" {
+		return nil, fmt.Errorf("glitch: sprite sheet %s doesn't name its image", name)
+	}
+
+	f, err := fsys.Open(path.Join(path.Dir(name), sheet.Meta.Image))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("glitch: decoding %s: %w", sheet.Meta.Image, err)
+	}
+
+	return ParseAnimationJSON(data, NewTexture(img, false))
+}
+
+// --------------------------------------------------------------------------------
+
+// An AnimatedSprite plays the frames of an Animation. Call Update every frame with the time that has passed:
+//
+//	player := glitch.NewAnimatedSprite(anim)
+//	player.OnEvent = func(e glitch.AnimationEvent) {
+//		if e.Name == "footstep" { playFootstep() }
+//	}
+//	player.Play("walk")
+//
+//	// Every frame
+//	player.Update(dt)
+//	player.Draw(win, mat)
+type AnimatedSprite struct {
+	OnEvent func(AnimationEvent) // Called when a frame with events is reached, from inside of Update
+	Speed   float64              // Multiplies the time passed to Update. Defaults to 1
+
+	anim    *Animation
+	sprite  *Sprite
+	tag     AnimationTag
+	step    int // The position in the tag's sequence of frames
+	frame   int // The frame being shown
+	loops   int // The number of times the tag has finished
+	elapsed time.Duration
+	playing bool
+}
+
+// Creates a sprite that shows the first frame and plays every frame in order when Play("") is called
+func NewAnimatedSprite(anim *Animation) *AnimatedSprite {
+	if len(anim.Frames) == 0 {
+		panic("glitch: animation has no frames")
+	}
+	s := &AnimatedSprite{
+		Speed:  1,
+		anim:   anim,
+		sprite: NewSprite(anim.Texture, anim.Frames[0].Bounds),
+	}
+	s.tag = s.allFrames()
+	return s
+}
+
+func (s *AnimatedSprite) allFrames() AnimationTag {
+	return AnimationTag{From: 0, To: len(s.anim.Frames) - 1}
+}
+
+// Starts playing a tag from its beginning. An empty name plays every frame. If the tag is already playing, then it keeps going
+func (s *AnimatedSprite) Play(name string) error {
+	if s.playing && s.tag.Name == name {
+		return nil
+	}
+	tag := s.allFrames()
+	if name != "" {
+		var ok bool
+		tag, ok = s.anim.Tag(name)
+		if !ok {
+			return fmt.Errorf("glitch: animation has no tag %q", name)
+		}
+	}
+
+	s.tag = tag
+	s.step = 0
+	s.loops = 0
+	s.elapsed = 0
+	s.playing = true
+	s.setFrame(s.frameAt(0))
+	return nil
+}
+
+// Stops on the current frame
+func (s *AnimatedSprite) Stop() {
+	s.playing = false
+}
+
+// Continues playing after Stop
+func (s *AnimatedSprite) Resume() {
+	s.playing = true
+}
+
+// Returns false once the tag has repeated as many times as it should, or if the sprite was stopped
+func (s *AnimatedSprite) Playing() bool {
+	return s.playing
+}
+
+// Returns the name of the tag being played
+func (s *AnimatedSprite) Tag() string {
+	return s.tag.Name
+}
+
+// Returns the index of the frame that is shown
+func (s *AnimatedSprite) Frame() int {
+	return s.frame
+}
+
+// Advances the animation by dt, firing the events of every frame that is reached along the way
+func (s *AnimatedSprite) Update(dt time.Duration) {
+	if !s.playing {
+		return
+	}
+	s.elapsed += time.Duration(float64(dt) * s.Speed)
+	for s.playing {
+		duration := s.anim.Frames[s.frame].Duration
+		if duration <= 0 {
+			duration = DefaultFrameDuration
+		}
+		if s.elapsed < duration {
+			return
+		}
+		s.elapsed -= duration
+		s.advance()
+	}
+	s.elapsed = 0
+}
+
+func (s *AnimatedSprite) advance() {
+	s.step++
+	if s.step < s.sequenceLen() {
+		s.setFrame(s.frameAt(s.step))
+		return
+	}
+
+	s.step = 0
+	s.loops++
+	if s.tag.Repeat > 0 && s.loops >= s.tag.Repeat {
+		s.playing = false
+		if s.tag.Mode == LoopPingPong || s.tag.Mode == LoopPingPongReverse {
+			s.setFrame(s.frameAt(0)) // Ping pong ends back where it started
+		}
+		return
+	}
+	s.setFrame(s.frameAt(0))
+}
+
+// The number of steps in one play through of the tag. Ping pong doesn't repeat the frames at either end
+func (s *AnimatedSprite) sequenceLen() int {
+	n := s.tag.To - s.tag.From + 1
+	if (s.tag.Mode == LoopPingPong || s.tag.Mode == LoopPingPongReverse) && n > 1 {
+		return 2*n - 2
+	}
+	return n
+}
+
+func (s *AnimatedSprite) frameAt(step int) int {
+	n := s.tag.To - s.tag.From + 1
+	switch s.tag.Mode {
+	case LoopReverse:
+		return s.tag.To - step
+	case LoopPingPong:
+		if step < n {
+			return s.tag.From + step
+		}
+		return s.tag.To - (step - n + 1)
+	case LoopPingPongReverse:
+		if step < n {
+			return s.tag.To - step
+		}
+		return s.tag.From + (step - n + 1)
+	}
+	return s.tag.From + step
+}
+
+func (s *AnimatedSprite) setFrame(frame int) {
+	s.frame = frame
+	if bounds := s.anim.Frames[frame].Bounds; s.sprite.frame != bounds {
+		s.sprite.SetTextureBounds(bounds)
+	}
+
+	if s.OnEvent == nil {
+		return
+	}
+	for _, name := range s.anim.Events[frame] {
+		s.OnEvent(AnimationEvent{Name: name, Frame: frame})
+	}
+}
+
+// Returns the sprite that shows the current frame, to change its material or translucency
+func (s *AnimatedSprite) Sprite() *Sprite {
+	return s.sprite
+}
+
+func (s *AnimatedSprite) Material() *Material {
+	return s.sprite.Material()
+}
+
+func (s *AnimatedSprite) Draw(target BatchTarget, matrix Mat4) {
+	s.DrawColorMask(target, matrix, White)
+}
+
+// Draws the current frame, shifted by its offset so that trimmed frames line up
+func (s *AnimatedSprite) DrawColorMask(target BatchTarget, matrix Mat4, mask RGBA) {
+	offset := s.anim.Frames[s.frame].Offset
+	if offset != (Vec2{}) {
+		local := Mat4Ident
+		local.Translate(offset.X, offset.Y, 0)
+		matrix.Mul(&local)
+	}
+	s.sprite.DrawColorMask(target, matrix, mask)
+}
+
+func (s *AnimatedSprite) RectDraw(target BatchTarget, bounds Rect) {
+	s.RectDrawColorMask(target, bounds, White)
+}
+
+// Stretches the current frame over bounds. The frame's offset isn't applied, because the frame fills the rectangle
+func (s *AnimatedSprite) RectDrawColorMask(target BatchTarget, bounds Rect, mask RGBA) {
+	s.sprite.RectDrawColorMask(target, bounds, mask)
+}
+
+// Returns the bounds of the current frame, centered on (0, 0)
+func (s *AnimatedSprite) Bounds() Rect {
+	return s.sprite.Bounds()
+}
@@ -0,0 +1,87 @@
+//go:build headless
+// +build headless
+
+package glitch
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/unitoftime/flow/glm"
+)
+
+func TestHeadlessAnimatedSprite(t *testing.T) {
+	newTestWindow(t, 16, 16, WindowConfig{})
+
+	// An Aseprite export in the hash layout, where the frames must stay in the order they were written
+	sheet := `{
+		"frames": {
+			"run 0.aseprite": {"frame": {"x": 0, "y": 0, "w": 8, "h": 8}, "duration": 100},
+			"run 1.aseprite": {"frame": {"x": 8, "y": 0, "w": 8, "h": 8}, "duration": 50},
+			"run 2.aseprite": {"frame": {"x": 16, "y": 0, "w": 8, "h": 8}, "duration": 100},
+			"run 10.aseprite": {"frame": {"x": 24, "y": 0, "w": 4, "h": 4}, "duration": 100,
+				"trimmed": true, "spriteSourceSize": {"x": 4, "y": 0, "w": 4, "h": 4}, "sourceSize": {"w": 8, "h": 8}}
+		},
+		"meta": {
+			"image": "run.png",
+			"frameTags": [
+				{"name": "run", "from": 0, "to": 2, "direction": "pingpong", "repeat": "1"},
+				{"name": "step", "from": 1, "to": 1, "direction": "forward"},
+				{"name": "idle", "from": 3, "to": 3, "direction": "forward"}
+			]
+		}
+	}`
+	tex := NewEmptyTexture(32, 8, false)
+	defer tex.Destroy()
+	anim, err := ParseAnimationJSON([]byte(sheet), tex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Frames) != 4 || anim.Frames[3].Bounds != glm.R(24, 0, 28, 4) {
+		t.Fatalf("expected the frames in order, got %+v", anim.Frames)
+	}
+	if anim.Frames[3].Offset != (Vec2{2, 2}) {
+		t.Errorf("expected the trimmed frame to be offset to the top right, got %v", anim.Frames[3].Offset)
+	}
+
+	player := NewAnimatedSprite(anim)
+	var events []AnimationEvent
+	player.OnEvent = func(e AnimationEvent) {
+		events = append(events, e)
+	}
+	if err := player.Play("run"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ping pong plays 0 1 2 1 and then finishes back on 0
+	var frames []int
+	for i := 0; i < 8; i++ {
+		frames = append(frames, player.Frame())
+		player.Update(50 * time.Millisecond)
+	}
+	if want := []int{0, 0, 1, 2, 2, 1, 0, 0}; !slices.Equal(frames, want) {
+		t.Errorf("expected the frames %v, got %v", want, frames)
+	}
+	if player.Playing() {
+		t.Error("expected the tag to stop after repeating once")
+	}
+	if want := []AnimationEvent{{"step", 1}, {"step", 1}}; !slices.Equal(events, want) {
+		t.Errorf("expected the events %v, got %v", want, events)
+	}
+	if player.Sprite().Frame() != anim.Frames[0].Bounds {
+		t.Errorf("expected the sprite to show the first frame, got %v", player.Sprite().Frame())
+	}
+
+	// A long update passes through several frames at once
+	if err := player.Play(""); err != nil {
+		t.Fatal(err)
+	}
+	player.Update(260 * time.Millisecond)
+	if player.Frame() != 3 {
+		t.Errorf("expected to reach the last frame, got %d", player.Frame())
+	}
+	if err := player.Play("missing"); err == nil {
+		t.Error("expected an unknown tag to fail")
+	}
+}
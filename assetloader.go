@@ -0,0 +1,487 @@
+package glitch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io/fs"
+	"runtime"
+	"sync"
+	"unicode"
+
+	"github.com/golang/freetype/truetype"
+)
+
+const defaultUploadBudget = 4 << 20 // A 1024x1024 RGBA texture
+
+var ErrLoaderClosed = errors.New("glitch: asset loader was closed")
+
+type AssetLoaderConfig struct {
+	Workers      int      // The number of files decoded at once. Defaults to the number of CPUs
+	UploadBudget int      // The bytes of pixels uploaded each frame. Defaults to 4MB
+	Placeholder  *Texture // Drawn in place of textures that haven't loaded yet. Defaults to PlaceholderTexture
+}
+
+// Loads textures, fonts and SDF font atlases in the background. Files are read and decoded on worker goroutines, and
+// their pixels are uploaded a few rows at a time whenever Window.Update is called, so that a large image doesn't stall
+// the game for a frame. Files are read from an fs.FS, ie an embed.FS, os.DirFS or assets.FS from the examples:
+//
+//	loader := glitch.NewAssetLoader(assets.FS, glitch.AssetLoaderConfig{})
+//	gopher := loader.Texture("gopher.png", glitch.TextureConfig{})
+//	for !win.Closed() {
+//		tex := gopher.Texture() // The placeholder until the gopher is ready
+//		...
+//		win.Update()
+//	}
+type AssetLoader struct {
+	fsys fs.FS
+	cfg  AssetLoaderConfig
+	sem  chan struct{} // Limits the number of workers
+
+	mu      sync.Mutex
+	uploads []*assetUpload // Decoded assets in the order that they finished decoding
+	closed  bool
+}
+
+// Creates a loader that reads from fsys. Loaders upload as part of Window.Update until they are closed
+func NewAssetLoader(fsys fs.FS, cfg AssetLoaderConfig) *AssetLoader {
+	if cfg.Workers <= 0 {
+		cfg.Workers = runtime.NumCPU()
+	}
+	if cfg.UploadBudget <= 0 {
+		cfg.UploadBudget = defaultUploadBudget
+	}
+	if cfg.Placeholder == nil {
+		cfg.Placeholder = PlaceholderTexture()
+	}
+
+	l := &AssetLoader{
+		fsys: fsys,
+		cfg:  cfg,
+		sem:  make(chan struct{}, cfg.Workers),
+	}
+	global.loaders = append(global.loaders, l)
+	return l
+}
+
+// Stops uploading. Assets that haven't loaded yet fail with ErrLoaderClosed, and the ones that have loaded are kept
+func (l *AssetLoader) Close() {
+	l.mu.Lock()
+	l.closed = true
+	uploads := l.uploads
+	l.uploads = nil
+	l.mu.Unlock()
+
+	for _, u := range uploads {
+		if u.tex != nil {
+			u.tex.Destroy() // Partially uploaded
+		}
+		u.fail(ErrLoaderClosed)
+	}
+	for i, loader := range global.loaders {
+		if loader == l {
+			global.loaders = append(global.loaders[:i], global.loaders[i+1:]...)
+			break
+		}
+	}
+}
+
+// Returns the number of assets that have been decoded but haven't finished uploading
+func (l *AssetLoader) Pending() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.uploads)
+}
+
+// --------------------------------------------------------------------------------
+
+// A handle to an asset that is loading in the background. It is a future: Ready reports whether the asset has loaded,
+// Done can be selected on from any goroutine, and Wait finishes loading it right away
+type Asset[T any] struct {
+	loader  *AssetLoader
+	name    string
+	decoded chan struct{} // Closed once the worker is done with the file
+	done    chan struct{} // Closed once the asset has loaded or failed
+	upload  *assetUpload  // Set by the worker if the asset decoded
+	value   T
+	err     error
+}
+
+func newAsset[T any](l *AssetLoader, name string) *Asset[T] {
+	return &Asset[T]{
+		loader:  l,
+		name:    name,
+		decoded: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+func (a *Asset[T]) Name() string {
+	return a.name
+}
+
+// Returns true once the asset has loaded. Assets that fail to load are never ready, see Err
+func (a *Asset[T]) Ready() bool {
+	select {
+	case <-a.done:
+		return a.err == nil
+	default:
+		return false
+	}
+}
+
+// Returns a channel that is closed once the asset has loaded or failed to load
+func (a *Asset[T]) Done() <-chan struct{} {
+	return a.done
+}
+
+// Returns the error that the asset failed to load with, or nil if it hasn't failed
+func (a *Asset[T]) Err() error {
+	select {
+	case <-a.done:
+		return a.err
+	default:
+		return nil
+	}
+}
+
+// Returns the asset, or the zero value if it isn't ready
+func (a *Asset[T]) Value() T {
+	if !a.Ready() {
+		var zero T
+		return zero
+	}
+	return a.value
+}
+
+// Waits for the asset to be decoded and then uploads the rest of it, ignoring the loader's upload budget. Like the
+// rest of glitch's drawing functions, this must be called from the goroutine that calls Window.Update
+func (a *Asset[T]) Wait() (T, error) {
+	<-a.decoded
+	if a.upload != nil {
+		a.loader.finish(a.upload)
+	}
+	<-a.done
+	return a.value, a.err
+}
+
+// Loads on a worker goroutine. Decode returns the pixels to upload, and complete turns the finished texture into the
+// asset on the goroutine that uploaded it
+func (a *Asset[T]) start(decode func() (*assetUpload, error), complete func(tex *Texture) T) {
+	go func() {
+		a.loader.sem <- struct{}{}
+		defer func() { <-a.loader.sem }()
+
+		u, err := a.decode(decode)
+		if err != nil {
+			a.err = fmt.Errorf("glitch: loading %s: %w", a.name, err)
+			close(a.decoded)
+			close(a.done)
+			return
+		}
+
+		u.complete = func(tex *Texture) {
+			a.value = complete(tex)
+			close(a.done)
+		}
+		u.fail = func(err error) {
+			a.err = err
+			close(a.done)
+		}
+		a.upload = u
+
+		// Queue before closing decoded, so that the upload can always be found once the file has decoded
+		a.loader.mu.Lock()
+		if a.loader.closed {
+			u.fail(ErrLoaderClosed)
+		} else {
+			a.loader.uploads = append(a.loader.uploads, u)
+		}
+		a.loader.mu.Unlock()
+		close(a.decoded)
+	}()
+}
+
+// Decoders can panic on malformed files (ie fonts that are missing a rune), which would otherwise take down the game
+func (a *Asset[T]) decode(decode func() (*assetUpload, error)) (u *assetUpload, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	if a.loader.isClosed() {
+		return nil, ErrLoaderClosed
+	}
+	return decode()
+}
+
+func (l *AssetLoader) isClosed() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.closed
+}
+
+// --------------------------------------------------------------------------------
+
+// A texture that is loading. Texture returns the loader's placeholder until it is ready
+type TextureAsset struct {
+	*Asset[*Texture]
+}
+
+// Returns the texture if it is ready, otherwise the placeholder
+func (a TextureAsset) Texture() *Texture {
+	if tex := a.Value(); tex != nil {
+		return tex
+	}
+	return a.loader.cfg.Placeholder
+}
+
+// Loads an image, or a KTX2 or DDS file (see NewCompressedTexture). Images can be in any format registered with the
+// image package
+func (l *AssetLoader) Texture(name string, cfg TextureConfig) TextureAsset {
+	asset := newAsset[*Texture](l, name)
+	asset.start(func() (*assetUpload, error) {
+		data, err := fs.ReadFile(l.fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		file, err := parseTextureFile(data)
+		if err == nil {
+			if err := file.prepare(); err != nil {
+				return nil, err
+			}
+			if file.pixels == nil {
+				// Compressed levels are small, so they are uploaded all at once
+				return &assetUpload{file: file, cfg: cfg}, nil
+			}
+			return newAssetUpload(file.width, file.height, file.format.format, file.pixels, cfg), nil
+		} else if !errors.Is(err, errNotTextureFile) {
+			return nil, err
+		}
+
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		rgba := toRgba(img)
+		return newAssetUpload(rgba.Bounds().Dx(), rgba.Bounds().Dy(), TextureRGBA8, rgba.Pix, cfg), nil
+	}, func(tex *Texture) *Texture {
+		return tex
+	})
+	return TextureAsset{asset}
+}
+
+// Loads a TrueType font and draws its glyphs into an atlas, like NewAtlas. Runes defaults to printable ASCII
+func (l *AssetLoader) Font(name string, size float64, runes []rune, cfg AtlasConfig) *Asset[*Atlas] {
+	if runes == nil {
+		for r := rune(32); r < unicode.MaxASCII; r++ {
+			runes = append(runes, r)
+		}
+	}
+
+	var atlas *Atlas
+	asset := newAsset[*Atlas](l, name)
+	asset.start(func() (*assetUpload, error) {
+		data, err := fs.ReadFile(l.fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		font, err := truetype.Parse(data)
+		if err != nil {
+			return nil, err
+		}
+		face := truetype.NewFace(font, &truetype.Options{Size: size})
+
+		var img *image.RGBA
+		atlas, img = newAtlasImage(face, runes, cfg)
+		return newAssetUpload(img.Bounds().Dx(), img.Bounds().Dy(), TextureRGBA8, img.Pix, smoothConfig(cfg.Smooth)), nil
+	}, func(tex *Texture) *Atlas {
+		atlas.texture = tex
+		atlas.defaultMaterial = DefaultMaterial(tex)
+		return atlas
+	})
+	return asset
+}
+
+// Loads an SDF font atlas made by msdf-atlas-gen from its JSON layout and image, like AtlasFromSdf
+func (l *AssetLoader) SdfAtlas(jsonName, imageName string, kerning float64) *Asset[*Atlas] {
+	var atlas *Atlas
+	asset := newAsset[*Atlas](l, jsonName)
+	asset.start(func() (*assetUpload, error) {
+		data, err := fs.ReadFile(l.fsys, jsonName)
+		if err != nil {
+			return nil, err
+		}
+		var sdf SdfAtlas
+		if err := json.Unmarshal(data, &sdf); err != nil {
+			return nil, err
+		}
+
+		f, err := l.fsys.Open(imageName)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		img, _, err := image.Decode(f)
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", imageName, err)
+		}
+
+		atlas = atlasFromSdfGlyphs(sdf, kerning)
+		rgba := toRgba(img)
+		return newAssetUpload(rgba.Bounds().Dx(), rgba.Bounds().Dy(), TextureRGBA8, rgba.Pix, smoothConfig(true)), nil
+	}, func(tex *Texture) *Atlas {
+		atlas.texture = tex
+		atlas.defaultMaterial = DefaultMsdfMaterial(tex)
+		return atlas
+	})
+	return asset
+}
+
+// --------------------------------------------------------------------------------
+
+// Decoded pixels that are waiting to be uploaded. Uploads are only touched by the goroutine that calls Window.Update
+type assetUpload struct {
+	width, height int
+	format        TextureFormat
+	pixels        []uint8 // Rows are padded to the unpack alignment
+	stride        int
+	cfg           TextureConfig
+
+	file *textureFile // Set instead of the pixels for compressed files
+
+	tex  *Texture
+	row  int // The next row to upload
+	done bool
+
+	complete func(tex *Texture)
+	fail     func(err error)
+}
+
+func newAssetUpload(width, height int, format TextureFormat, pixels []uint8, cfg TextureConfig) *assetUpload {
+	f := textureFormats[format]
+	return &assetUpload{
+		width:  width,
+		height: height,
+		format: format,
+		pixels: pixels,
+		stride: alignRow(width * texturePixelBytes(f.format, f.ty)),
+		cfg:    cfg,
+	}
+}
+
+// Uploads as many rows as fit in the budget, but always at least one. Returns the number of bytes uploaded
+func (u *assetUpload) step(budget int) int {
+	if u.file != nil {
+		u.tex = u.file.texture(u.cfg)
+		u.done = true
+		return u.tex.bytes
+	}
+
+	if u.tex == nil {
+		// Mipmaps are generated once every row is uploaded, rather than after each chunk
+		cfg := u.cfg
+		cfg.Mipmaps = false
+		u.tex = &Texture{
+			width:  u.width,
+			height: u.height,
+			config: cfg,
+		}
+		f := textureFormats[u.format]
+		u.tex.initializeFormat(f.internal, f.format, f.ty, nil)
+	}
+
+	rows := min(max(budget/max(u.stride, 1), 1), u.height-u.row)
+	if rows > 0 {
+		u.tex.setPixels(0, u.row, u.width, rows, u.pixels[u.row*u.stride:(u.row+rows)*u.stride])
+		u.row += rows
+	}
+	if u.row >= u.height {
+		if u.cfg.Mipmaps {
+			u.tex.SetConfig(u.cfg)
+		}
+		u.pixels = nil
+		u.done = true
+	}
+	return rows * u.stride
+}
+
+// Uploads within the budget, oldest assets first. Called by Window.Update
+func (l *AssetLoader) upload() {
+	budget := l.cfg.UploadBudget
+	for budget > 0 {
+		l.mu.Lock()
+		if len(l.uploads) == 0 {
+			l.mu.Unlock()
+			return
+		}
+		u := l.uploads[0]
+		l.mu.Unlock()
+
+		budget -= u.step(budget)
+		if u.done {
+			l.remove(u)
+			u.complete(u.tex)
+		}
+	}
+}
+
+// Uploads the rest of an asset right away
+func (l *AssetLoader) finish(u *assetUpload) {
+	if !l.remove(u) {
+		return // Already finished, or the loader was closed
+	}
+	for !u.done {
+		u.step(u.height * u.stride)
+	}
+	u.complete(u.tex)
+}
+
+// Returns false if the upload wasn't queued
+func (l *AssetLoader) remove(u *assetUpload) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, queued := range l.uploads {
+		if queued == u {
+			l.uploads = append(l.uploads[:i], l.uploads[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func uploadAssets() {
+	for _, l := range global.loaders {
+		l.upload()
+	}
+}
+
+// --------------------------------------------------------------------------------
+
+var placeholderTexture *Texture
+
+// Returns a magenta and black checkerboard, which stands out wherever an asset hasn't loaded
+func PlaceholderTexture() *Texture {
+	if placeholderTexture != nil {
+		return placeholderTexture
+	}
+
+	magenta := color.RGBA{0xFF, 0x00, 0xFF, 0xFF}
+	black := color.RGBA{0x00, 0x00, 0x00, 0xFF}
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			if (x/4+y/4)%2 == 0 {
+				img.SetRGBA(x, y, magenta)
+			} else {
+				img.SetRGBA(x, y, black)
+			}
+		}
+	}
+	placeholderTexture = NewTexture(img, false)
+	return placeholderTexture
+}
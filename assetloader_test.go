@@ -0,0 +1,109 @@
+//go:build headless
+// +build headless
+
+package glitch
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/unitoftime/flow/glm"
+	"github.com/unitoftime/glitch/examples/assets"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestHeadlessAssetLoader(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i)
+		if i%4 == 3 {
+			img.Pix[i] = 255 // Opaque, so that the png round trip is exact
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{
+		"image.png": {Data: buf.Bytes()},
+		"font.ttf":  {Data: goregular.TTF},
+		"bad.dds":   {Data: []byte("DDS not really")},
+	}
+
+	// Four rows fit in the budget, so the image takes four frames to upload
+	loader := NewAssetLoader(fsys, AssetLoaderConfig{UploadBudget: 4 * 16 * 4})
+	defer loader.Close()
+	asset := loader.Texture("image.png", TextureConfig{})
+	if asset.Ready() || asset.Texture() != PlaceholderTexture() {
+		t.Fatal("expected the placeholder before the texture has loaded")
+	}
+	<-asset.decoded
+	frames := 0
+	for !asset.Ready() && frames < 10 {
+		win.Update()
+		frames++
+	}
+	if frames != 4 {
+		t.Errorf("expected the upload to be spread over 4 frames, got %d", frames)
+	}
+	tex := asset.Texture()
+	if tex == PlaceholderTexture() || tex.width != 16 || tex.height != 16 {
+		t.Fatal("expected the loaded texture")
+	}
+	defer tex.Destroy()
+
+	// The rows uploaded in chunks match a texture uploaded all at once
+	reference := NewTexture(img, false)
+	defer reference.Destroy()
+	mesh := NewQuadMesh(win.Bounds(), glm.R(0, 0, 1, 1))
+	draw := func(tex *Texture) []uint8 {
+		Clear(win, RGBA{})
+		win.Add(mesh, glMat4Ident, White, DefaultMaterial(tex), false)
+		return win.Image().Pix
+	}
+	if !slices.Equal(draw(tex), draw(reference)) {
+		t.Error("expected the loaded texture to match the image")
+	}
+	win.Update()
+
+	missing := loader.Texture("missing.png", TextureConfig{})
+	if _, err := missing.Wait(); err == nil || missing.Ready() || missing.Texture() != PlaceholderTexture() {
+		t.Error("expected a missing file to fail and keep the placeholder")
+	}
+	if _, err := loader.Texture("bad.dds", TextureConfig{}).Wait(); err == nil {
+		t.Error("expected a malformed DDS file to fail")
+	}
+
+	font, err := loader.Font("font.ttf", 16, nil, AtlasConfig{Smooth: true, TextureSize: 256, Padding: 2}).Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if font.texture == nil || font.Measure("hello", 1).W() <= 0 {
+		t.Error("expected the font atlas to be built")
+	}
+	font.texture.Destroy()
+
+	sdfLoader := NewAssetLoader(assets.FS, AssetLoaderConfig{})
+	sdf, err := sdfLoader.SdfAtlas("atlas-msdf.json", "atlas-msdf.png", 3).Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sdf.texture == nil || sdf.defaultMaterial.shader != GetDefaultMsdfShader() {
+		t.Error("expected the SDF atlas to use the msdf material")
+	}
+	sdf.texture.Destroy()
+
+	// Assets that haven't been uploaded when the loader closes fail
+	pending := sdfLoader.Texture("gopher.png", TextureConfig{})
+	sdfLoader.Close()
+	if _, err := pending.Wait(); !errors.Is(err, ErrLoaderClosed) {
+		t.Errorf("expected the closed loader to fail the texture, got %v", err)
+	}
+}
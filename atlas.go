@@ -60,6 +60,14 @@ func sdfUnitToFloat(sdfUnit float64, fontSize, texSize int) float64 {
 func AtlasFromSdf(sdf SdfAtlas, sdfImg image.Image, kerning float64) (*Atlas, error) {
 	texture := NewTexture(sdfImg, true) // TODO: Smoothing for sdf?
 
+	atlas := atlasFromSdfGlyphs(sdf, kerning)
+	atlas.texture = texture
+	atlas.defaultMaterial = DefaultMsdfMaterial(texture)
+	return atlas, nil
+}
+
+// Builds the glyph mapping without the texture
+func atlasFromSdfGlyphs(sdf SdfAtlas, kerning float64) *Atlas {
 	// height := sdfUnitToFloat(sdf.Metrics.LineHeight, sdf.Atlas.Size, sdf.Atlas.Width)
 	height := sdf.Metrics.LineHeight * float64(sdf.Atlas.Size)
 	ascent := sdf.Metrics.Ascender * float64(sdf.Atlas.Size)
@@ -79,10 +87,8 @@ func AtlasFromSdf(sdf SdfAtlas, sdfImg image.Image, kerning float64) (*Atlas, er
 		// ascent: descent,
 		// descent: ascent,
 		// height: height,
-		texture: texture,
 		// pixelPerfect: true,
-		defaultKerning:  kerning,
-		defaultMaterial: DefaultMsdfMaterial(texture),
+		defaultKerning: kerning,
 	}
 
 	for _, g := range sdf.Glyphs {
@@ -123,5 +129,5 @@ func AtlasFromSdf(sdf SdfAtlas, sdfImg image.Image, kerning float64) (*Atlas, er
 		atlas.mapping[rune(g.Unicode)] = glyph
 	}
 
-	return atlas, nil
+	return atlas
 }
@@ -12,6 +12,8 @@ type meshDraw struct {
 
 // For batching multiple sprites into one
 type DrawBatch struct {
+	Cull bool // If set, draws that are outside of the current camera are skipped when the batch is drawn
+
 	draws []meshDraw
 
 	boundsSet bool
@@ -41,7 +43,7 @@ func (b *DrawBatch) Add(filler GeometryFiller, matrix glMat4, mask RGBA, materia
 		translucent: translucent,
 	})
 
-	newBounds := filler.Bounds().Apply(matrix.Mat4())
+	newBounds := transformBox(filler.Bounds(), matrix.Mat4())
 	// TODO: Does this improve performance?
 	// if matrix != glMat4Ident {
 	// 	newBounds = newBounds.Apply(matrix)
@@ -61,9 +63,15 @@ func (b *DrawBatch) Clear() {
 }
 
 func (b *DrawBatch) Draw(target BatchTarget, matrix Mat4) {
+	if b.Cull && cullBox(b.bounds, matrix, len(b.draws)) {
+		return
+	}
 	for i := range b.draws {
 		mat := glm4(matrix)
 		mat.Mul(&b.draws[i].matrix)
+		if b.Cull && cullDraw(b.draws[i].filler, mat) {
+			continue
+		}
 		target.Add(b.draws[i].filler, mat, b.draws[i].mask, b.draws[i].material, b.draws[i].translucent)
 	}
 	// target.Add(b.mesh, matrix.gl(), RGBA{1.0, 1.0, 1.0, 1.0}, b.material, b.Translucent)
@@ -71,9 +79,15 @@ func (b *DrawBatch) Draw(target BatchTarget, matrix Mat4) {
 }
 
 func (b *DrawBatch) DrawColorMask(target BatchTarget, matrix Mat4, color RGBA) {
+	if b.Cull && cullBox(b.bounds, matrix, len(b.draws)) {
+		return
+	}
 	for i := range b.draws {
 		mat := glm4(matrix)
 		mat.Mul(&b.draws[i].matrix)
+		if b.Cull && cullDraw(b.draws[i].filler, mat) {
+			continue
+		}
 
 		mask := b.draws[i].mask.Mult(color)
 		target.Add(b.draws[i].filler, mat, mask, b.draws[i].material, b.draws[i].translucent)
@@ -241,6 +255,13 @@ func (b *Batch) Add(filler GeometryFiller, matrix glMat4, mask RGBA, material Ma
 		}
 	}
 
+	mode, indices := mesh.listIndices()
+	if len(b.mesh.indices) == 0 {
+		b.mesh.primitive = mode
+	} else if b.mesh.primitive != mode {
+		panic(fmt.Sprintf("Primitives must match inside a batch! %v != %v", b.mesh.primitive, mode))
+	}
+
 	// If anything translucent is added to the batch, then we will consider the entire thing translucent
 	b.Translucent = b.Translucent || translucent
 
@@ -248,10 +269,12 @@ func (b *Batch) Add(filler GeometryFiller, matrix glMat4, mask RGBA, material Ma
 
 	// Append each index
 	currentElement := uint32(len(b.mesh.positions))
-	for i := range mesh.indices {
-		b.mesh.indices = append(b.mesh.indices, currentElement+mesh.indices[i])
+	for i := range indices {
+		b.mesh.indices = append(b.mesh.indices, currentElement+indices[i])
 	}
 
+	b.mesh.bones = appendBones(b.mesh.bones, len(b.mesh.positions), mesh.bones, len(mesh.positions))
+
 	// Append each position
 	for i := range mesh.positions {
 		b.mesh.positions = append(b.mesh.positions, matrix.Apply(mesh.positions[i]))
@@ -1,25 +1,140 @@
 package glitch
 
-import "github.com/unitoftime/glitch/internal/gl"
+import (
+	"math"
+
+	"github.com/unitoftime/glitch/internal/gl"
+)
 
 // Note: These are all packed into uint8s to reduce size of the Material object
 type BlendMode uint8
 
+// Glitch renders with premultiplied alpha, so these modes expect premultiplied source colors unless noted
+const (
+	BlendModeNormal           BlendMode = iota
+	BlendModeMultiply                   // Multiplies the destination by the source color
+	BlendModeAdd                        // Adds the source to the destination
+	BlendModeScreen                     // Inverse multiply, brightens without saturating as quickly as add
+	BlendModeSubtract                   // Subtracts the source color from the destination, keeping destination alpha
+	BlendModeMin                        // Keeps the minimum of each channel
+	BlendModeMax                        // Keeps the maximum of each channel
+	BlendModeErase                      // Cuts the source alpha out of the destination
+	BlendModeReplace                    // Overwrites the destination, including alpha
+	BlendModeNonPremultiplied           // Normal blending for sources with straight alpha, producing premultiplied output
+)
+
+// Factors that the source and destination are multiplied by before the blend equation is applied
+type BlendFactor uint8
+
+const (
+	BlendFactorZero BlendFactor = iota
+	BlendFactorOne
+	BlendFactorSrcColor
+	BlendFactorOneMinusSrcColor
+	BlendFactorDstColor
+	BlendFactorOneMinusDstColor
+	BlendFactorSrcAlpha
+	BlendFactorOneMinusSrcAlpha
+	BlendFactorDstAlpha
+	BlendFactorOneMinusDstAlpha
+	BlendFactorSrcAlphaSaturate
+)
+
+var blendFactorLut = []gl.Enum{
+	BlendFactorZero:             gl.ZERO,
+	BlendFactorOne:              gl.ONE,
+	BlendFactorSrcColor:         gl.SRC_COLOR,
+	BlendFactorOneMinusSrcColor: gl.ONE_MINUS_SRC_COLOR,
+	BlendFactorDstColor:         gl.DST_COLOR,
+	BlendFactorOneMinusDstColor: gl.ONE_MINUS_DST_COLOR,
+	BlendFactorSrcAlpha:         gl.SRC_ALPHA,
+	BlendFactorOneMinusSrcAlpha: gl.ONE_MINUS_SRC_ALPHA,
+	BlendFactorDstAlpha:         gl.DST_ALPHA,
+	BlendFactorOneMinusDstAlpha: gl.ONE_MINUS_DST_ALPHA,
+	BlendFactorSrcAlphaSaturate: gl.SRC_ALPHA_SATURATE,
+}
+
+// How the weighted source and destination are combined
+type BlendEquation uint8
+
 const (
-	BlendModeNormal BlendMode = iota
-	BlendModeMultiply
+	BlendEquationAdd             BlendEquation = iota // src + dst
+	BlendEquationSubtract                             // src - dst
+	BlendEquationReverseSubtract                      // dst - src
+	BlendEquationMin                                  // min(src, dst), ignores the factors
+	BlendEquationMax                                  // max(src, dst), ignores the factors
 )
 
-type blendModeData struct {
-	src, dst gl.Enum
+var blendEquationLut = []gl.Enum{
+	BlendEquationAdd:             gl.FUNC_ADD,
+	BlendEquationSubtract:        gl.FUNC_SUBTRACT,
+	BlendEquationReverseSubtract: gl.FUNC_REVERSE_SUBTRACT,
+	BlendEquationMin:             gl.MIN,
+	BlendEquationMax:             gl.MAX,
+}
+
+// Describes a blend mode with separate factors and equations for the color and alpha channels
+type BlendDescriptor struct {
+	SrcRGB, DstRGB     BlendFactor
+	SrcAlpha, DstAlpha BlendFactor
+	EquationRGB        BlendEquation
+	EquationAlpha      BlendEquation
+}
+
+// Uses the same factors and equation for color and alpha
+func NewBlendDescriptor(src, dst BlendFactor, equation BlendEquation) BlendDescriptor {
+	return BlendDescriptor{
+		SrcRGB:        src,
+		DstRGB:        dst,
+		SrcAlpha:      src,
+		DstAlpha:      dst,
+		EquationRGB:   equation,
+		EquationAlpha: equation,
+	}
 }
 
-var blendModeLut []blendModeData = []blendModeData{
+var blendModeLut []BlendDescriptor = []BlendDescriptor{
 	// Note: This is what I used before premult: gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA
-	BlendModeNormal: {gl.ONE, gl.ONE_MINUS_SRC_ALPHA},
-	// BlendModeNormal: {gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA},
-	// BlendModeNormal: {gl.SRC_ALPHA, gl.ONE},
-	BlendModeMultiply: {gl.DST_COLOR, gl.ZERO},
+	BlendModeNormal:   NewBlendDescriptor(BlendFactorOne, BlendFactorOneMinusSrcAlpha, BlendEquationAdd),
+	BlendModeMultiply: NewBlendDescriptor(BlendFactorDstColor, BlendFactorZero, BlendEquationAdd),
+	BlendModeAdd:      NewBlendDescriptor(BlendFactorOne, BlendFactorOne, BlendEquationAdd),
+	BlendModeScreen: {
+		SrcRGB: BlendFactorOne, DstRGB: BlendFactorOneMinusSrcColor,
+		SrcAlpha: BlendFactorOne, DstAlpha: BlendFactorOneMinusSrcAlpha,
+	},
+	BlendModeSubtract: {
+		SrcRGB: BlendFactorOne, DstRGB: BlendFactorOne,
+		SrcAlpha: BlendFactorZero, DstAlpha: BlendFactorOne,
+		EquationRGB: BlendEquationReverseSubtract, EquationAlpha: BlendEquationAdd,
+	},
+	BlendModeMin:     NewBlendDescriptor(BlendFactorOne, BlendFactorOne, BlendEquationMin),
+	BlendModeMax:     NewBlendDescriptor(BlendFactorOne, BlendFactorOne, BlendEquationMax),
+	BlendModeErase:   NewBlendDescriptor(BlendFactorZero, BlendFactorOneMinusSrcAlpha, BlendEquationAdd),
+	BlendModeReplace: NewBlendDescriptor(BlendFactorOne, BlendFactorZero, BlendEquationAdd),
+	BlendModeNonPremultiplied: {
+		SrcRGB: BlendFactorSrcAlpha, DstRGB: BlendFactorOneMinusSrcAlpha,
+		SrcAlpha: BlendFactorOne, DstAlpha: BlendFactorOneMinusSrcAlpha,
+	},
+}
+
+// Registers a custom blend mode and returns it. Registering a descriptor that already has a mode returns the existing mode.
+// Only 256 modes can exist, so this panics if the table is full
+func RegisterBlendMode(desc BlendDescriptor) BlendMode {
+	for i := range blendModeLut {
+		if blendModeLut[i] == desc {
+			return BlendMode(i)
+		}
+	}
+	if len(blendModeLut) > math.MaxUint8 {
+		panic("RegisterBlendMode: too many blend modes")
+	}
+	blendModeLut = append(blendModeLut, desc)
+	return BlendMode(len(blendModeLut) - 1)
+}
+
+// Returns the factors and equations used by the blend mode
+func (b BlendMode) Descriptor() BlendDescriptor {
+	return blendModeLut[b]
 }
 
 type DepthMode uint8
@@ -56,6 +171,92 @@ var cullModeLut []cullModeData = []cullModeData{
 	CullModeNormal: {gl.BACK, gl.CCW},
 }
 
+type StencilFunc uint8
+
+const (
+	StencilFuncDisabled StencilFunc = iota // The stencil test is turned off
+	StencilFuncAlways
+	StencilFuncNever
+	StencilFuncEqual
+	StencilFuncNotEqual
+	StencilFuncLess
+	StencilFuncLequal
+	StencilFuncGreater
+	StencilFuncGequal
+)
+
+var stencilFuncLut = []gl.Enum{
+	StencilFuncDisabled: gl.ALWAYS,
+	StencilFuncAlways:   gl.ALWAYS,
+	StencilFuncNever:    gl.NEVER,
+	StencilFuncEqual:    gl.EQUAL,
+	StencilFuncNotEqual: gl.NOTEQUAL,
+	StencilFuncLess:     gl.LESS,
+	StencilFuncLequal:   gl.LEQUAL,
+	StencilFuncGreater:  gl.GREATER,
+	StencilFuncGequal:   gl.GEQUAL,
+}
+
+type StencilOp uint8
+
+const (
+	StencilOpKeep StencilOp = iota
+	StencilOpZero
+	StencilOpReplace
+	StencilOpIncr
+	StencilOpIncrWrap
+	StencilOpDecr
+	StencilOpDecrWrap
+	StencilOpInvert
+)
+
+var stencilOpLut = []gl.Enum{
+	StencilOpKeep:     gl.KEEP,
+	StencilOpZero:     gl.ZERO,
+	StencilOpReplace:  gl.REPLACE,
+	StencilOpIncr:     gl.INCR,
+	StencilOpIncrWrap: gl.INCR_WRAP,
+	StencilOpDecr:     gl.DECR,
+	StencilOpDecrWrap: gl.DECR_WRAP,
+	StencilOpInvert:   gl.INVERT,
+}
+
+// Controls how a material tests against and writes to the stencil buffer. The zero value disables stencil testing.
+// The target must have a stencil buffer (see WindowConfig.Stencil and NewFrameExt)
+type StencilMode struct {
+	Func      StencilFunc
+	Ref       uint8 // The reference value that Func compares against and that StencilOpReplace writes
+	ReadMask  uint8 // ANDed with both the reference and stored value before comparing
+	WriteMask uint8 // The bits that can be written
+
+	Fail      StencilOp // Applied when the stencil test fails
+	DepthFail StencilOp // Applied when the stencil test passes but the depth test fails
+	Pass      StencilOp // Applied when both tests pass
+
+	HideColor bool // Disables color writes, so only the stencil buffer is drawn to
+}
+
+// Draws only where the stencil buffer equals ref
+func StencilModeTest(ref uint8) StencilMode {
+	return StencilMode{
+		Func:     StencilFuncEqual,
+		Ref:      ref,
+		ReadMask: 0xFF,
+	}
+}
+
+// Writes ref into the stencil buffer wherever the geometry covers, without drawing any color
+func StencilModeWrite(ref uint8) StencilMode {
+	return StencilMode{
+		Func:      StencilFuncAlways,
+		Ref:       ref,
+		ReadMask:  0xFF,
+		WriteMask: 0xFF,
+		Pass:      StencilOpReplace,
+		HideColor: true,
+	}
+}
+
 // // https://registry.khronos.org/OpenGL-Refpages/gl4/html/glBlendFunc.xhtml
 // type BlendMode struct {
 // 	src, dst gl.Enum
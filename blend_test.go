@@ -0,0 +1,60 @@
+//go:build headless
+// +build headless
+
+package glitch
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/unitoftime/flow/glm"
+)
+
+func TestHeadlessBlendModes(t *testing.T) {
+	win, _ := newTestWindow(t, 8, 8, WindowConfig{})
+
+	custom := RegisterBlendMode(BlendDescriptor{
+		SrcRGB: BlendFactorZero, DstRGB: BlendFactorSrcColor,
+		SrcAlpha: BlendFactorZero, DstAlpha: BlendFactorOne,
+	})
+	if RegisterBlendMode(custom.Descriptor()) != custom {
+		t.Errorf("expected registering the same descriptor to return the same mode")
+	}
+
+	background := RGBA{0.5, 0.5, 0.5, 1}
+	tests := []struct {
+		name  string
+		mode  BlendMode
+		color RGBA
+		want  color.RGBA
+	}{
+		{"add", BlendModeAdd, RGBA{0.25, 0, 0, 0}, color.RGBA{191, 128, 128, 255}},
+		{"subtract", BlendModeSubtract, RGBA{0.25, 0, 0, 1}, color.RGBA{64, 128, 128, 255}},
+		{"min", BlendModeMin, RGBA{0.25, 1, 1, 1}, color.RGBA{64, 128, 128, 255}},
+		{"max", BlendModeMax, RGBA{0.25, 1, 1, 1}, color.RGBA{128, 255, 255, 255}},
+		{"erase", BlendModeErase, RGBA{1, 1, 1, 1}, color.RGBA{0, 0, 0, 0}},
+		{"replace", BlendModeReplace, RGBA{0, 0, 1, 0.5}, color.RGBA{0, 0, 128, 128}},
+		{"custom", custom, RGBA{0.5, 1, 0, 0.25}, color.RGBA{64, 128, 0, 255}},
+	}
+
+	mesh := NewQuadMesh(win.Bounds(), glm.R(0, 0, 1, 1))
+	for _, test := range tests {
+		Clear(win, background)
+		material := DefaultMaterial(WhiteTexture())
+		material.SetBlendMode(test.mode)
+		win.Add(mesh, glMat4Ident, test.color, material, false)
+
+		got := win.Image().RGBAAt(4, 4)
+		if absDiff(got.R, test.want.R) > 1 || absDiff(got.G, test.want.G) > 1 || absDiff(got.B, test.want.B) > 1 || absDiff(got.A, test.want.A) > 1 {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, got)
+		}
+	}
+	win.Update()
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -0,0 +1,297 @@
+package glitch
+
+import "encoding/binary"
+
+// Software decoders for block compressed textures, for GPUs that can't sample them. Each decoder writes the 16 texels
+// of a 4x4 block in row order. BC blocks are little endian, and ETC blocks are big endian with their texels ordered
+// by column
+
+func decodeBC1(block []byte, texels *[16][4]uint8) {
+	decodeBCColors(block, texels, true, true)
+}
+
+// Opaque BC1 decodes the transparent texels of the three color mode as black
+func decodeBC1RGB(block []byte, texels *[16][4]uint8) {
+	decodeBCColors(block, texels, true, false)
+}
+
+// BC2 stores 4 bits of alpha per texel before its colors
+func decodeBC2(block []byte, texels *[16][4]uint8) {
+	decodeBCColors(block[8:], texels, false, false)
+	alpha := binary.LittleEndian.Uint64(block)
+	for i := range texels {
+		texels[i][3] = uint8(alpha>>(4*i)&0xF) * 17
+	}
+}
+
+// BC3 stores a BC4 block of alpha before its colors
+func decodeBC3(block []byte, texels *[16][4]uint8) {
+	decodeBCColors(block[8:], texels, false, false)
+	var alpha [16]uint8
+	decodeBCChannel(block, &alpha)
+	for i := range texels {
+		texels[i][3] = alpha[i]
+	}
+}
+
+func decodeBC4(block []byte, texels *[16][4]uint8) {
+	var red [16]uint8
+	decodeBCChannel(block, &red)
+	for i := range texels {
+		texels[i] = [4]uint8{red[i], 0, 0, 255}
+	}
+}
+
+func decodeBC5(block []byte, texels *[16][4]uint8) {
+	var red, green [16]uint8
+	decodeBCChannel(block, &red)
+	decodeBCChannel(block[8:], &green)
+	for i := range texels {
+		texels[i] = [4]uint8{red[i], green[i], 0, 255}
+	}
+}
+
+// Decodes two RGB565 endpoints and a 2 bit index per texel. BC1 switches to three colors and black when the endpoints
+// are ordered c0 <= c1, while BC2 and BC3 always use four colors
+func decodeBCColors(block []byte, texels *[16][4]uint8, bc1, punchThrough bool) {
+	c0 := binary.LittleEndian.Uint16(block)
+	c1 := binary.LittleEndian.Uint16(block[2:])
+
+	var palette [4][4]uint8
+	palette[0] = expand565(c0)
+	palette[1] = expand565(c1)
+	if c0 > c1 || !bc1 {
+		palette[2] = lerpTexel(palette[0], palette[1], 1, 3)
+		palette[3] = lerpTexel(palette[0], palette[1], 2, 3)
+	} else {
+		palette[2] = lerpTexel(palette[0], palette[1], 1, 2)
+		palette[3] = [4]uint8{0, 0, 0, 255}
+		if punchThrough {
+			palette[3][3] = 0
+		}
+	}
+
+	indices := binary.LittleEndian.Uint32(block[4:])
+	for i := range texels {
+		texels[i] = palette[indices>>(2*i)&3]
+	}
+}
+
+// Decodes two 8 bit endpoints and a 3 bit index per texel, which interpolate 8 values or 6 values plus 0 and 255
+func decodeBCChannel(block []byte, values *[16]uint8) {
+	a0, a1 := int(block[0]), int(block[1])
+
+	var palette [8]uint8
+	palette[0], palette[1] = block[0], block[1]
+	if a0 > a1 {
+		for i := 1; i < 7; i++ {
+			palette[i+1] = uint8(((7-i)*a0 + i*a1 + 3) / 7)
+		}
+	} else {
+		for i := 1; i < 5; i++ {
+			palette[i+1] = uint8(((5-i)*a0 + i*a1 + 2) / 5)
+		}
+		palette[6] = 0
+		palette[7] = 255
+	}
+
+	indices := binary.LittleEndian.Uint64(block) >> 16
+	for i := range values {
+		values[i] = palette[indices>>(3*i)&7]
+	}
+}
+
+func expand565(c uint16) [4]uint8 {
+	r := uint8(c >> 11 & 0x1F)
+	g := uint8(c >> 5 & 0x3F)
+	b := uint8(c & 0x1F)
+	return [4]uint8{r<<3 | r>>2, g<<2 | g>>4, b<<3 | b>>2, 255}
+}
+
+// Returns a + (b - a) * num / den, rounded
+func lerpTexel(a, b [4]uint8, num, den int) [4]uint8 {
+	var ret [4]uint8
+	for i := range ret {
+		ret[i] = uint8((int(a[i])*(den-num) + int(b[i])*num + den/2) / den)
+	}
+	return ret
+}
+
+// --------------------------------------------------------------------------------
+
+var etc1Modifiers = [8][2]int{{2, 8}, {5, 17}, {9, 29}, {13, 42}, {18, 60}, {24, 80}, {33, 106}, {47, 183}}
+
+var etc2Distances = [8]int{3, 6, 11, 16, 23, 32, 41, 64}
+
+var eacModifiers = [16][8]int{
+	{-3, -6, -9, -15, 2, 5, 8, 14},
+	{-3, -7, -10, -13, 2, 6, 9, 12},
+	{-2, -5, -8, -13, 1, 4, 7, 12},
+	{-2, -4, -6, -13, 1, 3, 5, 12},
+	{-3, -6, -8, -12, 2, 5, 7, 11},
+	{-3, -7, -9, -11, 2, 6, 8, 10},
+	{-4, -7, -8, -11, 3, 6, 7, 10},
+	{-3, -5, -8, -11, 2, 4, 7, 10},
+	{-2, -6, -8, -10, 1, 5, 7, 9},
+	{-2, -5, -8, -10, 1, 4, 7, 9},
+	{-2, -4, -8, -10, 1, 3, 7, 9},
+	{-2, -5, -7, -10, 1, 4, 6, 9},
+	{-3, -4, -7, -10, 2, 3, 6, 9},
+	{-1, -2, -3, -10, 0, 1, 2, 9},
+	{-4, -6, -8, -9, 3, 5, 7, 8},
+	{-3, -5, -7, -9, 2, 4, 6, 8},
+}
+
+// ETC2 RGBA stores an EAC block of alpha before an ETC2 RGB block
+func decodeETC2EAC(block []byte, texels *[16][4]uint8) {
+	decodeETC2(block[8:], texels)
+
+	base := int(block[0])
+	multiplier := int(block[1] >> 4)
+	modifiers := eacModifiers[block[1]&0xF]
+	indices := binary.BigEndian.Uint64(block)
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 4; y++ {
+			idx := indices >> (45 - 3*(x*4+y)) & 7
+			texels[y*4+x][3] = clampByte(base + modifiers[idx]*multiplier)
+		}
+	}
+}
+
+// Decodes an ETC2 RGB block. The individual and differential modes are ETC1, and differential blocks whose second
+// color overflows are the T, H and planar modes that ETC2 added
+func decodeETC2(block []byte, texels *[16][4]uint8) {
+	if block[3]&2 == 0 {
+		// Individual mode: two 4 bit colors
+		base := [2][3]int{
+			{extend4(block[0] >> 4), extend4(block[1] >> 4), extend4(block[2] >> 4)},
+			{extend4(block[0] & 0xF), extend4(block[1] & 0xF), extend4(block[2] & 0xF)},
+		}
+		decodeETCSubblocks(block, base, texels)
+		return
+	}
+
+	r, g, b := int(block[0]>>3), int(block[1]>>3), int(block[2]>>3)
+	dr, dg, db := signed3(block[0]), signed3(block[1]), signed3(block[2])
+	switch {
+	case r+dr < 0 || r+dr > 31:
+		c0 := [3]int{extend4(block[0]>>1&0xC | block[0]&3), extend4(block[1] >> 4), extend4(block[1] & 0xF)}
+		c1 := [3]int{extend4(block[2] >> 4), extend4(block[2] & 0xF), extend4(block[3] >> 4)}
+		d := etc2Distances[block[3]>>1&6|block[3]&1]
+		decodeETCPaint(block, [4][3]int{c0, offsetColor(c1, d), c1, offsetColor(c1, -d)}, texels)
+	case g+dg < 0 || g+dg > 31:
+		c0 := [3]int{extend4(block[0] >> 3 & 0xF), extend4(block[0]&7<<1 | block[1]>>4&1), extend4(block[1]&8 | block[1]&3<<1 | block[2]>>7)}
+		c1 := [3]int{extend4(block[2] >> 3 & 0xF), extend4(block[2]&7<<1 | block[3]>>7), extend4(block[3] >> 3 & 0xF)}
+		di := int(block[3]&4 | block[3]&1<<1)
+		if c0[0]<<16|c0[1]<<8|c0[2] >= c1[0]<<16|c1[1]<<8|c1[2] {
+			di |= 1
+		}
+		d := etc2Distances[di]
+		decodeETCPaint(block, [4][3]int{offsetColor(c0, d), offsetColor(c0, -d), offsetColor(c1, d), offsetColor(c1, -d)}, texels)
+	case b+db < 0 || b+db > 31:
+		decodeETCPlanar(block, texels)
+	default:
+		// Differential mode: a 5 bit color and a 3 bit offset to the second color
+		base := [2][3]int{
+			{extend5(r), extend5(g), extend5(b)},
+			{extend5(r + dr), extend5(g + dg), extend5(b + db)},
+		}
+		decodeETCSubblocks(block, base, texels)
+	}
+}
+
+// Returns the 2 bit index of a texel
+func etcIndex(block []byte, x, y int) int {
+	indices := binary.BigEndian.Uint32(block[4:])
+	p := x*4 + y
+	return int(indices>>(p+15)&2 | indices>>p&1)
+}
+
+// Splits the block into two halves, which each have a base color and a table of offsets to add to it
+func decodeETCSubblocks(block []byte, base [2][3]int, texels *[16][4]uint8) {
+	tables := [2]int{int(block[3] >> 5 & 7), int(block[3] >> 2 & 7)}
+	flip := block[3]&1 == 1
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			sub := 0
+			if (!flip && x >= 2) || (flip && y >= 2) {
+				sub = 1
+			}
+			idx := etcIndex(block, x, y)
+			d := etc1Modifiers[tables[sub]][idx&1]
+			if idx&2 != 0 {
+				d = -d
+			}
+			c := offsetColor(base[sub], d)
+			texels[y*4+x] = [4]uint8{clampByte(c[0]), clampByte(c[1]), clampByte(c[2]), 255}
+		}
+	}
+}
+
+// The T and H modes pick each texel from four paint colors
+func decodeETCPaint(block []byte, paint [4][3]int, texels *[16][4]uint8) {
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			c := paint[etcIndex(block, x, y)]
+			texels[y*4+x] = [4]uint8{clampByte(c[0]), clampByte(c[1]), clampByte(c[2]), 255}
+		}
+	}
+}
+
+// The planar mode is a gradient between an origin color and the colors at its horizontal and vertical edges
+func decodeETCPlanar(block []byte, texels *[16][4]uint8) {
+	o := [3]int{
+		extendBits(int(block[0]>>1&0x3F), 6),
+		extendBits(int(block[0]&1)<<6|int(block[1]>>1&0x3F), 7),
+		extendBits(int(block[1]&1)<<5|int(block[2]&0x18)|int(block[2]&3)<<1|int(block[3]>>7), 6),
+	}
+	h := [3]int{
+		extendBits(int(block[3]>>1&0x3E)|int(block[3]&1), 6),
+		extendBits(int(block[4]>>1&0x7F), 7),
+		extendBits(int(block[4]&1)<<5|int(block[5]>>3&0x1F), 6),
+	}
+	v := [3]int{
+		extendBits(int(block[5]&7)<<3|int(block[6]>>5&7), 6),
+		extendBits(int(block[6]&0x1F)<<2|int(block[7]>>6&3), 7),
+		extendBits(int(block[7]&0x3F), 6),
+	}
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			var texel [4]uint8
+			for c := 0; c < 3; c++ {
+				texel[c] = clampByte((x*(h[c]-o[c]) + y*(v[c]-o[c]) + 4*o[c] + 2) >> 2)
+			}
+			texel[3] = 255
+			texels[y*4+x] = texel
+		}
+	}
+}
+
+func offsetColor(c [3]int, d int) [3]int {
+	return [3]int{c[0] + d, c[1] + d, c[2] + d}
+}
+
+func signed3(v uint8) int {
+	d := int(v & 7)
+	if d >= 4 {
+		d -= 8
+	}
+	return d
+}
+
+func extend4(v uint8) int {
+	return int(v<<4 | v)
+}
+
+func extend5(v int) int {
+	return v<<3 | v>>2
+}
+
+// Expands a value with fewer than 8 bits by repeating its top bits
+func extendBits(v, bits int) int {
+	return v<<(8-bits) | v>>(2*bits-8)
+}
+
+func clampByte(v int) uint8 {
+	return uint8(min(max(v, 0), 255))
+}
@@ -0,0 +1,445 @@
+package glitch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/unitoftime/glitch/internal/gl"
+	"github.com/unitoftime/glitch/internal/mainthread"
+)
+
+// The pixel format of a KTX2 or DDS file
+type fileFormat struct {
+	name       string
+	compressed gl.Enum // The GL format of a block compressed format, zero for uncompressed formats
+	blockBytes int     // The size of each 4x4 block
+	decode     func(block []byte, texels *[16][4]uint8)
+	format     TextureFormat // The format of uncompressed pixels, or the format that blocks are decompressed to
+}
+
+// sRGB formats are loaded as their linear versions, which is how glitch treats the pixels of every other image
+var (
+	formatBC1RGB  = &fileFormat{"BC1", gl.COMPRESSED_RGB_S3TC_DXT1_EXT, 8, decodeBC1RGB, TextureRGBA8}
+	formatBC1     = &fileFormat{"BC1", gl.COMPRESSED_RGBA_S3TC_DXT1_EXT, 8, decodeBC1, TextureRGBA8}
+	formatBC2     = &fileFormat{"BC2", gl.COMPRESSED_RGBA_S3TC_DXT3_EXT, 16, decodeBC2, TextureRGBA8}
+	formatBC3     = &fileFormat{"BC3", gl.COMPRESSED_RGBA_S3TC_DXT5_EXT, 16, decodeBC3, TextureRGBA8}
+	formatBC4     = &fileFormat{"BC4", gl.COMPRESSED_RED_RGTC1, 8, decodeBC4, TextureR8}
+	formatBC5     = &fileFormat{"BC5", gl.COMPRESSED_RG_RGTC2, 16, decodeBC5, TextureRG8}
+	formatBC7     = &fileFormat{"BC7", gl.COMPRESSED_RGBA_BPTC_UNORM, 16, nil, TextureRGBA8}
+	formatETC2    = &fileFormat{"ETC2", gl.COMPRESSED_RGB8_ETC2, 8, decodeETC2, TextureRGBA8}
+	formatETC2EAC = &fileFormat{"ETC2 EAC", gl.COMPRESSED_RGBA8_ETC2_EAC, 16, decodeETC2EAC, TextureRGBA8}
+)
+
+var rawFileFormats = [...]fileFormat{
+	TextureRGBA8:   {name: "RGBA8", format: TextureRGBA8},
+	TextureR8:      {name: "R8", format: TextureR8},
+	TextureRG8:     {name: "RG8", format: TextureRG8},
+	TextureR16F:    {name: "R16F", format: TextureR16F},
+	TextureR32F:    {name: "R32F", format: TextureR32F},
+	TextureRGBA16F: {name: "RGBA16F", format: TextureRGBA16F},
+	TextureRGBA32F: {name: "RGBA32F", format: TextureRGBA32F},
+}
+
+// Reports whether the blocks store colors with straight alpha. Glitch expects premultiplied alpha, so these are
+// premultiplied when they are decompressed, and blended with BlendModeNonPremultiplied when they are sampled natively.
+// BC1's punch through alpha is always black when transparent, so it is already premultiplied
+func (f *fileFormat) straightAlpha() bool {
+	return f == formatBC2 || f == formatBC3 || f == formatBC7 || f == formatETC2EAC
+}
+
+// Returns the size of a mip level, which is tightly packed in both containers
+func (f *fileFormat) levelSize(width, height int) int {
+	if f.compressed == 0 {
+		rf := textureFormats[f.format]
+		return width * height * texturePixelBytes(rf.format, rf.ty)
+	}
+	return ((width + 3) / 4) * ((height + 3) / 4) * f.blockBytes
+}
+
+// Returns the memory used by the first level of a compressed texture
+func compressedSize(internalFormat gl.Enum, width, height int) int {
+	blockBytes := 16
+	switch internalFormat {
+	case gl.COMPRESSED_RGB_S3TC_DXT1_EXT, gl.COMPRESSED_RGBA_S3TC_DXT1_EXT, gl.COMPRESSED_RED_RGTC1, gl.COMPRESSED_RGB8_ETC2:
+		blockBytes = 8
+	}
+	return ((width + 3) / 4) * ((height + 3) / 4) * blockBytes
+}
+
+// A parsed KTX2 or DDS file
+type textureFile struct {
+	width, height int
+	format        *fileFormat
+	levels        [][]byte // Mip levels, largest first
+	pixels        []uint8  // Set by prepare if the file is uploaded as uncompressed pixels
+}
+
+// Decompresses the file if the GPU can't sample its format. Unlike creating the texture, this can be called from any goroutine
+func (f *textureFile) prepare() error {
+	format := f.format
+	if format.compressed == 0 {
+		// Mipmaps are generated from the first level, like any other texture
+		tf := textureFormats[format.format]
+		f.pixels = padRows(f.levels[0], f.width*texturePixelBytes(tf.format, tf.ty), f.height)
+		return nil
+	}
+
+	var supported bool
+	mainthread.Call(func() {
+		supported = gl.SupportsCompressedFormat(format.compressed)
+	})
+	if supported {
+		return nil
+	}
+	if format.decode == nil {
+		return fmt.Errorf("glitch: %s textures aren't supported by this GPU and can't be decompressed", format.name)
+	}
+	f.pixels = f.decompress()
+	return nil
+}
+
+func (f *textureFile) texture(cfg TextureConfig) *Texture {
+	t := &Texture{
+		width:  f.width,
+		height: f.height,
+		config: cfg,
+	}
+	if f.pixels == nil {
+		t.straightAlpha = f.format.straightAlpha()
+		t.initializeCompressed(f.format.compressed, f.levels)
+		return t
+	}
+	tf := textureFormats[f.format.format]
+	t.initializeFormat(tf.internal, tf.format, tf.ty, f.pixels)
+	return t
+}
+
+// Creates a texture from the bytes of a KTX2 or DDS file. Block compressed formats (BC1-BC5, BC7 and ETC2) are
+// uploaded as they are if the GPU supports them, which keeps them small in video memory. Otherwise they are
+// decompressed, except for BC7 which returns an error. Only plain 2D textures are supported, and KTX2 files can't be
+// supercompressed (ie Basis Universal). The mip levels in the file are used if the config turns on mipmaps.
+//
+// Compressed files store straight alpha. Decompressed pixels are premultiplied like every other texture, but blocks
+// that are uploaded as they are can't be, so DefaultMaterial (and so NewSprite) draws those textures with
+// BlendModeNonPremultiplied. Custom materials should do the same, see Texture.StraightAlpha
+func NewCompressedTexture(data []byte, cfg TextureConfig) (*Texture, error) {
+	file, err := parseTextureFile(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.prepare(); err != nil {
+		return nil, err
+	}
+	return file.texture(cfg), nil
+}
+
+// Loads a KTX2 or DDS file, see NewCompressedTexture
+func LoadCompressedTexture(path string, cfg TextureConfig) (*Texture, error) {
+	return LoadCompressedTextureFS(os.DirFS(filepath.Dir(path)), filepath.Base(path), cfg)
+}
+
+// Like LoadCompressedTexture, but reads the file from fsys
+func LoadCompressedTextureFS(fsys fs.FS, path string, cfg TextureConfig) (*Texture, error) {
+	data, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	tex, err := NewCompressedTexture(data, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("glitch: loading %s: %w", path, err)
+	}
+	return tex, nil
+}
+
+// Creates the texture from block compressed mip levels. Compressed textures can't generate mipmaps, so they keep
+// whichever levels they were given
+func (t *Texture) initializeCompressed(internalFormat gl.Enum, levels [][]byte) {
+	global.flush()
+	t.internalFormat = internalFormat
+	t.levels = len(levels)
+	t.config.Mipmaps = t.config.Mipmaps && len(levels) > 1
+	t.mipmapped = len(levels) > 1
+	t.updateMemory()
+	mainthread.Call(func() {
+		t.texture = gl.CreateTexture()
+		gl.BindTexture(gl.TEXTURE_2D, t.texture)
+
+		for i, level := range levels {
+			gl.CompressedTexImage2D(gl.TEXTURE_2D, i, internalFormat, max(t.width>>i, 1), max(t.height>>i, 1), 0, level)
+		}
+		// Files don't always go down to 1x1, and the texture is incomplete if it expects levels that it doesn't have
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAX_LEVEL, len(levels)-1)
+		t.mainthreadApplyConfig()
+	})
+	state.invalidateTexture()
+
+	runtime.SetFinalizer(t, (*Texture).finalize)
+	t.trackID = trackResource("Texture")
+}
+
+// Decompresses the first level into the pixels of the format's fallback format, premultiplying straight alpha
+func (f *textureFile) decompress() []uint8 {
+	channels := f.format.format.Channels()
+	premultiply := f.format.straightAlpha()
+	stride := alignRow(f.width * channels)
+	pixels := make([]uint8, stride*f.height)
+
+	blocksX := (f.width + 3) / 4
+	blocksY := (f.height + 3) / 4
+	var texels [16][4]uint8
+	for by := 0; by < blocksY; by++ {
+		for bx := 0; bx < blocksX; bx++ {
+			start := (by*blocksX + bx) * f.format.blockBytes
+			f.format.decode(f.levels[0][start:start+f.format.blockBytes], &texels)
+			if premultiply {
+				for i := range texels {
+					a := uint16(texels[i][3])
+					for c := 0; c < 3; c++ {
+						texels[i][c] = uint8((uint16(texels[i][c])*a + 127) / 255)
+					}
+				}
+			}
+			for i, texel := range texels {
+				x, y := bx*4+i%4, by*4+i/4
+				if x >= f.width || y >= f.height {
+					continue // Blocks on the edge are padded out to 4x4
+				}
+				copy(pixels[y*stride+x*channels:], texel[:channels])
+			}
+		}
+	}
+	return pixels
+}
+
+var (
+	errTruncatedTexture = errors.New("glitch: texture file is truncated")
+	errNotTextureFile   = errors.New("glitch: texture file isn't a KTX2 or DDS file")
+)
+
+func parseTextureFile(data []byte) (*textureFile, error) {
+	switch {
+	case bytes.HasPrefix(data, ktx2Identifier):
+		return parseKTX2(data)
+	case bytes.HasPrefix(data, []byte("DDS ")):
+		return parseDDS(data)
+	}
+	return nil, errNotTextureFile
+}
+
+// Reads the mip levels that follow each other from offset, largest first. Levels past 1x1 are dropped
+func (f *textureFile) readLevels(data []byte, offset, count int) error {
+	count = min(max(count, 1), bits.Len(uint(max(f.width, f.height))))
+	for i := 0; i < count; i++ {
+		size := f.format.levelSize(max(f.width>>i, 1), max(f.height>>i, 1))
+		if offset+size > len(data) {
+			return errTruncatedTexture
+		}
+		f.levels = append(f.levels, data[offset:offset+size])
+		offset += size
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------------
+// KTX2: https://registry.khronos.org/KTX/specs/2.0/ktxspec.v2.html
+
+var ktx2Identifier = []byte{0xAB, 'K', 'T', 'X', ' ', '2', '0', 0xBB, '\r', '\n', 0x1A, '\n'}
+
+// The Vulkan formats that KTX2 files describe their pixels with
+var ktx2Formats = map[uint32]*fileFormat{
+	9:   &rawFileFormats[TextureR8],
+	16:  &rawFileFormats[TextureRG8],
+	37:  &rawFileFormats[TextureRGBA8],
+	43:  &rawFileFormats[TextureRGBA8],
+	76:  &rawFileFormats[TextureR16F],
+	97:  &rawFileFormats[TextureRGBA16F],
+	100: &rawFileFormats[TextureR32F],
+	109: &rawFileFormats[TextureRGBA32F],
+	131: formatBC1RGB,
+	132: formatBC1RGB,
+	133: formatBC1,
+	134: formatBC1,
+	135: formatBC2,
+	136: formatBC2,
+	137: formatBC3,
+	138: formatBC3,
+	139: formatBC4,
+	141: formatBC5,
+	145: formatBC7,
+	146: formatBC7,
+	147: formatETC2,
+	148: formatETC2,
+	151: formatETC2EAC,
+	152: formatETC2EAC,
+}
+
+func parseKTX2(data []byte) (*textureFile, error) {
+	const levelIndex = 80
+	if len(data) < levelIndex {
+		return nil, errTruncatedTexture
+	}
+	le := binary.LittleEndian
+	vkFormat := le.Uint32(data[12:])
+	width := int(le.Uint32(data[20:]))
+	height := int(le.Uint32(data[24:]))
+	depth := le.Uint32(data[28:])
+	layers := le.Uint32(data[32:])
+	faces := le.Uint32(data[36:])
+	levelCount := max(int(le.Uint32(data[40:])), 1)
+	supercompression := le.Uint32(data[44:])
+
+	if width == 0 || height == 0 || depth != 0 || layers != 0 || faces != 1 {
+		return nil, errors.New("glitch: only 2D KTX2 textures are supported")
+	}
+	if supercompression != 0 {
+		return nil, errors.New("glitch: supercompressed KTX2 textures aren't supported")
+	}
+	format, ok := ktx2Formats[vkFormat]
+	if !ok {
+		return nil, fmt.Errorf("glitch: KTX2 format %d isn't supported", vkFormat)
+	}
+
+	file := &textureFile{width: width, height: height, format: format}
+	levelCount = min(levelCount, bits.Len(uint(max(width, height))))
+	if len(data) < levelIndex+levelCount*24 {
+		return nil, errTruncatedTexture
+	}
+	// Each level has its own offset, and the smallest level is usually stored first
+	for i := 0; i < levelCount; i++ {
+		entry := data[levelIndex+i*24:]
+		offset, length := le.Uint64(entry), le.Uint64(entry[8:])
+		size := format.levelSize(max(width>>i, 1), max(height>>i, 1))
+		if length != uint64(size) {
+			return nil, fmt.Errorf("glitch: KTX2 level %d is %d bytes, expected %d", i, length, size)
+		}
+		if offset > uint64(len(data)) || length > uint64(len(data))-offset {
+			return nil, errTruncatedTexture
+		}
+		file.levels = append(file.levels, data[offset:offset+length])
+	}
+	return file, nil
+}
+
+// --------------------------------------------------------------------------------
+// DDS: https://learn.microsoft.com/en-us/windows/win32/direct3ddds/dx-graphics-dds-pguide
+
+var ddsFourCCs = map[string]*fileFormat{
+	"DXT1": formatBC1,
+	"DXT2": formatBC2,
+	"DXT3": formatBC2,
+	"DXT4": formatBC3,
+	"DXT5": formatBC3,
+	"ATI1": formatBC4,
+	"BC4U": formatBC4,
+	"ATI2": formatBC5,
+	"BC5U": formatBC5,
+
+	// Float formats are stored as their D3DFORMAT number rather than as characters
+	"\x6f\x00\x00\x00": &rawFileFormats[TextureR16F],
+	"\x71\x00\x00\x00": &rawFileFormats[TextureRGBA16F],
+	"\x72\x00\x00\x00": &rawFileFormats[TextureR32F],
+	"\x74\x00\x00\x00": &rawFileFormats[TextureRGBA32F],
+}
+
+// The formats of DDS files with the DX10 header
+var dxgiFormats = map[uint32]*fileFormat{
+	2:  &rawFileFormats[TextureRGBA32F],
+	10: &rawFileFormats[TextureRGBA16F],
+	28: &rawFileFormats[TextureRGBA8],
+	29: &rawFileFormats[TextureRGBA8],
+	41: &rawFileFormats[TextureR32F],
+	49: &rawFileFormats[TextureRG8],
+	54: &rawFileFormats[TextureR16F],
+	61: &rawFileFormats[TextureR8],
+	71: formatBC1,
+	72: formatBC1,
+	74: formatBC2,
+	75: formatBC2,
+	77: formatBC3,
+	78: formatBC3,
+	80: formatBC4,
+	83: formatBC5,
+	98: formatBC7,
+	99: formatBC7,
+}
+
+const (
+	ddsHeaderSize     = 128 // Including the magic number
+	ddsDX10HeaderSize = 20
+
+	ddsdMipmapCount = 0x20000
+	ddpfAlphaPixels = 0x1
+	ddpfFourCC      = 0x4
+	ddpfRGB         = 0x40
+	ddpfLuminance   = 0x20000
+	ddsCaps2Cubemap = 0x200
+	ddsCaps2Volume  = 0x200000
+)
+
+func parseDDS(data []byte) (*textureFile, error) {
+	if len(data) < ddsHeaderSize {
+		return nil, errTruncatedTexture
+	}
+	le := binary.LittleEndian
+	if le.Uint32(data[4:]) != 124 {
+		return nil, errors.New("glitch: DDS header is invalid")
+	}
+	flags := le.Uint32(data[8:])
+	height := int(le.Uint32(data[12:]))
+	width := int(le.Uint32(data[16:]))
+	mipCount := 1
+	if flags&ddsdMipmapCount != 0 {
+		mipCount = int(le.Uint32(data[28:]))
+	}
+	pfFlags := le.Uint32(data[80:])
+	fourCC := string(data[84:88])
+	rgbBits := le.Uint32(data[88:])
+	masks := [4]uint32{le.Uint32(data[92:]), le.Uint32(data[96:]), le.Uint32(data[100:]), le.Uint32(data[104:])}
+	caps2 := le.Uint32(data[112:])
+
+	if width == 0 || height == 0 || caps2&(ddsCaps2Cubemap|ddsCaps2Volume) != 0 {
+		return nil, errors.New("glitch: only 2D DDS textures are supported")
+	}
+
+	offset := ddsHeaderSize
+	var format *fileFormat
+	switch {
+	case pfFlags&ddpfFourCC != 0 && fourCC == "DX10":
+		if len(data) < ddsHeaderSize+ddsDX10HeaderSize {
+			return nil, errTruncatedTexture
+		}
+		dxgi := le.Uint32(data[ddsHeaderSize:])
+		if le.Uint32(data[ddsHeaderSize+12:]) > 1 {
+			return nil, errors.New("glitch: DDS texture arrays aren't supported")
+		}
+		var ok bool
+		if format, ok = dxgiFormats[dxgi]; !ok {
+			return nil, fmt.Errorf("glitch: DDS format %d isn't supported", dxgi)
+		}
+		offset += ddsDX10HeaderSize
+	case pfFlags&ddpfFourCC != 0:
+		var ok bool
+		if format, ok = ddsFourCCs[fourCC]; !ok {
+			return nil, fmt.Errorf("glitch: DDS format %q isn't supported", fourCC)
+		}
+	case pfFlags&ddpfRGB != 0 && pfFlags&ddpfAlphaPixels != 0 && rgbBits == 32 && masks == [4]uint32{0xFF, 0xFF00, 0xFF0000, 0xFF000000}:
+		format = &rawFileFormats[TextureRGBA8]
+	case pfFlags&(ddpfRGB|ddpfLuminance) != 0 && rgbBits == 8 && masks[0] == 0xFF:
+		format = &rawFileFormats[TextureR8]
+	default:
+		return nil, errors.New("glitch: DDS pixel format isn't supported")
+	}
+
+	file := &textureFile{width: width, height: height, format: format}
+	if err := file.readLevels(data, offset, mipCount); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
@@ -0,0 +1,122 @@
+//go:build headless
+// +build headless
+
+package glitch
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/unitoftime/flow/glm"
+	"github.com/unitoftime/glitch/internal/gl"
+)
+
+func TestHeadlessCompressedTexture(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	// Draws the texture over the whole window and returns the color of the texel at x, y (top down)
+	mesh := NewQuadMesh(win.Bounds(), glm.R(0, 0, 1, 1))
+	draw := func(tex *Texture) *image.RGBA {
+		Clear(win, RGBA{})
+		win.Add(mesh, glMat4Ident, White, DefaultMaterial(tex), false)
+		return win.Image()
+	}
+	texel := func(img *image.RGBA, tex *Texture, x, y int) color.RGBA {
+		sx, sy := 16/tex.width, 16/tex.height
+		return img.RGBAAt(x*sx+sx/2, y*sy+sy/2)
+	}
+
+	// A BC1 block whose rows use each of the four palette colors, between red and blue
+	dds := make([]byte, 128, 136)
+	copy(dds, "DDS ")
+	binary.LittleEndian.PutUint32(dds[4:], 124)
+	binary.LittleEndian.PutUint32(dds[8:], 0x1007)
+	binary.LittleEndian.PutUint32(dds[12:], 4)
+	binary.LittleEndian.PutUint32(dds[16:], 4)
+	binary.LittleEndian.PutUint32(dds[76:], 32)
+	binary.LittleEndian.PutUint32(dds[80:], 0x4)
+	copy(dds[84:], "DXT1")
+	dds = append(dds, 0x00, 0xF8, 0x1F, 0x00, 0x00, 0x55, 0xAA, 0xFF)
+
+	// An ETC2 block in individual mode, with a red left half and a blue right half
+	ktx := make([]byte, 104)
+	copy(ktx, ktx2Identifier)
+	for i, v := range []uint32{147, 1, 4, 4, 0, 0, 1, 1, 0} {
+		binary.LittleEndian.PutUint32(ktx[12+i*4:], v)
+	}
+	binary.LittleEndian.PutUint64(ktx[80:], 104)
+	binary.LittleEndian.PutUint64(ktx[88:], 8)
+	ktx = append(ktx, 0xF0, 0x00, 0x0F, 0x00, 0, 0, 0, 0)
+
+	// A white BC2 block with every alpha at 0x88, which is straight alpha and is premultiplied when decompressed
+	bc2 := append([]byte(nil), dds[:128]...)
+	copy(bc2[84:], "DXT3")
+	bc2 = append(bc2, 0x88, 0x88, 0x88, 0x88, 0x88, 0x88, 0x88, 0x88, 0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0)
+
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	rows := []color.RGBA{red, blue, {170, 0, 85, 255}, {85, 0, 170, 255}}
+	tests := []struct {
+		name string
+		data []byte
+		want func(x, y int) color.RGBA
+	}{
+		{"dds", dds, func(x, y int) color.RGBA { return rows[y] }},
+		{"ktx2", ktx, func(x, y int) color.RGBA {
+			if x < 2 {
+				return color.RGBA{255, 2, 2, 255}
+			}
+			return color.RGBA{2, 2, 255, 255}
+		}},
+	}
+	for _, test := range tests {
+		tex, err := NewCompressedTexture(test.data, TextureConfig{})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		// The headless context can't sample compressed formats, so the block is decompressed
+		if tex.levels != 0 || tex.internalFormat != gl.RGBA {
+			t.Errorf("%s: expected the fallback decompressor to be used", test.name)
+		}
+		img := draw(tex)
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 4; x++ {
+				if got, want := texel(img, tex, x, y), test.want(x, y); got != want {
+					t.Errorf("%s %d,%d: expected %v, got %v", test.name, x, y, want, got)
+				}
+			}
+		}
+		tex.Destroy()
+	}
+
+	// Window.Image converts to straight alpha, so the framebuffer is read directly
+	tex, err := NewCompressedTexture(bc2, TextureConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Clear(win, RGBA{})
+	win.Add(mesh, glMat4Ident, White, DefaultMaterial(tex), false)
+	win.Update()
+	if got := readWindowPixel(win, 8, 8); got != (color.RGBA{136, 136, 136, 136}) {
+		t.Errorf("bc2: expected the decompressed texels to be premultiplied, got %v", got)
+	}
+	tex.Destroy()
+
+	// Blocks that the GPU samples directly keep their straight alpha, so they are blended as such
+	if m := DefaultMaterial(&Texture{straightAlpha: true}); m.blend != BlendModeNonPremultiplied {
+		t.Errorf("expected a straight alpha texture to be drawn with BlendModeNonPremultiplied, got %v", m.blend)
+	}
+
+	// BC7 has no fallback decompressor
+	bc7 := append([]byte(nil), dds[:128]...)
+	copy(bc7[84:], "DX10")
+	bc7 = binary.LittleEndian.AppendUint32(bc7, 98)
+	bc7 = append(bc7, make([]byte, 16+16)...)
+	if _, err := NewCompressedTexture(bc7, TextureConfig{}); err == nil {
+		t.Error("expected BC7 to fail without GPU support")
+	}
+	if _, err := NewCompressedTexture(dds[:100], TextureConfig{}); err == nil {
+		t.Error("expected a truncated file to fail")
+	}
+}
@@ -0,0 +1,121 @@
+package glitch
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+// The volume that draws are culled against. It is updated whenever the camera is set
+type cullVolume struct {
+	ortho   bool // If set, only the xy rectangle is tested, so that depth and layers never cull anything
+	rect    Rect
+	frustum Frustum
+}
+
+// Returns true if the box is at least partially inside of the volume
+func (c *cullVolume) visible(box Box) bool {
+	if c.ortho {
+		return box.Max.X >= c.rect.Min.X && box.Min.X <= c.rect.Max.X &&
+			box.Max.Y >= c.rect.Min.Y && box.Min.Y <= c.rect.Max.Y
+	}
+	return c.frustum.IntersectsBox(box)
+}
+
+// Returns true if the filler, drawn with the matrix, is entirely outside of the current camera. Culled draws are counted in the metrics
+func cullDraw(filler GeometryFiller, mat glMat4) bool {
+	return cullBox(filler.Bounds(), mat.Mat4(), 1)
+}
+
+// Returns true if the box, transformed by the matrix, is entirely outside of the current camera. If so, count is added to the culled metric
+func cullBox(bounds Box, mat Mat4, count int) bool {
+	if bounds == (Box{}) {
+		return false // The bounds are unknown, so always draw it
+	}
+
+	if global.cull.visible(transformBox(bounds, mat)) {
+		return false
+	}
+	global.metric.Culled += count
+	return true
+}
+
+// Returns the axis aligned box that contains all eight corners of the transformed box
+func transformBox(box Box, mat Mat4) Box {
+	ret := Box{
+		Min: Vec3{math.Inf(1), math.Inf(1), math.Inf(1)},
+		Max: Vec3{math.Inf(-1), math.Inf(-1), math.Inf(-1)},
+	}
+	for i := 0; i < 8; i++ {
+		corner := box.Min
+		if i&1 != 0 {
+			corner.X = box.Max.X
+		}
+		if i&2 != 0 {
+			corner.Y = box.Max.Y
+		}
+		if i&4 != 0 {
+			corner.Z = box.Max.Z
+		}
+		p := mat.Apply(corner)
+		ret.Min = Vec3{math.Min(ret.Min.X, p.X), math.Min(ret.Min.Y, p.Y), math.Min(ret.Min.Z, p.Z)}
+		ret.Max = Vec3{math.Max(ret.Max.X, p.X), math.Max(ret.Max.Y, p.Y), math.Max(ret.Max.Z, p.Z)}
+	}
+	return ret
+}
+
+// The six planes (left, right, bottom, top, near, far) that bound what a camera can see. Each plane is stored as
+// (a, b, c, d) where points with a*x + b*y + c*z + d >= 0 are on the inside
+type Frustum [6]Vec4
+
+// Extracts the frustum planes from a camera's projection and view matrices
+func NewFrustum(projection, view Mat4) Frustum {
+	m := mgl64.Mat4(projection).Mul4(mgl64.Mat4(view))
+	plane := func(r int, sign float64) Vec4 {
+		a, b := m.Row(3), m.Row(r)
+		return Vec4{a[0] + sign*b[0], a[1] + sign*b[1], a[2] + sign*b[2], a[3] + sign*b[3]}
+	}
+
+	return Frustum{
+		plane(0, 1), plane(0, -1), // Left, Right
+		plane(1, 1), plane(1, -1), // Bottom, Top
+		plane(2, 1), plane(2, -1), // Near, Far
+	}
+}
+
+// Returns true if the box is at least partially inside of the frustum. This is conservative: Some boxes near the corners
+// of the frustum are reported as inside even though they are outside
+func (f Frustum) IntersectsBox(box Box) bool {
+	for _, plane := range f {
+		// Test the corner of the box that is furthest along the plane's normal
+		p := box.Min
+		if plane.X >= 0 {
+			p.X = box.Max.X
+		}
+		if plane.Y >= 0 {
+			p.Y = box.Max.Y
+		}
+		if plane.Z >= 0 {
+			p.Z = box.Max.Z
+		}
+		if plane.X*p.X+plane.Y*p.Y+plane.Z*p.Z+plane.W < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Camera) Frustum() Frustum {
+	return NewFrustum(c.Projection, c.View)
+}
+
+// Returns the rectangle, in world space, that the camera can see
+func (c *CameraOrtho) ViewRect() Rect {
+	bounds := c.Bounds()
+	p0 := c.Unproject(Vec3{bounds.Min.X, bounds.Min.Y, 0})
+	p1 := c.Unproject(Vec3{bounds.Max.X, bounds.Max.Y, 0})
+	return Rect{
+		Min: Vec2{math.Min(p0.X, p1.X), math.Min(p0.Y, p1.Y)},
+		Max: Vec2{math.Max(p0.X, p1.X), math.Max(p0.Y, p1.Y)},
+	}
+}
@@ -0,0 +1,89 @@
+//go:build headless
+// +build headless
+
+package glitch
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+func TestHeadlessCulling(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	white := NewRGBATexture(4, 4, color.RGBA{255, 255, 255, 255}, false)
+	sprite := NewSprite(white, white.Bounds())
+	positions := []Vec2{{8, 8}, {-10, 8}, {8, 40}, {17, 8}} // The last one overlaps the right edge
+
+	sorter := NewSorter()
+	sorter.Cull = true
+	batch := NewDrawBatch()
+	batch.Cull = true
+	for _, pos := range positions {
+		mat := Mat4Ident
+		mat.Translate(pos.X, pos.Y, 0)
+		sprite.Draw(sorter, mat)
+		sprite.Draw(batch, mat)
+	}
+	if metrics := GetMetrics(); metrics.Culled != 2 {
+		t.Errorf("expected the sorter to cull 2 draws, got %d", metrics.Culled)
+	}
+
+	batch.Draw(sorter, Mat4Ident)
+	if metrics := GetMetrics(); metrics.Culled != 2 {
+		t.Errorf("expected the batch to cull 2 draws, got %d", metrics.Culled)
+	}
+
+	// Moving the whole batch off screen culls it at once
+	offscreen := Mat4Ident
+	offscreen.Translate(100, 100, 0)
+	batch.Draw(sorter, offscreen)
+	if metrics := GetMetrics(); metrics.Culled != len(positions) {
+		t.Errorf("expected the whole batch to be culled, got %d", metrics.Culled)
+	}
+
+	Clear(win, RGBA{})
+	sorter.Draw(win)
+	win.Update()
+	if got := win.Image().RGBAAt(8, 16-1-8); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("expected the visible sprite to draw, got %v", got)
+	}
+
+	// Perspective cameras cull against their frustum
+	pCam := NewCamera()
+	pCam.Position = Vec3{0, -10, 0}
+	pCam.Projection = Mat4(mgl64.Perspective(math.Pi/4, 1, 0.1, 100))
+	pCam.SetViewLookAt(win)
+	frustum := pCam.Frustum()
+	box := Box{Min: Vec3{-1, -1, -1}, Max: Vec3{1, 1, 1}}
+	if !frustum.IntersectsBox(box) {
+		t.Errorf("expected a box in front of the camera to be inside the frustum")
+	}
+	behind := Box{Min: Vec3{-1, -21, -1}, Max: Vec3{1, -19, 1}}
+	if frustum.IntersectsBox(behind) {
+		t.Errorf("expected a box behind the camera to be outside the frustum")
+	}
+	far := Box{Min: Vec3{-1, 199, -1}, Max: Vec3{1, 201, 1}}
+	if frustum.IntersectsBox(far) {
+		t.Errorf("expected a box past the far plane to be outside the frustum")
+	}
+}
+
+func TestHeadlessPostProcessKeepsCulling(t *testing.T) {
+	win, camera := newTestWindow(t, 16, 16, WindowConfig{})
+	camera.SetView2D(4, 0, 1, 1) // Differ from the post process camera, so that it is switched to and back
+	SetCamera(camera)
+
+	post := NewPostProcess(win)
+	defer post.Destroy()
+
+	before := global.cull
+	post.Draw(win)
+	if global.cull != before {
+		t.Errorf("expected the post process to restore the camera's cull rectangle, got %+v", global.cull)
+	}
+	win.Update()
+}
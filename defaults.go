@@ -54,7 +54,10 @@ func GetDefaultSpriteShader() *Shader {
 
 func DefaultMaterial(texture *Texture) Material {
 	material := NewMaterial(GetDefaultSpriteShader())
-	material.texture = texture
+	material.textures[0] = texture
+	if texture != nil && texture.straightAlpha {
+		material.blend = BlendModeNonPremultiplied
+	}
 	return material
 }
 
@@ -80,7 +83,7 @@ func GetDefaultMsdfShader() *Shader {
 
 func DefaultMsdfMaterial(texture *Texture) Material {
 	material := NewMaterial(GetDefaultMsdfShader())
-	material.texture = texture
+	material.textures[0] = texture
 	material.
 		SetUniform("u_threshold", 0.5).
 		SetUniform("u_outline_width_relative", 0.1).
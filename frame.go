@@ -1,7 +1,11 @@
 package glitch
 
 import (
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"image"
+	"math"
 	"runtime"
 
 	"github.com/unitoftime/flow/glm"
@@ -12,67 +16,381 @@ import (
 type Frame struct {
 	fbo gl.Framebuffer
 	// *Batcher
-	tex      *Texture
-	depth    gl.Texture
-	mesh     *Mesh
-	material Material
-	bounds   Rect
+	tex         *Texture   // The first color texture
+	textures    []*Texture // Every color texture, in attachment order
+	formats     []FrameFormat
+	depth       *Texture
+	depthFormat FrameDepth
+	mesh        *Mesh
+	material    Material
+	bounds      Rect
+
+	// Multisampling: Draws go to msaaFbo and are resolved into the textures before they are read
+	samples       int
+	msaaFbo       gl.Framebuffer
+	renderbuffers []gl.Renderbuffer
+	dirty         bool // True if the multisampled buffers have been drawn to since the last resolve
+
+	renderbufferBytes int  // The memory counted for the renderbuffers in the metrics
+	destroyed         bool // Set once the framebuffers have been deleted
+	trackID           uint64
+}
+
+// The format of a frame's color texture
+type FrameFormat uint8
+
+const (
+	FrameFormatRGBA8   FrameFormat = iota // 8 bits per channel
+	FrameFormatRGBA16F                    // A half float per channel, for values outside of [0, 1] (ie HDR)
+	FrameFormatR8                         // A single 8 bit red channel
+)
+
+type frameFormat struct {
+	internal, format, ty gl.Enum
+}
+
+var frameFormats = [...]frameFormat{
+	FrameFormatRGBA8:   {gl.RGBA8, gl.RGBA, gl.UNSIGNED_BYTE},
+	FrameFormatRGBA16F: {gl.RGBA16F, gl.RGBA, gl.HALF_FLOAT},
+	FrameFormatR8:      {gl.R8, gl.RED, gl.UNSIGNED_BYTE},
+}
+
+// The depth buffer of a frame
+type FrameDepth uint8
+
+const (
+	FrameDepthNone    FrameDepth = iota
+	FrameDepth24                 // A 24 bit depth buffer
+	FrameDepthStencil            // A 24 bit depth buffer with an 8 bit stencil buffer packed alongside it
+)
+
+var frameDepths = [...]frameFormat{
+	FrameDepth24:      {gl.DEPTH_COMPONENT24, gl.DEPTH_COMPONENT, gl.UNSIGNED_INT},
+	FrameDepthStencil: {gl.DEPTH24_STENCIL8, gl.DEPTH_STENCIL, gl.UNSIGNED_INT_24_8},
+}
+
+func (d FrameDepth) attachment() gl.Enum {
+	if d == FrameDepthStencil {
+		return gl.DEPTH_STENCIL_ATTACHMENT
+	}
+	return gl.DEPTH_ATTACHMENT
+}
+
+type FrameConfig struct {
+	Smooth bool          // Sample the color textures linearly rather than nearest
+	Color  []FrameFormat // A color texture is created for each entry. Multiple entries are drawn to with multiple render targets. Leave empty for a depth only frame
+	Depth  FrameDepth
+
+	// If greater than zero, the frame is drawn into multisampled renderbuffers. They are resolved
+	// into the frame's textures whenever the frame is read from, or manually with Resolve
+	Samples int
 }
 
 // Type? Color, depth, stencil?
 func NewFrame(bounds Rect, smooth bool) *Frame {
+	return NewFrameExt(bounds, smooth, false)
+}
+
+// Creates a frame, optionally with a stencil buffer packed alongside the depth buffer
+func NewFrameExt(bounds Rect, smooth, stencil bool) *Frame {
+	depth := FrameDepth24
+	if stencil {
+		depth = FrameDepthStencil
+	}
+	frame, err := NewFrameWithConfig(bounds, FrameConfig{
+		Smooth: smooth,
+		Color:  []FrameFormat{FrameFormatRGBA8},
+		Depth:  depth,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return frame
+}
+
+// Creates a frame with the attachments described by cfg. An error is returned if the GPU doesn't support the combination
+func NewFrameWithConfig(bounds Rect, cfg FrameConfig) (*Frame, error) {
+	if len(cfg.Color) == 0 && cfg.Depth == FrameDepthNone {
+		return nil, errors.New("glitch: frame must have a color or depth attachment")
+	}
+	for _, format := range cfg.Color {
+		if int(format) >= len(frameFormats) {
+			return nil, fmt.Errorf("glitch: invalid frame format %d", format)
+		}
+	}
+	if int(cfg.Depth) >= len(frameDepths) {
+		return nil, fmt.Errorf("glitch: invalid frame depth %d", cfg.Depth)
+	}
+
 	var frame = &Frame{
-		bounds: bounds,
+		bounds:      bounds,
+		formats:     append([]FrameFormat(nil), cfg.Color...),
+		samples:     cfg.Samples,
+		depthFormat: cfg.Depth,
 		// Batcher: NewBatcher(),
 	}
 	// frame.Batcher.target = frame
 
-	// Create texture
-	// TODO - Note: I'm passing actual data to the texture object, rather than null. That might be suboptimal. This fills the GPU memory, whereas if I pass null I can just allocate it.
-	img := image.NewRGBA(image.Rect(int(bounds.Min.X), int(bounds.Min.Y), int(bounds.Max.X), int(bounds.Max.Y)))
-	frame.tex = NewTexture(img, smooth)
-	// frame.tex = NewEmptyTexture(int(bounds.W()), int(bounds.H()), smooth)
+	// Create textures. They are allocated without uploading any pixel data
+	width, height := int(bounds.W()), int(bounds.H())
+	for _, format := range cfg.Color {
+		f := frameFormats[format]
+		tex := &Texture{
+			width:  width,
+			height: height,
+			config: smoothConfig(cfg.Smooth),
+		}
+		tex.initializeFormat(f.internal, f.format, f.ty, nil)
+		frame.textures = append(frame.textures, tex)
+	}
+	if cfg.Depth != FrameDepthNone {
+		// https://webgl2fundamentals.org/webgl/lessons/webgl-render-to-texture.html
+		f := frameDepths[cfg.Depth]
+		frame.depth = &Texture{
+			width:  width,
+			height: height,
+		}
+		frame.depth.initializeFormat(f.internal, f.format, f.ty, nil)
+	}
 
 	// Create mesh (in case we want to draw the fbo to another target)
 	// frame.mesh = NewQuadMesh(R(-1, -1, 1, 1), R(0, 1, 1, 0))
 	frame.mesh = NewQuadMesh(bounds, glm.R(0, 1, 1, 0))
 	// frame.material = NewSpriteMaterial(frame.tex)
 	frame.material = NewMaterial(GetDefaultSpriteShader())
-	frame.material.texture = frame.tex
+	if len(frame.textures) > 0 {
+		frame.tex = frame.textures[0]
+		frame.material.textures[0] = frame.tex
+	} else {
+		frame.material.textures[0] = frame.depth
+	}
 
-	// frame.tex.Bind(0)///??????
+	var err error
+	global.flush()
 	mainthread.Call(func() {
+		defer func() {
+			// Restore the framebuffer that the state tracker thinks is bound
+			gl.BindFramebuffer(gl.FRAMEBUFFER, state.fbo)
+		}()
+
 		frame.fbo = gl.CreateFramebuffer()
 		gl.BindFramebuffer(gl.FRAMEBUFFER, frame.fbo)
-		gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, frame.tex.texture, 0)
+		for i, tex := range frame.textures {
+			gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0+gl.Enum(i), gl.TEXTURE_2D, tex.texture, 0)
+		}
+		if frame.depth != nil {
+			gl.FramebufferTexture2D(gl.FRAMEBUFFER, frame.depthFormat.attachment(), gl.TEXTURE_2D, frame.depth.texture, 0)
+		}
+		frame.setDrawBuffers()
+		if status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER); status != gl.FRAMEBUFFER_COMPLETE {
+			err = fmt.Errorf("glitch: incomplete framebuffer: 0x%x", status)
+			return
+		}
 
-		// https://webgl2fundamentals.org/webgl/lessons/webgl-render-to-texture.html
-		// TODO - maybe centralize this into texture creation api
-		// TODO - make fbo depth attachment optional
-		frame.depth = gl.CreateTexture()
-		gl.BindTexture(gl.TEXTURE_2D, frame.depth)
-		// gl.TexImage2DFull(gl.TEXTURE_2D, 0, gl.DEPTH24_STENCIL8, frame.tex.width, frame.tex.height, gl.DEPTH_STENCIL, gl.UNSIGNED_INT_24_8, nil)
-		gl.TexImage2DFull(gl.TEXTURE_2D, 0, gl.DEPTH_COMPONENT24, frame.tex.width, frame.tex.height, gl.DEPTH_COMPONENT, gl.UNSIGNED_INT, nil)
-		gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.TEXTURE_2D, frame.depth, 0)
+		if frame.samples <= 0 {
+			return
+		}
+
+		frame.msaaFbo = gl.CreateFramebuffer()
+		gl.BindFramebuffer(gl.FRAMEBUFFER, frame.msaaFbo)
+		for i, format := range frame.formats {
+			rb := frame.createRenderbuffer(frameFormats[format].internal)
+			gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0+gl.Enum(i), gl.RENDERBUFFER, rb)
+		}
+		if frame.depth != nil {
+			rb := frame.createRenderbuffer(frameDepths[cfg.Depth].internal)
+			gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, cfg.Depth.attachment(), gl.RENDERBUFFER, rb)
+		}
+		frame.setDrawBuffers()
+		if status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER); status != gl.FRAMEBUFFER_COMPLETE {
+			err = fmt.Errorf("glitch: incomplete multisampled framebuffer: 0x%x", status)
+		}
 	})
+	state.invalidateTexture()
 
-	runtime.SetFinalizer(frame, (*Frame).delete)
-	return frame
+	if err != nil {
+		frame.Destroy()
+		return nil, err
+	}
+
+	runtime.SetFinalizer(frame, (*Frame).finalize)
+	frame.trackID = trackResource("Frame")
+	return frame, nil
+}
+
+// Must be called on the mainthread with the framebuffer bound
+func (f *Frame) setDrawBuffers() {
+	if len(f.textures) == 0 {
+		gl.DrawBuffers([]gl.Enum{gl.NONE})
+		gl.ReadBuffer(gl.NONE)
+		return
+	}
+
+	buffers := make([]gl.Enum, len(f.textures))
+	for i := range buffers {
+		buffers[i] = gl.COLOR_ATTACHMENT0 + gl.Enum(i)
+	}
+	gl.DrawBuffers(buffers)
+}
+
+// Must be called on the mainthread with the framebuffer bound
+func (f *Frame) createRenderbuffer(internalFormat gl.Enum) gl.Renderbuffer {
+	rb := gl.CreateRenderbuffer()
+	gl.BindRenderbuffer(gl.RENDERBUFFER, rb)
+	gl.RenderbufferStorageMultisample(gl.RENDERBUFFER, f.samples, internalFormat, int(f.bounds.W()), int(f.bounds.H()))
+	f.renderbuffers = append(f.renderbuffers, rb)
+	f.addRenderbufferMemory(f.samples * int(f.bounds.W()) * int(f.bounds.H()) * textureFormatBytes(internalFormat))
+	return rb
+}
+
+// Copies the multisampled buffers into the frame's textures. This happens automatically when the frame is
+// drawn or read from, so it only needs to be called before using the textures directly in your own materials
+func (f *Frame) Resolve() {
+	if f.samples <= 0 {
+		return
+	}
+
+	if global.target == f {
+		global.flush()
+	}
+	if !f.dirty {
+		return
+	}
+	f.dirty = false
+
+	width, height := int32(f.bounds.W()), int32(f.bounds.H())
+	mainthread.Call(func() {
+		gl.BindFramebuffer(gl.READ_FRAMEBUFFER, f.msaaFbo)
+		gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, f.fbo)
+
+		// Blits copy from the read buffer to every draw buffer, so resolve each attachment by itself
+		for i := range f.textures {
+			attachment := gl.COLOR_ATTACHMENT0 + gl.Enum(i)
+			gl.ReadBuffer(attachment)
+			buffers := make([]gl.Enum, i+1)
+			for j := range buffers {
+				buffers[j] = gl.NONE
+			}
+			buffers[i] = attachment
+			gl.DrawBuffers(buffers)
+			gl.BlitFramebuffer(0, 0, width, height, 0, 0, width, height, gl.COLOR_BUFFER_BIT, gl.NEAREST)
+		}
+		if len(f.textures) > 0 {
+			gl.ReadBuffer(gl.COLOR_ATTACHMENT0)
+			f.setDrawBuffers()
+		}
+
+		if f.depth != nil {
+			mask := uint32(gl.DEPTH_BUFFER_BIT)
+			if f.depthFormat == FrameDepthStencil {
+				mask |= gl.STENCIL_BUFFER_BIT
+			}
+			gl.BlitFramebuffer(0, 0, width, height, 0, 0, width, height, mask, gl.NEAREST)
+		}
+
+		// Restore the framebuffer that the state tracker thinks is bound
+		gl.BindFramebuffer(gl.FRAMEBUFFER, state.fbo)
+	})
 }
 
 func (f *Frame) Bounds() Rect {
 	return f.bounds
 }
 
+// Reallocates the frame's attachments at a new size. The contents of the frame are lost, but its textures
+// are resized in place, so materials that reference them stay valid
+func (f *Frame) Resize(bounds Rect) {
+	if f.bounds == bounds {
+		return
+	}
+	resized := f.bounds.W() != bounds.W() || f.bounds.H() != bounds.H()
+	f.bounds = bounds
+	f.mesh = NewQuadMesh(bounds, glm.R(0, 1, 1, 0))
+	if !resized {
+		return
+	}
+
+	// Flush anything drawn at the old size, and force the next draw to rebind so that the viewport is updated
+	global.flush()
+	global.target = nil
+	f.dirty = false
+
+	width, height := int(bounds.W()), int(bounds.H())
+	mainthread.Call(func() {
+		for i, tex := range f.textures {
+			format := frameFormats[f.formats[i]]
+			gl.BindTexture(gl.TEXTURE_2D, tex.texture)
+			gl.TexImage2DFull(gl.TEXTURE_2D, 0, format.internal, width, height, format.format, format.ty, nil)
+			tex.width, tex.height = width, height
+			tex.updateMemory()
+		}
+		if f.depth != nil {
+			format := frameDepths[f.depthFormat]
+			gl.BindTexture(gl.TEXTURE_2D, f.depth.texture)
+			gl.TexImage2DFull(gl.TEXTURE_2D, 0, format.internal, width, height, format.format, format.ty, nil)
+			f.depth.width, f.depth.height = width, height
+			f.depth.updateMemory()
+		}
+
+		// Renderbuffers are created with the color attachments first, followed by the depth attachment
+		f.addRenderbufferMemory(-f.renderbufferBytes)
+		for i, rb := range f.renderbuffers {
+			internalFormat := frameDepths[f.depthFormat].internal
+			if i < len(f.formats) {
+				internalFormat = frameFormats[f.formats[i]].internal
+			}
+			gl.BindRenderbuffer(gl.RENDERBUFFER, rb)
+			gl.RenderbufferStorageMultisample(gl.RENDERBUFFER, f.samples, internalFormat, width, height)
+			f.addRenderbufferMemory(f.samples * width * height * textureFormatBytes(internalFormat))
+		}
+	})
+	state.invalidateTexture()
+}
+
+// Returns the first color texture, or nil for a depth only frame
 func (f *Frame) Texture() *Texture {
+	f.Resolve()
 	return f.tex
 }
 
+// Returns every color texture, in the order of FrameConfig.Color
+func (f *Frame) Textures() []*Texture {
+	f.Resolve()
+	return f.textures
+}
+
+// Returns the depth texture, or nil if the frame has no depth buffer
+func (f *Frame) DepthTexture() *Texture {
+	f.Resolve()
+	return f.depth
+}
+
+// Reads the frame's first color buffer back from the GPU. Any batched draws to the frame are flushed first.
+// Returns nil for a depth only frame
+func (f *Frame) Image() *image.RGBA {
+	return f.ImageAt(0)
+}
+
+// Reads a color buffer back from the GPU. Float formats are clamped to [0, 1].
+// Returns nil if the frame doesn't have that color attachment
+func (f *Frame) ImageAt(attachment int) *image.RGBA {
+	if attachment < 0 || attachment >= len(f.formats) {
+		return nil
+	}
+	f.Resolve()
+	ty := gl.Enum(gl.UNSIGNED_BYTE)
+	if f.formats[attachment] == FrameFormatRGBA16F {
+		ty = gl.FLOAT
+	}
+	return readImageExt(frameResolveTarget{f}, f.tex.width, f.tex.height, gl.COLOR_ATTACHMENT0+gl.Enum(attachment), ty)
+}
+
 func (f *Frame) Draw(target BatchTarget, matrix Mat4) {
 	f.DrawColorMask(target, matrix, RGBA{1.0, 1.0, 1.0, 1.0})
 }
 func (f *Frame) DrawColorMask(target BatchTarget, matrix Mat4, mask RGBA) {
 	// pass.SetTexture(0, s.texture)
+	f.Resolve()
 	target.Add(f.mesh, glm4(matrix), mask, f.material, false)
 }
 
@@ -89,13 +407,68 @@ func (f *Frame) RectDrawColorMask(target BatchTarget, bounds Rect, mask RGBA) {
 	f.DrawColorMask(target, matrix, mask)
 }
 
-func (f *Frame) delete() {
-	mainthread.CallNonBlock(func() {
-		gl.DeleteFramebuffer(f.fbo)
+func (f *Frame) addRenderbufferMemory(bytes int) {
+	f.renderbufferBytes += bytes
+	textureMemory.Add(int64(bytes))
+}
+
+// Deletes the frame and its textures from the GPU. Neither can be used after they are destroyed, and destroying the frame
+// again does nothing
+func (f *Frame) Destroy() {
+	runtime.SetFinalizer(f, nil)
+	if f.destroyed {
+		return
+	}
+	if global.target == f {
+		global.flush()
+		global.target = nil
+	}
+	state.invalidateFramebuffer(f.fbo)
+	if f.samples > 0 {
+		state.invalidateFramebuffer(f.msaaFbo)
+	}
+	f.release(mainthread.Call)
+
+	for _, tex := range f.textures {
+		tex.Destroy()
+	}
+	if f.depth != nil {
+		f.depth.Destroy()
+	}
+}
+
+// The textures have their own finalizers, and might still be referenced by materials, so only the framebuffers are released
+func (f *Frame) finalize() {
+	f.release(mainthread.CallNonBlock)
+}
+
+func (f *Frame) release(call func(func())) {
+	if f.destroyed {
+		return
+	}
+	f.destroyed = true
+	untrackResource(f.trackID)
+	textureMemory.Add(int64(-f.renderbufferBytes))
+	f.renderbufferBytes = 0
+
+	fbo, msaaFbo, samples, renderbuffers := f.fbo, f.msaaFbo, f.samples, f.renderbuffers
+	call(func() {
+		gl.DeleteFramebuffer(fbo)
+		if samples > 0 {
+			gl.DeleteFramebuffer(msaaFbo)
+		}
+		for _, rb := range renderbuffers {
+			gl.DeleteRenderbuffer(rb)
+		}
 	})
 }
 
 func (f *Frame) Bind() {
+	if f.samples > 0 {
+		f.dirty = true
+		state.bindFramebuffer(f.msaaFbo, f.bounds)
+		return
+	}
 	state.bindFramebuffer(f.fbo, f.bounds)
 }
 
@@ -105,5 +478,69 @@ func (f *Frame) Material() *Material {
 
 func (f *Frame) Add(filler GeometryFiller, mat glMat4, mask RGBA, material Material, translucent bool) {
 	setTarget(f)
+	f.dirty = true
 	global.Add(filler, mat, mask, material, translucent)
 }
+
+// Binds the framebuffer holding the resolved textures, so that they can be read back
+type frameResolveTarget struct {
+	frame *Frame
+}
+
+func (t frameResolveTarget) Bind() {
+	state.bindFramebuffer(t.frame.fbo, t.frame.bounds)
+}
+
+// Flushes pending draws to the target and reads its color buffer into an image.
+// OpenGL returns rows bottom to top, so they are flipped to match image.RGBA. Glitch blends with
+// premultiplied alpha, so the color channels are clamped to alpha to keep the result a valid premultiplied image
+func readImage(target Target, width, height int) *image.RGBA {
+	return readImageExt(target, width, height, gl.NONE, gl.UNSIGNED_BYTE)
+}
+
+// Reads the target's color buffer from the attachment, or from the target's current read buffer if attachment is NONE.
+// The pixels are read as ty, which must be UNSIGNED_BYTE or FLOAT
+func readImageExt(target Target, width, height int, attachment, ty gl.Enum) *image.RGBA {
+	setTarget(target)
+	global.flush()
+	target.Bind()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	if width <= 0 || height <= 0 {
+		return img
+	}
+
+	buf := make([]byte, len(img.Pix))
+	mainthread.Call(func() {
+		if attachment != gl.NONE {
+			gl.ReadBuffer(attachment)
+		}
+		if ty == gl.FLOAT {
+			floats := make([]byte, len(buf)*4)
+			gl.ReadPixels(floats, 0, 0, width, height, gl.RGBA, gl.FLOAT)
+			for i := range buf {
+				v := math.Float32frombits(binary.NativeEndian.Uint32(floats[i*4:]))
+				buf[i] = uint8(math.Round(float64(max(0, min(v, 1))) * 255))
+			}
+		} else {
+			gl.ReadPixels(buf, 0, 0, width, height, gl.RGBA, gl.UNSIGNED_BYTE)
+		}
+		if attachment != gl.NONE {
+			gl.ReadBuffer(gl.COLOR_ATTACHMENT0)
+		}
+	})
+
+	stride := width * 4
+	for y := 0; y < height; y++ {
+		src := buf[(height-1-y)*stride : (height-y)*stride]
+		dst := img.Pix[y*img.Stride : y*img.Stride+stride]
+		for i := 0; i < stride; i += 4 {
+			a := src[i+3]
+			dst[i+0] = min(src[i+0], a)
+			dst[i+1] = min(src[i+1], a)
+			dst[i+2] = min(src[i+2], a)
+			dst[i+3] = a
+		}
+	}
+	return img
+}
@@ -0,0 +1,131 @@
+//go:build headless
+// +build headless
+
+package glitch
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/unitoftime/flow/glm"
+	"github.com/unitoftime/glitch/internal/mainthread"
+)
+
+func TestHeadlessFrameConfig(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+	bounds := glm.R(0, 0, 16, 16)
+
+	// Multisampled with two color attachments: Both are written by the same draw and resolved on read
+	frame, err := NewFrameWithConfig(bounds, FrameConfig{
+		Color:   []FrameFormat{FrameFormatRGBA8, FrameFormatR8},
+		Depth:   FrameDepth24,
+		Samples: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Clear(frame, RGBA{0, 0, 1, 1})
+	NewQuadMesh(glm.R(0, 0, 8, 16), glm.R(0, 0, 1, 1)).DrawColorMask(frame, Mat4Ident, RGBA{1, 0.5, 0, 1})
+
+	color0 := frame.ImageAt(0)
+	if got := color0.RGBAAt(2, 2); got != (color.RGBA{255, 128, 0, 255}) {
+		t.Errorf("attachment 0: expected orange, got %v", got)
+	}
+	if got := color0.RGBAAt(12, 2); got != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("attachment 0: expected the clear color, got %v", got)
+	}
+	if got := frame.ImageAt(1).RGBAAt(2, 2); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("attachment 1: expected only the red channel, got %v", got)
+	}
+	if len(frame.Textures()) != 2 || frame.DepthTexture() == nil {
+		t.Errorf("expected two color textures and a depth texture")
+	}
+	if frame.ImageAt(2) != nil || frame.ImageAt(-1) != nil {
+		t.Errorf("expected no image for a missing attachment")
+	}
+
+	depthOnly, err := NewFrameWithConfig(bounds, FrameConfig{Depth: FrameDepth24})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if depthOnly.Image() != nil {
+		t.Errorf("expected no image for a depth only frame")
+	}
+	depthOnly.Destroy()
+
+	// Float frames keep values outside of [0, 1] until they are read back
+	hdr, err := NewFrameWithConfig(bounds, FrameConfig{Color: []FrameFormat{FrameFormatRGBA16F}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Clear(hdr, RGBA{4, 0.5, 0, 1})
+	Clear(win, RGBA{})
+	hdr.DrawColorMask(win, Mat4Ident, RGBA{0.25, 1, 1, 1})
+	if got := win.Image().RGBAAt(2, 2); got != (color.RGBA{255, 128, 0, 255}) {
+		t.Errorf("hdr frame: expected the float value to survive scaling, got %v", got)
+	}
+
+	if _, err := NewFrameWithConfig(bounds, FrameConfig{}); err == nil {
+		t.Errorf("expected an error for a frame without attachments")
+	}
+	win.Update()
+}
+
+func TestHeadlessFrameResize(t *testing.T) {
+	win, camera := newTestWindow(t, 16, 16, WindowConfig{})
+
+	frame, err := win.NewTrackedFrame(FrameConfig{Color: []FrameFormat{FrameFormatRGBA8}, Depth: FrameDepth24}, FrameSizing{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	half, err := win.NewTrackedFrame(FrameConfig{Color: []FrameFormat{FrameFormatRGBA8}}, FrameSizing{Scale: 0.5, Logical: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	texture := frame.Texture()
+
+	mainthread.Call(func() {
+		win.window.SetSize(32, 24)
+		win.window.SetContentScale(2, 2)
+	})
+	win.Update()
+
+	if got := frame.Bounds(); got != glm.R(0, 0, 64, 48) {
+		t.Errorf("expected the frame to match the framebuffer, got %v", got)
+	}
+	if got := half.Bounds(); got != glm.R(0, 0, 16, 12) {
+		t.Errorf("expected the half frame to be half the logical size, got %v", got)
+	}
+	if frame.Texture() != texture || texture.Bounds() != frame.Bounds() {
+		t.Errorf("expected the texture to be resized in place")
+	}
+
+	camera.SetOrtho2D(frame.Bounds())
+	SetCamera(camera)
+
+	Clear(frame, RGBA{0, 0, 1, 1})
+	NewQuadMesh(glm.R(32, 0, 64, 48), glm.R(0, 0, 1, 1)).DrawColorMask(frame, Mat4Ident, RGBA{1, 0, 0, 1})
+	img := frame.Image()
+	if img.Bounds() != image.Rect(0, 0, 64, 48) {
+		t.Fatalf("unexpected image size %v", img.Bounds())
+	}
+	if got := img.RGBAAt(60, 40); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("expected the right half to be red, got %v", got)
+	}
+	if got := img.RGBAAt(4, 4); got != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("expected the left half to be the clear color, got %v", got)
+	}
+
+	win.UntrackFrame(half)
+	mainthread.Call(func() {
+		win.window.SetSize(16, 16)
+	})
+	win.Update()
+	if got := half.Bounds(); got != glm.R(0, 0, 16, 12) {
+		t.Errorf("expected an untracked frame to keep its size, got %v", got)
+	}
+	if got := frame.Bounds(); got != glm.R(0, 0, 32, 32) {
+		t.Errorf("expected the frame to shrink with the window, got %v", got)
+	}
+}
@@ -1,6 +1,10 @@
 package glitch
 
 import (
+	"fmt"
+	"math"
+
+	"github.com/unitoftime/flow/glm"
 	"github.com/unitoftime/glitch/internal/mainthread"
 )
 
@@ -46,6 +50,30 @@ func (u *Uniforms) Bind(shader *Shader) {
 	}
 }
 
+// Reports whether both hold the same values, so that binding either one sets the shader the same way
+func (u *Uniforms) equal(u2 *Uniforms) bool {
+	if u == u2 {
+		return true
+	}
+	var a, b map[string]any
+	if u != nil {
+		a = u.set
+	}
+	if u2 != nil {
+		b = u2.set
+	}
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		v2, ok := b[k]
+		if !ok || !openglEquals(v, v2) {
+			return false
+		}
+	}
+	return true
+}
+
 func (u *Uniforms) SetUniform(name string, val any) {
 	if u.set == nil {
 		u.set = make(map[string]any)
@@ -67,12 +95,14 @@ func (u *Uniforms) Copy() *Uniforms {
 // Uniform: uniform slot lut ID 256 maximum
 type Material struct {
 	shader   *Shader
-	texture  *Texture
-	uniforms *Uniforms // TODO: Generic binder (eg old Material interface)?
-
-	blend BlendMode
-	depth DepthMode
-	cull  CullMode
+	textures [MaxTextureUnits]*Texture // Indexed by texture unit. Unit 0 is the main texture
+	uniforms *Uniforms                 // TODO: Generic binder (eg old Material interface)?
+
+	blend   BlendMode
+	depth   DepthMode
+	cull    CullMode
+	stencil StencilMode
+	scissor scissor
 }
 
 func NewMaterial(shader *Shader) Material {
@@ -105,8 +135,19 @@ func (m *Material) SetUniform(name string, val any) *Material {
 	return m
 }
 
-func (m *Material) SetTexture( /* slot int, */ texture *Texture) {
-	m.texture = texture
+// Sets the main texture, which is bound to texture unit 0
+func (m *Material) SetTexture(texture *Texture) {
+	m.textures[0] = texture
+}
+
+// Sets the texture for a sampler uniform declared in the shader's UniformFormat
+func (m *Material) SetSampler(name string, texture *Texture) *Material {
+	unit, ok := m.shader.samplers[name]
+	if !ok {
+		panic(fmt.Sprintf("Sampler not found in shader uniform format: %s", name))
+	}
+	m.textures[unit] = texture
+	return m
 }
 
 func (m *Material) SetCullMode(cullMode CullMode) *Material {
@@ -124,20 +165,46 @@ func (m *Material) SetBlendMode(blendMode BlendMode) *Material {
 	return m
 }
 
+func (m *Material) SetStencilMode(stencilMode StencilMode) *Material {
+	m.stencil = stencilMode
+	return m
+}
+
+// Clips draws with this material to a rectangle. The scissor is part of the material, so it is kept when the draw
+// goes through a Sorter, RenderQueue, Batch or CommandRecorder. See glitch.SetScissor for how the rectangle is transformed
+func (m *Material) SetScissor(rect Rect) *Material {
+	m.scissor = scissor{rect, true}
+	return m
+}
+
+// Stops clipping draws with this material
+func (m *Material) DisableScissor() *Material {
+	m.scissor = scissor{}
+	return m
+}
+
+// Reports whether drawing with m2 needs the same GL state as m. Materials that each have their own
+// uniforms, but with the same values, bind the same way, so switching between them doesn't break the batch
+func (m Material) sameState(m2 Material) bool {
+	u, u2 := m.uniforms, m2.uniforms
+	m.uniforms, m2.uniforms = nil, nil
+	return m == m2 && u.equal(u2)
+}
+
 func (m Material) Bind() {
 	setShader(m.shader)
 	// m.shader.Use()
 
-	if m.texture != nil {
-		// texSlot := 0
-		// m.texture.bind(texSlot)
-
-		state.bindTexture(m.texture)
+	for unit, texture := range m.textures {
+		if texture != nil {
+			state.bindTexture(unit, texture)
+		}
 	}
 
 	state.setBlendMode(m.blend)
 	state.setDepthMode(m.depth)
 	state.setCullMode(m.cull)
+	state.setStencilMode(m.stencil)
 
 	// // Bind Depthmode
 	// if m.depth == DepthModeNone {
@@ -181,33 +248,6 @@ func (m Material) Bind() {
 // 	}
 // }
 
-type Metrics struct {
-	setShader    int
-	setCamera    int
-	clearTarget  int
-	setTarget    int
-	setMaterial  int
-	add          int
-	flushAttempt int
-	flush        int
-	finish       int
-	draw         int
-
-	vertsTotal int // The total number of vertices drawn
-	vertsAvg   int // The average number of vertices drawn per drawCall
-
-	// Note: Disabled because this didn't really give me any insight
-	// vertsMin int
-	// vertsMax int
-}
-
-func GetMetrics() Metrics {
-	metric := global.metric
-	global.metric = Metrics{}
-
-	return metric
-}
-
 // --------------------------------------------------------------------------------
 type CameraMaterial struct {
 	Projection, View glMat4
@@ -217,6 +257,7 @@ type CameraMaterial struct {
 
 var global = &globalBatcher{
 	shaderCache: make(map[*Shader]struct{}), // TODO: Does this cause shaders to not cleanup?
+	history:     NewFrameHistory(defaultFrameHistory),
 	// camera: NewCameraOrtho(), // Identity camera
 	camera: CameraMaterial{
 		glMat4Ident, glMat4Ident,
@@ -226,22 +267,33 @@ var global = &globalBatcher{
 type globalBatcher struct {
 	shader     *Shader
 	camera     CameraMaterial
+	cull       cullVolume // What the camera can see, for culling. The zero value sees everything
 	lastBuffer *VertexBuffer
 	target     Target
 	blend      BlendMode
 
 	material Material
 
+	scissor      scissor // The scissor set with SetScissor
+	clip         scissor // The scissor that was last applied, which includes the material's scissor
+	scissorDirty bool    // Set when the scissor needs to be recomputed because the camera or target changed
+
 	shaderCache map[*Shader]struct{}
 
-	metric Metrics
+	metric     Metrics // Running totals, see GetMetrics
+	metricMark Metrics // The totals when GetMetrics was last called
+	frameMark  Metrics // The totals at the end of the last frame
+	history    *FrameHistory
+	gpuTimer   gpuTimer
+
+	loaders []*AssetLoader // Asset loaders that upload a little each frame
 }
 
 func Clear(target Target, color RGBA) {
 	setTarget(target)
 	state.clearTarget(color)
 
-	global.metric.clearTarget++
+	global.metric.ClearTarget++
 }
 
 // func setBlendMode(blend BlendMode) {
@@ -263,14 +315,17 @@ func SetCameraMaterial(camMaterial CameraMaterial) {
 		return
 	}
 
-	if global.shader != nil {
-		global.flush() // TODO: You technically only need to do this if it will change the uniform
-		global.camera = camMaterial
+	global.flush() // TODO: You technically only need to do this if it will change the uniform
+	global.camera = camMaterial
+	global.cull = cullVolume{frustum: NewFrustum(camMaterial.Projection.Mat4(), camMaterial.View.Mat4())}
+	global.scissorDirty = true
 
+	// Note: If no shader is bound yet, the camera is applied when one is bound in setShader
+	if global.shader != nil {
 		global.shader.setUniformMat4("projection", global.camera.Projection)
 		global.shader.setUniformMat4("view", global.camera.View)
 
-		global.metric.setCamera++
+		global.metric.SetCamera++
 	}
 }
 
@@ -280,6 +335,93 @@ func SetCamera(camera *CameraOrtho) {
 		View:       glm4(camera.View),
 	}
 	SetCameraMaterial(camMaterial)
+
+	// Ortho cameras only cull by their rectangle, so that layers and depth don't cull anything
+	global.cull = cullVolume{ortho: true, rect: camera.ViewRect()}
+}
+
+// A clipping rectangle in world space. It is transformed by the camera when it is applied
+type scissor struct {
+	rect    Rect
+	enabled bool
+}
+
+// Returns the region that is kept by both scissors
+func (s scissor) intersect(s2 scissor) scissor {
+	if !s.enabled {
+		return s2
+	}
+	if !s2.enabled {
+		return s
+	}
+	r := glm.R(
+		max(s.rect.Min.X, s2.rect.Min.X), max(s.rect.Min.Y, s2.rect.Min.Y),
+		min(s.rect.Max.X, s2.rect.Max.X), min(s.rect.Max.Y, s2.rect.Max.Y),
+	)
+	r.Max.X = max(r.Min.X, r.Max.X)
+	r.Max.Y = max(r.Min.Y, r.Max.Y)
+	return scissor{r, true}
+}
+
+// Clips the following draws to a Window or Frame to a rectangle. The rectangle is in world space, so it is transformed
+// through the current camera (see SetCamera) into pixels of the target when something is drawn.
+// Note: This is global draw state, so it isn't stored by a Sorter, RenderQueue, Batch or CommandRecorder. Draws added
+// to those are clipped by the scissor that is set when they are drawn to a Window or Frame. Use Material.SetScissor, or
+// Sorter.SetScissor, to clip draws that are stored for later
+func SetScissor(rect Rect) {
+	global.scissor = scissor{rect, true}
+}
+
+// Stops clipping draws to the scissor rectangle
+func DisableScissor() {
+	global.scissor = scissor{}
+}
+
+// Projects the scissor through the camera and into the pixels of the bound framebuffer's viewport
+func (g *globalBatcher) applyScissor(clip scissor) {
+	g.clip = clip
+	g.scissorDirty = false
+
+	box := scissorBox{}
+	if clip.enabled {
+		projection := g.camera.Projection.Mat4()
+		view := g.camera.View.Mat4()
+		viewport := state.viewport()
+
+		minX, minY := math.Inf(1), math.Inf(1)
+		maxX, maxY := math.Inf(-1), math.Inf(-1)
+		rect := clip.rect
+		corners := [4]Vec3{
+			{rect.Min.X, rect.Min.Y, 0},
+			{rect.Max.X, rect.Min.Y, 0},
+			{rect.Min.X, rect.Max.Y, 0},
+			{rect.Max.X, rect.Max.Y, 0},
+		}
+		for _, c := range corners {
+			ndc := projection.Apply(view.Apply(c))
+			x := viewport.Min.X + (ndc.X+1)/2*viewport.W()
+			y := viewport.Min.Y + (ndc.Y+1)/2*viewport.H()
+			minX, minY = min(minX, x), min(minY, y)
+			maxX, maxY = max(maxX, x), max(maxY, y)
+		}
+
+		// Round outwards so that pixels partially covered by the rect are kept
+		x0, y0 := int32(math.Floor(minX)), int32(math.Floor(minY))
+		x1, y1 := int32(math.Ceil(maxX)), int32(math.Ceil(maxY))
+		box = scissorBox{
+			enabled: true,
+			x:       x0,
+			y:       y0,
+			w:       max(0, x1-x0),
+			h:       max(0, y1-y0),
+		}
+	}
+
+	if state.scissor == box {
+		return
+	}
+	g.flush()
+	state.setScissor(box)
 }
 
 func setTarget(target Target) {
@@ -290,8 +432,9 @@ func setTarget(target Target) {
 	global.flush() // TODO: You technically only need to do this if it will change the uniform
 	global.target = target
 	target.Bind()
+	global.scissorDirty = true
 
-	global.metric.setTarget++
+	global.metric.SetTarget++
 }
 
 func setShader(shader *Shader) {
@@ -307,7 +450,7 @@ func setShader(shader *Shader) {
 	global.shader.setUniformMat4("view", global.camera.View)
 
 	global.shaderCache[shader] = struct{}{}
-	global.metric.setShader++
+	global.metric.SetShader++
 }
 
 func (g *globalBatcher) Add(filler GeometryFiller, mat glMat4, mask RGBA, material Material, translucent bool) {
@@ -315,13 +458,18 @@ func (g *globalBatcher) Add(filler GeometryFiller, mat glMat4, mask RGBA, materi
 		return
 	} // Skip nil meshes
 
-	global.metric.add++
+	global.metric.Add++
+
+	if clip := g.scissor.intersect(material.scissor); g.scissorDirty || clip != g.clip {
+		g.flush() // The batched geometry was added under the last scissor
+		g.applyScissor(clip)
+	}
 
 	// 1. If you switch materials, then draw the last one
-	if material != g.material {
+	if material != g.material && !material.sameState(g.material) {
 		// fmt.Printf("setmaterial (old -> new):\n%+v\n%+v\n", g.material, material)
 
-		global.metric.setMaterial++
+		global.metric.SetMaterial++
 		// Note: This is kindof different from a global material. it's more like a local material
 		g.flush()
 		g.material = material
@@ -349,15 +497,15 @@ func (g *globalBatcher) Add(filler GeometryFiller, mat glMat4, mask RGBA, materi
 func (g *globalBatcher) finish() {
 	g.flush()
 	for shader := range g.shaderCache {
-		shader.pool.Clear()
+		shader.pool.NextFrame()
 	}
 	// clear(g.shaderCache) // TODO: the shaderCache leaks right now, but only grows to as many shaders as the user loads which isn't that much. You cant clear here because in single shader scenarios itll never get set back again
-	g.metric.finish++
+	g.metric.Finish++
 }
 
 // Draws the current buffer and progress the shader pool to the next available
 func (g *globalBatcher) flush() {
-	g.metric.flushAttempt++
+	g.metric.FlushAttempt++
 	if g.lastBuffer == nil {
 		return
 	}
@@ -366,7 +514,7 @@ func (g *globalBatcher) flush() {
 	g.lastBuffer = nil
 	g.shader.pool.gotoNextClean()
 
-	g.metric.flush++
+	g.metric.Flush++
 }
 
 // Executes a drawcall with ...
@@ -381,11 +529,10 @@ func (g *globalBatcher) drawCall(buffer *VertexBuffer, mat glMat4) {
 	}
 
 	buffer.Draw()
-	g.metric.draw++
+	g.metric.Draw++
 
 	vertCount := int(buffer.numVerts)
-	g.metric.vertsTotal += vertCount
-	g.metric.vertsAvg = g.metric.vertsTotal / g.metric.draw
+	g.metric.VertsTotal += vertCount
 	// g.metric.vertsMax = max(vertCount, g.metric.vertsMax)
 	// g.metric.vertsMin = min(vertCount, g.metric.vertsMin)
 	// if g.metric.vertsMin == 0 {
@@ -0,0 +1,76 @@
+//go:build headless
+// +build headless
+
+package glitch
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/unitoftime/flow/glm"
+	"github.com/unitoftime/glitch/shaders"
+)
+
+const maskFragmentShader = `#version 300 es
+precision highp float;
+out vec4 FragColor;
+in vec4 ourColor;
+in vec2 TexCoord;
+uniform sampler2D mask;
+uniform sampler2D texture1;
+void main()
+{
+  FragColor = ourColor * texture(mask, TexCoord) * texture(texture1, TexCoord).a;
+}
+`
+
+func solidTexture(r, g, b uint8) *Texture {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i+0] = r
+		img.Pix[i+1] = g
+		img.Pix[i+2] = b
+		img.Pix[i+3] = 255
+	}
+	return NewTexture(img, false)
+}
+
+// A sprite shader that also samples a mask texture on unit 1
+func newMaskShader(t *testing.T) *Shader {
+	t.Helper()
+	cfg := shaders.SpriteShader
+	cfg.FragmentShader = maskFragmentShader
+	cfg.UniformFormat = append(cfg.UniformFormat[:len(cfg.UniformFormat):len(cfg.UniformFormat)], shaders.Attr{Name: "mask", Type: shaders.AttrSampler2D})
+	shader, err := NewShader(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return shader
+}
+
+func TestHeadlessNamedSampler(t *testing.T) {
+	win, _ := newTestWindow(t, 32, 32, WindowConfig{})
+
+	main := solidTexture(255, 0, 0)
+	mask := solidTexture(0, 255, 0)
+	material := NewMaterial(newMaskShader(t))
+	material.SetTexture(main)
+	material.SetSampler("mask", mask)
+
+	other := material
+	other.SetSampler("mask", main)
+	if material == other {
+		t.Errorf("expected materials with different samplers to be unequal")
+	}
+
+	Clear(win, RGBA{0, 0, 0, 1})
+	mesh := NewQuadMesh(win.Bounds(), glm.R(0, 0, 1, 1))
+	win.Add(mesh, glMat4Ident, White, material, false)
+
+	// The mask sampler is declared first, so the software renderer samples it
+	if got := win.Image().RGBAAt(16, 16); got != (color.RGBA{0, 255, 0, 255}) {
+		t.Errorf("expected the mask texture on unit 1 to be sampled, got %v", got)
+	}
+	win.Update()
+}
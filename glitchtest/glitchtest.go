@@ -0,0 +1,229 @@
+// Package glitchtest renders scenes offscreen and compares them against golden PNG images.
+//
+// Tests must run on glitch's main thread, so a test package using glitchtest should forward TestMain:
+//
+//	func TestMain(m *testing.M) { glitchtest.Main(m) }
+//
+// Golden images are stored in testdata/golden/<name>.png. Set GLITCHTEST_UPDATE=1 to write the
+// rendered images as the new goldens. On a mismatch the rendered image and a diff image are written
+// next to the golden as <name>.actual.png and <name>.diff.png.
+package glitchtest
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/unitoftime/flow/glm"
+	"github.com/unitoftime/glitch"
+)
+
+// The environment variable that causes goldens to be rewritten instead of compared
+const UpdateEnv = "GLITCHTEST_UPDATE"
+
+// The directory, relative to the test's package, that golden images are stored in
+var GoldenDir = filepath.Join("testdata", "golden")
+
+// Main runs the test binary on glitch's main thread and exits with its result
+func Main(m *testing.M) {
+	code := 0
+	glitch.Run(func() {
+		code = m.Run()
+	})
+	os.Exit(code)
+}
+
+var (
+	windowOnce sync.Once
+	window     *glitch.Window
+	windowErr  error
+)
+
+// Window returns a window shared by every test in the binary. It only exists to own the GL context that frames are rendered with
+func Window(t testing.TB) *glitch.Window {
+	t.Helper()
+	windowOnce.Do(func() {
+		window, windowErr = glitch.NewWindow(64, 64, "glitchtest", glitch.WindowConfig{})
+	})
+	if windowErr != nil {
+		t.Fatalf("glitchtest: failed to create window: %v", windowErr)
+	}
+	return window
+}
+
+// Render draws a scene into a transparent frame of the given size and reads it back.
+// The camera is set to a 2D orthographic projection covering the frame before draw is called
+func Render(t testing.TB, width, height int, draw func(frame *glitch.Frame)) *image.RGBA {
+	t.Helper()
+	Window(t)
+
+	bounds := glm.R(0, 0, float64(width), float64(height))
+	frame := glitch.NewFrame(bounds, false)
+
+	camera := glitch.NewCameraOrtho()
+	camera.SetOrtho2D(bounds)
+	camera.SetView2D(0, 0, 1, 1)
+	glitch.SetCamera(camera)
+
+	glitch.Clear(frame, glitch.RGBA{})
+	draw(frame)
+	return frame.Image()
+}
+
+// Result describes how two images differ
+type Result struct {
+	Mismatched int         // The number of pixels with at least one channel outside the tolerance
+	MaxDelta   uint8       // The largest per channel difference found
+	Diff       *image.RGBA // Mismatched pixels in red over a faded copy of the expected image
+}
+
+// Compare compares two images channel by channel, allowing each channel to differ by up to tolerance.
+// Images of different sizes are reported as an error
+func Compare(got, want image.Image, tolerance uint8) (Result, error) {
+	gb, wb := got.Bounds(), want.Bounds()
+	if gb.Dx() != wb.Dx() || gb.Dy() != wb.Dy() {
+		return Result{}, fmt.Errorf("glitchtest: image size %dx%d does not match expected %dx%d", gb.Dx(), gb.Dy(), wb.Dx(), wb.Dy())
+	}
+
+	g := toNRGBA(got)
+	w := toNRGBA(want)
+	res := Result{
+		Diff: image.NewRGBA(image.Rect(0, 0, wb.Dx(), wb.Dy())),
+	}
+	for i := 0; i < len(w.Pix); i += 4 {
+		var delta uint8
+		for c := 0; c < 4; c++ {
+			delta = max(delta, absDiff(g.Pix[i+c], w.Pix[i+c]))
+		}
+		res.MaxDelta = max(res.MaxDelta, delta)
+
+		if delta > tolerance {
+			res.Mismatched++
+			res.Diff.Pix[i+0] = 255
+			res.Diff.Pix[i+1] = 0
+			res.Diff.Pix[i+2] = 0
+			res.Diff.Pix[i+3] = 255
+			continue
+		}
+
+		// Fade matching pixels so mismatches stand out
+		a := w.Pix[i+3]
+		lum := uint8((uint32(w.Pix[i+0])*299 + uint32(w.Pix[i+1])*587 + uint32(w.Pix[i+2])*114) / 1000)
+		gray := uint8(uint32(lum) * uint32(a) / 255 / 4)
+		res.Diff.Pix[i+0] = gray
+		res.Diff.Pix[i+1] = gray
+		res.Diff.Pix[i+2] = gray
+		res.Diff.Pix[i+3] = 255
+	}
+	return res, nil
+}
+
+// AssertGolden compares img against the golden image with the given name and fails the test if any
+// channel differs by more than tolerance. If GLITCHTEST_UPDATE is set the golden is rewritten instead
+func AssertGolden(t testing.TB, name string, img image.Image, tolerance uint8) {
+	t.Helper()
+
+	path := filepath.Join(GoldenDir, name+".png")
+	if os.Getenv(UpdateEnv) != "" {
+		if err := WritePNG(path, img); err != nil {
+			t.Fatalf("glitchtest: failed to update golden: %v", err)
+		}
+		t.Logf("glitchtest: updated golden %s", path)
+		return
+	}
+
+	want, err := ReadPNG(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("glitchtest: missing golden %s, run with %s=1 to create it", path, UpdateEnv)
+		}
+		t.Fatalf("glitchtest: failed to read golden: %v", err)
+	}
+
+	res, err := Compare(img, want, tolerance)
+	if err != nil {
+		writeFailure(t, path, img, nil)
+		t.Fatal(err)
+	}
+	if res.Mismatched == 0 {
+		return
+	}
+
+	writeFailure(t, path, img, res.Diff)
+	t.Errorf("glitchtest: %s: %d pixels differ by more than %d (max difference %d)", name, res.Mismatched, tolerance, res.MaxDelta)
+}
+
+func writeFailure(t testing.TB, goldenPath string, got image.Image, diff image.Image) {
+	t.Helper()
+	base := goldenPath[:len(goldenPath)-len(filepath.Ext(goldenPath))]
+
+	actualPath := base + ".actual.png"
+	if err := WritePNG(actualPath, got); err != nil {
+		t.Logf("glitchtest: failed to write %s: %v", actualPath, err)
+	} else {
+		t.Logf("glitchtest: wrote rendered image to %s", actualPath)
+	}
+
+	if diff == nil {
+		return
+	}
+	diffPath := base + ".diff.png"
+	if err := WritePNG(diffPath, diff); err != nil {
+		t.Logf("glitchtest: failed to write %s: %v", diffPath, err)
+	} else {
+		t.Logf("glitchtest: wrote diff image to %s", diffPath)
+	}
+}
+
+// ReadPNG decodes the PNG file at path
+func ReadPNG(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return png.Decode(file)
+}
+
+// WritePNG encodes img to path, creating parent directories as needed
+func WritePNG(path string, img image.Image) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = png.Encode(file, img)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Images are compared without premultiplied alpha, which is how they are stored in PNG files
+func toNRGBA(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	out := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(out, out.Bounds(), img, b.Min, draw.Src)
+	return out
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
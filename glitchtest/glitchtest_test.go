@@ -0,0 +1,39 @@
+package glitchtest
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestCompareTolerance(t *testing.T) {
+	want := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	want.SetNRGBA(0, 0, color.NRGBA{100, 100, 100, 255})
+	want.SetNRGBA(1, 0, color.NRGBA{100, 100, 100, 255})
+
+	got := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	got.SetNRGBA(0, 0, color.NRGBA{102, 100, 100, 255})
+	got.SetNRGBA(1, 0, color.NRGBA{100, 110, 100, 255})
+
+	res, err := Compare(got, want, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Mismatched != 1 {
+		t.Errorf("expected 1 mismatched pixel, got %d", res.Mismatched)
+	}
+	if res.MaxDelta != 10 {
+		t.Errorf("expected max delta of 10, got %d", res.MaxDelta)
+	}
+	if c := res.Diff.RGBAAt(1, 0); c != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("expected mismatched pixel to be red in the diff, got %v", c)
+	}
+	if c := res.Diff.RGBAAt(0, 0); c.R == 255 {
+		t.Errorf("expected matching pixel to be faded in the diff, got %v", c)
+	}
+
+	_, err = Compare(got, image.NewNRGBA(image.Rect(0, 0, 1, 1)), 0)
+	if err == nil {
+		t.Errorf("expected an error for mismatched image sizes")
+	}
+}
@@ -0,0 +1,54 @@
+//go:build headless
+// +build headless
+
+package glitchtest
+
+import (
+	"image"
+	"testing"
+
+	"github.com/unitoftime/glitch"
+)
+
+func TestMain(m *testing.M) { Main(m) }
+
+func TestGoldenSprite(t *testing.T) {
+	img := Render(t, 32, 32, func(frame *glitch.Frame) {
+		pix := image.NewRGBA(image.Rect(0, 0, 8, 8))
+		for i := 0; i < len(pix.Pix); i += 4 {
+			pix.Pix[i+0] = 255
+			pix.Pix[i+3] = 255
+		}
+		texture := glitch.NewTexture(pix, false)
+		sprite := glitch.NewSprite(texture, texture.Bounds())
+
+		// Top left corner, so the golden also checks that readback is flipped
+		mat := glitch.Mat4Ident
+		mat.Translate(8, 24, 0)
+		sprite.Draw(frame, mat)
+	})
+
+	if c := img.RGBAAt(8, 8); c.R != 255 || c.A != 255 {
+		t.Errorf("expected sprite in the top left of the image, got %v", c)
+	}
+	if c := img.RGBAAt(8, 24); c.A != 0 {
+		t.Errorf("expected transparent bottom left, got %v", c)
+	}
+	AssertGolden(t, "sprite", img, 2)
+}
+
+func TestGoldenText(t *testing.T) {
+	Window(t) // The atlas texture needs a context
+	atlas, err := glitch.DefaultAtlas()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img := Render(t, 128, 48, func(frame *glitch.Frame) {
+		text := atlas.Text("Glitch", 1.0)
+		mat := glitch.Mat4Ident
+		mat.Translate(4, 8, 0)
+		text.Draw(frame, mat)
+	})
+	AssertGolden(t, "text", img, 2)
+}
@@ -0,0 +1,109 @@
+//go:build headless
+// +build headless
+
+package glitch
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"testing"
+
+	"github.com/unitoftime/glitch/internal/gl"
+	"github.com/unitoftime/glitch/internal/mainthread"
+)
+
+// These tests run against the software renderer: go test -tags headless
+func TestMain(m *testing.M) {
+	code := 0
+	Run(func() {
+		code = m.Run()
+	})
+	os.Exit(code)
+}
+
+// Opens a window with a camera that maps one unit to one pixel
+func newTestWindow(t *testing.T, width, height int, config WindowConfig) (*Window, *CameraOrtho) {
+	t.Helper()
+	win, err := NewWindow(width, height, "test", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	camera := NewCameraOrtho()
+	camera.SetOrtho2D(win.Bounds())
+	camera.SetView2D(0, 0, 1, 1)
+	SetCamera(camera)
+	return win, camera
+}
+
+func readWindowPixel(win *Window, x, y int) color.RGBA {
+	win.Bind()
+	dst := make([]byte, 4)
+	mainthread.Call(func() {
+		gl.ReadPixels(dst, x, y, 1, 1, gl.RGBA, gl.UNSIGNED_BYTE)
+	})
+	return color.RGBA{dst[0], dst[1], dst[2], dst[3]}
+}
+
+func TestHeadlessSpriteDraw(t *testing.T) {
+	win, _ := newTestWindow(t, 64, 64, WindowConfig{})
+
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i+0] = 255
+		img.Pix[i+3] = 255
+	}
+	texture := NewTexture(img, false)
+	sprite := NewSprite(texture, texture.Bounds())
+
+	Clear(win, RGBA{0, 0, 1, 1})
+	mat := Mat4Ident
+	mat.Translate(32, 32, 0)
+	sprite.Draw(win, mat)
+	win.Update()
+
+	if got := readWindowPixel(win, 32, 32); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("sprite pixel: expected red, got %v", got)
+	}
+	if got := readWindowPixel(win, 24, 24); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("sprite corner pixel: expected red, got %v", got)
+	}
+	if got := readWindowPixel(win, 40, 32); got != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("pixel past sprite edge: expected blue, got %v", got)
+	}
+	if got := readWindowPixel(win, 2, 2); got != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("background pixel: expected blue, got %v", got)
+	}
+}
+
+func TestHeadlessMSDFText(t *testing.T) {
+	win, _ := newTestWindow(t, 256, 64, WindowConfig{})
+
+	atlas, err := DefaultAtlas()
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := atlas.Text("Glitch", 1.0)
+
+	Clear(win, RGBA{0, 0, 0, 1})
+	mat := Mat4Ident
+	mat.Translate(8, 16, 0)
+	text.Draw(win, mat)
+	win.Update()
+
+	win.Bind()
+	dst := make([]byte, 256*64*4)
+	mainthread.Call(func() {
+		gl.ReadPixels(dst, 0, 0, 256, 64, gl.RGBA, gl.UNSIGNED_BYTE)
+	})
+	lit := 0
+	for i := 0; i < len(dst); i += 4 {
+		if dst[i] > 128 {
+			lit++
+		}
+	}
+	if lit == 0 {
+		t.Errorf("expected the msdf shader to produce text pixels")
+	}
+}
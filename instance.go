@@ -0,0 +1,168 @@
+package glitch
+
+import (
+	"github.com/unitoftime/flow/glm"
+	"github.com/unitoftime/glitch/shaders"
+)
+
+var defaultInstancedShader *Shader
+
+func SetDefaultInstancedShader(shader *Shader) {
+	defaultInstancedShader = shader
+}
+
+// Returns an instanced sprite shader, for materials that are drawn into an InstanceBatch
+func GetDefaultInstancedShader() *Shader {
+	if defaultInstancedShader != nil {
+		return defaultInstancedShader
+	}
+
+	var err error
+	defaultInstancedShader, err = NewShader(shaders.SpriteInstancedShader)
+	if err != nil {
+		panic(err)
+	}
+	return defaultInstancedShader
+}
+
+type instanceKey struct {
+	filler   GeometryFiller
+	material Material
+}
+
+// All of the instances of one filler and material
+type instanceGroup struct {
+	key         instanceKey
+	pool        *BufferPool
+	data        []float32
+	count       int
+	translucent bool
+	bounds      Box
+	filler      bufferFiller // What is passed to the target
+}
+
+// An InstanceBatch draws many copies of the same geometry with one draw call. Everything added with the same filler
+// and material is grouped together, and each addition becomes an instance with its own model matrix, color mask and uv rect.
+// The geometry is only filled once per group, so this is useful for drawing lots of the same sprite or mesh.
+// The material's shader must declare per instance attributes (see shaders.InstanceAttribute), adding anything
+// else panics. Sprites can be switched over to the default instanced sprite shader.
+//
+//	sprite.Material().SetShader(glitch.GetDefaultInstancedShader())
+//
+//	batch := glitch.NewInstanceBatch()
+//	for i := range positions {
+//		sprite.Draw(batch, glitch.Mat4Ident.Translate(positions[i].X, positions[i].Y, 0))
+//	}
+//	batch.Draw(win)
+type InstanceBatch struct {
+	groups []*instanceGroup
+	lookup map[instanceKey]*instanceGroup
+}
+
+func NewInstanceBatch() *InstanceBatch {
+	return &InstanceBatch{
+		lookup: make(map[instanceKey]*instanceGroup),
+	}
+}
+
+func (b *InstanceBatch) Add(filler GeometryFiller, mat glMat4, mask RGBA, material Material, translucent bool) {
+	b.AddUV(filler, mat, mask, material, translucent, glm.R(0, 0, 1, 1))
+}
+
+// Adds an instance whose texture coordinates are remapped into uv. The filler's coordinates are scaled by the size
+// of uv and then offset by its min, so an atlas frame can be selected per instance while sharing the geometry
+func (b *InstanceBatch) AddUV(filler GeometryFiller, mat glMat4, mask RGBA, material Material, translucent bool, uv Rect) {
+	if filler == nil {
+		return
+	}
+	if mask.A == 0 {
+		return
+	} // discard b/c its completely transparent
+
+	if material.shader == nil || len(material.shader.instanceFmt) == 0 {
+		panic("InstanceBatch: the material's shader doesn't have any per instance attributes")
+	}
+
+	key := instanceKey{filler, material}
+	group, ok := b.lookup[key]
+	if !ok {
+		group = &instanceGroup{
+			key:  key,
+			pool: NewBufferPool(material.shader, 0),
+		}
+		b.lookup[key] = group
+		b.groups = append(b.groups, group)
+	}
+
+	for _, attr := range material.shader.instanceFmt {
+		switch attr.Swizzle {
+		case shaders.InstanceModel:
+			group.data = append(group.data, mat[:]...)
+		case shaders.InstanceColor:
+			group.data = append(group.data, float32(mask.R), float32(mask.G), float32(mask.B), float32(mask.A))
+		case shaders.InstanceUV:
+			size := uv.Size()
+			group.data = append(group.data, float32(uv.Min.X), float32(uv.Min.Y), float32(size.X), float32(size.Y))
+		default:
+			// Unknown per instance attributes are zeroed
+			for i := 0; i < attr.Size(); i++ {
+				group.data = append(group.data, 0)
+			}
+		}
+	}
+
+	bounds := transformBox(filler.Bounds(), mat.Mat4())
+	if group.count == 0 {
+		group.bounds = bounds
+	} else {
+		group.bounds = group.bounds.Union(bounds)
+	}
+	group.count++
+	group.translucent = group.translucent || translucent || mask.A != 1
+}
+
+// Removes every instance. Groups that weren't used since the last clear are released, along with their buffers
+func (b *InstanceBatch) Clear() {
+	groups := b.groups[:0]
+	for _, group := range b.groups {
+		if group.count == 0 {
+			delete(b.lookup, group.key)
+			group.pool.Destroy()
+			continue
+		}
+		group.data = group.data[:0]
+		group.count = 0
+		groups = append(groups, group)
+	}
+	clear(b.groups[len(groups):])
+	b.groups = groups
+}
+
+// Removes everything from the batch and deletes its buffers from the GPU. The batch can still be used afterwards
+func (b *InstanceBatch) Destroy() {
+	for _, group := range b.groups {
+		group.pool.Destroy()
+	}
+	clear(b.groups)
+	b.groups = b.groups[:0]
+	clear(b.lookup)
+}
+
+// Draws each group with one instanced draw call and then clears the batch
+func (b *InstanceBatch) Draw(target BatchTarget) {
+	for _, group := range b.groups {
+		if group.count == 0 {
+			continue
+		}
+
+		// Fill the shared geometry, then hand the instances to the buffer so that they remain valid if the target draws later
+		group.pool.NextFrame()
+		buffer := group.key.filler.Fill(group.pool, glMat4Ident, White)
+		buffer.setInstances(group.data, group.count)
+
+		group.filler = bufferFiller{buffer, group.bounds}
+		target.Add(&group.filler, glMat4Ident, White, group.key.material, group.translucent)
+	}
+
+	b.Clear()
+}
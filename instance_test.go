@@ -0,0 +1,140 @@
+//go:build headless
+// +build headless
+
+package glitch
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/unitoftime/flow/glm"
+)
+
+func TestHeadlessInstanceBatch(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	white := NewRGBATexture(4, 4, color.RGBA{255, 255, 255, 255}, false)
+	sprite := NewSprite(white, white.Bounds())
+	sprite.Material().SetShader(GetDefaultInstancedShader())
+
+	batch := NewInstanceBatch()
+	colors := []RGBA{{1, 0, 0, 1}, {0, 1, 0, 1}, {0, 0, 1, 1}}
+	for i, c := range colors {
+		mat := Mat4Ident
+		mat.Translate(float64(2+i*5), 8, 0)
+		sprite.DrawColorMask(batch, mat, c)
+	}
+
+	Clear(win, RGBA{})
+	GetMetrics()
+	batch.Draw(win)
+	win.Update()
+	metrics := GetMetrics()
+	if metrics.Draw != 1 {
+		t.Errorf("expected every instance in one draw call, got %d", metrics.Draw)
+	}
+
+	img := win.Image()
+	expected := []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}}
+	for i, want := range expected {
+		if got := img.RGBAAt(2+i*5, 16-1-8); got != want {
+			t.Errorf("instance %d: expected %v, got %v", i, want, got)
+		}
+	}
+
+	// Select half of a two color texture per instance
+	pixels := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	pixels.SetRGBA(0, 0, color.RGBA{255, 0, 0, 255})
+	pixels.SetRGBA(1, 0, color.RGBA{0, 255, 0, 255})
+	strip := NewTexture(pixels, false)
+	stripSprite := NewSprite(strip, strip.Bounds())
+	stripSprite.Material().SetShader(GetDefaultInstancedShader())
+
+	left, right := Mat4Ident, Mat4Ident
+	left.Scale(4, 4, 1).Translate(4, 8, 0)
+	right.Scale(4, 4, 1).Translate(12, 8, 0)
+	batch.AddUV(stripSprite.mesh, glm4(left), White, stripSprite.material, false, glm.R(0, 0, 0.5, 1))
+	batch.AddUV(stripSprite.mesh, glm4(right), White, stripSprite.material, false, glm.R(0.5, 0, 1, 1))
+
+	Clear(win, RGBA{})
+	batch.Draw(win)
+	win.Update()
+	img = win.Image()
+	if got := img.RGBAAt(4, 16-1-8); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("expected the left half of the texture, got %v", got)
+	}
+	if got := img.RGBAAt(12, 16-1-8); got != (color.RGBA{0, 255, 0, 255}) {
+		t.Errorf("expected the right half of the texture, got %v", got)
+	}
+}
+
+func TestHeadlessInstanceBatchRotated(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	white := NewRGBATexture(4, 4, color.RGBA{255, 255, 255, 255}, false)
+	defer white.Destroy()
+	sprite := NewSprite(white, white.Bounds())
+	sprite.Material().SetShader(GetDefaultInstancedShader())
+
+	// A diamond centered left of the camera with only its right tip in view. Its bounds must cover the rotated corners,
+	// or the sorter culls the group even though the tip is visible
+	batch := NewInstanceBatch()
+	defer batch.Destroy()
+	mat := Mat4Ident
+	mat.Scale(4, 4, 1).RotateZ(math.Pi/4).Translate(-6, 8, 0)
+	sprite.Draw(batch, mat)
+
+	sorter := NewSorter()
+	sorter.Cull = true
+	batch.Draw(sorter)
+	Clear(win, RGBA{})
+	sorter.Draw(win)
+	win.Update()
+	if got := win.Image().RGBAAt(2, 16-1-8); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("expected the rotated instance to be drawn, got %v", got)
+	}
+}
+
+func TestHeadlessInstanceBatchRelease(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	white := NewRGBATexture(4, 4, color.RGBA{255, 255, 255, 255}, false)
+	defer white.Destroy()
+	sprite := NewSprite(white, white.Bounds())
+
+	batch := NewInstanceBatch()
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected a material without instance attributes to panic")
+			}
+		}()
+		sprite.Draw(batch, Mat4Ident)
+	}()
+
+	SetLeakTracking(true)
+	defer SetLeakTracking(false)
+
+	sprite.Material().SetShader(GetDefaultInstancedShader())
+	sprite.Draw(batch, Mat4Ident)
+	batch.Draw(win)
+	if len(TrackedResources()) == 0 {
+		t.Fatalf("expected the group's buffers to be tracked")
+	}
+
+	// The group wasn't used since the last clear, so clearing again releases it
+	batch.Clear()
+	if left := TrackedResources(); len(left) != 0 {
+		t.Errorf("expected clearing an unused group to release its buffers, got %d resources", len(left))
+	}
+
+	sprite.Draw(batch, Mat4Ident)
+	batch.Draw(win)
+	batch.Destroy()
+	if left := TrackedResources(); len(left) != 0 {
+		t.Errorf("expected destroy to release the buffers, got %d resources", len(left))
+	}
+	win.Update()
+}
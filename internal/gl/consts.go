@@ -40,12 +40,31 @@ const (
 // TODO - regenerate these? for webgl2
 // https://www.khronos.org/registry/webgl/specs/latest/2.0/#3.7
 const (
-	LINE = 0x1B01
-	FILL = 0x1B02
+	POINT = 0x1B00
+	LINE  = 0x1B01
+	FILL  = 0x1B02
+
+	STENCIL_INDEX = 0x1901
 
 	DEPTH_COMPONENT24        = 0x81A6
+	RGBA8                    = 0x8058
+	R8                       = 0x8229
+	RGBA16F                  = 0x881A
+	RGBA32F                  = 0x8814
+	RG                       = 0x8227
+	RG8                      = 0x822B
+	R16F                     = 0x822D
+	R32F                     = 0x822E
+	RG16F                    = 0x822F
+	RG32F                    = 0x8230
+	HALF_FLOAT               = 0x140B
+	TEXTURE_MAX_LEVEL        = 0x813D
+	MAX_SAMPLES              = 0x8D57
+	MAX_DRAW_BUFFERS         = 0x8824
 	DEPTH_COMPONENT32F       = 0x8CAC
 	TEXTURE_BORDER_COLOR     = 0x1004
+	TEXTURE_MAX_ANISOTROPY   = 0x84FE /* EXT_texture_filter_anisotropic */
+	MAX_TEXTURE_ANISOTROPY   = 0x84FF
 	READ_FRAMEBUFFER         = 0x8CA8
 	DRAW_FRAMEBUFFER         = 0x8CA9
 	READ_FRAMEBUFFER_BINDING = 0x8CAA
@@ -60,6 +79,10 @@ const (
 	TRIANGLES                                    = 0x0004
 	TRIANGLE_STRIP                               = 0x0005
 	TRIANGLE_FAN                                 = 0x0006
+	PROGRAM_POINT_SIZE                           = 0x8642
+	TIME_ELAPSED                                 = 0x88BF
+	QUERY_RESULT                                 = 0x8866
+	QUERY_RESULT_AVAILABLE                       = 0x8867
 	SRC_COLOR                                    = 0x0300
 	ONE_MINUS_SRC_COLOR                          = 0x0301
 	SRC_ALPHA                                    = 0x0302
@@ -70,6 +93,8 @@ const (
 	ONE_MINUS_DST_COLOR                          = 0x0307
 	SRC_ALPHA_SATURATE                           = 0x0308
 	FUNC_ADD                                     = 0x8006
+	MIN                                          = 0x8007
+	MAX                                          = 0x8008
 	BLEND_EQUATION                               = 0x8009
 	BLEND_EQUATION_RGB                           = 0x8009
 	BLEND_EQUATION_ALPHA                         = 0x883D
@@ -319,6 +344,10 @@ const (
 	COLOR_ATTACHMENT0                            = 0x8CE0
 	DEPTH_ATTACHMENT                             = 0x8D00
 	STENCIL_ATTACHMENT                           = 0x8D20
+	DEPTH_STENCIL_ATTACHMENT                     = 0x821A
+	DEPTH_STENCIL                                = 0x84F9
+	DEPTH24_STENCIL8                             = 0x88F0
+	UNSIGNED_INT_24_8                            = 0x84FA
 	FRAMEBUFFER_COMPLETE                         = 0x8CD5
 	FRAMEBUFFER_INCOMPLETE_ATTACHMENT            = 0x8CD6
 	FRAMEBUFFER_INCOMPLETE_MISSING_ATTACHMENT    = 0x8CD7
@@ -371,3 +400,16 @@ const (
 	NO_ERROR = 0
 	NONE     = 0
 )
+
+// Block compressed texture formats. Each one needs an extension, see SupportsCompressedFormat
+const (
+	COMPRESSED_RGB_S3TC_DXT1_EXT  = 0x83F0 // BC1
+	COMPRESSED_RGBA_S3TC_DXT1_EXT = 0x83F1 // BC1 with 1 bit alpha
+	COMPRESSED_RGBA_S3TC_DXT3_EXT = 0x83F2 // BC2
+	COMPRESSED_RGBA_S3TC_DXT5_EXT = 0x83F3 // BC3
+	COMPRESSED_RED_RGTC1          = 0x8DBB // BC4
+	COMPRESSED_RG_RGTC2           = 0x8DBD // BC5
+	COMPRESSED_RGBA_BPTC_UNORM    = 0x8E8C // BC7
+	COMPRESSED_RGB8_ETC2          = 0x9274
+	COMPRESSED_RGBA8_ETC2_EAC     = 0x9278
+)
@@ -0,0 +1,1497 @@
+//go:build !js && headless
+// +build !js,headless
+
+package gl
+
+// This is a software implementation of the gl function set. It is selected with the `headless` build tag and rasterizes everything on the CPU so that rendering can be tested on machines without a GPU. It is not intended to be fast, it is intended to be correct enough that golden image tests produce the same pixels as a real driver would (within a small tolerance).
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+	"unsafe"
+)
+
+// ContextWatcher is this library's context watcher, satisfying glfw.ContextWatcher interface.
+// It must be notified when context is made current or detached.
+var ContextWatcher = new(contextWatcher)
+
+type contextWatcher struct {
+	initGL bool
+}
+
+// The headless window passes itself in as the context. We only need its framebuffer size to back the default framebuffer
+type framebufferSizer interface {
+	GetFramebufferSize() (int, int)
+}
+
+func (cw *contextWatcher) OnMakeCurrent(context interface{}) {
+	if !cw.initGL {
+		ctx = newSoftContext()
+		cw.initGL = true
+	}
+	if sizer, ok := context.(framebufferSizer); ok {
+		// Like a real context, the viewport and scissor box start out covering the window the first time it is made current
+		first := ctx.window == nil
+		ctx.window = sizer
+		width, height := sizer.GetFramebufferSize()
+		ctx.resizeDefaultFramebuffer(width, height)
+		if first {
+			ctx.viewport = [4]int{0, 0, width, height}
+			ctx.scissor = [4]int{0, 0, width, height}
+		}
+	}
+}
+func (contextWatcher) OnDetach() {}
+
+// TODO: right now I force you to make them 1 at a time
+func GenVertexArrays() Buffer {
+	id := ctx.genID()
+	ctx.vaos[id] = newSoftVAO()
+	return Buffer{id}
+}
+
+// TODO: right now I force you to make them 1 at a time
+func GenBuffers() Buffer {
+	return CreateBuffer()
+}
+
+func BindVertexArray(b Buffer) {
+	ctx.vao = b.Value
+}
+
+func DeleteBuffers(v Buffer) {
+	DeleteBuffer(v)
+}
+
+func DeleteVertexArrays(v Buffer) {
+	if ctx.vao == v.Value {
+		ctx.vao = 0
+	}
+	delete(ctx.vaos, v.Value)
+}
+
+func BufferData(target Enum, size int, data interface{}, usage Enum) {
+	buf := ctx.boundBuffer(target)
+	if buf == nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	buf.data = make([]byte, size)
+	buf.usage = usage
+	if data != nil {
+		copy(buf.data, toBytes(data))
+	}
+}
+
+func BufferDataImguiPassthrough(target Enum, size int, data unsafe.Pointer, usage Enum) {
+	buf := ctx.boundBuffer(target)
+	if buf == nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	buf.data = make([]byte, size)
+	buf.usage = usage
+	if data != nil && size > 0 {
+		copy(buf.data, unsafe.Slice((*byte)(data), size))
+	}
+}
+
+func BlitFramebuffer(srcX0 int32, srcY0 int32, srcX1 int32, srcY1 int32, dstX0 int32, dstY0 int32, dstX1 int32, dstY1 int32, mask uint32, filter uint32) {
+	ctx.blit(
+		[4]int{int(srcX0), int(srcY0), int(srcX1), int(srcY1)},
+		[4]int{int(dstX0), int(dstY0), int(dstX1), int(dstY1)},
+		Enum(mask), Enum(filter),
+	)
+}
+
+func DrawBuffer(target Enum) {
+	fb := ctx.framebuffer(DRAW_FRAMEBUFFER)
+	fb.drawBuffers = []Enum{target}
+}
+
+func DrawBuffers(targets []Enum) {
+	fb := ctx.framebuffer(DRAW_FRAMEBUFFER)
+	fb.drawBuffers = append([]Enum(nil), targets...)
+}
+
+func ReadBuffer(target Enum) {
+	fb := ctx.framebuffer(READ_FRAMEBUFFER)
+	fb.readBuffer = target
+}
+
+func VertexAttribPointer(dst Attrib, size int, ty Enum, normalized bool, stride int, offset int) {
+	ctx.vertexAttribPointer(dst, size, ty, normalized, false, stride, offset)
+}
+
+func VertexAttribIPointer(dst Attrib, size int, ty Enum, stride int, offset int) {
+	ctx.vertexAttribPointer(dst, size, ty, false, true, stride, offset)
+}
+
+func VertexAttribDivisor(dst Attrib, divisor int) {
+	if dst.Value < 0 || dst.Value >= maxVertexAttribs || divisor < 0 {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	ctx.currentVAO().attribs[dst.Value].divisor = divisor
+}
+
+func PolygonMode(face, mode Enum) {
+	ctx.polygonMode = mode
+}
+
+// ActiveTexture sets the active texture unit.
+func ActiveTexture(texture Enum) {
+	unit := int(texture - TEXTURE0)
+	if unit < 0 || unit >= len(ctx.units) {
+		ctx.setError(INVALID_ENUM)
+		return
+	}
+	ctx.activeUnit = unit
+}
+
+// AttachShader attaches a shader to a program.
+func AttachShader(p Program, s Shader) {
+	prog := ctx.programs[p.Value]
+	if prog == nil {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	prog.shaders = append(prog.shaders, s.Value)
+}
+
+// BindAttribLocation binds a vertex attribute index with a named
+// variable.
+func BindAttribLocation(p Program, a Attrib, name string) {
+	prog := ctx.programs[p.Value]
+	if prog == nil {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	prog.boundAttribs[name] = a.Value
+}
+
+// BindBuffer binds a buffer.
+func BindBuffer(target Enum, b Buffer) {
+	if b.Value != 0 && ctx.buffers[b.Value] == nil {
+		ctx.buffers[b.Value] = &softBuffer{}
+	}
+	switch target {
+	case ARRAY_BUFFER:
+		ctx.arrayBuffer = b.Value
+	case ELEMENT_ARRAY_BUFFER:
+		ctx.currentVAO().elementBuffer = b.Value
+	default:
+		ctx.otherBuffers[target] = b.Value
+	}
+}
+
+// BindFramebuffer binds a framebuffer.
+func BindFramebuffer(target Enum, fb Framebuffer) {
+	if fb.Value != 0 && ctx.framebuffers[fb.Value] == nil {
+		ctx.framebuffers[fb.Value] = newSoftFramebuffer()
+	}
+	switch target {
+	case FRAMEBUFFER:
+		ctx.readFramebuffer = fb.Value
+		ctx.drawFramebuffer = fb.Value
+	case READ_FRAMEBUFFER:
+		ctx.readFramebuffer = fb.Value
+	case DRAW_FRAMEBUFFER:
+		ctx.drawFramebuffer = fb.Value
+	default:
+		ctx.setError(INVALID_ENUM)
+	}
+}
+
+// BindRenderbuffer binds a render buffer.
+func BindRenderbuffer(target Enum, rb Renderbuffer) {
+	if rb.Value != 0 && ctx.renderbuffers[rb.Value] == nil {
+		ctx.renderbuffers[rb.Value] = newSoftTexture()
+	}
+	ctx.renderbuffer = rb.Value
+}
+
+// BindTexture binds a texture.
+func BindTexture(target Enum, t Texture) {
+	if t.Value != 0 && ctx.textures[t.Value] == nil {
+		ctx.textures[t.Value] = newSoftTexture()
+	}
+	ctx.units[ctx.activeUnit] = t.Value
+}
+
+// BlendColor sets the blend color.
+func BlendColor(red, green, blue, alpha float32) {
+	ctx.blendColor = [4]float32{red, green, blue, alpha}
+}
+
+// BlendEquation sets both RGB and alpha blend equations.
+func BlendEquation(mode Enum) {
+	BlendEquationSeparate(mode, mode)
+}
+
+// BlendEquationSeparate sets RGB and alpha blend equations separately.
+func BlendEquationSeparate(modeRGB, modeAlpha Enum) {
+	ctx.blendEquationRGB = modeRGB
+	ctx.blendEquationAlpha = modeAlpha
+}
+
+// BlendFunc sets the pixel blending factors.
+func BlendFunc(sfactor, dfactor Enum) {
+	BlendFuncSeparate(sfactor, dfactor, sfactor, dfactor)
+}
+
+// BlendFunc sets the pixel RGB and alpha blending factors separately.
+func BlendFuncSeparate(sfactorRGB, dfactorRGB, sfactorAlpha, dfactorAlpha Enum) {
+	ctx.blendSrcRGB = sfactorRGB
+	ctx.blendDstRGB = dfactorRGB
+	ctx.blendSrcAlpha = sfactorAlpha
+	ctx.blendDstAlpha = dfactorAlpha
+}
+
+// BufferInit creates a new unitialized data store for the bound buffer object.
+func BufferInit(target Enum, size int, usage Enum) {
+	BufferData(target, size, nil, usage)
+}
+
+// The anisotropy is stored on the texture but isn't used by the software sampler
+func MaxAnisotropy() float32 {
+	return 16
+}
+
+// The software sampler has no block decoders, so compressed textures always take the decompressing fallback
+func SupportsCompressedFormat(format Enum) bool {
+	return false
+}
+
+// The software buffers are plain memory that is read when drawing, so they can always be mapped
+func SupportsPersistentMapping() bool {
+	return true
+}
+
+func BufferStoragePersistent(target Enum, size int) []byte {
+	buf := ctx.boundBuffer(target)
+	if buf == nil {
+		ctx.setError(INVALID_OPERATION)
+		return nil
+	}
+	buf.data = make([]byte, size)
+	buf.usage = DYNAMIC_DRAW
+	return buf.data
+}
+
+// Draws finish before they return, so fences are always signaled
+func FenceSync() Sync {
+	return Sync{1}
+}
+
+func ClientWaitSync(sync Sync) {}
+
+func DeleteSync(sync Sync) {}
+
+// The software rasterizer draws synchronously, so timer queries measure the wall time between begin and end
+func SupportsTimerQuery() bool {
+	return true
+}
+
+func CreateQuery() Query {
+	id := ctx.genID()
+	ctx.queries[id] = &softQuery{}
+	return Query{id}
+}
+
+func DeleteQuery(q Query) {
+	delete(ctx.queries, q.Value)
+}
+
+func BeginQuery(target Enum, q Query) {
+	query := ctx.queries[q.Value]
+	if query == nil || ctx.activeQuery != nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	query.start = time.Now()
+	ctx.activeQuery = query
+}
+
+func EndQuery(target Enum) {
+	if ctx.activeQuery == nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	ctx.activeQuery.elapsed = time.Since(ctx.activeQuery.start)
+	ctx.activeQuery = nil
+}
+
+func QueryResultAvailable(q Query) bool {
+	return ctx.queries[q.Value] != nil && ctx.queries[q.Value] != ctx.activeQuery
+}
+
+func QueryResult(q Query) uint64 {
+	query := ctx.queries[q.Value]
+	if query == nil {
+		ctx.setError(INVALID_OPERATION)
+		return 0
+	}
+	return uint64(query.elapsed)
+}
+
+func BufferSubDataUint32(target Enum, offset int, data []uint32) {
+	BufferSubData(target, offset, data)
+}
+
+func BufferSubDataByte(target Enum, offset int, data []byte) {
+	BufferSubData(target, offset, data)
+}
+
+func BufferSubData(target Enum, offset int, data interface{}) {
+	buf := ctx.boundBuffer(target)
+	if buf == nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	src := toBytes(data)
+	if offset < 0 || offset+len(src) > len(buf.data) {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	copy(buf.data[offset:], src)
+}
+
+func GetBufferSubData(target Enum, offset int, data interface{}) {
+	buf := ctx.boundBuffer(target)
+	if buf == nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	switch t := data.(type) {
+	case *[]float32:
+		for i := range *t {
+			(*t)[i] = math.Float32frombits(binary.NativeEndian.Uint32(buf.data[offset+4*i:]))
+		}
+	case *[]byte:
+		copy(*t, buf.data[offset:])
+	default:
+		panic("Invalid data type!")
+	}
+}
+
+// CheckFramebufferStatus reports the completeness status of the
+// active framebuffer.
+func CheckFramebufferStatus(target Enum) Enum {
+	return ctx.framebuffer(target).status()
+}
+
+// Clear clears the window.
+//
+// The behavior of Clear is influenced by the pixel ownership test,
+// the scissor test, dithering, and the buffer writemasks.
+func Clear(mask Enum) {
+	ctx.clear(mask)
+}
+
+// ClearColor specifies the RGBA values used to clear color buffers.
+func ClearColor(red, green, blue, alpha float32) {
+	ctx.clearColor = [4]float32{red, green, blue, alpha}
+}
+
+// ClearDepthf sets the depth value used to clear the depth buffer.
+func ClearDepthf(d float32) {
+	ctx.clearDepth = clamp01(d)
+}
+
+// ClearStencil sets the index used to clear the stencil buffer.
+func ClearStencil(s int) {
+	ctx.clearStencil = s
+}
+
+// ColorMask specifies whether color components in the framebuffer
+// can be written.
+func ColorMask(red, green, blue, alpha bool) {
+	ctx.colorMask = [4]bool{red, green, blue, alpha}
+}
+
+// CompileShader compiles the source code of s.
+func CompileShader(s Shader) {
+	shader := ctx.shaders[s.Value]
+	if shader == nil {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	shader.compile()
+}
+
+// CompressedTexImage2D writes a compressed 2D texture.
+func CompressedTexImage2D(target Enum, level int, internalformat Enum, width, height, border int, data []byte) {
+	// Compressed formats can't be decoded by the software renderer
+	ctx.setError(INVALID_ENUM)
+}
+
+// CompressedTexSubImage2D writes a subregion of a compressed 2D texture.
+func CompressedTexSubImage2D(target Enum, level, xoffset, yoffset, width, height int, format Enum, data []byte) {
+	ctx.setError(INVALID_ENUM)
+}
+
+// CopyTexImage2D writes a 2D texture from the current framebuffer.
+func CopyTexImage2D(target Enum, level int, internalformat Enum, x, y, width, height, border int) {
+	tex := ctx.boundTexture()
+	if tex == nil || level != 0 {
+		return
+	}
+	tex.resize(internalformat, width, height)
+	ctx.copyToTexture(tex, 0, 0, x, y, width, height)
+}
+
+// CopyTexSubImage2D copies from the current framebuffer to an existing texture.
+func CopyTexSubImage2D(target Enum, level, xoffset, yoffset, x, y, width, height int) {
+	tex := ctx.boundTexture()
+	if tex == nil || level != 0 {
+		return
+	}
+	ctx.copyToTexture(tex, xoffset, yoffset, x, y, width, height)
+}
+
+// CreateBuffer creates a buffer object.
+func CreateBuffer() Buffer {
+	id := ctx.genID()
+	ctx.buffers[id] = &softBuffer{}
+	return Buffer{id}
+}
+
+// CreateFramebuffer creates a framebuffer object.
+func CreateFramebuffer() Framebuffer {
+	id := ctx.genID()
+	ctx.framebuffers[id] = newSoftFramebuffer()
+	return Framebuffer{id}
+}
+
+// CreateProgram creates a new empty program object.
+func CreateProgram() Program {
+	id := ctx.genID()
+	ctx.programs[id] = newSoftProgram()
+	return Program{id}
+}
+
+// CreateRenderbuffer create a renderbuffer object.
+func CreateRenderbuffer() Renderbuffer {
+	id := ctx.genID()
+	ctx.renderbuffers[id] = newSoftTexture()
+	return Renderbuffer{id}
+}
+
+// CreateShader creates a new empty shader object.
+func CreateShader(ty Enum) Shader {
+	id := ctx.genID()
+	ctx.shaders[id] = &softShader{ty: ty}
+	return Shader{id}
+}
+
+// CreateTexture creates a texture object.
+func CreateTexture() Texture {
+	id := ctx.genID()
+	ctx.textures[id] = newSoftTexture()
+	return Texture{id}
+}
+
+// CullFace specifies which polygons are candidates for culling.
+//
+// Valid modes: FRONT, BACK, FRONT_AND_BACK.
+func CullFace(mode Enum) {
+	ctx.cullFace = mode
+}
+
+// DeleteBuffer deletes the given buffer object.
+func DeleteBuffer(v Buffer) {
+	if ctx.arrayBuffer == v.Value {
+		ctx.arrayBuffer = 0
+	}
+	delete(ctx.buffers, v.Value)
+}
+
+// DeleteFramebuffer deletes the given framebuffer object.
+func DeleteFramebuffer(v Framebuffer) {
+	if v.Value == 0 {
+		return
+	}
+	if ctx.readFramebuffer == v.Value {
+		ctx.readFramebuffer = 0
+	}
+	if ctx.drawFramebuffer == v.Value {
+		ctx.drawFramebuffer = 0
+	}
+	delete(ctx.framebuffers, v.Value)
+}
+
+// DeleteProgram deletes the given program object.
+func DeleteProgram(p Program) {
+	if ctx.program == p.Value {
+		ctx.program = 0
+	}
+	delete(ctx.programs, p.Value)
+}
+
+// DeleteRenderbuffer deletes the given render buffer object.
+func DeleteRenderbuffer(v Renderbuffer) {
+	if ctx.renderbuffer == v.Value {
+		ctx.renderbuffer = 0
+	}
+	delete(ctx.renderbuffers, v.Value)
+}
+
+// DeleteShader deletes shader s.
+func DeleteShader(s Shader) {
+	delete(ctx.shaders, s.Value)
+}
+
+// DeleteTexture deletes the given texture object.
+func DeleteTexture(v Texture) {
+	for i := range ctx.units {
+		if ctx.units[i] == v.Value {
+			ctx.units[i] = 0
+		}
+	}
+	delete(ctx.textures, v.Value)
+}
+
+// DepthFunc sets the function used for depth buffer comparisons.
+func DepthFunc(fn Enum) {
+	ctx.depthFunc = fn
+}
+
+// DepthMask sets the depth buffer enabled for writing.
+func DepthMask(flag bool) {
+	ctx.depthMask = flag
+}
+
+// DepthRangef sets the mapping from normalized device coordinates to
+// window coordinates.
+func DepthRangef(n, f float32) {
+	ctx.depthRange = [2]float32{clamp01(n), clamp01(f)}
+}
+
+// DetachShader detaches the shader s from the program p.
+func DetachShader(p Program, s Shader) {
+	prog := ctx.programs[p.Value]
+	if prog == nil {
+		return
+	}
+	for i := range prog.shaders {
+		if prog.shaders[i] == s.Value {
+			prog.shaders = append(prog.shaders[:i], prog.shaders[i+1:]...)
+			return
+		}
+	}
+}
+
+// Disable disables various GL capabilities.
+func Disable(cap Enum) {
+	ctx.caps[cap] = false
+}
+
+// DisableVertexAttribArray disables a vertex attribute array.
+func DisableVertexAttribArray(a Attrib) {
+	if a.Value < 0 || a.Value >= maxVertexAttribs {
+		return
+	}
+	ctx.currentVAO().attribs[a.Value].enabled = false
+}
+
+// DrawArrays renders geometric primitives from the bound data.
+func DrawArrays(mode Enum, first, count int) {
+	ctx.drawArrays(mode, first, count)
+}
+
+// DrawElements renders primitives from a bound buffer.
+func DrawElements(mode Enum, count int, ty Enum, offset int) {
+	ctx.drawElements(mode, count, ty, offset, 1)
+}
+
+// DrawElementsInstanced renders instances copies of the primitives in the bound element array buffer.
+func DrawElementsInstanced(mode Enum, count int, ty Enum, offset int, instances int) {
+	ctx.drawElements(mode, count, ty, offset, instances)
+}
+
+// Enable enables various GL capabilities.
+func Enable(cap Enum) {
+	ctx.caps[cap] = true
+}
+
+// Point sizes always come from the vertex shader in the software rasterizer
+func EnableProgramPointSize() {}
+
+// EnableVertexAttribArray enables a vertex attribute array.
+func EnableVertexAttribArray(a Attrib) {
+	if a.Value < 0 || a.Value >= maxVertexAttribs {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	ctx.currentVAO().attribs[a.Value].enabled = true
+}
+
+// Finish blocks until the effects of all previously called GL
+// commands are complete.
+//
+// Everything is rendered synchronously, so this is a no-op
+func Finish() {}
+
+// Flush empties all buffers.
+func Flush() {}
+
+// FramebufferRenderbuffer attaches rb to the current frame buffer.
+func FramebufferRenderbuffer(target, attachment, rbTarget Enum, rb Renderbuffer) {
+	ctx.framebuffer(target).attach(attachment, ctx.renderbuffers[rb.Value])
+}
+
+// FramebufferTexture2D attaches the t to the current frame buffer.
+func FramebufferTexture2D(target, attachment, texTarget Enum, t Texture, level int) {
+	ctx.framebuffer(target).attach(attachment, ctx.textures[t.Value])
+}
+
+// FrontFace defines which polygons are front-facing.
+//
+// Valid modes: CW, CCW.
+func FrontFace(mode Enum) {
+	ctx.frontFace = mode
+}
+
+// GenerateMipmap generates mipmaps for the current texture.
+//
+// The software renderer doesn't keep a mip chain, minification is approximated by the filter instead
+func GenerateMipmap(target Enum) {}
+
+// GetActiveAttrib returns details about an active attribute variable.
+func GetActiveAttrib(p Program, index uint32) (name string, size int, ty Enum) {
+	prog := ctx.programs[p.Value]
+	if prog == nil || int(index) >= len(prog.attribs) {
+		ctx.setError(INVALID_VALUE)
+		return "", 0, 0
+	}
+	a := prog.attribs[index]
+	return a.name, 1, a.ty
+}
+
+// GetActiveUniform returns details about an active uniform variable.
+func GetActiveUniform(p Program, index uint32) (name string, size int, ty Enum) {
+	prog := ctx.programs[p.Value]
+	if prog == nil || int(index) >= len(prog.uniforms) {
+		ctx.setError(INVALID_VALUE)
+		return "", 0, 0
+	}
+	u := prog.uniforms[index]
+	return u.name, u.count, u.ty
+}
+
+// GetAttachedShaders returns the shader objects attached to program p.
+func GetAttachedShaders(p Program) []Shader {
+	prog := ctx.programs[p.Value]
+	if prog == nil {
+		return nil
+	}
+	shaders := make([]Shader, len(prog.shaders))
+	for i, s := range prog.shaders {
+		shaders[i] = Shader{Value: s}
+	}
+	return shaders
+}
+
+// GetAttribLocation returns the location of an attribute variable.
+func GetAttribLocation(p Program, name string) Attrib {
+	prog := ctx.programs[p.Value]
+	if prog == nil {
+		return Attrib{Value: -1}
+	}
+	for _, a := range prog.attribs {
+		if a.name == name {
+			return Attrib{Value: a.location}
+		}
+	}
+	return Attrib{Value: -1}
+}
+
+// GetBooleanv returns the boolean values of parameter pname.
+func GetBooleanv(dst []bool, pname Enum) {
+	switch pname {
+	case COLOR_WRITEMASK:
+		copy(dst, ctx.colorMask[:])
+	case DEPTH_WRITEMASK:
+		dst[0] = ctx.depthMask
+	default:
+		dst[0] = ctx.caps[pname]
+	}
+}
+
+// GetFloatv returns the float values of parameter pname.
+func GetFloatv(dst []float32, pname Enum) {
+	switch pname {
+	case COLOR_CLEAR_VALUE:
+		copy(dst, ctx.clearColor[:])
+	case BLEND_COLOR:
+		copy(dst, ctx.blendColor[:])
+	case DEPTH_CLEAR_VALUE:
+		dst[0] = ctx.clearDepth
+	case DEPTH_RANGE:
+		copy(dst, ctx.depthRange[:])
+	case LINE_WIDTH:
+		dst[0] = ctx.lineWidth
+	default:
+		tmp := make([]int32, len(dst))
+		GetIntegerv(pname, tmp)
+		for i := range tmp {
+			dst[i] = float32(tmp[i])
+		}
+	}
+}
+
+// GetIntegerv returns the int values of parameter pname.
+//
+// Single values may be queried more easily using GetInteger.
+func GetIntegerv(pname Enum, data []int32) {
+	switch pname {
+	case VIEWPORT:
+		for i := range ctx.viewport {
+			data[i] = int32(ctx.viewport[i])
+		}
+	case SCISSOR_BOX:
+		for i := range ctx.scissor {
+			data[i] = int32(ctx.scissor[i])
+		}
+	default:
+		data[0] = ctx.getInteger(pname)
+	}
+}
+
+// GetInteger returns the int value of parameter pname.
+func GetInteger(pname Enum) Object {
+	return Object{uint32(ctx.getInteger(pname))}
+}
+
+// GetBufferParameteri returns a parameter for the active buffer.
+func GetBufferParameteri(target, pname Enum) int {
+	buf := ctx.boundBuffer(target)
+	if buf == nil {
+		ctx.setError(INVALID_OPERATION)
+		return 0
+	}
+	switch pname {
+	case BUFFER_SIZE:
+		return len(buf.data)
+	case BUFFER_USAGE:
+		return int(buf.usage)
+	}
+	ctx.setError(INVALID_ENUM)
+	return 0
+}
+
+// GetError returns the next error.
+func GetError() Enum {
+	err := ctx.err
+	ctx.err = NO_ERROR
+	return err
+}
+
+// GetBoundFramebuffer returns the currently bound framebuffer.
+// Use this method instead of gl.GetInteger(gl.FRAMEBUFFER_BINDING) to
+// enable support on all platforms
+func GetBoundFramebuffer() Framebuffer {
+	return Framebuffer{Value: ctx.drawFramebuffer}
+}
+
+// GetFramebufferAttachmentParameteri returns attachment parameters
+// for the active framebuffer object.
+func GetFramebufferAttachmentParameteri(target, attachment, pname Enum) int {
+	fb := ctx.framebuffer(target)
+	tex := fb.attachment(attachment)
+	switch pname {
+	case FRAMEBUFFER_ATTACHMENT_OBJECT_TYPE:
+		if tex == nil {
+			return NONE
+		}
+		return TEXTURE
+	case FRAMEBUFFER_ATTACHMENT_OBJECT_NAME:
+		for id, t := range ctx.textures {
+			if t == tex {
+				return int(id)
+			}
+		}
+		for id, t := range ctx.renderbuffers {
+			if t == tex {
+				return int(id)
+			}
+		}
+	}
+	return 0
+}
+
+// GetProgrami returns a parameter value for a program.
+func GetProgrami(p Program, pname Enum) int {
+	prog := ctx.programs[p.Value]
+	if prog == nil {
+		ctx.setError(INVALID_VALUE)
+		return 0
+	}
+	switch pname {
+	case LINK_STATUS:
+		if prog.linked {
+			return TRUE
+		}
+		return FALSE
+	case VALIDATE_STATUS:
+		return TRUE
+	case INFO_LOG_LENGTH:
+		return len(prog.log)
+	case ATTACHED_SHADERS:
+		return len(prog.shaders)
+	case ACTIVE_ATTRIBUTES:
+		return len(prog.attribs)
+	case ACTIVE_UNIFORMS:
+		return len(prog.uniforms)
+	}
+	ctx.setError(INVALID_ENUM)
+	return 0
+}
+
+// GetProgramInfoLog returns the information log for a program.
+func GetProgramInfoLog(p Program) string {
+	prog := ctx.programs[p.Value]
+	if prog == nil {
+		return ""
+	}
+	return prog.log
+}
+
+// GetRenderbufferParameteri returns a parameter value for a render buffer.
+func GetRenderbufferParameteri(target, pname Enum) int {
+	rb := ctx.renderbuffers[ctx.renderbuffer]
+	if rb == nil {
+		ctx.setError(INVALID_OPERATION)
+		return 0
+	}
+	switch pname {
+	case RENDERBUFFER_WIDTH:
+		return rb.width
+	case RENDERBUFFER_HEIGHT:
+		return rb.height
+	case RENDERBUFFER_INTERNAL_FORMAT:
+		return int(rb.format)
+	}
+	return 0
+}
+
+// GetShaderi returns a parameter value for a shader.
+func GetShaderi(s Shader, pname Enum) int {
+	shader := ctx.shaders[s.Value]
+	if shader == nil {
+		ctx.setError(INVALID_VALUE)
+		return 0
+	}
+	switch pname {
+	case SHADER_TYPE:
+		return int(shader.ty)
+	case COMPILE_STATUS:
+		if shader.compiled {
+			return TRUE
+		}
+		return FALSE
+	case INFO_LOG_LENGTH:
+		return len(shader.log)
+	case SHADER_SOURCE_LENGTH:
+		return len(shader.source)
+	}
+	ctx.setError(INVALID_ENUM)
+	return 0
+}
+
+// GetShaderInfoLog returns the information log for a shader.
+func GetShaderInfoLog(s Shader) string {
+	shader := ctx.shaders[s.Value]
+	if shader == nil {
+		return ""
+	}
+	return shader.log
+}
+
+// GetShaderPrecisionFormat returns range and precision limits for
+// shader types.
+func GetShaderPrecisionFormat(shadertype, precisiontype Enum) (rangeLow, rangeHigh, precision int) {
+	// Everything is evaluated with float32
+	return 127, 127, 23
+}
+
+// GetShaderSource returns source code of shader s.
+func GetShaderSource(s Shader) string {
+	shader := ctx.shaders[s.Value]
+	if shader == nil {
+		return ""
+	}
+	return shader.source
+}
+
+// GetString reports current GL state.
+//
+// Valid name values:
+//
+//	EXTENSIONS
+//	RENDERER
+//	SHADING_LANGUAGE_VERSION
+//	VENDOR
+//	VERSION
+func GetString(pname Enum) string {
+	switch pname {
+	case VENDOR:
+		return "glitch"
+	case RENDERER:
+		return "glitch software rasterizer"
+	case VERSION:
+		return "3.3 headless"
+	case SHADING_LANGUAGE_VERSION:
+		return "3.30 headless"
+	case EXTENSIONS:
+		return ""
+	}
+	ctx.setError(INVALID_ENUM)
+	return ""
+}
+
+// GetTexParameterfv returns the float values of a texture parameter.
+func GetTexParameterfv(dst []float32, target, pname Enum) {
+	tmp := make([]int32, len(dst))
+	GetTexParameteriv(tmp, target, pname)
+	for i := range tmp {
+		dst[i] = float32(tmp[i])
+	}
+}
+
+// GetTexParameteriv returns the int values of a texture parameter.
+func GetTexParameteriv(dst []int32, target, pname Enum) {
+	tex := ctx.boundTexture()
+	if tex == nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	switch pname {
+	case TEXTURE_MIN_FILTER:
+		dst[0] = int32(tex.minFilter)
+	case TEXTURE_MAG_FILTER:
+		dst[0] = int32(tex.magFilter)
+	case TEXTURE_WRAP_S:
+		dst[0] = int32(tex.wrapS)
+	case TEXTURE_WRAP_T:
+		dst[0] = int32(tex.wrapT)
+	case TEXTURE_MAX_ANISOTROPY:
+		dst[0] = int32(tex.anisotropy)
+	default:
+		ctx.setError(INVALID_ENUM)
+	}
+}
+
+// GetUniformfv returns the float values of a uniform variable.
+func GetUniformfv(dst []float32, src Uniform, p Program) {
+	prog := ctx.programs[p.Value]
+	if prog == nil {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	copy(dst, prog.uniformValue(src.Value))
+}
+
+// GetUniformiv returns the float values of a uniform variable.
+func GetUniformiv(dst []int32, src Uniform, p Program) {
+	prog := ctx.programs[p.Value]
+	if prog == nil {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	for i, v := range prog.uniformValue(src.Value) {
+		if i >= len(dst) {
+			break
+		}
+		dst[i] = int32(v)
+	}
+}
+
+// GetUniformLocation returns the location of a uniform variable.
+func GetUniformLocation(p Program, name string) Uniform {
+	prog := ctx.programs[p.Value]
+	if prog == nil {
+		ctx.setError(INVALID_VALUE)
+		return Uniform{Value: -1}
+	}
+	return Uniform{Value: prog.uniformLocation(name)}
+}
+
+// GetVertexAttribf reads the float value of a vertex attribute.
+func GetVertexAttribf(src Attrib, pname Enum) float32 {
+	dst := make([]float32, 4)
+	GetVertexAttribfv(dst, src, pname)
+	return dst[0]
+}
+
+// GetVertexAttribfv reads float values of a vertex attribute.
+func GetVertexAttribfv(dst []float32, src Attrib, pname Enum) {
+	if src.Value < 0 || src.Value >= maxVertexAttribs {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	if pname == CURRENT_VERTEX_ATTRIB {
+		copy(dst, ctx.currentAttribs[src.Value][:])
+		return
+	}
+	dst[0] = float32(GetVertexAttribi(src, pname))
+}
+
+// GetVertexAttribi reads the int value of a vertex attribute.
+func GetVertexAttribi(src Attrib, pname Enum) int32 {
+	if src.Value < 0 || src.Value >= maxVertexAttribs {
+		ctx.setError(INVALID_VALUE)
+		return 0
+	}
+	a := ctx.currentVAO().attribs[src.Value]
+	switch pname {
+	case VERTEX_ATTRIB_ARRAY_ENABLED:
+		if a.enabled {
+			return TRUE
+		}
+		return FALSE
+	case VERTEX_ATTRIB_ARRAY_SIZE:
+		return int32(a.size)
+	case VERTEX_ATTRIB_ARRAY_STRIDE:
+		return int32(a.stride)
+	case VERTEX_ATTRIB_ARRAY_TYPE:
+		return int32(a.ty)
+	case VERTEX_ATTRIB_ARRAY_NORMALIZED:
+		if a.normalized {
+			return TRUE
+		}
+		return FALSE
+	case VERTEX_ATTRIB_ARRAY_BUFFER_BINDING:
+		return int32(a.buffer)
+	}
+	ctx.setError(INVALID_ENUM)
+	return 0
+}
+
+// GetVertexAttribiv reads the int values of a vertex attribute.
+func GetVertexAttribiv(dst []int32, src Attrib, pname Enum) {
+	dst[0] = GetVertexAttribi(src, pname)
+}
+
+// Hint sets implementation-specific modes.
+func Hint(target, mode Enum) {}
+
+// IsBuffer reports if b is a valid buffer.
+func IsBuffer(b Buffer) bool {
+	_, ok := ctx.buffers[b.Value]
+	return ok
+}
+
+// IsEnabled reports if cap is an enabled capability.
+func IsEnabled(cap Enum) bool {
+	return ctx.caps[cap]
+}
+
+// IsFramebuffer reports if fb is a valid frame buffer.
+func IsFramebuffer(fb Framebuffer) bool {
+	_, ok := ctx.framebuffers[fb.Value]
+	return ok
+}
+
+// IsProgram reports if p is a valid program object.
+func IsProgram(p Program) bool {
+	_, ok := ctx.programs[p.Value]
+	return ok
+}
+
+// IsRenderbuffer reports if rb is a valid render buffer.
+func IsRenderbuffer(rb Renderbuffer) bool {
+	_, ok := ctx.renderbuffers[rb.Value]
+	return ok
+}
+
+// IsShader reports if s is valid shader.
+func IsShader(s Shader) bool {
+	_, ok := ctx.shaders[s.Value]
+	return ok
+}
+
+// IsTexture reports if t is a valid texture.
+func IsTexture(t Texture) bool {
+	_, ok := ctx.textures[t.Value]
+	return ok
+}
+
+// LineWidth specifies the width of lines.
+func LineWidth(width float32) {
+	ctx.lineWidth = width
+}
+
+// LinkProgram links the specified program.
+func LinkProgram(p Program) {
+	prog := ctx.programs[p.Value]
+	if prog == nil {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	prog.link(ctx.shaders)
+}
+
+// PixelStorei sets pixel storage parameters.
+func PixelStorei(pname Enum, param int32) {
+	switch pname {
+	case UNPACK_ALIGNMENT:
+		ctx.unpackAlignment = int(param)
+	case PACK_ALIGNMENT:
+		ctx.packAlignment = int(param)
+	}
+}
+
+// PolygonOffset sets the scaling factors for depth offsets.
+func PolygonOffset(factor, units float32) {
+	ctx.polygonOffset = [2]float32{factor, units}
+}
+
+// ReadPixels returns pixel data from a buffer.
+//
+// In GLES 3, the source buffer is controlled with ReadBuffer.
+func ReadPixels(dst []byte, x, y, width, height int, format, ty Enum) {
+	ctx.readPixels(dst, x, y, width, height, format, ty)
+}
+
+// ReleaseShaderCompiler frees resources allocated by the shader compiler.
+func ReleaseShaderCompiler() {}
+
+// RenderbufferStorage establishes the data storage, format, and
+// dimensions of a renderbuffer object's image.
+func RenderbufferStorage(target, internalFormat Enum, width, height int) {
+	rb := ctx.renderbuffers[ctx.renderbuffer]
+	if rb == nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	rb.resize(internalFormat, width, height)
+}
+
+// RenderbufferStorageMultisample establishes the data storage of a multisampled renderbuffer.
+// The software renderer doesn't multisample, so the renderbuffer is stored with a single sample
+func RenderbufferStorageMultisample(target Enum, samples int, internalFormat Enum, width, height int) {
+	RenderbufferStorage(target, internalFormat, width, height)
+}
+
+// SampleCoverage sets multisample coverage parameters.
+func SampleCoverage(value float32, invert bool) {}
+
+// Scissor defines the scissor box rectangle, in window coordinates.
+func Scissor(x, y, width, height int32) {
+	ctx.scissor = [4]int{int(x), int(y), int(width), int(height)}
+}
+
+// ShaderSource sets the source code of s to the given source code.
+func ShaderSource(s Shader, src string) {
+	shader := ctx.shaders[s.Value]
+	if shader == nil {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	shader.source = src
+}
+
+// StencilFunc sets the front and back stencil test reference value.
+func StencilFunc(fn Enum, ref int, mask uint32) {
+	StencilFuncSeparate(FRONT_AND_BACK, fn, ref, mask)
+}
+
+// StencilFuncSeparate sets the front or back stencil test reference value.
+func StencilFuncSeparate(face, fn Enum, ref int, mask uint32) {
+	for _, s := range ctx.stencilFaces(face) {
+		s.fn = fn
+		s.ref = ref
+		s.valueMask = mask
+	}
+}
+
+// StencilMask controls the writing of bits in the stencil planes.
+func StencilMask(mask uint32) {
+	StencilMaskSeparate(FRONT_AND_BACK, mask)
+}
+
+// StencilMaskSeparate controls the writing of bits in the stencil planes.
+func StencilMaskSeparate(face Enum, mask uint32) {
+	for _, s := range ctx.stencilFaces(face) {
+		s.writeMask = mask
+	}
+}
+
+// StencilOp sets front and back stencil test actions.
+func StencilOp(fail, zfail, zpass Enum) {
+	StencilOpSeparate(FRONT_AND_BACK, fail, zfail, zpass)
+}
+
+// StencilOpSeparate sets front or back stencil tests.
+func StencilOpSeparate(face, sfail, dpfail, dppass Enum) {
+	for _, s := range ctx.stencilFaces(face) {
+		s.fail = sfail
+		s.zfail = dpfail
+		s.zpass = dppass
+	}
+}
+
+// TexImage2D writes a 2D texture image.
+func TexImage2D(target Enum, level int, width, height int, format Enum, ty Enum, data []byte) {
+	TexImage2DFull(target, level, format, width, height, format, ty, data)
+}
+
+func TexImage2DFull(target Enum, level int, format1 Enum, width, height int, format Enum, ty Enum, data []byte) {
+	tex := ctx.boundTexture()
+	if tex == nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	if level != 0 {
+		// There is no mip chain, so only the base level is kept
+		return
+	}
+	tex.resize(format1, width, height)
+	if data != nil {
+		tex.upload(0, 0, width, height, format, ty, data, ctx.unpackAlignment)
+	}
+}
+
+func TexImage2DFullImguiPassthrough(target Enum, level int, format1 Enum, width, height int, format Enum, ty Enum, p unsafe.Pointer) {
+	var data []byte
+	if p != nil {
+		data = unsafe.Slice((*byte)(p), width*height*pixelSize(format, ty))
+	}
+	TexImage2DFull(target, level, format1, width, height, format, ty, data)
+}
+
+// TexSubImage2D writes a subregion of a 2D texture image.
+func TexSubImage2D(target Enum, level int, x, y, width, height int, format, ty Enum, data []byte) {
+	tex := ctx.boundTexture()
+	if tex == nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	if level != 0 {
+		return
+	}
+	if x < 0 || y < 0 || x+width > tex.width || y+height > tex.height {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	tex.upload(x, y, width, height, format, ty, data, ctx.unpackAlignment)
+}
+
+// TexParameterf sets a float texture parameter.
+func TexParameterf(target, pname Enum, param float32) {
+	if pname == TEXTURE_MAX_ANISOTROPY {
+		tex := ctx.boundTexture()
+		if tex == nil {
+			ctx.setError(INVALID_OPERATION)
+			return
+		}
+		tex.anisotropy = param
+		return
+	}
+	TexParameteri(target, pname, int(param))
+}
+
+// TexParameterfv sets a float texture parameter array.
+func TexParameterfv(target, pname Enum, params []float32) {
+	tex := ctx.boundTexture()
+	if tex == nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	if pname == TEXTURE_BORDER_COLOR {
+		copy(tex.borderColor[:], params)
+		return
+	}
+	TexParameterf(target, pname, params[0])
+}
+
+// TexParameteri sets an integer texture parameter.
+func TexParameteri(target, pname Enum, param int) {
+	tex := ctx.boundTexture()
+	if tex == nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	switch pname {
+	case TEXTURE_MIN_FILTER:
+		tex.minFilter = Enum(param)
+	case TEXTURE_MAG_FILTER:
+		tex.magFilter = Enum(param)
+	case TEXTURE_WRAP_S:
+		tex.wrapS = Enum(param)
+	case TEXTURE_WRAP_T:
+		tex.wrapT = Enum(param)
+	}
+}
+
+// TexParameteriv sets an integer texture parameter array.
+func TexParameteriv(target, pname Enum, params []int32) {
+	TexParameteri(target, pname, int(params[0]))
+}
+
+// Uniform1f writes a float uniform variable.
+func Uniform1f(dst Uniform, v float32) {
+	ctx.setUniform(dst, 1, []float32{v})
+}
+
+// Uniform1fv writes a [len(src)]float uniform array.
+func Uniform1fv(dst Uniform, src []float32) {
+	ctx.setUniform(dst, 1, src)
+}
+
+// Uniform1i writes an int uniform variable.
+//
+// Uniform1i and Uniform1iv are the only two functions that may be used
+// to load uniform variables defined as sampler types. Loading samplers
+// with any other function will result in a INVALID_OPERATION error.
+func Uniform1i(dst Uniform, v int) {
+	ctx.setUniform(dst, 1, []float32{float32(v)})
+}
+
+// Uniform1iv writes a int uniform array of len(src) elements.
+func Uniform1iv(dst Uniform, src []int32) {
+	ctx.setUniform(dst, 1, intsToFloats(src))
+}
+
+// Uniform2f writes a vec2 uniform variable.
+func Uniform2f(dst Uniform, v0, v1 float32) {
+	ctx.setUniform(dst, 2, []float32{v0, v1})
+}
+
+// Uniform2fv writes a vec2 uniform array of len(src)/2 elements.
+func Uniform2fv(dst Uniform, src []float32) {
+	ctx.setUniform(dst, 2, src)
+}
+
+// Uniform2i writes an ivec2 uniform variable.
+func Uniform2i(dst Uniform, v0, v1 int) {
+	ctx.setUniform(dst, 2, []float32{float32(v0), float32(v1)})
+}
+
+// Uniform2iv writes an ivec2 uniform array of len(src)/2 elements.
+func Uniform2iv(dst Uniform, src []int32) {
+	ctx.setUniform(dst, 2, intsToFloats(src))
+}
+
+// Uniform3f writes a vec3 uniform variable.
+func Uniform3f(dst Uniform, v0, v1, v2 float32) {
+	ctx.setUniform(dst, 3, []float32{v0, v1, v2})
+}
+
+// Uniform3fv writes a vec3 uniform array of len(src)/3 elements.
+func Uniform3fv(dst Uniform, src []float32) {
+	ctx.setUniform(dst, 3, src)
+}
+
+// Uniform3i writes an ivec3 uniform variable.
+func Uniform3i(dst Uniform, v0, v1, v2 int32) {
+	ctx.setUniform(dst, 3, []float32{float32(v0), float32(v1), float32(v2)})
+}
+
+// Uniform3iv writes an ivec3 uniform array of len(src)/3 elements.
+func Uniform3iv(dst Uniform, src []int32) {
+	ctx.setUniform(dst, 3, intsToFloats(src))
+}
+
+// Uniform4f writes a vec4 uniform variable.
+func Uniform4f(dst Uniform, v0, v1, v2, v3 float32) {
+	ctx.setUniform(dst, 4, []float32{v0, v1, v2, v3})
+}
+
+// Uniform4fv writes a vec4 uniform array of len(src)/4 elements.
+func Uniform4fv(dst Uniform, src []float32) {
+	ctx.setUniform(dst, 4, src)
+}
+
+// Uniform4i writes an ivec4 uniform variable.
+func Uniform4i(dst Uniform, v0, v1, v2, v3 int32) {
+	ctx.setUniform(dst, 4, []float32{float32(v0), float32(v1), float32(v2), float32(v3)})
+}
+
+// Uniform4iv writes an ivec4 uniform array of len(src)/4 elements.
+func Uniform4iv(dst Uniform, src []int32) {
+	ctx.setUniform(dst, 4, intsToFloats(src))
+}
+
+// UniformMatrix2fv writes 2x2 matrices. Each matrix uses four
+// float32 values, so the number of matrices written is len(src)/4.
+//
+// Each matrix must be supplied in column major order.
+func UniformMatrix2fv(dst Uniform, src []float32) {
+	ctx.setUniform(dst, 4, src)
+}
+
+// UniformMatrix3fv writes 3x3 matrices. Each matrix uses nine
+// float32 values, so the number of matrices written is count.
+//
+// Each matrix must be supplied in column major order.
+func UniformMatrix3fv(dst Uniform, count int32, transpose bool, value *float32) {
+	src := unsafe.Slice(value, int(count)*9)
+	ctx.setUniform(dst, 9, src)
+}
+
+// UniformMatrix4fv writes 4x4 matrices. Each matrix uses 16
+// float32 values, so the number of matrices written is len(src)/16.
+//
+// Each matrix must be supplied in column major order.
+func UniformMatrix4fv(dst Uniform, src []float32) {
+	ctx.setUniform(dst, 16, src)
+}
+
+// UseProgram sets the active program.
+func UseProgram(p Program) {
+	ctx.program = p.Value
+}
+
+// ValidateProgram checks to see whether the executables contained in
+// program can execute given the current OpenGL state.
+func ValidateProgram(p Program) {}
+
+// VertexAttrib1f writes a float vertex attribute.
+func VertexAttrib1f(dst Attrib, x float32) {
+	ctx.setCurrentAttrib(dst, []float32{x})
+}
+
+// VertexAttrib1fv writes a float vertex attribute.
+func VertexAttrib1fv(dst Attrib, src []float32) {
+	ctx.setCurrentAttrib(dst, src[:1])
+}
+
+// VertexAttrib2f writes a vec2 vertex attribute.
+func VertexAttrib2f(dst Attrib, x, y float32) {
+	ctx.setCurrentAttrib(dst, []float32{x, y})
+}
+
+// VertexAttrib2fv writes a vec2 vertex attribute.
+func VertexAttrib2fv(dst Attrib, src []float32) {
+	ctx.setCurrentAttrib(dst, src[:2])
+}
+
+// VertexAttrib3f writes a vec3 vertex attribute.
+func VertexAttrib3f(dst Attrib, x, y, z float32) {
+	ctx.setCurrentAttrib(dst, []float32{x, y, z})
+}
+
+// VertexAttrib3fv writes a vec3 vertex attribute.
+func VertexAttrib3fv(dst Attrib, src []float32) {
+	ctx.setCurrentAttrib(dst, src[:3])
+}
+
+// VertexAttrib4f writes a vec4 vertex attribute.
+func VertexAttrib4f(dst Attrib, x, y, z, w float32) {
+	ctx.setCurrentAttrib(dst, []float32{x, y, z, w})
+}
+
+// VertexAttrib4fv writes a vec4 vertex attribute.
+func VertexAttrib4fv(dst Attrib, src []float32) {
+	ctx.setCurrentAttrib(dst, src[:4])
+}
+
+// Viewport sets the viewport, an affine transformation that
+// normalizes device coordinates to window coordinates.
+func Viewport(x, y, width, height int) {
+	ctx.viewport = [4]int{x, y, width, height}
+}
@@ -1,5 +1,5 @@
-//go:build !js
-// +build !js
+//go:build !js && !headless
+// +build !js,!headless
 
 package gl
 
@@ -70,6 +70,144 @@ func BufferData(target Enum, size int, data interface{}, usage Enum) {
 	gl.BufferData(uint32(target), size, gl.Ptr(data), uint32(usage))
 }
 
+var persistentMapping = -1 // Unknown until the first check
+
+// Returns true if buffers can be persistently mapped with BufferStoragePersistent. Requires ARB_buffer_storage
+func SupportsPersistentMapping() bool {
+	if persistentMapping < 0 {
+		persistentMapping = 0
+		if hasExtension("GL_ARB_buffer_storage") {
+			persistentMapping = 1
+		}
+	}
+	return persistentMapping == 1
+}
+
+func hasExtension(names ...string) bool {
+	var n int32
+	gl.GetIntegerv(gl.NUM_EXTENSIONS, &n)
+	for i := int32(0); i < n; i++ {
+		ext := gl.GoStr(gl.GetStringi(gl.EXTENSIONS, uint32(i)))
+		for _, name := range names {
+			if ext == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var maxAnisotropy float32 // Zero until the first check
+
+// Returns the highest anisotropy that TEXTURE_MAX_ANISOTROPY can be set to, or 1 if anisotropic filtering isn't supported
+func MaxAnisotropy() float32 {
+	if maxAnisotropy == 0 {
+		maxAnisotropy = 1
+		if hasExtension("GL_EXT_texture_filter_anisotropic", "GL_ARB_texture_filter_anisotropic") {
+			gl.GetFloatv(MAX_TEXTURE_ANISOTROPY, &maxAnisotropy)
+		}
+	}
+	return maxAnisotropy
+}
+
+var compressedFormats map[Enum]bool // Nil until the first check
+
+// Returns true if the context can sample textures of a block compressed format, ie COMPRESSED_RGBA_S3TC_DXT5_EXT
+func SupportsCompressedFormat(format Enum) bool {
+	if compressedFormats == nil {
+		compressedFormats = make(map[Enum]bool)
+		var n int32
+		gl.GetIntegerv(NUM_COMPRESSED_TEXTURE_FORMATS, &n)
+		if n > 0 {
+			formats := make([]int32, n)
+			gl.GetIntegerv(COMPRESSED_TEXTURE_FORMATS, &formats[0])
+			for _, f := range formats {
+				compressedFormats[Enum(f)] = true
+			}
+		}
+		// Drivers aren't required to list every format that they support
+		if hasExtension("GL_EXT_texture_compression_s3tc") {
+			for _, f := range []Enum{COMPRESSED_RGB_S3TC_DXT1_EXT, COMPRESSED_RGBA_S3TC_DXT1_EXT, COMPRESSED_RGBA_S3TC_DXT3_EXT, COMPRESSED_RGBA_S3TC_DXT5_EXT} {
+				compressedFormats[f] = true
+			}
+		}
+		if hasExtension("GL_ARB_texture_compression_rgtc", "GL_EXT_texture_compression_rgtc") {
+			compressedFormats[COMPRESSED_RED_RGTC1] = true
+			compressedFormats[COMPRESSED_RG_RGTC2] = true
+		}
+		if hasExtension("GL_ARB_texture_compression_bptc") {
+			compressedFormats[COMPRESSED_RGBA_BPTC_UNORM] = true
+		}
+	}
+	return compressedFormats[format]
+}
+
+// Allocates immutable storage for the bound buffer and maps all of it for writing. The mapping is coherent, so writes
+// are seen by the GPU without flushing, and it stays valid until the buffer is deleted. Returns nil if the mapping failed
+func BufferStoragePersistent(target Enum, size int) []byte {
+	flags := uint32(gl.MAP_WRITE_BIT | gl.MAP_PERSISTENT_BIT | gl.MAP_COHERENT_BIT)
+	gl.BufferStorage(uint32(target), size, nil, flags|gl.DYNAMIC_STORAGE_BIT)
+	ptr := gl.MapBufferRange(uint32(target), 0, size, flags)
+	if ptr == nil {
+		return nil
+	}
+	return unsafe.Slice((*byte)(ptr), size)
+}
+
+func FenceSync() Sync {
+	return Sync{gl.FenceSync(gl.SYNC_GPU_COMMANDS_COMPLETE, 0)}
+}
+
+// Blocks until the fence is signaled
+func ClientWaitSync(sync Sync) {
+	for {
+		switch gl.ClientWaitSync(sync.Value, gl.SYNC_FLUSH_COMMANDS_BIT, 1e9) {
+		case gl.ALREADY_SIGNALED, gl.CONDITION_SATISFIED, gl.WAIT_FAILED:
+			return
+		}
+	}
+}
+
+func DeleteSync(sync Sync) {
+	gl.DeleteSync(sync.Value)
+}
+
+// Timer queries are core in GL 3.3
+func SupportsTimerQuery() bool {
+	return true
+}
+
+func CreateQuery() Query {
+	var q Query
+	gl.GenQueries(1, &q.Value)
+	return q
+}
+
+func DeleteQuery(q Query) {
+	gl.DeleteQueries(1, &q.Value)
+}
+
+func BeginQuery(target Enum, q Query) {
+	gl.BeginQuery(uint32(target), q.Value)
+}
+
+func EndQuery(target Enum) {
+	gl.EndQuery(uint32(target))
+}
+
+func QueryResultAvailable(q Query) bool {
+	var available uint32
+	gl.GetQueryObjectuiv(q.Value, gl.QUERY_RESULT_AVAILABLE, &available)
+	return available != 0
+}
+
+// Returns the result of the query, waiting for it if it isn't available yet. Timer queries are in nanoseconds
+func QueryResult(q Query) uint64 {
+	var result uint64
+	gl.GetQueryObjectui64v(q.Value, gl.QUERY_RESULT, &result)
+	return result
+}
+
 func BufferDataImguiPassthrough(target Enum, size int, data unsafe.Pointer, usage Enum) {
 	gl.BufferData(uint32(target), size, data, uint32(usage))
 }
@@ -90,6 +228,15 @@ func DrawBuffer(target Enum) {
 	gl.DrawBuffer(uint32(target))
 }
 
+// Selects the color attachments that fragment shader outputs are written to
+func DrawBuffers(targets []Enum) {
+	bufs := make([]uint32, len(targets))
+	for i := range targets {
+		bufs[i] = uint32(targets[i])
+	}
+	gl.DrawBuffers(int32(len(bufs)), &bufs[0])
+}
+
 func ReadBuffer(target Enum) {
 	gl.ReadBuffer(uint32(target))
 }
@@ -103,6 +250,10 @@ func VertexAttribIPointer(dst Attrib, size int, ty Enum, stride int, offset int)
 	gl.VertexAttribIPointer(uint32(dst.Value), int32(size), uint32(ty), int32(stride), gl.PtrOffset(offset))
 }
 
+func VertexAttribDivisor(dst Attrib, divisor int) {
+	gl.VertexAttribDivisor(uint32(dst.Value), uint32(divisor))
+}
+
 func PolygonMode(face, mode Enum) {
 	gl.PolygonMode(uint32(face), uint32(mode))
 }
@@ -522,6 +673,13 @@ func DrawElements(mode Enum, count int, ty Enum, offset int) {
 	gl.DrawElements(uint32(mode), int32(count), uint32(ty), gl.PtrOffset(offset))
 }
 
+// DrawElementsInstanced renders instances copies of the primitives in the bound element array buffer.
+//
+// http://www.khronos.org/opengles/sdk/docs/man3/html/glDrawElementsInstanced.xhtml
+func DrawElementsInstanced(mode Enum, count int, ty Enum, offset int, instances int) {
+	gl.DrawElementsInstanced(uint32(mode), int32(count), uint32(ty), gl.PtrOffset(offset), int32(instances))
+}
+
 // Enable enables various GL capabilities.
 //
 // http://www.khronos.org/opengles/sdk/docs/man3/html/glEnable.xhtml
@@ -529,6 +687,11 @@ func Enable(cap Enum) {
 	gl.Enable(uint32(cap))
 }
 
+// Lets vertex shaders set gl_PointSize. This is always enabled in webgl and gles
+func EnableProgramPointSize() {
+	gl.Enable(gl.PROGRAM_POINT_SIZE)
+}
+
 // EnableVertexAttribArray enables a vertex attribute array.
 //
 // http://www.khronos.org/opengles/sdk/docs/man3/html/glEnableVertexAttribArray.xhtml
@@ -985,6 +1148,13 @@ func RenderbufferStorage(target, internalFormat Enum, width, height int) {
 	gl.RenderbufferStorage(uint32(target), uint32(internalFormat), int32(width), int32(height))
 }
 
+// RenderbufferStorageMultisample establishes the data storage of a multisampled renderbuffer.
+//
+// http://www.khronos.org/opengles/sdk/docs/man3/html/glRenderbufferStorageMultisample.xhtml
+func RenderbufferStorageMultisample(target Enum, samples int, internalFormat Enum, width, height int) {
+	gl.RenderbufferStorageMultisample(uint32(target), int32(samples), uint32(internalFormat), int32(width), int32(height))
+}
+
 // SampleCoverage sets multisample coverage parameters.
 //
 // http://www.khronos.org/opengles/sdk/docs/man3/html/glSampleCoverage.xhtml
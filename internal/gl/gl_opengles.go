@@ -2,9 +2,10 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-//go:build linux && (arm || arm64)
+//go:build linux && (arm || arm64) && !headless
 // +build linux
 // +build arm arm64
+// +build !headless
 
 package gl
 
@@ -231,6 +232,18 @@ func Enable(cap Enum) {
 	C.glEnable(cap.c())
 }
 
+// Vertex shaders can always set gl_PointSize in gles
+func EnableProgramPointSize() {}
+
+// Anisotropic filtering isn't queried in gles
+func MaxAnisotropy() float32 {
+	return 1
+}
+
+func SupportsCompressedFormat(format Enum) bool {
+	return false
+}
+
 func EnableVertexAttribArray(a Attrib) {
 	C.glEnableVertexAttribArray(a.c())
 }
@@ -22,6 +22,7 @@ var (
 	object       = js.Global().Get("Object")
 	arrayBuffer  = js.Global().Get("ArrayBuffer")
 	uint8Array   = js.Global().Get("Uint8Array")
+	uint16Array  = js.Global().Get("Uint16Array")
 	float32Array = js.Global().Get("Float32Array")
 	int32Array   = js.Global().Get("Int32Array")
 	uint32Array  = js.Global().Get("Uint32Array")
@@ -97,6 +98,7 @@ var (
 
 	fnBindFramebuffer  js.Value
 	fnUniform1fv       js.Value
+	fnUniform2fv       js.Value
 	fnUniform3fv       js.Value
 	fnUniform4fv       js.Value
 	fnUniformMatrix4fv js.Value
@@ -165,6 +167,7 @@ func (contextWatcher) OnMakeCurrent(context interface{}) {
 	fnCullFace = c.Get("cullFace").Call("bind", c)
 	fnFrontFace = c.Get("frontFace").Call("bind", c)
 	fnUniform1fv = c.Get("uniform1fv").Call("bind", c)
+	fnUniform2fv = c.Get("uniform2fv").Call("bind", c)
 	fnUniform3fv = c.Get("uniform3fv").Call("bind", c)
 	fnUniform4fv = c.Get("uniform4fv").Call("bind", c)
 	fnUniformMatrix4fv = c.Get("uniformMatrix4fv").Call("bind", c)
@@ -433,9 +436,109 @@ func BufferData(target Enum, size int, data any, usage Enum) {
 	fnBufferData.Invoke(int(target), subarray, int(usage))
 }
 
+// Webgl can't map buffers, so buffers are orphaned instead
+func SupportsPersistentMapping() bool {
+	return false
+}
+
+func BufferStoragePersistent(target Enum, size int) []byte {
+	return nil
+}
+
+func FenceSync() Sync {
+	return Sync{}
+}
+
+func ClientWaitSync(sync Sync) {}
+
+func DeleteSync(sync Sync) {}
+
+var timerQueryExt js.Value
+var anisotropyExt js.Value
+
+// Returns the highest anisotropy that TEXTURE_MAX_ANISOTROPY can be set to, or 1 if EXT_texture_filter_anisotropic isn't supported.
+// Getting the extension is what enables it
+func MaxAnisotropy() float32 {
+	if anisotropyExt.IsUndefined() {
+		anisotropyExt = c.Call("getExtension", "EXT_texture_filter_anisotropic")
+	}
+	if anisotropyExt.IsNull() {
+		return 1
+	}
+	return float32(c.Call("getParameter", int(MAX_TEXTURE_ANISOTROPY)).Float())
+}
+
+// The extension that each compressed format needs. Getting an extension is what enables its formats
+var compressedFormatExts = map[Enum]string{
+	COMPRESSED_RGB_S3TC_DXT1_EXT:  "WEBGL_compressed_texture_s3tc",
+	COMPRESSED_RGBA_S3TC_DXT1_EXT: "WEBGL_compressed_texture_s3tc",
+	COMPRESSED_RGBA_S3TC_DXT3_EXT: "WEBGL_compressed_texture_s3tc",
+	COMPRESSED_RGBA_S3TC_DXT5_EXT: "WEBGL_compressed_texture_s3tc",
+	COMPRESSED_RED_RGTC1:          "EXT_texture_compression_rgtc",
+	COMPRESSED_RG_RGTC2:           "EXT_texture_compression_rgtc",
+	COMPRESSED_RGBA_BPTC_UNORM:    "EXT_texture_compression_bptc",
+	COMPRESSED_RGB8_ETC2:          "WEBGL_compressed_texture_etc",
+	COMPRESSED_RGBA8_ETC2_EAC:     "WEBGL_compressed_texture_etc",
+}
+
+var compressedExts = make(map[string]bool)
+
+// Returns true if the browser can sample textures of a block compressed format, ie COMPRESSED_RGBA_S3TC_DXT5_EXT
+func SupportsCompressedFormat(format Enum) bool {
+	name, ok := compressedFormatExts[format]
+	if !ok {
+		return false
+	}
+	supported, checked := compressedExts[name]
+	if !checked {
+		supported = !c.Call("getExtension", name).IsNull()
+		compressedExts[name] = supported
+	}
+	return supported
+}
+
+// Timer queries need the EXT_disjoint_timer_query_webgl2 extension, which most browsers only expose behind a flag
+func SupportsTimerQuery() bool {
+	if webgl1Mode {
+		return false
+	}
+	if timerQueryExt.IsUndefined() {
+		timerQueryExt = c.Call("getExtension", "EXT_disjoint_timer_query_webgl2")
+	}
+	return !timerQueryExt.IsNull()
+}
+
+func CreateQuery() Query {
+	return Query{c.Call("createQuery")}
+}
+
+func DeleteQuery(q Query) {
+	c.Call("deleteQuery", q.Value)
+}
+
+func BeginQuery(target Enum, q Query) {
+	c.Call("beginQuery", int(target), q.Value)
+}
+
+func EndQuery(target Enum) {
+	c.Call("endQuery", int(target))
+}
+
+func QueryResultAvailable(q Query) bool {
+	return c.Call("getQueryParameter", q.Value, int(QUERY_RESULT_AVAILABLE)).Bool()
+}
+
+// Results can't be waited on in webgl, so this should only be called once QueryResultAvailable is true.
+// Results from while the GPU was disjoint (ie the clock changed) are meaningless and are returned as zero
+func QueryResult(q Query) uint64 {
+	if c.Call("getParameter", timerQueryExt.Get("GPU_DISJOINT_EXT")).Bool() {
+		return 0
+	}
+	return uint64(c.Call("getQueryParameter", q.Value, int(QUERY_RESULT)).Float())
+}
+
 func BlitFramebuffer(srcX0 int32, srcY0 int32, srcX1 int32, srcY1 int32, dstX0 int32, dstY0 int32, dstX1 int32, dstY1 int32, mask uint32, filter uint32) {
-	panic("Not supported!") // TODO
-	// gl.BlitFrameBuffer(srcX0 int32, srcY0 int32, srcX1 int32, srcY1 int32, dstX0 int32, dstY0 int32, dstX1 int32, dstY1 int32, mask uint32, filter uint32)
+	c.Call("blitFramebuffer", srcX0, srcY0, srcX1, srcY1, dstX0, dstY0, dstX1, dstY1, mask, filter)
 }
 
 // func PtrOffset(offset int) unsafe.Pointer {
@@ -455,6 +558,14 @@ func BlitFramebuffer(srcX0 int32, srcY0 int32, srcX1 int32, srcY1 int32, dstX0 i
 // 	//	gl.DrawBuffer(uint32(target))
 // }
 
+func DrawBuffers(targets []Enum) {
+	bufs := make([]any, len(targets))
+	for i := range targets {
+		bufs[i] = int(targets[i])
+	}
+	c.Call("drawBuffers", bufs)
+}
+
 func ReadBuffer(target Enum) {
 	c.Call("readBuffer", int(target))
 	//	gl.ReadBuffer(uint32(target))
@@ -576,23 +687,23 @@ func ClearColor(red, green, blue, alpha float32) {
 // 	c.Call("clearDepth", d)
 // }
 
-// func ClearStencil(s int) {
-// 	c.Call("clearStencil", s)
-// }
+func ClearStencil(s int) {
+	c.Call("clearStencil", s)
+}
 
-// func ColorMask(red, green, blue, alpha bool) {
-// 	c.Call("colorMask", red, green, blue, alpha)
-// }
+func ColorMask(red, green, blue, alpha bool) {
+	c.Call("colorMask", red, green, blue, alpha)
+}
 
 func CompileShader(s Shader) {
 	c.Call("compileShader", s.Value)
 }
 
-// func CompressedTexImage2D(target Enum, level int, internalformat Enum, width, height, border int, data interface{}) {
-// 	array, length := SliceToTypedArray(data)
-// 	subarray := array.Call("subarray", 0, length)
-// 	c.Call("compressedTexImage2D", int(target), level, internalformat, width, height, border, subarray)
-// }
+func CompressedTexImage2D(target Enum, level int, internalformat Enum, width, height, border int, data []byte) {
+	array, length := byteSliceToTypedArray(data)
+	subarray := array.Call("subarray", 0, length)
+	c.Call("compressedTexImage2D", int(target), level, int(internalformat), width, height, border, subarray)
+}
 
 // func CompressedTexSubImage2D(target Enum, level, xoffset, yoffset, width, height int, format Enum, data interface{}) {
 // 	array, length := SliceToTypedArray(data)
@@ -623,9 +734,9 @@ func CreateProgram() Program {
 	// return Program{Value: c.Call("createProgram")}
 }
 
-// func CreateRenderbuffer() Renderbuffer {
-// 	return Renderbuffer{Value: c.Call("createRenderbuffer")}
-// }
+func CreateRenderbuffer() Renderbuffer {
+	return Renderbuffer{Value: c.Call("createRenderbuffer")}
+}
 
 func CreateShader(ty Enum) Shader {
 	return Shader{Value: c.Call("createShader", int(ty))}
@@ -659,9 +770,9 @@ func DeleteProgram(p Program) {
 	c.Call("deleteProgram", p.Value)
 }
 
-// func DeleteRenderbuffer(v Renderbuffer) {
-// 	c.Call("deleteRenderbuffer", v.Value)
-// }
+func DeleteRenderbuffer(v Renderbuffer) {
+	c.Call("deleteRenderbuffer", v.Value)
+}
 
 func DeleteShader(s Shader) {
 	fnDeleteShader.Invoke(s.Value)
@@ -708,11 +819,18 @@ func DrawElements(mode Enum, count int, ty Enum, offset int) {
 	// c.Call("drawElements", int(mode), count, int(ty), offset)
 }
 
+func DrawElementsInstanced(mode Enum, count int, ty Enum, offset int, instances int) {
+	c.Call("drawElementsInstanced", int(mode), count, int(ty), offset, instances)
+}
+
 func Enable(cap Enum) {
 	fnEnable.Invoke(int(cap))
 	// c.Call("enable", int(cap))
 }
 
+// Vertex shaders can always set gl_PointSize in webgl
+func EnableProgramPointSize() {}
+
 func EnableVertexAttribArray(a Attrib) {
 	fnEnableVertexAttribArray.Invoke(a.Value)
 	// c.Call("enableVertexAttribArray", a.Value)
@@ -726,9 +844,9 @@ func Flush() {
 	fnFlush.Invoke()
 }
 
-// func FramebufferRenderbuffer(target, attachment, rbTarget Enum, rb Renderbuffer) {
-// 	c.Call("framebufferRenderbuffer", target, attachment, int(rbTarget), rb.Value)
-// }
+func FramebufferRenderbuffer(target, attachment, rbTarget Enum, rb Renderbuffer) {
+	c.Call("framebufferRenderbuffer", int(target), int(attachment), int(rbTarget), rb.Value)
+}
 
 func FramebufferTexture2D(target, attachment, texTarget Enum, t Texture, level int) {
 	fnFramebufferTexture2D.Invoke(int(target), int(attachment), int(texTarget), t.Value, level)
@@ -988,58 +1106,63 @@ func LinkProgram(p Program) {
 // 	c.Call("polygonOffset", factor, units)
 // }
 
-// func ReadPixels(dst []byte, x, y, width, height int, format, ty Enum) {
-// 	println("ReadPixels: not yet tested (TODO: remove this after it's confirmed to work. Your feedback is welcome.)")
-// 	if ty == Enum(UNSIGNED_BYTE) {
-// 		c.Call("readPixels", x, y, width, height, format, int(ty), dst)
-// 	} else {
-// 		tmpDst := make([]float32, len(dst)/4)
-// 		c.Call("readPixels", x, y, width, height, format, int(ty), tmpDst)
-// 		for i, f := range tmpDst {
-// 			binary.LittleEndian.PutUint32(dst[i*4:], math.Float32bits(f))
-// 		}
-// 	}
-// }
+func ReadPixels(dst []byte, x, y, width, height int, format, ty Enum) {
+	// Note: webgl requires the typed array to match the pixel type
+	if ty == FLOAT {
+		array := float32Array.New(len(dst) / 4)
+		c.Call("readPixels", x, y, width, height, int(format), int(ty), array)
+		js.CopyBytesToGo(dst, uint8Array.New(array.Get("buffer")))
+		return
+	}
+
+	array := uint8Array.New(len(dst))
+	c.Call("readPixels", x, y, width, height, int(format), int(ty), array)
+	js.CopyBytesToGo(dst, array)
+}
 
 func ReleaseShaderCompiler() {
 	// do nothing
 }
 
-// func RenderbufferStorage(target, internalFormat Enum, width, height int) {
-// 	c.Call("renderbufferStorage", target, internalFormat, width, height)
-// }
+func RenderbufferStorage(target, internalFormat Enum, width, height int) {
+	c.Call("renderbufferStorage", int(target), int(internalFormat), width, height)
+}
+
+func RenderbufferStorageMultisample(target Enum, samples int, internalFormat Enum, width, height int) {
+	c.Call("renderbufferStorageMultisample", int(target), samples, int(internalFormat), width, height)
+}
 
 // func SampleCoverage(value float32, invert bool) {
 // 	c.Call("sampleCoverage", value, invert)
 // }
 
-// func Scissor(x, y, width, height int32) {
-// 	c.Call("scissor", x, y, width, height)
-// }
+func Scissor(x, y, width, height int32) {
+	c.Call("scissor", x, y, width, height)
+}
 
 func ShaderSource(s Shader, src string) {
 	c.Call("shaderSource", s.Value, src)
 }
 
-// func StencilFunc(fn Enum, ref int, mask uint32) {
-// 	c.Call("stencilFunc", fn, ref, mask)
-// }
+func StencilFunc(fn Enum, ref int, mask uint32) {
+	c.Call("stencilFunc", int(fn), ref, mask)
+}
 
 // func StencilFuncSeparate(face, fn Enum, ref int, mask uint32) {
 // 	c.Call("stencilFuncSeparate", face, fn, ref, mask)
 // }
 
-// func StencilMask(mask uint32) {
-// 	c.Call("stencilMask", mask)
-// }
+func StencilMask(mask uint32) {
+	c.Call("stencilMask", mask)
+}
 
 // func StencilMaskSeparate(face Enum, mask uint32) {
 // 	c.Call("stencilMaskSeparate", face, mask)
 // }
 
-// func StencilOp(fail, zfail, zpass Enum) {
-// 	c.Call("stencilOp", fail, zfail, zpass)
-// }
+func StencilOp(fail, zfail, zpass Enum) {
+	c.Call("stencilOp", int(fail), int(zfail), int(zpass))
+}
 
 // func StencilOpSeparate(face, sfail, dpfail, dppass Enum) {
 // 	c.Call("stencilOpSeparate", face, sfail, dpfail, dppass)
@@ -1059,17 +1182,28 @@ func ShaderSource(s Shader, src string) {
 func TexImage2DFull(target Enum, level int, format1 Enum, width, height int, format Enum, ty Enum, data []byte) {
 	array, length := byteSliceToTypedArray(data)
 	if !array.IsNull() {
-		subarray := array.Call("subarray", 0, length)
+		subarray := pixelView(length, ty)
 		fnTexImage2D.Invoke(int(target), level, int(format1), width, height, 0, int(format), int(ty), subarray)
 	} else {
 		fnTexImage2D.Invoke(int(target), level, int(format1), width, height, 0, int(format), int(ty), nil)
 	}
 }
 
+// WebGL checks that the typed array matches the pixel type, so float and half float pixels are passed as views of the copy buffer
+func pixelView(length int, ty Enum) js.Value {
+	switch ty {
+	case FLOAT:
+		return jsMemoryFloat32.Call("subarray", 0, length/4)
+	case HALF_FLOAT:
+		return uint16Array.New(jsMemoryBuffer, 0, length/2)
+	}
+	return jsMemory.Call("subarray", 0, length)
+}
+
 func TexSubImage2D(target Enum, level int, x, y, width, height int, format, ty Enum, data []byte) {
 	array, length := byteSliceToTypedArray(data)
 	if !array.IsNull() {
-		subarray := array.Call("subarray", 0, length)
+		subarray := pixelView(length, ty)
 		fnTexSubImage2D.Invoke(int(target), level, x, y, width, height, int(format), int(ty), subarray)
 	} else {
 		// TODO: is this the correct behavior?
@@ -1079,9 +1213,9 @@ func TexSubImage2D(target Enum, level int, x, y, width, height int, format, ty E
 	// c.Call("texSubImage2D", int(target), level, x, y, width, height, format, int(ty), subarray)
 }
 
-// func TexParameterf(target, pname Enum, param float32) {
-// 	c.Call("texParameterf", int(target), int(pname), param)
-// }
+func TexParameterf(target, pname Enum, param float32) {
+	c.Call("texParameterf", int(target), int(pname), param)
+}
 
 // func TexParameterfv(target, pname Enum, params []float32) {
 // 	println("TexParameterfv: not yet tested (TODO: remove this after it's confirmed to work. Your feedback is welcome.)")
@@ -1111,9 +1245,9 @@ func Uniform1fv(dst Uniform, src []float32) {
 	fnUniform1fv.Invoke(dst.Value, subarray)
 }
 
-// func Uniform1i(dst Uniform, v int) {
-// 	c.Call("uniform1i", dst.Value, v)
-// }
+func Uniform1i(dst Uniform, v int) {
+	c.Call("uniform1i", dst.Value, v)
+}
 
 // func Uniform1iv(dst Uniform, src []int32) {
 // 	c.Call("uniform1iv", dst.Value, src)
@@ -1123,12 +1257,11 @@ func Uniform1fv(dst Uniform, src []float32) {
 // 	c.Call("uniform2f", dst.Value, v0, v1)
 // }
 
-// func Uniform2fv(dst Uniform, src []float32) {
-// 	// c.Call("uniform2fv", dst.Value, src)
-
-// 	SliceToTypedArray(src)
-// 	c.Call("uniform3fv", dst.Value, jsMemoryBufferVec2)
-// }
+func Uniform2fv(dst Uniform, src []float32) {
+	array, length := SliceToTypedArray(src)
+	subarray := array.Call("subarray", 0, length)
+	fnUniform2fv.Invoke(dst.Value, subarray)
+}
 
 // func Uniform2i(dst Uniform, v0, v1 int) {
 // 	c.Call("uniform2i", dst.Value, v0, v1)
@@ -1241,9 +1374,13 @@ func VertexAttribPointer(dst Attrib, size int, ty Enum, normalized bool, stride,
 	// c.Call("vertexAttribPointer", dst.Value, size, int(ty), normalized, stride, offset)
 }
 
-// func VertexAttribIPointer(dst Attrib, size int, ty Enum, stride, offset int) {
-// 	c.Call("vertexAttribIPointer", dst.Value, size, int(ty), stride, offset)
-// }
+func VertexAttribDivisor(dst Attrib, divisor int) {
+	c.Call("vertexAttribDivisor", dst.Value, divisor)
+}
+
+func VertexAttribIPointer(dst Attrib, size int, ty Enum, stride, offset int) {
+	c.Call("vertexAttribIPointer", dst.Value, size, int(ty), stride, offset)
+}
 
 func Viewport(x, y, width, height int) {
 	// c.Call("viewport", x, y, width, height)
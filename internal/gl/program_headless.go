@@ -0,0 +1,700 @@
+//go:build !js && headless
+// +build !js,headless
+
+package gl
+
+// GLSL can't be executed on the CPU, so programs are emulated. Linking parses the attribute and uniform declarations out of the shader source so that locations and uniform storage behave like a real driver. The vertex stage is a fixed function transform (projection * view * model * position) that passes color and uv through, applying the instanceModel, instanceColor and instanceUV attributes if the shader declares them. The fragment stage is chosen by matching the fragment source against the shaders that glitch ships with, falling back to modulating the color by the first sampler.
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/unitoftime/glitch/shaders"
+)
+
+// Varyings are laid out as: color (rgba), uv (xy)
+const (
+	maxVaryings = 8
+	varyColor   = 0
+	varyUV      = 4
+)
+
+type softVertex struct {
+	pos       [4]float32 // clip space
+	vary      [maxVaryings]float32
+	pointSize float32
+}
+
+type fragment struct {
+	vary       [maxVaryings]float32
+	ddx, ddy   [maxVaryings]float32
+	x, y, z    float32 // gl_FragCoord
+	front      bool
+	pointCoord [2]float32
+}
+
+func (f *fragment) color() [4]float32 {
+	return [4]float32{f.vary[varyColor], f.vary[varyColor+1], f.vary[varyColor+2], f.vary[varyColor+3]}
+}
+
+func (f *fragment) uv() (float32, float32) {
+	return f.vary[varyUV], f.vary[varyUV+1]
+}
+
+func (f *fragment) uvDerivatives() ([2]float32, [2]float32) {
+	return [2]float32{f.ddx[varyUV], f.ddx[varyUV+1]}, [2]float32{f.ddy[varyUV], f.ddy[varyUV+1]}
+}
+
+// Returns the color and false if the fragment was discarded
+type fragmentProgram func(c *softContext, p *softProgram, f *fragment) ([4]float32, bool)
+
+type softShader struct {
+	ty       Enum
+	source   string
+	compiled bool
+	log      string
+}
+
+var (
+	blockCommentRegex = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	lineCommentRegex  = regexp.MustCompile(`//[^\n]*`)
+	attribRegex       = regexp.MustCompile(`(?:layout\s*\(\s*location\s*=\s*(\d+)\s*\)\s*)?\b(?:in|attribute)\s+(?:(?:lowp|mediump|highp)\s+)?(\w+)\s+(\w+)\s*;`)
+	uniformRegex      = regexp.MustCompile(`\buniform\s+(?:(?:lowp|mediump|highp)\s+)?(\w+)\s+(\w+)\s*(?:\[\s*(\d+)\s*\])?\s*;`)
+	mainRegex         = regexp.MustCompile(`\bvoid\s+main\s*\(`)
+)
+
+func stripComments(src string) string {
+	src = blockCommentRegex.ReplaceAllString(src, "")
+	return lineCommentRegex.ReplaceAllString(src, "")
+}
+
+func (s *softShader) compile() {
+	if s.ty != VERTEX_SHADER && s.ty != FRAGMENT_SHADER {
+		s.compiled = false
+		s.log = "unsupported shader type"
+		return
+	}
+	if !mainRegex.MatchString(stripComments(s.source)) {
+		s.compiled = false
+		s.log = "ERROR: 0:1: no main function found"
+		return
+	}
+	s.compiled = true
+	s.log = ""
+}
+
+type softAttrib struct {
+	name     string
+	ty       Enum
+	location int
+}
+
+type softUniform struct {
+	name     string
+	ty       Enum
+	count    int
+	size     int // number of floats per element
+	location int32
+}
+
+// GLSL types to their gl enum and float count
+var glslTypes = map[string]struct {
+	ty   Enum
+	size int
+}{
+	"float":     {FLOAT, 1},
+	"vec2":      {FLOAT_VEC2, 2},
+	"vec3":      {FLOAT_VEC3, 3},
+	"vec4":      {FLOAT_VEC4, 4},
+	"int":       {INT, 1},
+	"ivec2":     {INT_VEC2, 2},
+	"ivec3":     {INT_VEC3, 3},
+	"ivec4":     {INT_VEC4, 4},
+	"bool":      {BOOL, 1},
+	"bvec2":     {BOOL_VEC2, 2},
+	"bvec3":     {BOOL_VEC3, 3},
+	"bvec4":     {BOOL_VEC4, 4},
+	"mat2":      {FLOAT_MAT2, 4},
+	"mat3":      {FLOAT_MAT3, 9},
+	"mat4":      {FLOAT_MAT4, 16},
+	"sampler2D": {SAMPLER_2D, 1},
+}
+
+type softProgram struct {
+	shaders      []uint32
+	boundAttribs map[string]int
+	linked       bool
+	log          string
+
+	attribs   []softAttrib
+	uniforms  []softUniform
+	locations map[string]int32
+	values    [][]float32 // Uniform storage, indexed by location
+
+	// Resolved at link time
+	positionLoc      int
+	colorLoc         int
+	uvLoc            int
+	instanceModelLoc int // Per instance attributes, for instanced shaders
+	instanceColorLoc int
+	instanceUVLoc    int
+	projectionLoc    int32
+	viewLoc          int32
+	modelLoc         int32
+	pointSizeLoc     int32
+	samplers         []int32
+	fragment         fragmentProgram
+	source           [2]string // vertex, fragment
+}
+
+func newSoftProgram() *softProgram {
+	return &softProgram{
+		boundAttribs: make(map[string]int),
+	}
+}
+
+func (p *softProgram) link(shaderObjects map[uint32]*softShader) {
+	p.linked = false
+	p.attribs = p.attribs[:0]
+	p.uniforms = p.uniforms[:0]
+	p.locations = make(map[string]int32)
+	p.values = p.values[:0]
+	p.samplers = p.samplers[:0]
+
+	var vertex, frag *softShader
+	for _, id := range p.shaders {
+		s := shaderObjects[id]
+		if s == nil {
+			continue
+		}
+		switch s.ty {
+		case VERTEX_SHADER:
+			vertex = s
+		case FRAGMENT_SHADER:
+			frag = s
+		}
+	}
+	if vertex == nil || frag == nil {
+		p.log = "ERROR: program requires a vertex and a fragment shader"
+		return
+	}
+	if !vertex.compiled || !frag.compiled {
+		p.log = "ERROR: attached shaders were not compiled successfully"
+		return
+	}
+	p.source = [2]string{vertex.source, frag.source}
+
+	// Attributes
+	used := make(map[int]bool)
+	pending := make([]softAttrib, 0)
+	for _, m := range attribRegex.FindAllStringSubmatch(stripComments(vertex.source), -1) {
+		a := softAttrib{name: m[3], ty: glslTypes[m[2]].ty, location: -1}
+		if m[1] != "" {
+			a.location, _ = strconv.Atoi(m[1])
+		} else if loc, ok := p.boundAttribs[a.name]; ok {
+			a.location = loc
+		}
+		if a.location >= 0 {
+			for i := 0; i < attribSlots(a.ty); i++ {
+				used[a.location+i] = true
+			}
+		}
+		pending = append(pending, a)
+	}
+	next := 0
+	for _, a := range pending {
+		if a.location < 0 {
+			for used[next] {
+				next++
+			}
+			a.location = next
+			for i := 0; i < attribSlots(a.ty); i++ {
+				used[next+i] = true
+			}
+		}
+		p.attribs = append(p.attribs, a)
+	}
+
+	// Uniforms, shared between both stages
+	for _, src := range p.source {
+		for _, m := range uniformRegex.FindAllStringSubmatch(stripComments(src), -1) {
+			name := m[2]
+			if _, ok := p.locations[name]; ok {
+				continue
+			}
+			info, ok := glslTypes[m[1]]
+			if !ok {
+				p.log = fmt.Sprintf("ERROR: unsupported uniform type: %s", m[1])
+				return
+			}
+			count := 1
+			if m[3] != "" {
+				count, _ = strconv.Atoi(m[3])
+			}
+			u := softUniform{
+				name:     name,
+				ty:       info.ty,
+				count:    count,
+				size:     info.size,
+				location: int32(len(p.values)),
+			}
+			p.uniforms = append(p.uniforms, u)
+			p.locations[name] = u.location
+			for i := 0; i < count; i++ {
+				p.values = append(p.values, make([]float32, info.size))
+			}
+			if info.ty == SAMPLER_2D {
+				p.samplers = append(p.samplers, u.location)
+			}
+		}
+	}
+
+	p.positionLoc = p.attribLocation("positionIn", "Position", "position", "aPos", "vertexPosition")
+	p.colorLoc = p.attribLocation("colorIn", "Color", "color", "aColor", "vertexColor")
+	p.uvLoc = p.attribLocation("texCoordIn", "UV", "texCoord", "uv", "aTexCoord")
+	if p.positionLoc < 0 && len(p.attribs) > 0 {
+		p.positionLoc = p.attribs[0].location
+	}
+	p.instanceModelLoc = p.attribLocation("instanceModel")
+	p.instanceColorLoc = p.attribLocation("instanceColor")
+	p.instanceUVLoc = p.attribLocation("instanceUV")
+	p.projectionLoc = p.uniformLocationOf("projection", "ProjMtx", "u_projection")
+	p.viewLoc = p.uniformLocationOf("view", "u_view")
+	p.modelLoc = p.uniformLocationOf("model", "u_model")
+	p.pointSizeLoc = p.uniformLocationOf("pointSize", "u_pointSize")
+
+	p.fragment = selectFragmentProgram(frag.source, len(p.samplers) > 0)
+	p.linked = true
+	p.log = ""
+}
+
+// Returns the number of locations that an attribute of the type takes up. Matrices take one location per column
+func attribSlots(ty Enum) int {
+	switch ty {
+	case FLOAT_MAT2:
+		return 2
+	case FLOAT_MAT3:
+		return 3
+	case FLOAT_MAT4:
+		return 4
+	}
+	return 1
+}
+
+func (p *softProgram) attribLocation(names ...string) int {
+	for _, name := range names {
+		for _, a := range p.attribs {
+			if a.name == name {
+				return a.location
+			}
+		}
+	}
+	return -1
+}
+
+func (p *softProgram) uniformLocationOf(names ...string) int32 {
+	for _, name := range names {
+		if loc, ok := p.locations[name]; ok {
+			return loc
+		}
+	}
+	return -1
+}
+
+// Supports plain names and array element names (ie "name[2]")
+func (p *softProgram) uniformLocation(name string) int32 {
+	if loc, ok := p.locations[name]; ok {
+		return loc
+	}
+	open := strings.IndexByte(name, '[')
+	if open < 0 || !strings.HasSuffix(name, "]") {
+		return -1
+	}
+	base, ok := p.locations[name[:open]]
+	if !ok {
+		return -1
+	}
+	index, err := strconv.Atoi(name[open+1 : len(name)-1])
+	if err != nil || index < 0 {
+		return -1
+	}
+	for _, u := range p.uniforms {
+		if u.location == base && index < u.count {
+			return base + int32(index)
+		}
+	}
+	return -1
+}
+
+func (p *softProgram) uniformValue(loc int32) []float32 {
+	if loc < 0 || int(loc) >= len(p.values) {
+		return nil
+	}
+	return p.values[loc]
+}
+
+func (p *softProgram) setUniform(loc int32, components int, src []float32) {
+	if int(loc) >= len(p.values) {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	for i := 0; i*components < len(src) && int(loc)+i < len(p.values); i++ {
+		copy(p.values[int(loc)+i], src[i*components:])
+	}
+}
+
+// Returns the value of a uniform, or the fallback if the uniform doesn't exist
+func (p *softProgram) uniform(loc int32, fallback float32) float32 {
+	v := p.uniformValue(loc)
+	if len(v) == 0 {
+		return fallback
+	}
+	return v[0]
+}
+
+func (p *softProgram) uniformVec4(loc int32, fallback [4]float32) [4]float32 {
+	v := p.uniformValue(loc)
+	if len(v) < 4 {
+		return fallback
+	}
+	return [4]float32{v[0], v[1], v[2], v[3]}
+}
+
+func (p *softProgram) uniformVec2(loc int32, fallback [2]float32) [2]float32 {
+	v := p.uniformValue(loc)
+	if len(v) < 2 {
+		return fallback
+	}
+	return [2]float32{v[0], v[1]}
+}
+
+// Returns the texture bound to the unit that the sampler points at
+func (p *softProgram) sampler(c *softContext, loc int32) *softTexture {
+	unit := int(p.uniform(loc, 0))
+	if unit < 0 || unit >= len(c.units) {
+		return nil
+	}
+	return c.textures[c.units[unit]]
+}
+
+func (p *softProgram) matrix(loc int32) ([16]float32, bool) {
+	var m [16]float32
+	v := p.uniformValue(loc)
+	if len(v) != 16 {
+		return m, false
+	}
+	copy(m[:], v)
+	return m, true
+}
+
+func mulMat4Vec4(m [16]float32, v [4]float32) [4]float32 {
+	var out [4]float32
+	for r := 0; r < 4; r++ {
+		out[r] = m[r]*v[0] + m[4+r]*v[1] + m[8+r]*v[2] + m[12+r]*v[3]
+	}
+	return out
+}
+
+func (p *softProgram) runVertex(fetch func(loc int) [4]float32) softVertex {
+	var v softVertex
+	v.pointSize = 1
+	if size := p.uniformValue(p.pointSizeLoc); len(size) > 0 {
+		v.pointSize = size[0]
+	}
+	v.pos = [4]float32{0, 0, 0, 1}
+	if p.positionLoc >= 0 {
+		v.pos = fetch(p.positionLoc)
+	}
+	if p.instanceModelLoc >= 0 {
+		var m [16]float32
+		for i := 0; i < 4; i++ {
+			col := fetch(p.instanceModelLoc + i)
+			copy(m[i*4:], col[:])
+		}
+		v.pos = mulMat4Vec4(m, v.pos)
+	}
+	for _, loc := range []int32{p.modelLoc, p.viewLoc, p.projectionLoc} {
+		if m, ok := p.matrix(loc); ok {
+			v.pos = mulMat4Vec4(m, v.pos)
+		}
+	}
+
+	color := [4]float32{1, 1, 1, 1}
+	if p.colorLoc >= 0 {
+		color = fetch(p.colorLoc)
+	}
+	if p.instanceColorLoc >= 0 {
+		color = mul4(color, fetch(p.instanceColorLoc))
+	}
+	copy(v.vary[varyColor:], color[:])
+	if p.uvLoc >= 0 {
+		uv := fetch(p.uvLoc)
+		if p.instanceUVLoc >= 0 {
+			// The uv rect is packed as (offset x, offset y, scale x, scale y)
+			rect := fetch(p.instanceUVLoc)
+			uv[0] = rect[0] + uv[0]*rect[2]
+			uv[1] = rect[1] + uv[1]*rect[3]
+		}
+		copy(v.vary[varyUV:], uv[:2])
+	}
+	return v
+}
+
+func (p *softProgram) runFragment(c *softContext, f *fragment) ([4]float32, bool) {
+	return p.fragment(c, p, f)
+}
+
+func selectFragmentProgram(src string, hasSampler bool) fragmentProgram {
+	switch src {
+	case shaders.SpriteFragmentShader:
+		return spriteFragment
+	case shaders.MSDFFragmentShader:
+		return msdfFragment
+	case shaders.VignetteFragmentShader:
+		return vignetteFragment
+	case shaders.BlurFragmentShader:
+		return blurFragment
+	case shaders.BrightPassFragmentShader:
+		return brightPassFragment
+	case shaders.BloomFragmentShader:
+		return bloomFragment
+	case shaders.ColorGradeFragmentShader:
+		return colorGradeFragment
+	case shaders.CRTFragmentShader:
+		return crtFragment
+	case shaders.PointFragmentShader:
+		return pointFragment
+	}
+	if hasSampler {
+		return texturedFragment
+	}
+	return colorFragment
+}
+
+func mul4(a, b [4]float32) [4]float32 {
+	return [4]float32{a[0] * b[0], a[1] * b[1], a[2] * b[2], a[3] * b[3]}
+}
+
+func (p *softProgram) texture(c *softContext, f *fragment) [4]float32 {
+	if len(p.samplers) == 0 {
+		return [4]float32{0, 0, 0, 1}
+	}
+	u, v := f.uv()
+	ddx, ddy := f.uvDerivatives()
+	return p.sampler(c, p.samplers[0]).sample(u, v, ddx, ddy)
+}
+
+func colorFragment(c *softContext, p *softProgram, f *fragment) ([4]float32, bool) {
+	return f.color(), true
+}
+
+func texturedFragment(c *softContext, p *softProgram, f *fragment) ([4]float32, bool) {
+	return mul4(f.color(), p.texture(c, f)), true
+}
+
+// Samples the named sampler at uv, for shaders that read at computed coordinates rather than the fragment's own
+func (p *softProgram) textureAt(c *softContext, name string, u, v float32) [4]float32 {
+	return p.sampler(c, p.uniformLocation(name)).sample(u, v, [2]float32{}, [2]float32{})
+}
+
+// Port of point.fs
+func pointFragment(c *softContext, p *softProgram, f *fragment) ([4]float32, bool) {
+	if len(p.samplers) == 0 {
+		return f.color(), true
+	}
+	tex := p.sampler(c, p.samplers[0]).sample(f.pointCoord[0], f.pointCoord[1], [2]float32{}, [2]float32{})
+	if tex[3] == 0 {
+		return tex, false
+	}
+	return mul4(f.color(), tex), true
+}
+
+// Port of sprite.fs
+func spriteFragment(c *softContext, p *softProgram, f *fragment) ([4]float32, bool) {
+	tex := p.texture(c, f)
+	if tex[3] == 0 {
+		return tex, false
+	}
+	return mul4(f.color(), tex), true
+}
+
+// Port of vignette.fs
+func vignetteFragment(c *softContext, p *softProgram, f *fragment) ([4]float32, bool) {
+	radius := p.uniform(p.uniformLocation("u_radius"), 0)
+	softness := p.uniform(p.uniformLocation("u_softness"), 0)
+	strength := p.uniform(p.uniformLocation("u_strength"), 0)
+
+	color := p.texture(c, f)
+	u, v := f.uv()
+	dist := float32(math.Hypot(float64(u-0.5), float64(v-0.5))) * math.Sqrt2
+	vignette := smoothstep(radius, radius-softness, dist)
+	scale := 1 + (vignette-1)*strength
+	return [4]float32{color[0] * scale, color[1] * scale, color[2] * scale, color[3]}, true
+}
+
+// Port of blur.fs
+func blurFragment(c *softContext, p *softProgram, f *fragment) ([4]float32, bool) {
+	resolution := p.uniformVec2(p.uniformLocation("u_resolution"), [2]float32{1, 1})
+	direction := p.uniformVec2(p.uniformLocation("u_direction"), [2]float32{})
+	texelU, texelV := direction[0]/resolution[0], direction[1]/resolution[1]
+
+	taps := [...]struct{ offset, weight float32 }{
+		{0, 0.2270270270},
+		{1.3846153846, 0.3162162162},
+		{-1.3846153846, 0.3162162162},
+		{3.2307692308, 0.0702702703},
+		{-3.2307692308, 0.0702702703},
+	}
+	u, v := f.uv()
+	var sum [4]float32
+	for _, tap := range taps {
+		sample := p.textureAt(c, "texture1", u+texelU*tap.offset, v+texelV*tap.offset)
+		for i := range sum {
+			sum[i] += sample[i] * tap.weight
+		}
+	}
+	return sum, true
+}
+
+// Port of bright.fs
+func brightPassFragment(c *softContext, p *softProgram, f *fragment) ([4]float32, bool) {
+	threshold := p.uniform(p.uniformLocation("u_threshold"), 0)
+
+	color := p.texture(c, f)
+	lum := color[0]*0.2126 + color[1]*0.7152 + color[2]*0.0722
+	amount := max(lum-threshold, 0) / max(lum, 0.0001)
+	return [4]float32{color[0] * amount, color[1] * amount, color[2] * amount, color[3] * amount}, true
+}
+
+// Port of bloom.fs
+func bloomFragment(c *softContext, p *softProgram, f *fragment) ([4]float32, bool) {
+	intensity := p.uniform(p.uniformLocation("u_intensity"), 0)
+
+	u, v := f.uv()
+	scene := p.textureAt(c, "u_scene", u, v)
+	bloom := p.textureAt(c, "texture1", u, v)
+	return [4]float32{scene[0] + bloom[0]*intensity, scene[1] + bloom[1]*intensity, scene[2] + bloom[2]*intensity, scene[3]}, true
+}
+
+// Port of lut.fs
+func colorGradeFragment(c *softContext, p *softProgram, f *fragment) ([4]float32, bool) {
+	size := p.uniform(p.uniformLocation("u_lutSize"), 0)
+	strength := p.uniform(p.uniformLocation("u_strength"), 0)
+
+	color := p.texture(c, f)
+	alpha := max(color[3], 0.0001)
+	straight := [3]float32{clamp01(color[0] / alpha), clamp01(color[1] / alpha), clamp01(color[2] / alpha)}
+	lookup := func(slice float32) [4]float32 {
+		u := (straight[0]*(size-1) + 0.5 + slice*size) / (size * size)
+		v := (straight[1]*(size-1) + 0.5) / size
+		return p.textureAt(c, "u_lut", u, v)
+	}
+
+	slice := straight[2] * (size - 1)
+	s0 := float32(math.Floor(float64(slice)))
+	s1 := min(s0+1, size-1)
+	graded0, graded1 := lookup(s0), lookup(s1)
+
+	out := [4]float32{0, 0, 0, color[3]}
+	for i := range straight {
+		graded := graded0[i] + (graded1[i]-graded0[i])*(slice-s0)
+		out[i] = (straight[i] + (graded-straight[i])*strength) * color[3]
+	}
+	return out, true
+}
+
+// Port of crt.fs
+func crtFragment(c *softContext, p *softProgram, f *fragment) ([4]float32, bool) {
+	time := p.uniform(p.uniformLocation("u_time"), 0)
+	resolution := p.uniformVec2(p.uniformLocation("u_resolution"), [2]float32{1, 1})
+	curvature := p.uniform(p.uniformLocation("u_curvature"), 0)
+	scanline := p.uniform(p.uniformLocation("u_scanline"), 0)
+
+	u, v := f.uv()
+	x, y := u*2-1, v*2-1
+	x, y = x+x*y*y*curvature, y+y*x*x*curvature
+	u, v = x*0.5+0.5, y*0.5+0.5
+	if u < 0 || u > 1 || v < 0 || v > 1 {
+		return [4]float32{0, 0, 0, 1}, true
+	}
+
+	color := p.textureAt(c, "texture1", u, v)
+	line := float32(math.Sin(float64(v*resolution[1]*math.Pi)))*0.5 + 0.5
+	scale := (1 - scanline*(1-line)) * (0.98 + 0.02*float32(math.Sin(float64(time*110))))
+	return [4]float32{color[0] * scale, color[1] * scale, color[2] * scale, color[3]}, true
+}
+
+func median(r, g, b float32) float32 {
+	return max(min(r, g), min(max(r, g), b))
+}
+
+func smoothstep(edge0, edge1, x float32) float32 {
+	t := clamp01((x - edge0) / (edge1 - edge0))
+	return t * t * (3 - 2*t)
+}
+
+// Port of msdf.fs
+func msdfFragment(c *softContext, p *softProgram, f *fragment) ([4]float32, bool) {
+	const (
+		distanceRange        = 10.0
+		outBias              = 0.25
+		outlineWidthAbsolute = 0.3
+		roundedFonts         = 0.0
+		roundedOutlines      = 0.0
+		gamma                = 1.0
+	)
+	threshold := p.uniform(p.uniformLocation("u_threshold"), 0)
+	outlineWidthRelative := p.uniform(p.uniformLocation("u_outline_width_relative"), 0)
+	outlineBlur := p.uniform(p.uniformLocation("u_outline_blur"), 0)
+	outlineColor := p.uniformVec4(p.uniformLocation("u_outline_color"), [4]float32{})
+
+	innerColor := f.color()
+	outerColor := mul4(outlineColor, innerColor)
+
+	distances := p.texture(c, f)
+	dMSDF := median(distances[0], distances[1], distances[2])
+	dSDF := distances[3]
+	dMSDF = min(dMSDF, dSDF+0.1)
+
+	dInner := dMSDF + (dSDF-dMSDF)*roundedFonts
+	dOuter := dMSDF + (dSDF-dMSDF)*roundedOutlines
+
+	invertedThreshold := 1 - threshold
+
+	// screenPxRange()
+	tex := p.sampler(c, p.samplers[0])
+	width := float32(1)
+	if tex != nil && tex.width > 0 && tex.height > 0 {
+		ddx, ddy := f.uvDerivatives()
+		fwidthU := float32(math.Abs(float64(ddx[0])) + math.Abs(float64(ddy[0])))
+		fwidthV := float32(math.Abs(float64(ddx[1])) + math.Abs(float64(ddy[1])))
+		unitRangeU := distanceRange / float32(tex.width)
+		unitRangeV := distanceRange / float32(tex.height)
+		width = max(0.5*(unitRangeU/max(fwidthU, 1e-9)+unitRangeV/max(fwidthV, 1e-9)), 1)
+	}
+
+	inner := width*(dInner-invertedThreshold) + 0.5 + outBias
+	outer := width*(dOuter-invertedThreshold+outlineWidthRelative) + 0.5 + outBias + outlineWidthAbsolute
+
+	innerOpacity := clamp01(inner)
+	outerOpacity := clamp01(outer)
+
+	if outlineBlur > 0 {
+		blurStart := outlineWidthRelative + outlineWidthAbsolute/width
+		outerColor[3] = smoothstep(blurStart, blurStart*(1-outlineBlur), invertedThreshold-dSDF-outBias/width)
+	}
+
+	innerOpacity = float32(math.Pow(float64(innerOpacity), 1/gamma))
+
+	var color [4]float32
+	for i := range color {
+		color[i] = innerColor[i]*innerOpacity + outerColor[i]*(outerOpacity-innerOpacity)
+	}
+	if color[3] == 0 {
+		return color, false
+	}
+	return color, true
+}
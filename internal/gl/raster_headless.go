@@ -0,0 +1,1523 @@
+//go:build !js && headless
+// +build !js,headless
+
+package gl
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+	"unsafe"
+)
+
+const (
+	maxVertexAttribs    = 16
+	maxTextureUnits     = 32
+	maxColorAttachments = 8
+	maxTextureSize      = 16384
+)
+
+// The software context. It is created the first time a context is made current
+var ctx *softContext
+
+type softBuffer struct {
+	data  []byte
+	usage Enum
+}
+
+type softAttribPointer struct {
+	enabled    bool
+	buffer     uint32
+	size       int
+	ty         Enum
+	normalized bool
+	integer    bool
+	stride     int
+	offset     int
+	divisor    int // If nonzero, the attribute advances once every divisor instances rather than once per vertex
+}
+
+type softVAO struct {
+	attribs       [maxVertexAttribs]softAttribPointer
+	elementBuffer uint32
+}
+
+func newSoftVAO() *softVAO {
+	vao := &softVAO{}
+	for i := range vao.attribs {
+		vao.attribs[i].size = 4
+		vao.attribs[i].ty = FLOAT
+	}
+	return vao
+}
+
+type stencilState struct {
+	fn        Enum
+	ref       int
+	valueMask uint32
+	writeMask uint32
+	fail      Enum
+	zfail     Enum
+	zpass     Enum
+}
+
+type softContext struct {
+	nextID uint32
+
+	window        framebufferSizer
+	defaultFB     *softFramebuffer
+	defaultVAO    *softVAO
+	buffers       map[uint32]*softBuffer
+	vaos          map[uint32]*softVAO
+	textures      map[uint32]*softTexture
+	renderbuffers map[uint32]*softTexture
+	framebuffers  map[uint32]*softFramebuffer
+	shaders       map[uint32]*softShader
+	programs      map[uint32]*softProgram
+	queries       map[uint32]*softQuery
+	activeQuery   *softQuery
+
+	arrayBuffer     uint32
+	otherBuffers    map[Enum]uint32
+	vao             uint32
+	readFramebuffer uint32
+	drawFramebuffer uint32
+	renderbuffer    uint32
+	program         uint32
+	activeUnit      int
+	units           [maxTextureUnits]uint32
+	currentAttribs  [maxVertexAttribs][4]float32
+
+	caps               map[Enum]bool
+	viewport           [4]int
+	scissor            [4]int
+	clearColor         [4]float32
+	clearDepth         float32
+	clearStencil       int
+	colorMask          [4]bool
+	depthMask          bool
+	depthFunc          Enum
+	depthRange         [2]float32
+	blendSrcRGB        Enum
+	blendDstRGB        Enum
+	blendSrcAlpha      Enum
+	blendDstAlpha      Enum
+	blendEquationRGB   Enum
+	blendEquationAlpha Enum
+	blendColor         [4]float32
+	cullFace           Enum
+	frontFace          Enum
+	stencilFront       stencilState
+	stencilBack        stencilState
+	polygonMode        Enum
+	polygonOffset      [2]float32
+	lineWidth          float32
+	unpackAlignment    int
+	packAlignment      int
+
+	err Enum
+}
+
+type softQuery struct {
+	start   time.Time
+	elapsed time.Duration
+}
+
+func newSoftContext() *softContext {
+	c := &softContext{
+		defaultFB:          newSoftFramebuffer(),
+		defaultVAO:         newSoftVAO(),
+		buffers:            make(map[uint32]*softBuffer),
+		vaos:               make(map[uint32]*softVAO),
+		textures:           make(map[uint32]*softTexture),
+		renderbuffers:      make(map[uint32]*softTexture),
+		framebuffers:       make(map[uint32]*softFramebuffer),
+		shaders:            make(map[uint32]*softShader),
+		programs:           make(map[uint32]*softProgram),
+		queries:            make(map[uint32]*softQuery),
+		otherBuffers:       make(map[Enum]uint32),
+		caps:               map[Enum]bool{DITHER: true},
+		clearDepth:         1,
+		colorMask:          [4]bool{true, true, true, true},
+		depthMask:          true,
+		depthFunc:          LESS,
+		depthRange:         [2]float32{0, 1},
+		blendSrcRGB:        ONE,
+		blendDstRGB:        ZERO,
+		blendSrcAlpha:      ONE,
+		blendDstAlpha:      ZERO,
+		blendEquationRGB:   FUNC_ADD,
+		blendEquationAlpha: FUNC_ADD,
+		cullFace:           BACK,
+		frontFace:          CCW,
+		polygonMode:        FILL,
+		lineWidth:          1,
+		unpackAlignment:    4,
+		packAlignment:      4,
+		err:                NO_ERROR,
+	}
+	c.stencilFront = stencilState{fn: ALWAYS, valueMask: 0xFFFFFFFF, writeMask: 0xFFFFFFFF, fail: KEEP, zfail: KEEP, zpass: KEEP}
+	c.stencilBack = c.stencilFront
+	for i := range c.currentAttribs {
+		c.currentAttribs[i] = [4]float32{0, 0, 0, 1}
+	}
+
+	// The default framebuffer has a color, depth and stencil buffer, like the window hints ask for
+	c.defaultFB.drawBuffers = []Enum{BACK}
+	c.defaultFB.readBuffer = BACK
+	c.defaultFB.color[0] = newSoftTexture()
+	depthStencil := newSoftTexture()
+	c.defaultFB.depth = depthStencil
+	c.defaultFB.stencil = depthStencil
+	c.resizeDefaultFramebuffer(1, 1)
+	return c
+}
+
+func (c *softContext) genID() uint32 {
+	c.nextID++
+	return c.nextID
+}
+
+// Only the first error is kept until GetError is called
+func (c *softContext) setError(err Enum) {
+	if c.err == NO_ERROR {
+		c.err = err
+	}
+}
+
+func (c *softContext) resizeDefaultFramebuffer(width, height int) {
+	fb := c.defaultFB
+	if fb.color[0].width == width && fb.color[0].height == height {
+		return
+	}
+	fb.color[0].resize(RGBA, width, height)
+	fb.depth.resize(DEPTH_COMPONENT24, width, height)
+	for i := range fb.depth.pix {
+		if i%4 == 0 {
+			fb.depth.pix[i] = 1
+		}
+	}
+}
+
+func (c *softContext) currentVAO() *softVAO {
+	if c.vao == 0 {
+		return c.defaultVAO
+	}
+	vao := c.vaos[c.vao]
+	if vao == nil {
+		return c.defaultVAO
+	}
+	return vao
+}
+
+func (c *softContext) boundBuffer(target Enum) *softBuffer {
+	switch target {
+	case ARRAY_BUFFER:
+		return c.buffers[c.arrayBuffer]
+	case ELEMENT_ARRAY_BUFFER:
+		return c.buffers[c.currentVAO().elementBuffer]
+	}
+	return c.buffers[c.otherBuffers[target]]
+}
+
+func (c *softContext) boundTexture() *softTexture {
+	return c.textures[c.units[c.activeUnit]]
+}
+
+func (c *softContext) framebuffer(target Enum) *softFramebuffer {
+	id := c.drawFramebuffer
+	if target == READ_FRAMEBUFFER {
+		id = c.readFramebuffer
+	}
+	if id == 0 {
+		if c.window != nil {
+			c.resizeDefaultFramebuffer(c.window.GetFramebufferSize())
+		}
+		return c.defaultFB
+	}
+	fb := c.framebuffers[id]
+	if fb == nil {
+		return c.defaultFB
+	}
+	return fb
+}
+
+func (c *softContext) stencilFaces(face Enum) []*stencilState {
+	switch face {
+	case FRONT:
+		return []*stencilState{&c.stencilFront}
+	case BACK:
+		return []*stencilState{&c.stencilBack}
+	}
+	return []*stencilState{&c.stencilFront, &c.stencilBack}
+}
+
+func (c *softContext) vertexAttribPointer(dst Attrib, size int, ty Enum, normalized, integer bool, stride int, offset int) {
+	if dst.Value < 0 || dst.Value >= maxVertexAttribs {
+		c.setError(INVALID_VALUE)
+		return
+	}
+	a := &c.currentVAO().attribs[dst.Value]
+	a.buffer = c.arrayBuffer
+	a.size = size
+	a.ty = ty
+	a.normalized = normalized
+	a.integer = integer
+	a.stride = stride
+	a.offset = offset
+}
+
+func (c *softContext) setCurrentAttrib(dst Attrib, v []float32) {
+	if dst.Value < 0 || dst.Value >= maxVertexAttribs {
+		c.setError(INVALID_VALUE)
+		return
+	}
+	c.currentAttribs[dst.Value] = [4]float32{0, 0, 0, 1}
+	copy(c.currentAttribs[dst.Value][:], v)
+}
+
+func (c *softContext) setUniform(dst Uniform, components int, src []float32) {
+	prog := c.programs[c.program]
+	if prog == nil {
+		c.setError(INVALID_OPERATION)
+		return
+	}
+	if dst.Value < 0 {
+		// Location -1 is silently ignored
+		return
+	}
+	prog.setUniform(dst.Value, components, src)
+}
+
+func (c *softContext) getInteger(pname Enum) int32 {
+	boolInt := func(b bool) int32 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	switch pname {
+	case ACTIVE_TEXTURE:
+		return int32(TEXTURE0 + c.activeUnit)
+	case CURRENT_PROGRAM:
+		return int32(c.program)
+	case TEXTURE_BINDING_2D:
+		return int32(c.units[c.activeUnit])
+	case ARRAY_BUFFER_BINDING:
+		return int32(c.arrayBuffer)
+	case ELEMENT_ARRAY_BUFFER_BINDING:
+		return int32(c.currentVAO().elementBuffer)
+	case VERTEX_ARRAY_BINDING:
+		return int32(c.vao)
+	case FRAMEBUFFER_BINDING:
+		return int32(c.drawFramebuffer)
+	case READ_FRAMEBUFFER_BINDING:
+		return int32(c.readFramebuffer)
+	case RENDERBUFFER_BINDING:
+		return int32(c.renderbuffer)
+	case BLEND_SRC_RGB:
+		return int32(c.blendSrcRGB)
+	case BLEND_DST_RGB:
+		return int32(c.blendDstRGB)
+	case BLEND_SRC_ALPHA:
+		return int32(c.blendSrcAlpha)
+	case BLEND_DST_ALPHA:
+		return int32(c.blendDstAlpha)
+	case BLEND_EQUATION_RGB:
+		return int32(c.blendEquationRGB)
+	case BLEND_EQUATION_ALPHA:
+		return int32(c.blendEquationAlpha)
+	case CULL_FACE_MODE:
+		return int32(c.cullFace)
+	case FRONT_FACE:
+		return int32(c.frontFace)
+	case DEPTH_FUNC:
+		return int32(c.depthFunc)
+	case DEPTH_WRITEMASK:
+		return boolInt(c.depthMask)
+	case STENCIL_FUNC:
+		return int32(c.stencilFront.fn)
+	case STENCIL_REF:
+		return int32(c.stencilFront.ref)
+	case STENCIL_VALUE_MASK:
+		return int32(c.stencilFront.valueMask)
+	case STENCIL_WRITEMASK:
+		return int32(c.stencilFront.writeMask)
+	case STENCIL_FAIL:
+		return int32(c.stencilFront.fail)
+	case STENCIL_PASS_DEPTH_FAIL:
+		return int32(c.stencilFront.zfail)
+	case STENCIL_PASS_DEPTH_PASS:
+		return int32(c.stencilFront.zpass)
+	case STENCIL_BACK_FUNC:
+		return int32(c.stencilBack.fn)
+	case STENCIL_CLEAR_VALUE:
+		return int32(c.clearStencil)
+	case UNPACK_ALIGNMENT:
+		return int32(c.unpackAlignment)
+	case PACK_ALIGNMENT:
+		return int32(c.packAlignment)
+	case MAX_VERTEX_ATTRIBS:
+		return maxVertexAttribs
+	case MAX_TEXTURE_IMAGE_UNITS, MAX_COMBINED_TEXTURE_IMAGE_UNITS:
+		return maxTextureUnits
+	case MAX_TEXTURE_SIZE, MAX_RENDERBUFFER_SIZE:
+		return maxTextureSize
+	case SAMPLES:
+		return 0
+	}
+	if _, ok := c.caps[pname]; ok {
+		return boolInt(c.caps[pname])
+	}
+	c.setError(INVALID_ENUM)
+	return 0
+}
+
+// --------------------------------------------------------------------------------
+// - Framebuffers
+// --------------------------------------------------------------------------------
+type softFramebuffer struct {
+	color       [maxColorAttachments]*softTexture
+	depth       *softTexture
+	stencil     *softTexture
+	drawBuffers []Enum
+	readBuffer  Enum
+}
+
+func newSoftFramebuffer() *softFramebuffer {
+	return &softFramebuffer{
+		drawBuffers: []Enum{COLOR_ATTACHMENT0},
+		readBuffer:  COLOR_ATTACHMENT0,
+	}
+}
+
+func (fb *softFramebuffer) attach(attachment Enum, tex *softTexture) {
+	switch {
+	case attachment == DEPTH_ATTACHMENT:
+		fb.depth = tex
+	case attachment == STENCIL_ATTACHMENT:
+		fb.stencil = tex
+	case attachment == DEPTH_STENCIL_ATTACHMENT:
+		fb.depth = tex
+		fb.stencil = tex
+	case attachment >= COLOR_ATTACHMENT0 && attachment < COLOR_ATTACHMENT0+maxColorAttachments:
+		fb.color[attachment-COLOR_ATTACHMENT0] = tex
+	default:
+		ctx.setError(INVALID_ENUM)
+	}
+}
+
+func (fb *softFramebuffer) attachment(attachment Enum) *softTexture {
+	switch {
+	case attachment == DEPTH_ATTACHMENT, attachment == DEPTH_STENCIL_ATTACHMENT:
+		return fb.depth
+	case attachment == STENCIL_ATTACHMENT:
+		return fb.stencil
+	case attachment == BACK || attachment == FRONT:
+		return fb.color[0]
+	case attachment >= COLOR_ATTACHMENT0 && attachment < COLOR_ATTACHMENT0+maxColorAttachments:
+		return fb.color[attachment-COLOR_ATTACHMENT0]
+	}
+	return nil
+}
+
+func (fb *softFramebuffer) status() Enum {
+	width, height := -1, -1
+	any := false
+	for _, tex := range fb.allAttachments() {
+		if tex.width == 0 || tex.height == 0 {
+			return FRAMEBUFFER_INCOMPLETE_ATTACHMENT
+		}
+		if width >= 0 && (tex.width != width || tex.height != height) {
+			return FRAMEBUFFER_INCOMPLETE_DIMENSIONS
+		}
+		width, height = tex.width, tex.height
+		any = true
+	}
+	if !any {
+		return FRAMEBUFFER_INCOMPLETE_MISSING_ATTACHMENT
+	}
+	return FRAMEBUFFER_COMPLETE
+}
+
+func (fb *softFramebuffer) allAttachments() []*softTexture {
+	ret := make([]*softTexture, 0, maxColorAttachments+2)
+	for _, tex := range fb.color {
+		if tex != nil {
+			ret = append(ret, tex)
+		}
+	}
+	if fb.depth != nil {
+		ret = append(ret, fb.depth)
+	}
+	if fb.stencil != nil && fb.stencil != fb.depth {
+		ret = append(ret, fb.stencil)
+	}
+	return ret
+}
+
+// Returns the drawable size, which is the intersection of all attachments
+func (fb *softFramebuffer) size() (int, int) {
+	width, height := math.MaxInt, math.MaxInt
+	for _, tex := range fb.allAttachments() {
+		width = min(width, tex.width)
+		height = min(height, tex.height)
+	}
+	if width == math.MaxInt {
+		return 0, 0
+	}
+	return width, height
+}
+
+func (fb *softFramebuffer) drawTextures() []*softTexture {
+	ret := make([]*softTexture, 0, len(fb.drawBuffers))
+	for _, b := range fb.drawBuffers {
+		tex := fb.attachment(b)
+		if tex != nil {
+			ret = append(ret, tex)
+		}
+	}
+	return ret
+}
+
+// --------------------------------------------------------------------------------
+// - Drawing
+// --------------------------------------------------------------------------------
+// screenVertex is a vertex after the perspective divide and viewport transform
+type screenVertex struct {
+	x, y, z float32
+	invW    float32
+	vary    [maxVaryings]float32
+}
+
+// drawTarget caches everything needed to shade fragments for a single draw call
+type drawTarget struct {
+	fb      *softFramebuffer
+	colors  []*softTexture
+	prog    *softProgram
+	clip    [4]int // minX, minY, maxX, maxY (exclusive)
+	depth   bool
+	stencil bool
+	blend   bool
+	cull    bool
+	offset  bool
+}
+
+func (c *softContext) newDrawTarget() (*drawTarget, bool) {
+	prog := c.programs[c.program]
+	if prog == nil || !prog.linked {
+		c.setError(INVALID_OPERATION)
+		return nil, false
+	}
+	fb := c.framebuffer(DRAW_FRAMEBUFFER)
+	if fb.status() != FRAMEBUFFER_COMPLETE {
+		c.setError(INVALID_FRAMEBUFFER_OPERATION)
+		return nil, false
+	}
+	width, height := fb.size()
+
+	t := &drawTarget{
+		fb:      fb,
+		colors:  fb.drawTextures(),
+		prog:    prog,
+		depth:   c.caps[DEPTH_TEST] && fb.depth != nil,
+		stencil: c.caps[STENCIL_TEST] && fb.stencil != nil,
+		blend:   c.caps[BLEND],
+		cull:    c.caps[CULL_FACE],
+		offset:  c.caps[POLYGON_OFFSET_FILL],
+	}
+
+	// Clipping against the x and y planes is equivalent to restricting rasterization to the viewport
+	t.clip = [4]int{
+		max(0, c.viewport[0]),
+		max(0, c.viewport[1]),
+		min(width, c.viewport[0]+c.viewport[2]),
+		min(height, c.viewport[1]+c.viewport[3]),
+	}
+	if c.caps[SCISSOR_TEST] {
+		t.clip[0] = max(t.clip[0], c.scissor[0])
+		t.clip[1] = max(t.clip[1], c.scissor[1])
+		t.clip[2] = min(t.clip[2], c.scissor[0]+c.scissor[2])
+		t.clip[3] = min(t.clip[3], c.scissor[1]+c.scissor[3])
+	}
+	return t, true
+}
+
+func (c *softContext) drawArrays(mode Enum, first, count int) {
+	if first < 0 || count < 0 {
+		c.setError(INVALID_VALUE)
+		return
+	}
+	indices := make([]int, count)
+	for i := range indices {
+		indices[i] = first + i
+	}
+	c.draw(mode, indices, 1)
+}
+
+func (c *softContext) drawElements(mode Enum, count int, ty Enum, offset int, instances int) {
+	ebo := c.buffers[c.currentVAO().elementBuffer]
+	if ebo == nil {
+		c.setError(INVALID_OPERATION)
+		return
+	}
+	size := typeSize(ty)
+	if size == 0 || ty == BYTE || ty == SHORT || ty == INT || ty == FLOAT {
+		c.setError(INVALID_ENUM)
+		return
+	}
+	if offset+count*size > len(ebo.data) {
+		c.setError(INVALID_OPERATION)
+		return
+	}
+	indices := make([]int, count)
+	for i := range indices {
+		indices[i] = int(readUint(ebo.data[offset+i*size:], ty))
+	}
+	c.draw(mode, indices, instances)
+}
+
+func (c *softContext) draw(mode Enum, indices []int, instances int) {
+	for instance := 0; instance < instances; instance++ {
+		c.drawInstance(mode, indices, instance)
+	}
+}
+
+func (c *softContext) drawInstance(mode Enum, indices []int, instance int) {
+	t, ok := c.newDrawTarget()
+	if !ok {
+		return
+	}
+	if t.clip[0] >= t.clip[2] || t.clip[1] >= t.clip[3] {
+		return
+	}
+
+	// Run the vertex stage once per unique vertex
+	vao := c.currentVAO()
+	cache := make(map[int]*softVertex, len(indices))
+	vertex := func(index int) *softVertex {
+		if v, ok := cache[index]; ok {
+			return v
+		}
+		v := t.prog.runVertex(func(loc int) [4]float32 {
+			return c.fetchAttrib(vao, loc, index, instance)
+		})
+		cache[index] = &v
+		return &v
+	}
+
+	switch mode {
+	case TRIANGLES:
+		for i := 0; i+2 < len(indices); i += 3 {
+			c.drawTriangle(t, vertex(indices[i]), vertex(indices[i+1]), vertex(indices[i+2]))
+		}
+	case TRIANGLE_STRIP:
+		for i := 0; i+2 < len(indices); i++ {
+			if i%2 == 0 {
+				c.drawTriangle(t, vertex(indices[i]), vertex(indices[i+1]), vertex(indices[i+2]))
+			} else {
+				c.drawTriangle(t, vertex(indices[i+1]), vertex(indices[i]), vertex(indices[i+2]))
+			}
+		}
+	case TRIANGLE_FAN:
+		for i := 1; i+1 < len(indices); i++ {
+			c.drawTriangle(t, vertex(indices[0]), vertex(indices[i]), vertex(indices[i+1]))
+		}
+	case POINTS:
+		for i := range indices {
+			c.drawPoint(t, vertex(indices[i]))
+		}
+	case LINES:
+		for i := 0; i+1 < len(indices); i += 2 {
+			c.drawLine(t, vertex(indices[i]), vertex(indices[i+1]))
+		}
+	case LINE_STRIP, LINE_LOOP:
+		for i := 0; i+1 < len(indices); i++ {
+			c.drawLine(t, vertex(indices[i]), vertex(indices[i+1]))
+		}
+		if mode == LINE_LOOP && len(indices) > 2 {
+			c.drawLine(t, vertex(indices[len(indices)-1]), vertex(indices[0]))
+		}
+	default:
+		c.setError(INVALID_ENUM)
+	}
+}
+
+func (c *softContext) fetchAttrib(vao *softVAO, loc int, index, instance int) [4]float32 {
+	if loc < 0 || loc >= maxVertexAttribs {
+		return [4]float32{0, 0, 0, 1}
+	}
+	a := &vao.attribs[loc]
+	if !a.enabled {
+		return c.currentAttribs[loc]
+	}
+	buf := c.buffers[a.buffer]
+	if buf == nil {
+		return c.currentAttribs[loc]
+	}
+	if a.divisor > 0 {
+		index = instance / a.divisor
+	}
+
+	elemSize := typeSize(a.ty)
+	stride := a.stride
+	if stride == 0 {
+		stride = a.size * elemSize
+	}
+	base := a.offset + index*stride
+	out := [4]float32{0, 0, 0, 1}
+	if base < 0 || base+a.size*elemSize > len(buf.data) {
+		c.setError(INVALID_OPERATION)
+		return out
+	}
+	for i := 0; i < a.size && i < 4; i++ {
+		out[i] = readComponent(buf.data[base+i*elemSize:], a.ty, a.normalized && !a.integer)
+	}
+	return out
+}
+
+// Clips a polygon against a single plane, where dist returns a positive number for vertices on the inside
+func clipPolygon(poly []softVertex, dist func(v *softVertex) float32) []softVertex {
+	if len(poly) == 0 {
+		return poly
+	}
+	out := make([]softVertex, 0, len(poly)+2)
+	prev := poly[len(poly)-1]
+	prevDist := dist(&prev)
+	for _, cur := range poly {
+		curDist := dist(&cur)
+		if (prevDist >= 0) != (curDist >= 0) {
+			t := prevDist / (prevDist - curDist)
+			out = append(out, lerpVertex(&prev, &cur, t))
+		}
+		if curDist >= 0 {
+			out = append(out, cur)
+		}
+		prev, prevDist = cur, curDist
+	}
+	return out
+}
+
+func lerpVertex(a, b *softVertex, t float32) softVertex {
+	var v softVertex
+	for i := range v.pos {
+		v.pos[i] = a.pos[i] + (b.pos[i]-a.pos[i])*t
+	}
+	for i := range v.vary {
+		v.vary[i] = a.vary[i] + (b.vary[i]-a.vary[i])*t
+	}
+	v.pointSize = a.pointSize + (b.pointSize-a.pointSize)*t
+	return v
+}
+
+func (c *softContext) toScreen(v *softVertex) screenVertex {
+	invW := 1 / v.pos[3]
+	vp := c.viewport
+	s := screenVertex{
+		x:    float32(vp[0]) + (v.pos[0]*invW+1)*0.5*float32(vp[2]),
+		y:    float32(vp[1]) + (v.pos[1]*invW+1)*0.5*float32(vp[3]),
+		z:    c.depthRange[0] + (v.pos[2]*invW+1)*0.5*(c.depthRange[1]-c.depthRange[0]),
+		invW: invW,
+		vary: v.vary,
+	}
+	return s
+}
+
+func (c *softContext) drawTriangle(t *drawTarget, v0, v1, v2 *softVertex) {
+	poly := []softVertex{*v0, *v1, *v2}
+	poly = clipPolygon(poly, func(v *softVertex) float32 { return v.pos[3] - 1e-6 })
+	poly = clipPolygon(poly, func(v *softVertex) float32 { return v.pos[2] + v.pos[3] })
+	poly = clipPolygon(poly, func(v *softVertex) float32 { return v.pos[3] - v.pos[2] })
+	if len(poly) < 3 {
+		return
+	}
+
+	screen := make([]screenVertex, len(poly))
+	for i := range poly {
+		screen[i] = c.toScreen(&poly[i])
+	}
+
+	// Facing is decided by the whole polygon so that clipping can't flip it
+	area := float32(0)
+	for i := range screen {
+		j := (i + 1) % len(screen)
+		area += screen[i].x*screen[j].y - screen[j].x*screen[i].y
+	}
+	if area == 0 {
+		return
+	}
+	front := (area > 0) == (c.frontFace == CCW)
+	if t.cull {
+		switch c.cullFace {
+		case FRONT_AND_BACK:
+			return
+		case FRONT:
+			if front {
+				return
+			}
+		case BACK:
+			if !front {
+				return
+			}
+		}
+	}
+
+	if c.polygonMode == LINE {
+		for i := range poly {
+			c.drawLine(t, &poly[i], &poly[(i+1)%len(poly)])
+		}
+		return
+	}
+	if c.polygonMode == POINT {
+		for i := range poly {
+			c.drawPoint(t, &poly[i])
+		}
+		return
+	}
+
+	for i := 1; i+1 < len(screen); i++ {
+		c.rasterTriangle(t, screen[0], screen[i], screen[i+1], front)
+	}
+}
+
+func edgeFunc(ax, ay, bx, by, px, py float32) float32 {
+	return (bx-ax)*(py-ay) - (by-ay)*(px-ax)
+}
+
+// For counter-clockwise triangles (in a y-up coordinate system) the interior is on the left of each edge. Top edges are horizontal and point left, left edges point down.
+func isTopLeft(a, b *screenVertex) bool {
+	dx := b.x - a.x
+	dy := b.y - a.y
+	return (dy == 0 && dx < 0) || dy < 0
+}
+
+func (c *softContext) rasterTriangle(t *drawTarget, s0, s1, s2 screenVertex, front bool) {
+	area := edgeFunc(s0.x, s0.y, s1.x, s1.y, s2.x, s2.y)
+	if area == 0 {
+		return
+	}
+	if area < 0 {
+		s1, s2 = s2, s1
+		area = -area
+	}
+
+	minX := max(t.clip[0], int(math.Floor(float64(min(s0.x, s1.x, s2.x)))))
+	minY := max(t.clip[1], int(math.Floor(float64(min(s0.y, s1.y, s2.y)))))
+	maxX := min(t.clip[2]-1, int(math.Ceil(float64(max(s0.x, s1.x, s2.x)))))
+	maxY := min(t.clip[3]-1, int(math.Ceil(float64(max(s0.y, s1.y, s2.y)))))
+	if minX > maxX || minY > maxY {
+		return
+	}
+
+	// Screen space derivatives of each varying, used for fwidth and texture filtering
+	var frag fragment
+	dx1, dy1 := s1.x-s0.x, s1.y-s0.y
+	dx2, dy2 := s2.x-s0.x, s2.y-s0.y
+	for k := range frag.ddx {
+		d1 := s1.vary[k] - s0.vary[k]
+		d2 := s2.vary[k] - s0.vary[k]
+		frag.ddx[k] = (d1*dy2 - d2*dy1) / area
+		frag.ddy[k] = (d2*dx1 - d1*dx2) / area
+	}
+	frag.front = front
+
+	zOffset := float32(0)
+	if t.offset {
+		dzdx := ((s1.z-s0.z)*dy2 - (s2.z-s0.z)*dy1) / area
+		dzdy := ((s2.z-s0.z)*dx1 - (s1.z-s0.z)*dx2) / area
+		slope := max(float32(math.Abs(float64(dzdx))), float32(math.Abs(float64(dzdy))))
+		zOffset = c.polygonOffset[0]*slope + c.polygonOffset[1]/(1<<24)
+	}
+
+	topLeft0 := isTopLeft(&s1, &s2)
+	topLeft1 := isTopLeft(&s2, &s0)
+	topLeft2 := isTopLeft(&s0, &s1)
+
+	for y := minY; y <= maxY; y++ {
+		py := float32(y) + 0.5
+		for x := minX; x <= maxX; x++ {
+			px := float32(x) + 0.5
+			w0 := edgeFunc(s1.x, s1.y, s2.x, s2.y, px, py)
+			w1 := edgeFunc(s2.x, s2.y, s0.x, s0.y, px, py)
+			w2 := edgeFunc(s0.x, s0.y, s1.x, s1.y, px, py)
+			if w0 < 0 || w1 < 0 || w2 < 0 {
+				continue
+			}
+			if (w0 == 0 && !topLeft0) || (w1 == 0 && !topLeft1) || (w2 == 0 && !topLeft2) {
+				continue
+			}
+
+			b0, b1, b2 := w0/area, w1/area, w2/area
+
+			// Perspective correct interpolation
+			p0, p1, p2 := b0*s0.invW, b1*s1.invW, b2*s2.invW
+			sum := p0 + p1 + p2
+			p0, p1, p2 = p0/sum, p1/sum, p2/sum
+			for k := range frag.vary {
+				frag.vary[k] = p0*s0.vary[k] + p1*s1.vary[k] + p2*s2.vary[k]
+			}
+			frag.x = px
+			frag.y = py
+			frag.z = clamp01(b0*s0.z + b1*s1.z + b2*s2.z + zOffset)
+
+			c.shade(t, x, y, &frag)
+		}
+	}
+}
+
+func (c *softContext) drawPoint(t *drawTarget, v *softVertex) {
+	if v.pos[3] <= 0 || v.pos[2] < -v.pos[3] || v.pos[2] > v.pos[3] {
+		return
+	}
+	s := c.toScreen(v)
+	size := max(v.pointSize, 1)
+	half := size / 2
+
+	var frag fragment
+	frag.vary = s.vary
+	frag.z = clamp01(s.z)
+	frag.front = true
+	minX := max(t.clip[0], int(math.Floor(float64(s.x-half+0.5))))
+	minY := max(t.clip[1], int(math.Floor(float64(s.y-half+0.5))))
+	maxX := min(t.clip[2], int(math.Floor(float64(s.x+half+0.5))))
+	maxY := min(t.clip[3], int(math.Floor(float64(s.y+half+0.5))))
+	for y := minY; y < maxY; y++ {
+		for x := minX; x < maxX; x++ {
+			frag.x = float32(x) + 0.5
+			frag.y = float32(y) + 0.5
+			// Point coordinates have their origin in the upper left corner of the point
+			frag.pointCoord = [2]float32{
+				(frag.x - (s.x - half)) / size,
+				1 - (frag.y-(s.y-half))/size,
+			}
+			c.shade(t, x, y, &frag)
+		}
+	}
+}
+
+func (c *softContext) drawLine(t *drawTarget, v0, v1 *softVertex) {
+	poly := []softVertex{*v0, *v1}
+	clipLine := func(dist func(v *softVertex) float32) bool {
+		d0, d1 := dist(&poly[0]), dist(&poly[1])
+		if d0 < 0 && d1 < 0 {
+			return false
+		}
+		if d0 < 0 {
+			poly[0] = lerpVertex(&poly[0], &poly[1], d0/(d0-d1))
+		} else if d1 < 0 {
+			poly[1] = lerpVertex(&poly[1], &poly[0], d1/(d1-d0))
+		}
+		return true
+	}
+	if !clipLine(func(v *softVertex) float32 { return v.pos[3] - 1e-6 }) ||
+		!clipLine(func(v *softVertex) float32 { return v.pos[2] + v.pos[3] }) ||
+		!clipLine(func(v *softVertex) float32 { return v.pos[3] - v.pos[2] }) {
+		return
+	}
+
+	s0 := c.toScreen(&poly[0])
+	s1 := c.toScreen(&poly[1])
+	dx := s1.x - s0.x
+	dy := s1.y - s0.y
+	steps := int(math.Ceil(math.Max(math.Abs(float64(dx)), math.Abs(float64(dy)))))
+	if steps == 0 {
+		steps = 1
+	}
+
+	var frag fragment
+	frag.front = true
+	for i := 0; i < steps; i++ {
+		a := (float32(i) + 0.5) / float32(steps)
+		px := s0.x + dx*a
+		py := s0.y + dy*a
+		x, y := int(math.Floor(float64(px))), int(math.Floor(float64(py)))
+		if x < t.clip[0] || x >= t.clip[2] || y < t.clip[1] || y >= t.clip[3] {
+			continue
+		}
+
+		p0 := (1 - a) * s0.invW
+		p1 := a * s1.invW
+		sum := p0 + p1
+		for k := range frag.vary {
+			frag.vary[k] = (p0*s0.vary[k] + p1*s1.vary[k]) / sum
+		}
+		frag.x = float32(x) + 0.5
+		frag.y = float32(y) + 0.5
+		frag.z = clamp01(s0.z + (s1.z-s0.z)*a)
+		c.shade(t, x, y, &frag)
+	}
+}
+
+// Runs the fragment stage and all per-fragment operations for a single pixel
+func (c *softContext) shade(t *drawTarget, x, y int, frag *fragment) {
+	color, keep := t.prog.runFragment(c, frag)
+	if !keep {
+		return
+	}
+
+	if t.stencil {
+		s := &c.stencilFront
+		if !frag.front {
+			s = &c.stencilBack
+		}
+		stencilTex := t.fb.stencil
+		i := stencilTex.index(x, y) + stencilChannel
+		stored := int(stencilTex.pix[i])
+		if !compare(s.fn, float32(s.ref&int(s.valueMask)&0xFF), float32(stored&int(s.valueMask))) {
+			stencilTex.pix[i] = float32(stencilOp(s.fail, stored, s.ref, s.writeMask))
+			return
+		}
+		if t.depth && !compare(c.depthFunc, frag.z, t.fb.depth.pix[t.fb.depth.index(x, y)]) {
+			stencilTex.pix[i] = float32(stencilOp(s.zfail, stored, s.ref, s.writeMask))
+			return
+		}
+		stencilTex.pix[i] = float32(stencilOp(s.zpass, stored, s.ref, s.writeMask))
+	} else if t.depth && !compare(c.depthFunc, frag.z, t.fb.depth.pix[t.fb.depth.index(x, y)]) {
+		return
+	}
+
+	if t.depth && c.depthMask {
+		t.fb.depth.pix[t.fb.depth.index(x, y)] = frag.z
+	}
+
+	for _, tex := range t.colors {
+		src := color
+		if tex.normalized() {
+			src = clamp4(src)
+		}
+		dst := tex.texel(x, y)
+		out := src
+		if t.blend {
+			out = c.blend(src, dst)
+		}
+		for i := range out {
+			if !c.colorMask[i] {
+				out[i] = dst[i]
+			}
+		}
+		tex.setTexel(x, y, out)
+	}
+}
+
+func compare(fn Enum, a, b float32) bool {
+	switch fn {
+	case NEVER:
+		return false
+	case LESS:
+		return a < b
+	case EQUAL:
+		return a == b
+	case LEQUAL:
+		return a <= b
+	case GREATER:
+		return a > b
+	case NOTEQUAL:
+		return a != b
+	case GEQUAL:
+		return a >= b
+	}
+	return true // ALWAYS
+}
+
+func stencilOp(op Enum, stored, ref int, writeMask uint32) int {
+	var v int
+	switch op {
+	case ZERO:
+		v = 0
+	case REPLACE:
+		v = ref
+	case INCR:
+		v = min(stored+1, 0xFF)
+	case DECR:
+		v = max(stored-1, 0)
+	case INCR_WRAP:
+		v = (stored + 1) & 0xFF
+	case DECR_WRAP:
+		v = (stored - 1) & 0xFF
+	case INVERT:
+		v = ^stored
+	default: // KEEP
+		return stored
+	}
+	mask := int(writeMask & 0xFF)
+	return (stored &^ mask) | (v & mask)
+}
+
+func (c *softContext) blendFactor(factor Enum, src, dst [4]float32) [4]float32 {
+	switch factor {
+	case ZERO:
+		return [4]float32{0, 0, 0, 0}
+	case ONE:
+		return [4]float32{1, 1, 1, 1}
+	case SRC_COLOR:
+		return src
+	case ONE_MINUS_SRC_COLOR:
+		return [4]float32{1 - src[0], 1 - src[1], 1 - src[2], 1 - src[3]}
+	case DST_COLOR:
+		return dst
+	case ONE_MINUS_DST_COLOR:
+		return [4]float32{1 - dst[0], 1 - dst[1], 1 - dst[2], 1 - dst[3]}
+	case SRC_ALPHA:
+		return [4]float32{src[3], src[3], src[3], src[3]}
+	case ONE_MINUS_SRC_ALPHA:
+		a := 1 - src[3]
+		return [4]float32{a, a, a, a}
+	case DST_ALPHA:
+		return [4]float32{dst[3], dst[3], dst[3], dst[3]}
+	case ONE_MINUS_DST_ALPHA:
+		a := 1 - dst[3]
+		return [4]float32{a, a, a, a}
+	case CONSTANT_COLOR:
+		return c.blendColor
+	case ONE_MINUS_CONSTANT_COLOR:
+		b := c.blendColor
+		return [4]float32{1 - b[0], 1 - b[1], 1 - b[2], 1 - b[3]}
+	case CONSTANT_ALPHA:
+		a := c.blendColor[3]
+		return [4]float32{a, a, a, a}
+	case ONE_MINUS_CONSTANT_ALPHA:
+		a := 1 - c.blendColor[3]
+		return [4]float32{a, a, a, a}
+	case SRC_ALPHA_SATURATE:
+		f := min(src[3], 1-dst[3])
+		return [4]float32{f, f, f, 1}
+	}
+	return [4]float32{1, 1, 1, 1}
+}
+
+func blendEquation(eq Enum, s, d, sf, df float32) float32 {
+	switch eq {
+	case FUNC_SUBTRACT:
+		return s*sf - d*df
+	case FUNC_REVERSE_SUBTRACT:
+		return d*df - s*sf
+	case MIN:
+		return min(s, d)
+	case MAX:
+		return max(s, d)
+	}
+	return s*sf + d*df
+}
+
+func (c *softContext) blend(src, dst [4]float32) [4]float32 {
+	srcRGB := c.blendFactor(c.blendSrcRGB, src, dst)
+	dstRGB := c.blendFactor(c.blendDstRGB, src, dst)
+	srcA := c.blendFactor(c.blendSrcAlpha, src, dst)
+	dstA := c.blendFactor(c.blendDstAlpha, src, dst)
+
+	var out [4]float32
+	for i := 0; i < 3; i++ {
+		out[i] = blendEquation(c.blendEquationRGB, src[i], dst[i], srcRGB[i], dstRGB[i])
+	}
+	out[3] = blendEquation(c.blendEquationAlpha, src[3], dst[3], srcA[3], dstA[3])
+	return out
+}
+
+// --------------------------------------------------------------------------------
+// - Framebuffer operations
+// --------------------------------------------------------------------------------
+func (c *softContext) clear(mask Enum) {
+	fb := c.framebuffer(DRAW_FRAMEBUFFER)
+	width, height := fb.size()
+	rect := [4]int{0, 0, width, height}
+	if c.caps[SCISSOR_TEST] {
+		rect[0] = max(rect[0], c.scissor[0])
+		rect[1] = max(rect[1], c.scissor[1])
+		rect[2] = min(rect[2], c.scissor[0]+c.scissor[2])
+		rect[3] = min(rect[3], c.scissor[1]+c.scissor[3])
+	}
+
+	if mask&COLOR_BUFFER_BIT != 0 {
+		for _, tex := range fb.drawTextures() {
+			for y := rect[1]; y < rect[3]; y++ {
+				for x := rect[0]; x < rect[2]; x++ {
+					out := c.clearColor
+					if c.colorMask != [4]bool{true, true, true, true} {
+						dst := tex.texel(x, y)
+						for i := range out {
+							if !c.colorMask[i] {
+								out[i] = dst[i]
+							}
+						}
+					}
+					tex.setTexel(x, y, out)
+				}
+			}
+		}
+	}
+	if mask&DEPTH_BUFFER_BIT != 0 && fb.depth != nil && c.depthMask {
+		for y := rect[1]; y < rect[3]; y++ {
+			for x := rect[0]; x < rect[2]; x++ {
+				fb.depth.pix[fb.depth.index(x, y)] = c.clearDepth
+			}
+		}
+	}
+	if mask&STENCIL_BUFFER_BIT != 0 && fb.stencil != nil {
+		writeMask := int(c.stencilFront.writeMask & 0xFF)
+		for y := rect[1]; y < rect[3]; y++ {
+			for x := rect[0]; x < rect[2]; x++ {
+				i := fb.stencil.index(x, y) + stencilChannel
+				stored := int(fb.stencil.pix[i])
+				fb.stencil.pix[i] = float32((stored &^ writeMask) | (c.clearStencil & writeMask))
+			}
+		}
+	}
+}
+
+func (c *softContext) readPixels(dst []byte, x, y, width, height int, format, ty Enum) {
+	fb := c.framebuffer(READ_FRAMEBUFFER)
+	var src *softTexture
+	channel := 0
+	switch format {
+	case DEPTH_COMPONENT:
+		src = fb.depth
+	case STENCIL_INDEX:
+		src = fb.stencil
+		channel = stencilChannel
+	default:
+		src = fb.attachment(fb.readBuffer)
+	}
+	if src == nil {
+		c.setError(INVALID_OPERATION)
+		return
+	}
+
+	pixSize := pixelSize(format, ty)
+	rowSize := alignUp(width*pixSize, c.packAlignment)
+	if len(dst) < rowSize*(height-1)+width*pixSize {
+		c.setError(INVALID_VALUE)
+		return
+	}
+	for j := 0; j < height; j++ {
+		sy := y + j
+		if sy < 0 || sy >= src.height {
+			continue
+		}
+		for i := 0; i < width; i++ {
+			sx := x + i
+			if sx < 0 || sx >= src.width {
+				continue
+			}
+			texel := src.texel(sx, sy)
+			var comps []float32
+			switch format {
+			case RGBA:
+				comps = texel[:]
+			case RGB:
+				comps = texel[:3]
+			case RED, DEPTH_COMPONENT:
+				comps = texel[:1]
+			case ALPHA:
+				comps = texel[3:]
+			case STENCIL_INDEX:
+				comps = []float32{src.pix[src.index(sx, sy)+channel]}
+			default:
+				c.setError(INVALID_ENUM)
+				return
+			}
+			writePixel(dst[j*rowSize+i*pixSize:], comps, ty, format == STENCIL_INDEX)
+		}
+	}
+}
+
+func (c *softContext) copyToTexture(dst *softTexture, xoffset, yoffset, x, y, width, height int) {
+	fb := c.framebuffer(READ_FRAMEBUFFER)
+	src := fb.attachment(fb.readBuffer)
+	if src == nil {
+		c.setError(INVALID_OPERATION)
+		return
+	}
+	for j := 0; j < height; j++ {
+		for i := 0; i < width; i++ {
+			sx, sy := x+i, y+j
+			dx, dy := xoffset+i, yoffset+j
+			if sx < 0 || sy < 0 || sx >= src.width || sy >= src.height {
+				continue
+			}
+			if dx < 0 || dy < 0 || dx >= dst.width || dy >= dst.height {
+				continue
+			}
+			dst.setTexel(dx, dy, src.texel(sx, sy))
+		}
+	}
+}
+
+func (c *softContext) blit(srcRect, dstRect [4]int, mask, filter Enum) {
+	readFB := c.framebuffer(READ_FRAMEBUFFER)
+	drawFB := c.framebuffer(DRAW_FRAMEBUFFER)
+
+	if mask&(DEPTH_BUFFER_BIT|STENCIL_BUFFER_BIT) != 0 && filter != NEAREST {
+		c.setError(INVALID_OPERATION)
+		return
+	}
+
+	dw := float32(dstRect[2] - dstRect[0])
+	dh := float32(dstRect[3] - dstRect[1])
+	if dw == 0 || dh == 0 {
+		return
+	}
+	scaleX := float32(srcRect[2]-srcRect[0]) / dw
+	scaleY := float32(srcRect[3]-srcRect[1]) / dh
+
+	dstMinX, dstMaxX := min(dstRect[0], dstRect[2]), max(dstRect[0], dstRect[2])
+	dstMinY, dstMaxY := min(dstRect[1], dstRect[3]), max(dstRect[1], dstRect[3])
+	width, height := drawFB.size()
+	dstMinX, dstMinY = max(dstMinX, 0), max(dstMinY, 0)
+	dstMaxX, dstMaxY = min(dstMaxX, width), min(dstMaxY, height)
+	if c.caps[SCISSOR_TEST] {
+		dstMinX = max(dstMinX, c.scissor[0])
+		dstMinY = max(dstMinY, c.scissor[1])
+		dstMaxX = min(dstMaxX, c.scissor[0]+c.scissor[2])
+		dstMaxY = min(dstMaxY, c.scissor[1]+c.scissor[3])
+	}
+
+	type copyPair struct {
+		src, dst *softTexture
+		channel  int
+	}
+	pairs := make([]copyPair, 0)
+	if mask&COLOR_BUFFER_BIT != 0 {
+		src := readFB.attachment(readFB.readBuffer)
+		if src != nil {
+			for _, dst := range drawFB.drawTextures() {
+				pairs = append(pairs, copyPair{src, dst, -1})
+			}
+		}
+	}
+	if mask&DEPTH_BUFFER_BIT != 0 && readFB.depth != nil && drawFB.depth != nil {
+		pairs = append(pairs, copyPair{readFB.depth, drawFB.depth, 0})
+	}
+	if mask&STENCIL_BUFFER_BIT != 0 && readFB.stencil != nil && drawFB.stencil != nil {
+		pairs = append(pairs, copyPair{readFB.stencil, drawFB.stencil, stencilChannel})
+	}
+
+	for _, pair := range pairs {
+		// Snapshot the source so that blitting within the same framebuffer reads unmodified pixels
+		src := pair.src.clone()
+		for y := dstMinY; y < dstMaxY; y++ {
+			sy := float32(srcRect[1]) + (float32(y)+0.5-float32(dstRect[1]))*scaleY
+			for x := dstMinX; x < dstMaxX; x++ {
+				sx := float32(srcRect[0]) + (float32(x)+0.5-float32(dstRect[0]))*scaleX
+				if pair.channel >= 0 {
+					ix, iy := int(math.Floor(float64(sx))), int(math.Floor(float64(sy)))
+					if ix < 0 || iy < 0 || ix >= src.width || iy >= src.height {
+						continue
+					}
+					pair.dst.pix[pair.dst.index(x, y)+pair.channel] = src.pix[src.index(ix, iy)+pair.channel]
+					continue
+				}
+
+				if filter == LINEAR {
+					pair.dst.setTexel(x, y, src.sampleLinear(sx/float32(src.width), sy/float32(src.height), CLAMP_TO_EDGE, CLAMP_TO_EDGE))
+				} else {
+					ix, iy := int(math.Floor(float64(sx))), int(math.Floor(float64(sy)))
+					if ix < 0 || iy < 0 || ix >= src.width || iy >= src.height {
+						continue
+					}
+					pair.dst.setTexel(x, y, src.texel(ix, iy))
+				}
+			}
+		}
+	}
+}
+
+// --------------------------------------------------------------------------------
+// - Data conversion
+// --------------------------------------------------------------------------------
+func typeSize(ty Enum) int {
+	switch ty {
+	case BYTE, UNSIGNED_BYTE:
+		return 1
+	case SHORT, UNSIGNED_SHORT, HALF_FLOAT:
+		return 2
+	case INT, UNSIGNED_INT, FLOAT:
+		return 4
+	}
+	return 0
+}
+
+func readUint(b []byte, ty Enum) uint32 {
+	switch ty {
+	case UNSIGNED_BYTE:
+		return uint32(b[0])
+	case UNSIGNED_SHORT:
+		return uint32(binary.NativeEndian.Uint16(b))
+	}
+	return binary.NativeEndian.Uint32(b)
+}
+
+func readComponent(b []byte, ty Enum, normalized bool) float32 {
+	switch ty {
+	case FLOAT:
+		return math.Float32frombits(binary.NativeEndian.Uint32(b))
+	case HALF_FLOAT:
+		return halfToFloat32(binary.NativeEndian.Uint16(b))
+	case BYTE:
+		v := int8(b[0])
+		if normalized {
+			return max(float32(v)/127, -1)
+		}
+		return float32(v)
+	case UNSIGNED_BYTE:
+		if normalized {
+			return float32(b[0]) / 255
+		}
+		return float32(b[0])
+	case SHORT:
+		v := int16(binary.NativeEndian.Uint16(b))
+		if normalized {
+			return max(float32(v)/32767, -1)
+		}
+		return float32(v)
+	case UNSIGNED_SHORT:
+		v := binary.NativeEndian.Uint16(b)
+		if normalized {
+			return float32(v) / 65535
+		}
+		return float32(v)
+	case INT:
+		v := int32(binary.NativeEndian.Uint32(b))
+		if normalized {
+			return max(float32(float64(v)/math.MaxInt32), -1)
+		}
+		return float32(v)
+	case UNSIGNED_INT:
+		v := binary.NativeEndian.Uint32(b)
+		if normalized {
+			return float32(float64(v) / math.MaxUint32)
+		}
+		return float32(v)
+	}
+	return 0
+}
+
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1F
+	mant := uint32(h & 0x3FF)
+	switch {
+	case exp == 0x1F:
+		// Infinity or NaN
+		return math.Float32frombits(sign | 0xFF<<23 | mant<<13)
+	case exp == 0:
+		// Zero or a subnormal, which is mant * 2^-24
+		v := float32(mant) / (1 << 24)
+		if sign != 0 {
+			return -v
+		}
+		return v
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+}
+
+func writePixel(dst []byte, comps []float32, ty Enum, integer bool) {
+	size := typeSize(ty)
+	for i, v := range comps {
+		b := dst[i*size:]
+		switch ty {
+		case FLOAT:
+			binary.NativeEndian.PutUint32(b, math.Float32bits(v))
+		case UNSIGNED_BYTE:
+			if integer {
+				b[0] = uint8(v)
+			} else {
+				b[0] = uint8(math.Round(float64(clamp01(v)) * 255))
+			}
+		case UNSIGNED_SHORT:
+			binary.NativeEndian.PutUint16(b, uint16(math.Round(float64(clamp01(v))*65535)))
+		case UNSIGNED_INT:
+			if integer {
+				binary.NativeEndian.PutUint32(b, uint32(v))
+			} else {
+				binary.NativeEndian.PutUint32(b, uint32(math.Round(float64(clamp01(v))*math.MaxUint32)))
+			}
+		}
+	}
+}
+
+func formatComponents(format Enum) int {
+	switch format {
+	case RGBA:
+		return 4
+	case RGB:
+		return 3
+	case LUMINANCE_ALPHA, RG:
+		return 2
+	case RED, ALPHA, LUMINANCE, DEPTH_COMPONENT, STENCIL_INDEX, DEPTH_STENCIL:
+		return 1
+	}
+	return 4
+}
+
+func pixelSize(format, ty Enum) int {
+	switch ty {
+	case UNSIGNED_SHORT_5_6_5, UNSIGNED_SHORT_4_4_4_4, UNSIGNED_SHORT_5_5_5_1:
+		return 2
+	case UNSIGNED_INT_24_8:
+		return 4
+	}
+	return formatComponents(format) * typeSize(ty)
+}
+
+func alignUp(v, alignment int) int {
+	if alignment <= 1 {
+		return v
+	}
+	return (v + alignment - 1) / alignment * alignment
+}
+
+func toBytes(data interface{}) []byte {
+	switch t := data.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return t
+	case []float32:
+		return sliceBytes(t)
+	case []uint32:
+		return sliceBytes(t)
+	case []int32:
+		return sliceBytes(t)
+	case []uint16:
+		return sliceBytes(t)
+	case []int16:
+		return sliceBytes(t)
+	case [][2]float32:
+		return sliceBytes(t)
+	case [][3]float32:
+		return sliceBytes(t)
+	case [][4]float32:
+		return sliceBytes(t)
+	}
+	panic("Invalid data type!")
+}
+
+func sliceBytes[T any](s []T) []byte {
+	if len(s) == 0 {
+		return nil
+	}
+	var zero T
+	return unsafe.Slice((*byte)(unsafe.Pointer(&s[0])), len(s)*int(unsafe.Sizeof(zero)))
+}
+
+func intsToFloats(src []int32) []float32 {
+	ret := make([]float32, len(src))
+	for i := range src {
+		ret[i] = float32(src[i])
+	}
+	return ret
+}
+
+func clamp01(v float32) float32 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+func clamp4(v [4]float32) [4]float32 {
+	return [4]float32{clamp01(v[0]), clamp01(v[1]), clamp01(v[2]), clamp01(v[3])}
+}
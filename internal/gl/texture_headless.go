@@ -0,0 +1,263 @@
+//go:build !js && headless
+// +build !js,headless
+
+package gl
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Depth is stored in the first channel of a depth texture and stencil is stored in the second, so a combined depth stencil texture can be attached to both attachment points
+const stencilChannel = 1
+
+// softTexture backs textures, renderbuffers and the default framebuffer. Texels are stored as 4 float32s and are quantized on write to match the precision of the internal format. Row 0 is the bottom row, like GL.
+type softTexture struct {
+	width, height int
+	format        Enum
+	pix           []float32
+
+	minFilter   Enum
+	magFilter   Enum
+	wrapS       Enum
+	wrapT       Enum
+	borderColor [4]float32
+	anisotropy  float32 // Stored, but the software sampler doesn't filter anisotropically
+}
+
+func newSoftTexture() *softTexture {
+	return &softTexture{
+		format:    RGBA,
+		minFilter: NEAREST_MIPMAP_LINEAR,
+		magFilter: LINEAR,
+		wrapS:     REPEAT,
+		wrapT:     REPEAT,
+	}
+}
+
+func (t *softTexture) resize(format Enum, width, height int) {
+	t.format = format
+	t.width = width
+	t.height = height
+	t.pix = make([]float32, width*height*4)
+}
+
+func (t *softTexture) clone() *softTexture {
+	c := *t
+	c.pix = append([]float32(nil), t.pix...)
+	return &c
+}
+
+func (t *softTexture) index(x, y int) int {
+	return (y*t.width + x) * 4
+}
+
+func (t *softTexture) isDepth() bool {
+	switch t.format {
+	case DEPTH_COMPONENT, DEPTH_COMPONENT16, DEPTH_COMPONENT24, DEPTH_COMPONENT32F, STENCIL_INDEX8, DEPTH24_STENCIL8:
+		return true
+	}
+	return false
+}
+
+// Returns true if the format stores values clamped to [0, 1]
+func (t *softTexture) normalized() bool {
+	switch t.format {
+	case RGBA16F, RGBA32F, R16F, R32F, RG16F, RG32F:
+		return false
+	}
+	return !t.isDepth()
+}
+
+// Returns the number of bits stored per channel for normalized formats
+func (t *softTexture) bits() [4]int {
+	switch t.format {
+	case RGBA4:
+		return [4]int{4, 4, 4, 4}
+	case RGB565:
+		return [4]int{5, 6, 5, 0}
+	case RGB5_A1:
+		return [4]int{5, 5, 5, 1}
+	}
+	return [4]int{8, 8, 8, 8}
+}
+
+func (t *softTexture) texel(x, y int) [4]float32 {
+	i := t.index(x, y)
+	p := t.pix[i : i+4 : i+4]
+	if t.isDepth() {
+		return [4]float32{p[0], 0, 0, 1}
+	}
+	return [4]float32{p[0], p[1], p[2], p[3]}
+}
+
+// Stores a color, converting it to the texture's internal format
+func (t *softTexture) setTexel(x, y int, v [4]float32) {
+	i := t.index(x, y)
+	if t.isDepth() {
+		t.pix[i] = clamp01(v[0])
+		return
+	}
+
+	switch t.format {
+	case RGB, RGB565:
+		v[3] = 1
+	case RED, R8, R16F, R32F:
+		v = [4]float32{v[0], 0, 0, 1}
+	case RG, RG8, RG16F, RG32F:
+		v = [4]float32{v[0], v[1], 0, 1}
+	case ALPHA:
+		v = [4]float32{0, 0, 0, v[3]}
+	case LUMINANCE:
+		v = [4]float32{v[0], v[0], v[0], 1}
+	case LUMINANCE_ALPHA:
+		v = [4]float32{v[0], v[0], v[0], v[3]}
+	}
+
+	if !t.normalized() {
+		copy(t.pix[i:i+4], v[:])
+		return
+	}
+
+	bits := t.bits()
+	for c := range v {
+		if bits[c] == 0 {
+			t.pix[i+c] = 1
+			continue
+		}
+		scale := float32(int(1)<<bits[c] - 1)
+		t.pix[i+c] = float32(math.Round(float64(clamp01(v[c])*scale))) / scale
+	}
+}
+
+// Decodes client pixel data into the texture
+func (t *softTexture) upload(x, y, width, height int, format, ty Enum, data []byte, alignment int) {
+	pixSize := pixelSize(format, ty)
+	rowSize := alignUp(width*pixSize, alignment)
+	if len(data) < rowSize*(height-1)+width*pixSize {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+
+	comps := formatComponents(format)
+	size := typeSize(ty)
+	var raw [4]float32
+	for j := 0; j < height; j++ {
+		for i := 0; i < width; i++ {
+			b := data[j*rowSize+i*pixSize:]
+			switch ty {
+			case UNSIGNED_INT_24_8:
+				// Packed depth stencil is written straight into the depth and stencil channels
+				v := binary.NativeEndian.Uint32(b)
+				idx := t.index(x+i, y+j)
+				t.pix[idx] = float32(v>>8) / 0xFFFFFF
+				t.pix[idx+stencilChannel] = float32(v & 0xFF)
+				continue
+			case UNSIGNED_SHORT_5_6_5:
+				v := binary.NativeEndian.Uint16(b)
+				raw = [4]float32{float32(v>>11) / 31, float32((v>>5)&0x3F) / 63, float32(v&0x1F) / 31, 1}
+			case UNSIGNED_SHORT_4_4_4_4:
+				v := binary.NativeEndian.Uint16(b)
+				raw = [4]float32{float32(v>>12) / 15, float32((v>>8)&0xF) / 15, float32((v>>4)&0xF) / 15, float32(v&0xF) / 15}
+			case UNSIGNED_SHORT_5_5_5_1:
+				v := binary.NativeEndian.Uint16(b)
+				raw = [4]float32{float32(v>>11) / 31, float32((v>>6)&0x1F) / 31, float32((v>>1)&0x1F) / 31, float32(v & 1)}
+			default:
+				for c := 0; c < comps; c++ {
+					raw[c] = readComponent(b[c*size:], ty, ty != FLOAT && ty != HALF_FLOAT)
+				}
+			}
+
+			var v [4]float32
+			switch format {
+			case RGBA:
+				v = raw
+			case RGB:
+				v = [4]float32{raw[0], raw[1], raw[2], 1}
+			case RED, DEPTH_COMPONENT:
+				v = [4]float32{raw[0], 0, 0, 1}
+			case RG:
+				v = [4]float32{raw[0], raw[1], 0, 1}
+			case ALPHA:
+				v = [4]float32{0, 0, 0, raw[0]}
+			case LUMINANCE:
+				v = [4]float32{raw[0], raw[0], raw[0], 1}
+			case LUMINANCE_ALPHA:
+				v = [4]float32{raw[0], raw[0], raw[0], raw[1]}
+			default:
+				ctx.setError(INVALID_ENUM)
+				return
+			}
+			t.setTexel(x+i, y+j, v)
+		}
+	}
+}
+
+func wrapCoord(i, n int, mode Enum) int {
+	switch mode {
+	case REPEAT:
+		return ((i % n) + n) % n
+	case MIRRORED_REPEAT:
+		period := 2 * n
+		i = ((i % period) + period) % period
+		if i >= n {
+			i = period - 1 - i
+		}
+		return i
+	}
+	return min(max(i, 0), n-1)
+}
+
+func (t *softTexture) sampleNearest(u, v float32, wrapS, wrapT Enum) [4]float32 {
+	x := wrapCoord(int(math.Floor(float64(u*float32(t.width)))), t.width, wrapS)
+	y := wrapCoord(int(math.Floor(float64(v*float32(t.height)))), t.height, wrapT)
+	return t.texel(x, y)
+}
+
+func (t *softTexture) sampleLinear(u, v float32, wrapS, wrapT Enum) [4]float32 {
+	fx := u*float32(t.width) - 0.5
+	fy := v*float32(t.height) - 0.5
+	x0 := int(math.Floor(float64(fx)))
+	y0 := int(math.Floor(float64(fy)))
+	ax := fx - float32(x0)
+	ay := fy - float32(y0)
+	x1 := wrapCoord(x0+1, t.width, wrapS)
+	y1 := wrapCoord(y0+1, t.height, wrapT)
+	x0 = wrapCoord(x0, t.width, wrapS)
+	y0 = wrapCoord(y0, t.height, wrapT)
+
+	t00, t10 := t.texel(x0, y0), t.texel(x1, y0)
+	t01, t11 := t.texel(x0, y1), t.texel(x1, y1)
+	var out [4]float32
+	for c := range out {
+		top := t00[c] + (t10[c]-t00[c])*ax
+		bottom := t01[c] + (t11[c]-t01[c])*ax
+		out[c] = top + (bottom-top)*ay
+	}
+	return out
+}
+
+// Samples the texture. The uv derivatives are used to choose between the minification and magnification filters
+func (t *softTexture) sample(u, v float32, ddx, ddy [2]float32) [4]float32 {
+	if t == nil || t.width == 0 || t.height == 0 {
+		return [4]float32{0, 0, 0, 1}
+	}
+
+	w, h := float32(t.width), float32(t.height)
+	rhoX := (ddx[0]*w)*(ddx[0]*w) + (ddx[1]*h)*(ddx[1]*h)
+	rhoY := (ddy[0]*w)*(ddy[0]*w) + (ddy[1]*h)*(ddy[1]*h)
+	filter := t.magFilter
+	if max(rhoX, rhoY) > 1 {
+		switch t.minFilter {
+		case NEAREST, NEAREST_MIPMAP_NEAREST, NEAREST_MIPMAP_LINEAR:
+			filter = NEAREST
+		default:
+			filter = LINEAR
+		}
+	}
+
+	if filter == NEAREST {
+		return t.sampleNearest(u, v, t.wrapS, t.wrapT)
+	}
+	return t.sampleLinear(u, v, t.wrapS, t.wrapT)
+}
@@ -27,6 +27,16 @@ type Buffer struct {
 	Value uint32
 }
 
+// Sync identifies a fence that is signaled once the GPU has finished every command before it.
+type Sync struct {
+	Value uintptr
+}
+
+// Query identifies a GL query object.
+type Query struct {
+	Value uint32
+}
+
 // Framebuffer identifies a GL framebuffer.
 type Framebuffer struct {
 	Value uint32
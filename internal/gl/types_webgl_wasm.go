@@ -31,6 +31,14 @@ type Framebuffer struct {
 	js.Value
 }
 
+type Sync struct {
+	js.Value
+}
+
+type Query struct {
+	js.Value
+}
+
 func (o Framebuffer) Equal(o2 Framebuffer) bool {
 	return o.Value.Equal(o2.Value)
 }
@@ -0,0 +1,737 @@
+//go:build !js && headless
+// +build !js,headless
+
+package glfw
+
+// This is a windowless stand-in for the desktop backend. It is selected with the `headless` build tag and is intended to be paired with the software renderer in internal/gl so that windows can be created on machines without a display or a GPU (ie CI).
+
+import "errors"
+
+var contextWatcher ContextWatcher
+
+// Init initializes the library.
+//
+// A valid ContextWatcher must be provided. It gets notified when context becomes current or detached.
+func Init(cw ContextWatcher) error {
+	if cw == nil {
+		return errors.New("glfw: Init requires a ContextWatcher")
+	}
+	contextWatcher = cw
+	return nil
+}
+
+func Terminate() {
+	contextWatcher = nil
+	currentContext = nil
+}
+
+var currentContext *Window
+
+func CreateWindow(width, height int, title string, monitor *Monitor, share *Window) (*Window, error) {
+	if width <= 0 || height <= 0 {
+		return nil, errors.New("glfw: invalid window size")
+	}
+
+	window := &Window{
+		title:             title,
+		width:             width,
+		height:            height,
+		scaleX:            1,
+		scaleY:            1,
+		currentScreenMode: ScreenModeWindowed,
+		lastWindow: winRect{
+			width:  width,
+			height: height,
+		},
+	}
+
+	if monitor != nil {
+		window.currentScreenMode = ScreenModeFull
+	}
+
+	return window, nil
+}
+
+func SwapInterval(interval int) {}
+
+type winRect struct {
+	xpos, ypos, width, height int
+}
+
+type Window struct {
+	title                string
+	xpos, ypos           int
+	width, height        int
+	scaleX, scaleY       float32
+	cursorX, cursorY     float64
+	shouldClose          bool
+	clipboard            string
+	currentScreenMode    ScreenModeType
+	lastWindow           winRect
+	inputModes           map[InputMode]int
+	keys                 map[Key]Action
+	mouseButtons         map[MouseButton]Action
+	cursorPosCallback    CursorPosCallback
+	keyCallback          KeyCallback
+	charCallback         CharCallback
+	scrollCallback       ScrollCallback
+	mouseButtonCallback  MouseButtonCallback
+	framebufferCallback  FramebufferSizeCallback
+	sizeCallback         SizeCallback
+	closeCallback        CloseCallback
+	refreshCallback      RefreshCallback
+	cursorEnterCallback  CursorEnterCallback
+	charModsCallback     CharModsCallback
+	posCallback          PosCallback
+	focusCallback        FocusCallback
+	iconifyCallback      IconifyCallback
+	dropCallback         DropCallback
+	mouseMovementCallbck MouseMovementCallback
+}
+
+func (w *Window) MakeContextCurrent() {
+	currentContext = w
+	// The headless gl backend reads the default framebuffer size from the context that we pass it
+	contextWatcher.OnMakeCurrent(w)
+}
+
+func DetachCurrentContext() {
+	currentContext = nil
+	contextWatcher.OnDetach()
+}
+
+func GetCurrentContext() *Window {
+	return currentContext
+}
+
+func (w *Window) SwapBuffers() {}
+
+func (w *Window) ShouldClose() bool {
+	return w.shouldClose
+}
+
+func (w *Window) SetShouldClose(value bool) {
+	w.shouldClose = value
+	if value && w.closeCallback != nil {
+		w.closeCallback(w)
+	}
+}
+
+func (w *Window) SetTitle(title string) {
+	w.title = title
+}
+
+func (w *Window) Show()    {}
+func (w *Window) Hide()    {}
+func (w *Window) Destroy() {}
+
+func (w *Window) GetPos() (x, y int) {
+	return w.xpos, w.ypos
+}
+
+func (w *Window) SetPos(xpos, ypos int) {
+	w.xpos = xpos
+	w.ypos = ypos
+	if w.posCallback != nil {
+		w.posCallback(w, xpos, ypos)
+	}
+}
+
+func (w *Window) GetSize() (width, height int) {
+	return w.width, w.height
+}
+
+// Resizes the window and fires the size and framebuffer size callbacks, like a user dragging the window would
+func (w *Window) SetSize(width, height int) {
+	w.width = width
+	w.height = height
+	if w.sizeCallback != nil {
+		w.sizeCallback(w, width, height)
+	}
+	if w.framebufferCallback != nil {
+		fw, fh := w.GetFramebufferSize()
+		w.framebufferCallback(w, fw, fh)
+	}
+}
+
+func (w *Window) GetFramebufferSize() (width, height int) {
+	return int(float32(w.width) * w.scaleX), int(float32(w.height) * w.scaleY)
+}
+
+func (w *Window) GetContentScale() (float32, float32) {
+	return w.scaleX, w.scaleY
+}
+
+// Sets the content scale and fires the framebuffer size callback, like moving the window to a HiDPI display would
+func (w *Window) SetContentScale(x, y float32) {
+	w.scaleX = x
+	w.scaleY = y
+	if w.framebufferCallback != nil {
+		fw, fh := w.GetFramebufferSize()
+		w.framebufferCallback(w, fw, fh)
+	}
+}
+
+func (w *Window) GetCursorPos() (x, y float64) {
+	return w.cursorX, w.cursorY
+}
+
+// Moves the virtual cursor and fires the cursor position callbacks
+func (w *Window) SetCursorPos(xpos, ypos float64) {
+	lastX, lastY := w.cursorX, w.cursorY
+	w.cursorX = xpos
+	w.cursorY = ypos
+	if w.cursorPosCallback != nil {
+		w.cursorPosCallback(w, xpos, ypos)
+	}
+	if w.mouseMovementCallbck != nil {
+		w.mouseMovementCallbck(w, xpos, ypos, xpos-lastX, ypos-lastY)
+	}
+}
+
+// Always returns false because we aren't in a browser
+func (w *Window) BrowserHidden() bool {
+	return false
+}
+
+func (w *Window) EmbeddedIframe() bool {
+	return false
+}
+
+func (w *Window) GetKey(key Key) Action {
+	return w.keys[key]
+}
+
+// Sets the state of a key and fires the key callback. Used to simulate input
+func (w *Window) SetKey(key Key, action Action, mods ModifierKey) {
+	if w.keys == nil {
+		w.keys = make(map[Key]Action)
+	}
+	w.keys[key] = action
+	if w.keyCallback != nil {
+		w.keyCallback(w, key, 0, action, mods)
+	}
+}
+
+func (w *Window) GetMouseButton(button MouseButton) Action {
+	return w.mouseButtons[button]
+}
+
+// Sets the state of a mouse button and fires the mouse button callback. Used to simulate input
+func (w *Window) SetMouseButton(button MouseButton, action Action, mods ModifierKey) {
+	if w.mouseButtons == nil {
+		w.mouseButtons = make(map[MouseButton]Action)
+	}
+	w.mouseButtons[button] = action
+	if w.mouseButtonCallback != nil {
+		w.mouseButtonCallback(w, button, action, mods)
+	}
+}
+
+func (w *Window) GetInputMode(mode InputMode) int {
+	return w.inputModes[mode]
+}
+
+func (w *Window) SetInputMode(mode InputMode, value int) {
+	if w.inputModes == nil {
+		w.inputModes = make(map[InputMode]int)
+	}
+	w.inputModes[mode] = value
+}
+
+func (w *Window) SetClipboardString(str string) {
+	w.clipboard = str
+}
+
+func (w *Window) GetClipboardString() string {
+	return w.clipboard
+}
+
+func (w *Window) ScreenMode() ScreenModeType {
+	return w.currentScreenMode
+}
+
+func (w *Window) SetScreenMode(smt ScreenModeType) {
+	if smt == ScreenModeFull && w.currentScreenMode == ScreenModeWindowed {
+		w.lastWindow = winRect{w.xpos, w.ypos, w.width, w.height}
+		mode := GetPrimaryMonitor().GetVideoMode()
+		w.SetMonitor(GetPrimaryMonitor(), 0, 0, mode.Width, mode.Height, mode.RefreshRate)
+	} else if smt == ScreenModeWindowed && w.currentScreenMode == ScreenModeFull {
+		w.SetMonitor(nil, w.lastWindow.xpos, w.lastWindow.ypos, w.lastWindow.width, w.lastWindow.height, 0)
+	}
+
+	w.currentScreenMode = smt
+}
+
+// Note: Passing in nil here gives you a window instead of fullscreen
+func (w *Window) SetMonitor(monitor *Monitor, xpos, ypos, width, height, refreshRate int) {
+	w.xpos = xpos
+	w.ypos = ypos
+	w.SetSize(width, height)
+}
+
+func (w *Window) GetMonitor() *Monitor {
+	if w.currentScreenMode == ScreenModeFull {
+		return GetPrimaryMonitor()
+	}
+	return nil
+}
+
+func (w *Window) GetAttrib(attrib Hint) int {
+	return hints[attrib]
+}
+
+func (w *Window) GetConnectedGamepads() []Joystick {
+	return nil
+}
+
+// --------------------------------------------------------------------------------
+// - Callbacks
+// --------------------------------------------------------------------------------
+type CursorPosCallback func(w *Window, xpos float64, ypos float64)
+
+func (w *Window) SetCursorPosCallback(cbfun CursorPosCallback) (previous CursorPosCallback) {
+	previous, w.cursorPosCallback = w.cursorPosCallback, cbfun
+	return previous
+}
+
+type MouseMovementCallback func(w *Window, xpos float64, ypos float64, xdelta float64, ydelta float64)
+
+func (w *Window) SetMouseMovementCallback(cbfun MouseMovementCallback) (previous MouseMovementCallback) {
+	previous, w.mouseMovementCallbck = w.mouseMovementCallbck, cbfun
+	return previous
+}
+
+type KeyCallback func(w *Window, key Key, scancode int, action Action, mods ModifierKey)
+
+func (w *Window) SetKeyCallback(cbfun KeyCallback) (previous KeyCallback) {
+	previous, w.keyCallback = w.keyCallback, cbfun
+	return previous
+}
+
+type CharCallback func(w *Window, char rune)
+
+func (w *Window) SetCharCallback(cbfun CharCallback) (previous CharCallback) {
+	previous, w.charCallback = w.charCallback, cbfun
+	return previous
+}
+
+type ScrollCallback func(w *Window, xoff float64, yoff float64)
+
+func (w *Window) SetScrollCallback(cbfun ScrollCallback) (previous ScrollCallback) {
+	previous, w.scrollCallback = w.scrollCallback, cbfun
+	return previous
+}
+
+type MouseButtonCallback func(w *Window, button MouseButton, action Action, mods ModifierKey)
+
+func (w *Window) SetMouseButtonCallback(cbfun MouseButtonCallback) (previous MouseButtonCallback) {
+	previous, w.mouseButtonCallback = w.mouseButtonCallback, cbfun
+	return previous
+}
+
+type FramebufferSizeCallback func(w *Window, width int, height int)
+
+func (w *Window) SetFramebufferSizeCallback(cbfun FramebufferSizeCallback) (previous FramebufferSizeCallback) {
+	previous, w.framebufferCallback = w.framebufferCallback, cbfun
+	return previous
+}
+
+type CloseCallback func(w *Window)
+
+func (w *Window) SetCloseCallback(cbfun CloseCallback) (previous CloseCallback) {
+	previous, w.closeCallback = w.closeCallback, cbfun
+	return previous
+}
+
+type RefreshCallback func(w *Window)
+
+func (w *Window) SetRefreshCallback(cbfun RefreshCallback) (previous RefreshCallback) {
+	previous, w.refreshCallback = w.refreshCallback, cbfun
+	return previous
+}
+
+type SizeCallback func(w *Window, width int, height int)
+
+func (w *Window) SetSizeCallback(cbfun SizeCallback) (previous SizeCallback) {
+	previous, w.sizeCallback = w.sizeCallback, cbfun
+	return previous
+}
+
+type CursorEnterCallback func(w *Window, entered bool)
+
+func (w *Window) SetCursorEnterCallback(cbfun CursorEnterCallback) (previous CursorEnterCallback) {
+	previous, w.cursorEnterCallback = w.cursorEnterCallback, cbfun
+	return previous
+}
+
+type CharModsCallback func(w *Window, char rune, mods ModifierKey)
+
+func (w *Window) SetCharModsCallback(cbfun CharModsCallback) (previous CharModsCallback) {
+	previous, w.charModsCallback = w.charModsCallback, cbfun
+	return previous
+}
+
+type PosCallback func(w *Window, xpos int, ypos int)
+
+func (w *Window) SetPosCallback(cbfun PosCallback) (previous PosCallback) {
+	previous, w.posCallback = w.posCallback, cbfun
+	return previous
+}
+
+type FocusCallback func(w *Window, focused bool)
+
+func (w *Window) SetFocusCallback(cbfun FocusCallback) (previous FocusCallback) {
+	previous, w.focusCallback = w.focusCallback, cbfun
+	return previous
+}
+
+type IconifyCallback func(w *Window, iconified bool)
+
+func (w *Window) SetIconifyCallback(cbfun IconifyCallback) (previous IconifyCallback) {
+	previous, w.iconifyCallback = w.iconifyCallback, cbfun
+	return previous
+}
+
+type DropCallback func(w *Window, names []string)
+
+func (w *Window) SetDropCallback(cbfun DropCallback) (previous DropCallback) {
+	previous, w.dropCallback = w.dropCallback, cbfun
+	return previous
+}
+
+// Types a character into the window by firing the char callbacks. Used to simulate input
+func (w *Window) TypeChar(char rune, mods ModifierKey) {
+	if w.charCallback != nil {
+		w.charCallback(w, char)
+	}
+	if w.charModsCallback != nil {
+		w.charModsCallback(w, char, mods)
+	}
+}
+
+// Scrolls the window by firing the scroll callback. Used to simulate input
+func (w *Window) Scroll(xoff, yoff float64) {
+	if w.scrollCallback != nil {
+		w.scrollCallback(w, xoff, yoff)
+	}
+}
+
+// --------------------------------------------------------------------------------
+// - Monitors
+// --------------------------------------------------------------------------------
+type Monitor struct {
+	mode VidMode
+}
+
+var primaryMonitor = &Monitor{
+	mode: VidMode{
+		Width:       1920,
+		Height:      1080,
+		RedBits:     8,
+		GreenBits:   8,
+		BlueBits:    8,
+		RefreshRate: 60,
+	},
+}
+
+func GetPrimaryMonitor() *Monitor {
+	return primaryMonitor
+}
+
+func GetMonitors() []*Monitor {
+	return []*Monitor{primaryMonitor}
+}
+
+func (m *Monitor) GetVideoMode() *VidMode {
+	mode := m.mode
+	return &mode
+}
+
+func (m *Monitor) GetVideoModes() []*VidMode {
+	return []*VidMode{m.GetVideoMode()}
+}
+
+// --------------------------------------------------------------------------------
+// - Events
+// --------------------------------------------------------------------------------
+func PollEvents()                       {}
+func WaitEvents()                       {}
+func WaitEventsTimeout(timeout float64) {}
+func PostEmptyEvent()                   {}
+func DefaultWindowHints()               { clear(hints) }
+func GetKeyScanCode(key Key) int        { return int(key) }
+func GetKeyName(key Key, scancode int) string {
+	if key >= KeyA && key <= KeyZ {
+		return string(rune('a' + (key - KeyA)))
+	}
+	if key >= Key0 && key <= Key9 {
+		return string(rune('0' + (key - Key0)))
+	}
+	return ""
+}
+
+// --------------------------------------------------------------------------------
+// - Joysticks
+// --------------------------------------------------------------------------------
+type Joystick int
+
+// List all of the joysticks.
+const (
+	Joystick1 Joystick = iota
+	Joystick2
+	Joystick3
+	Joystick4
+	Joystick5
+	Joystick6
+	Joystick7
+	Joystick8
+	Joystick9
+	Joystick10
+	Joystick11
+	Joystick12
+	Joystick13
+	Joystick14
+	Joystick15
+	Joystick16
+
+	JoystickLast = Joystick16
+)
+
+type PeripheralEvent int
+
+const (
+	Connected    PeripheralEvent = 0x00040001
+	Disconnected PeripheralEvent = 0x00040002
+)
+
+type GamepadState struct {
+	Buttons [15]Action
+	Axes    [6]float32
+}
+
+// No joysticks are ever connected to a headless window
+func (j Joystick) GetName() string                { return "" }
+func (j Joystick) GetButtons() []Action           { return nil }
+func (j Joystick) GetAxes() []float32             { return nil }
+func (j Joystick) Present() bool                  { return false }
+func (j Joystick) IsGamepad() bool                { return false }
+func (j Joystick) GetGamepadState() *GamepadState { return nil }
+
+type GamepadAxis int
+
+const (
+	AxisLeftX GamepadAxis = iota
+	AxisLeftY
+	AxisRightX
+	AxisRightY
+	AxisLeftTrigger
+	AxisRightTrigger
+	AxisLast = AxisRightTrigger
+)
+
+type GamepadButton int
+
+// Gamepad button IDs.
+const (
+	ButtonA GamepadButton = iota
+	ButtonB
+	ButtonX
+	ButtonY
+	ButtonLeftBumper
+	ButtonRightBumper
+	ButtonBack
+	ButtonStart
+	ButtonGuide
+	ButtonLeftThumb
+	ButtonRightThumb
+	ButtonDpadUp
+	ButtonDpadRight
+	ButtonDpadDown
+	ButtonDpadLeft
+	ButtonLast = ButtonDpadLeft
+
+	ButtonCross    = ButtonA
+	ButtonCircle   = ButtonB
+	ButtonSquare   = ButtonX
+	ButtonTriangle = ButtonY
+)
+
+// --------------------------------------------------------------------------------
+// - Input enums (These match the values that glfw uses)
+// --------------------------------------------------------------------------------
+type Action int
+
+const (
+	Release Action = 0
+	Press   Action = 1
+	Repeat  Action = 2
+)
+
+type InputMode int
+
+const (
+	CursorMode             InputMode = 0x00033001
+	StickyKeysMode         InputMode = 0x00033002
+	StickyMouseButtonsMode InputMode = 0x00033003
+)
+
+const (
+	CursorNormal   = 0x00034001
+	CursorHidden   = 0x00034002
+	CursorDisabled = 0x00034003
+)
+
+type ModifierKey int
+
+const (
+	ModShift ModifierKey = (1 << iota)
+	ModControl
+	ModAlt
+	ModSuper
+)
+
+type MouseButton int
+
+const (
+	MouseButton1 MouseButton = iota
+	MouseButton2
+	MouseButton3
+	MouseButton4
+	MouseButton5
+	MouseButton6
+	MouseButton7
+	MouseButton8
+
+	MouseButtonLast   = MouseButton8
+	MouseButtonLeft   = MouseButton1
+	MouseButtonRight  = MouseButton2
+	MouseButtonMiddle = MouseButton3
+)
+
+type Key int
+
+const (
+	KeyUnknown      Key = -1
+	KeySpace        Key = 32
+	KeyApostrophe   Key = 39
+	KeyComma        Key = 44
+	KeyMinus        Key = 45
+	KeyPeriod       Key = 46
+	KeySlash        Key = 47
+	Key0            Key = 48
+	Key1            Key = 49
+	Key2            Key = 50
+	Key3            Key = 51
+	Key4            Key = 52
+	Key5            Key = 53
+	Key6            Key = 54
+	Key7            Key = 55
+	Key8            Key = 56
+	Key9            Key = 57
+	KeySemicolon    Key = 59
+	KeyEqual        Key = 61
+	KeyA            Key = 65
+	KeyB            Key = 66
+	KeyC            Key = 67
+	KeyD            Key = 68
+	KeyE            Key = 69
+	KeyF            Key = 70
+	KeyG            Key = 71
+	KeyH            Key = 72
+	KeyI            Key = 73
+	KeyJ            Key = 74
+	KeyK            Key = 75
+	KeyL            Key = 76
+	KeyM            Key = 77
+	KeyN            Key = 78
+	KeyO            Key = 79
+	KeyP            Key = 80
+	KeyQ            Key = 81
+	KeyR            Key = 82
+	KeyS            Key = 83
+	KeyT            Key = 84
+	KeyU            Key = 85
+	KeyV            Key = 86
+	KeyW            Key = 87
+	KeyX            Key = 88
+	KeyY            Key = 89
+	KeyZ            Key = 90
+	KeyLeftBracket  Key = 91
+	KeyBackslash    Key = 92
+	KeyRightBracket Key = 93
+	KeyGraveAccent  Key = 96
+	KeyWorld1       Key = 161
+	KeyWorld2       Key = 162
+	KeyEscape       Key = 256
+	KeyEnter        Key = 257
+	KeyTab          Key = 258
+	KeyBackspace    Key = 259
+	KeyInsert       Key = 260
+	KeyDelete       Key = 261
+	KeyRight        Key = 262
+	KeyLeft         Key = 263
+	KeyDown         Key = 264
+	KeyUp           Key = 265
+	KeyPageUp       Key = 266
+	KeyPageDown     Key = 267
+	KeyHome         Key = 268
+	KeyEnd          Key = 269
+	KeyCapsLock     Key = 280
+	KeyScrollLock   Key = 281
+	KeyNumLock      Key = 282
+	KeyPrintScreen  Key = 283
+	KeyPause        Key = 284
+	KeyF1           Key = 290
+	KeyF2           Key = 291
+	KeyF3           Key = 292
+	KeyF4           Key = 293
+	KeyF5           Key = 294
+	KeyF6           Key = 295
+	KeyF7           Key = 296
+	KeyF8           Key = 297
+	KeyF9           Key = 298
+	KeyF10          Key = 299
+	KeyF11          Key = 300
+	KeyF12          Key = 301
+	KeyF13          Key = 302
+	KeyF14          Key = 303
+	KeyF15          Key = 304
+	KeyF16          Key = 305
+	KeyF17          Key = 306
+	KeyF18          Key = 307
+	KeyF19          Key = 308
+	KeyF20          Key = 309
+	KeyF21          Key = 310
+	KeyF22          Key = 311
+	KeyF23          Key = 312
+	KeyF24          Key = 313
+	KeyF25          Key = 314
+	KeyKP0          Key = 320
+	KeyKP1          Key = 321
+	KeyKP2          Key = 322
+	KeyKP3          Key = 323
+	KeyKP4          Key = 324
+	KeyKP5          Key = 325
+	KeyKP6          Key = 326
+	KeyKP7          Key = 327
+	KeyKP8          Key = 328
+	KeyKP9          Key = 329
+	KeyKPDecimal    Key = 330
+	KeyKPDivide     Key = 331
+	KeyKPMultiply   Key = 332
+	KeyKPSubtract   Key = 333
+	KeyKPAdd        Key = 334
+	KeyKPEnter      Key = 335
+	KeyKPEqual      Key = 336
+	KeyLeftShift    Key = 340
+	KeyLeftControl  Key = 341
+	KeyLeftAlt      Key = 342
+	KeyLeftSuper    Key = 343
+	KeyRightShift   Key = 344
+	KeyRightControl Key = 345
+	KeyRightAlt     Key = 346
+	KeyRightSuper   Key = 347
+	KeyMenu         Key = 348
+	KeyLast         Key = KeyMenu
+)
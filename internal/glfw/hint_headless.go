@@ -0,0 +1,47 @@
+//go:build !js && headless
+// +build !js,headless
+
+package glfw
+
+var hints = make(map[Hint]int)
+
+const (
+	True  = 1
+	False = 0
+
+	OpenGLCoreProfile = 0x00032001
+)
+
+type Hint int
+
+const (
+	AlphaBits Hint = iota
+	DepthBits
+	StencilBits
+	Samples
+	Resizable
+
+	ContextVersionMajor
+	ContextVersionMinor
+	OpenGLProfile
+	OpenGLForwardCompatible
+
+	// These hints used for WebGL contexts, ignored on desktop.
+	PremultipliedAlpha
+	PreserveDrawingBuffer
+	PreferLowPowerToHighPerformance
+	FailIfMajorPerformanceCaveat
+
+	Focused
+	Decorated
+	Floating
+	AutoIconify
+	TransparentFramebuffer
+	Maximized
+	Visible
+)
+
+// Hints are stored so they can be inspected, but the headless window doesn't act on any of them
+func WindowHint(target Hint, hint int) {
+	hints[target] = hint
+}
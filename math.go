@@ -19,6 +19,8 @@ type RGBA = glm.RGBA
 type glVec2 [2]float32
 type glVec3 [3]float32
 type glVec4 [4]float32
+type glUByte4 [4]uint8
+type glUShort2 [2]uint16
 
 func glv2(v glm.Vec2) glVec2 {
 	return glVec2{float32(v.X), float32(v.Y)}
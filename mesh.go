@@ -12,8 +12,10 @@ type Mesh struct {
 	normals   []glVec3
 	colors    []glVec4
 	texCoords []glVec2
+	bones     []glUByte4 // Optional, empty if the mesh doesn't have any bones
 	indices   []uint32
 	bounds    Box
+	primitive PrimitiveMode
 
 	origin Vec3
 
@@ -33,7 +35,8 @@ func NewMesh() *Mesh {
 
 func (m *Mesh) Buffer(shader *Shader, translucent bool) *Mesh {
 	return &Mesh{
-		buffer: shader.BufferMesh(m, translucent),
+		buffer:    shader.BufferMesh(m, translucent),
+		primitive: m.primitive,
 	}
 }
 
@@ -43,6 +46,7 @@ func (m *Mesh) Clear() {
 	m.normals = m.normals[:0]
 	m.colors = m.colors[:0]
 	m.texCoords = m.texCoords[:0]
+	m.bones = m.bones[:0]
 	m.indices = m.indices[:0]
 	m.bounds = Box{}
 	m.origin = Vec3{}
@@ -64,13 +68,65 @@ func (m *Mesh) Bounds() Box {
 	return m.bounds
 }
 
+// Sets how the mesh's indices are drawn. This is kept when the mesh is cleared
+func (m *Mesh) SetPrimitive(mode PrimitiveMode) {
+	m.primitive = mode
+}
+
+func (m *Mesh) Primitive() PrimitiveMode {
+	return m.primitive
+}
+
+// Returns the list mode and indices that the mesh is batched with
+func (m *Mesh) listIndices() (PrimitiveMode, []uint32) {
+	return m.primitive.listMode(), listIndices(m.primitive, m.indices)
+}
+
+// Sets the four bone indices of each vertex, which are read by shaders with a BoneIndices attribute
+func (m *Mesh) SetBones(bones [][4]uint8) {
+	m.bones = m.bones[:0]
+	for i := range bones {
+		m.bones = append(m.bones, glUByte4(bones[i]))
+	}
+}
+
+// Appends the bones of a mesh with numVerts vertices onto bones, which belong to dstVerts vertices.
+// If only one of them has bones, then the other's vertices are given bone 0 so that they stay aligned
+func appendBones(bones []glUByte4, dstVerts int, src []glUByte4, numVerts int) []glUByte4 {
+	if len(bones) == 0 && len(src) == 0 {
+		return bones
+	}
+	for len(bones) < dstVerts {
+		bones = append(bones, glUByte4{})
+	}
+	bones = append(bones, src...)
+	for len(bones) < dstVerts+numVerts {
+		bones = append(bones, glUByte4{})
+	}
+	return bones
+}
+
 // TODO - should this be more like draw?
 func (m *Mesh) Append(m2 *Mesh) {
+	indices := m2.indices
+	if len(m.indices) == 0 {
+		m.primitive = m2.primitive
+	} else if m.primitive != m2.primitive || m.primitive != m.primitive.listMode() {
+		// Strips and fans can't be joined together, so both meshes are converted to lists
+		m.primitive, m.indices = m.listIndices()
+		var mode PrimitiveMode
+		mode, indices = m2.listIndices()
+		if m.primitive != mode {
+			panic(fmt.Sprintf("Can't append a %v mesh to a %v mesh", m2.primitive, m.primitive))
+		}
+	}
+
 	currentElement := uint32(len(m.positions))
-	for i := range m2.indices {
-		m.indices = append(m.indices, currentElement+m2.indices[i])
+	for i := range indices {
+		m.indices = append(m.indices, currentElement+indices[i])
 	}
 
+	m.bones = appendBones(m.bones, len(m.positions), m2.bones, len(m2.positions))
 	m.positions = append(m.positions, m2.positions...)
 	m.normals = append(m.normals, m2.normals...)
 	m.colors = append(m.colors, m2.colors...)
@@ -358,8 +414,8 @@ func (m *Mesh) Indices() []uint32 {
 
 func (m *Mesh) Fill(bufferPool *BufferPool, mat glMat4, mask RGBA) *VertexBuffer {
 	numVerts := m.NumVerts()
-	indices := m.Indices()
-	vertexBuffer := bufferPool.Reserve(indices, numVerts, bufferPool.shader.tmpBuffers)
+	mode, indices := m.listIndices()
+	vertexBuffer := bufferPool.ReservePrimitive(mode, indices, numVerts, bufferPool.shader.tmpBuffers)
 	batchToBuffers(bufferPool.shader, m, mat, mask)
 
 	return vertexBuffer
@@ -450,6 +506,18 @@ func batchToBuffers(shader *Shader, mesh *Mesh, mat32 glMat4, mask RGBA) {
 				}
 			}
 		case shaders.ColorRGBA:
+			if attr.Type == shaders.AttrUByte4Norm {
+				colBuf := *(destBuffs[bufIdx]).(*[]glUByte4)
+				for i := range mesh.colors {
+					colBuf[i] = glUByte4{
+						packUnorm8(mesh.colors[i][0] * float32(mask.R)),
+						packUnorm8(mesh.colors[i][1] * float32(mask.G)),
+						packUnorm8(mesh.colors[i][2] * float32(mask.B)),
+						packUnorm8(mesh.colors[i][3] * float32(mask.A)),
+					}
+				}
+				break
+			}
 			colBuf := *(destBuffs[bufIdx]).(*[]glVec4)
 			for i := range mesh.colors {
 				colBuf[i] = glVec4{
@@ -461,8 +529,21 @@ func batchToBuffers(shader *Shader, mesh *Mesh, mat32 glMat4, mask RGBA) {
 			}
 
 		case shaders.TexCoordXY:
+			if attr.Type == shaders.AttrUShort2Norm {
+				// Normalized shorts can only hold (0, 1), so coordinates that repeat a texture are clamped to its edge
+				texBuf := *(destBuffs[bufIdx]).(*[]glUShort2)
+				for i := range mesh.texCoords {
+					texBuf[i] = glUShort2{packUnorm16(mesh.texCoords[i][0]), packUnorm16(mesh.texCoords[i][1])}
+				}
+				break
+			}
 			texBuf := *(destBuffs[bufIdx]).(*[]glVec2)
 			copy(texBuf, mesh.texCoords)
+
+		case shaders.BoneIndices:
+			boneBuf := *(destBuffs[bufIdx]).(*[]glUByte4)
+			n := copy(boneBuf, mesh.bones)
+			clear(boneBuf[n:])
 		default:
 			panic(fmt.Sprintf("Unsupported %T: %+v", attr, attr))
 		}
@@ -497,3 +578,13 @@ func batchToBuffers(shader *Shader, mesh *Mesh, mat32 glMat4, mask RGBA) {
 	// 	}
 	//================================================================================
 }
+
+// Converts a float in (0, 1) to a normalized uint8, clamping values outside of that range
+func packUnorm8(v float32) uint8 {
+	return uint8(min(max(v, 0), 1)*255 + 0.5)
+}
+
+// Converts a float in (0, 1) to a normalized uint16, clamping values outside of that range
+func packUnorm16(v float32) uint16 {
+	return uint16(min(max(v, 0), 1)*65535 + 0.5)
+}
@@ -0,0 +1,262 @@
+package glitch
+
+import (
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/unitoftime/glitch/internal/gl"
+)
+
+// Counters of the work that the renderer has done. GetMetrics returns what was counted since it was last called,
+// and each frame in the FrameHistory holds what was counted during that frame
+type Metrics struct {
+	SetShader    int
+	SetCamera    int
+	ClearTarget  int
+	SetTarget    int
+	SetMaterial  int
+	Add          int
+	FlushAttempt int
+	Flush        int
+	Finish       int
+	Draw         int
+
+	VertsTotal int // The total number of vertices drawn
+	VertsAvg   int // The average number of vertices drawn per drawCall
+	Culled     int // The number of draws that were skipped because they were outside of the camera
+
+	UploadBytes int // The number of bytes written to vertex, index and instance buffers
+
+	QueueSorted           int // The number of commands sorted by render queues
+	QueueUnsortedSwitches int // The material switches that render queues would have made if they drew their commands in the order they were added. Compare with SetMaterial
+
+	// These are the latest values rather than counters
+	FrameTime    time.Duration // The time between the last two calls to Window.Update
+	GPUTime      time.Duration // The time the GPU spent on the most recent frame that it has finished. Zero if timer queries aren't supported
+	TextureBytes int           // The memory currently used by textures and frame attachments
+	BufferBytes  int           // The memory currently used by vertex, index and instance buffers
+
+	// Note: Disabled because this didn't really give me any insight
+	// vertsMin int
+	// vertsMax int
+}
+
+// Returns the metrics that were counted since the last call to GetMetrics
+func GetMetrics() Metrics {
+	metric := global.metric.since(global.metricMark)
+	global.metricMark = global.metric
+
+	return metric
+}
+
+// Returns the counters that were accumulated between the last totals and m
+func (m Metrics) since(last Metrics) Metrics {
+	ret := Metrics{
+		SetShader:             m.SetShader - last.SetShader,
+		SetCamera:             m.SetCamera - last.SetCamera,
+		ClearTarget:           m.ClearTarget - last.ClearTarget,
+		SetTarget:             m.SetTarget - last.SetTarget,
+		SetMaterial:           m.SetMaterial - last.SetMaterial,
+		Add:                   m.Add - last.Add,
+		FlushAttempt:          m.FlushAttempt - last.FlushAttempt,
+		Flush:                 m.Flush - last.Flush,
+		Finish:                m.Finish - last.Finish,
+		Draw:                  m.Draw - last.Draw,
+		VertsTotal:            m.VertsTotal - last.VertsTotal,
+		Culled:                m.Culled - last.Culled,
+		UploadBytes:           m.UploadBytes - last.UploadBytes,
+		QueueSorted:           m.QueueSorted - last.QueueSorted,
+		QueueUnsortedSwitches: m.QueueUnsortedSwitches - last.QueueUnsortedSwitches,
+
+		FrameTime:    m.FrameTime,
+		GPUTime:      m.GPUTime,
+		TextureBytes: int(textureMemory.Load()),
+		BufferBytes:  int(bufferMemory.Load()),
+	}
+	if ret.Draw > 0 {
+		ret.VertsAvg = ret.VertsTotal / ret.Draw
+	}
+	return ret
+}
+
+// Memory totals are atomic because resources are released by finalizers on other goroutines
+var textureMemory, bufferMemory atomic.Int64
+
+// Records the end of a frame. Called by Window.Update once the frame has been swapped
+func (g *globalBatcher) endFrame(dt time.Duration) {
+	g.metric.FrameTime = dt
+	g.metric.GPUTime = g.gpuTimer.last
+	if g.history != nil {
+		g.history.Push(g.metric.since(g.frameMark))
+	}
+	g.frameMark = g.metric
+}
+
+// --------------------------------------------------------------------------------
+
+const defaultFrameHistory = 240
+
+// Returns the history of the frames drawn by Window.Update
+func GetFrameHistory() *FrameHistory {
+	return global.history
+}
+
+// Replaces the history that Window.Update records frames into. Set to nil to stop recording
+func SetFrameHistory(history *FrameHistory) {
+	global.history = history
+}
+
+// A rolling history of the metrics of the last N frames. Useful for drawing a performance overlay:
+//
+//	history := glitch.GetFrameHistory()
+//	stats := history.FrameTimeStats()
+//	series = history.FrameTimeSeries(series[:0])
+//	graph.Line(series)
+type FrameHistory struct {
+	frames []Metrics
+	next   int // Where the next frame is written
+	count  int
+
+	sorted []time.Duration // Scratch space for percentiles
+}
+
+type FrameStats struct {
+	Min, Avg, Max, P99 time.Duration
+}
+
+func NewFrameHistory(size int) *FrameHistory {
+	return &FrameHistory{
+		frames: make([]Metrics, max(size, 1)),
+	}
+}
+
+// Adds a frame, overwriting the oldest one if the history is full
+func (h *FrameHistory) Push(m Metrics) {
+	h.frames[h.next] = m
+	h.next = (h.next + 1) % len(h.frames)
+	h.count = min(h.count+1, len(h.frames))
+}
+
+// Returns the number of frames in the history
+func (h *FrameHistory) Len() int {
+	return h.count
+}
+
+// Returns a frame, where 0 is the oldest frame and Len()-1 is the most recent
+func (h *FrameHistory) At(i int) Metrics {
+	start := h.next - h.count + len(h.frames)
+	return h.frames[(start+i)%len(h.frames)]
+}
+
+func (h *FrameHistory) Clear() {
+	h.next = 0
+	h.count = 0
+}
+
+func (h *FrameHistory) FrameTimeStats() FrameStats {
+	return h.stats(func(m Metrics) time.Duration { return m.FrameTime })
+}
+
+func (h *FrameHistory) GPUTimeStats() FrameStats {
+	return h.stats(func(m Metrics) time.Duration { return m.GPUTime })
+}
+
+// Appends a point for each frame, where x is the frame's index in the history and y is its frame time in milliseconds.
+// The result can be passed to graph.Graph.Line or ui.LineGraph
+func (h *FrameHistory) FrameTimeSeries(dst []Vec2) []Vec2 {
+	return h.series(dst, func(m Metrics) time.Duration { return m.FrameTime })
+}
+
+// Like FrameTimeSeries, but for the GPU time of each frame
+func (h *FrameHistory) GPUTimeSeries(dst []Vec2) []Vec2 {
+	return h.series(dst, func(m Metrics) time.Duration { return m.GPUTime })
+}
+
+func (h *FrameHistory) stats(value func(Metrics) time.Duration) FrameStats {
+	if h.count == 0 {
+		return FrameStats{}
+	}
+
+	h.sorted = h.sorted[:0]
+	var total time.Duration
+	for i := 0; i < h.count; i++ {
+		v := value(h.At(i))
+		h.sorted = append(h.sorted, v)
+		total += v
+	}
+	slices.Sort(h.sorted)
+
+	// The nearest rank percentile
+	p99 := (99*h.count + 99) / 100
+	return FrameStats{
+		Min: h.sorted[0],
+		Avg: total / time.Duration(h.count),
+		Max: h.sorted[h.count-1],
+		P99: h.sorted[p99-1],
+	}
+}
+
+func (h *FrameHistory) series(dst []Vec2, value func(Metrics) time.Duration) []Vec2 {
+	for i := 0; i < h.count; i++ {
+		ms := float64(value(h.At(i))) / float64(time.Millisecond)
+		dst = append(dst, Vec2{float64(i), ms})
+	}
+	return dst
+}
+
+// --------------------------------------------------------------------------------
+
+// Measures how long the GPU takes to draw each frame with timer queries. Results arrive a few frames late,
+// so a small ring of queries is kept to avoid waiting on them
+type gpuTimer struct {
+	checked   bool
+	supported bool
+	queries   [bufferPoolFrames]gl.Query
+	pending   [bufferPoolFrames]bool // Set if the query has ended but its result hasn't been read
+	frame     int                    // The query that is currently running
+	active    bool
+	last      time.Duration
+}
+
+// Ends the query for the frame that was just drawn, reads the results that are ready, and starts timing the next frame
+func (t *gpuTimer) mainthreadEndFrame() {
+	if !t.checked {
+		t.checked = true
+		t.supported = gl.SupportsTimerQuery()
+		if t.supported {
+			for i := range t.queries {
+				t.queries[i] = gl.CreateQuery()
+			}
+		}
+	}
+	if !t.supported {
+		return
+	}
+
+	if t.active {
+		gl.EndQuery(gl.TIME_ELAPSED)
+		t.pending[t.frame] = true
+		t.frame = (t.frame + 1) % len(t.queries)
+	}
+
+	// Read the results oldest first. The oldest query is about to be reused, so if it still isn't ready then it is dropped
+	for i := range t.queries {
+		idx := (t.frame + i) % len(t.queries)
+		if !t.pending[idx] {
+			continue
+		}
+		if !gl.QueryResultAvailable(t.queries[idx]) {
+			if i > 0 {
+				break
+			}
+			t.pending[idx] = false
+			continue
+		}
+		t.last = time.Duration(gl.QueryResult(t.queries[idx]))
+		t.pending[idx] = false
+	}
+
+	gl.BeginQuery(gl.TIME_ELAPSED, t.queries[t.frame])
+	t.active = true
+}
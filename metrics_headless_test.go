@@ -0,0 +1,85 @@
+//go:build headless
+// +build headless
+
+package glitch
+
+import (
+	"image/color"
+	"testing"
+	"time"
+)
+
+func TestHeadlessMetrics(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	white := NewRGBATexture(4, 4, color.RGBA{255, 255, 255, 255}, false)
+	if white.bytes != 4*4*4 {
+		t.Errorf("expected the texture to use 64 bytes, got %d", white.bytes)
+	}
+	if metrics := GetMetrics(); metrics.TextureBytes < white.bytes {
+		t.Errorf("expected the texture memory to include the texture, got %d", metrics.TextureBytes)
+	}
+
+	history := NewFrameHistory(8)
+	SetFrameHistory(history)
+	defer SetFrameHistory(NewFrameHistory(defaultFrameHistory))
+
+	sprite := NewSprite(white, white.Bounds())
+	for i := 0; i < 3; i++ {
+		Clear(win, RGBA{})
+		mat := Mat4Ident
+		mat.Translate(8, 8, 0)
+		sprite.Draw(win, mat)
+		time.Sleep(time.Millisecond)
+		win.Update()
+	}
+
+	if history.Len() != 3 {
+		t.Fatalf("expected 3 frames, got %d", history.Len())
+	}
+	for i := 0; i < history.Len(); i++ {
+		if frame := history.At(i); frame.Draw != 1 || frame.VertsTotal != 4 {
+			t.Errorf("frame %d: expected one draw of 4 vertices, got %d draws of %d vertices", i, frame.Draw, frame.VertsTotal)
+		}
+	}
+	last := history.At(history.Len() - 1)
+	if last.FrameTime < time.Millisecond {
+		t.Errorf("expected the frame time to be measured, got %v", last.FrameTime)
+	}
+	if last.GPUTime <= 0 {
+		t.Errorf("expected the GPU time to be measured, got %v", last.GPUTime)
+	}
+	if stats := history.FrameTimeStats(); stats.Max < stats.Min || stats.P99 > stats.Max {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if last.BufferBytes <= 0 {
+		t.Errorf("expected the buffer memory to be counted, got %d", last.BufferBytes)
+	}
+
+	// GetMetrics still counts everything since it was last called, independent of the history
+	if metrics := GetMetrics(); metrics.Draw != 3 {
+		t.Errorf("expected 3 draws since the last call, got %d", metrics.Draw)
+	}
+}
+
+func TestHeadlessEqualUniformsBatch(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	white := NewRGBATexture(4, 4, color.RGBA{255, 255, 255, 255}, false)
+	defer white.Destroy()
+
+	// Each material gets its own uniforms, but they hold the same values, so they shouldn't break the batch
+	materials := []Material{DefaultMsdfMaterial(white), DefaultMsdfMaterial(white)}
+	mesh := NewQuadMesh(white.Bounds(), white.Bounds())
+
+	Clear(win, RGBA{})
+	GetMetrics()
+	for i := 0; i < 4; i++ {
+		win.Add(mesh, glMat4Ident, White, materials[i%2], false)
+	}
+	win.Update()
+
+	if metrics := GetMetrics(); metrics.SetMaterial != 1 || metrics.Draw != 1 {
+		t.Errorf("expected one material switch and one draw, got %d switches and %d draws", metrics.SetMaterial, metrics.Draw)
+	}
+}
@@ -0,0 +1,37 @@
+package glitch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFrameHistory(t *testing.T) {
+	history := NewFrameHistory(100)
+	for i := 1; i <= 150; i++ {
+		history.Push(Metrics{FrameTime: time.Duration(i) * time.Millisecond})
+	}
+
+	// Only the last 100 frames (51ms to 150ms) are kept
+	if history.Len() != 100 {
+		t.Fatalf("expected 100 frames, got %d", history.Len())
+	}
+	if first := history.At(0).FrameTime; first != 51*time.Millisecond {
+		t.Errorf("expected the oldest frame to be 51ms, got %v", first)
+	}
+
+	stats := history.FrameTimeStats()
+	expected := FrameStats{
+		Min: 51 * time.Millisecond,
+		Avg: 100500 * time.Microsecond,
+		Max: 150 * time.Millisecond,
+		P99: 149 * time.Millisecond,
+	}
+	if stats != expected {
+		t.Errorf("expected %+v, got %+v", expected, stats)
+	}
+
+	series := history.FrameTimeSeries(nil)
+	if len(series) != 100 || series[99] != (Vec2{99, 150}) {
+		t.Errorf("unexpected series, last point %v", series[len(series)-1])
+	}
+}
@@ -2,6 +2,7 @@ package glitch
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 	"runtime"
 	"unsafe"
@@ -22,10 +23,15 @@ type ISubBuffer interface {
 	Len() int
 	Cap() int
 	SetData(any)
+
+	reserveTo(count int, dest any)      // Reserves count vertices and points dest (a *[]T) at them
+	sliceTo(start, count int, dest any) // Points dest (a *[]T) at vertices that were already reserved
+	attribute() (shaders.Attr, int)     // Returns the attribute and its offset in the buffer
+	setOffset(offset int)               // Sets the offset of the attribute in the buffer
 }
 
 type SupportedSubBuffers interface {
-	glVec4 | glVec3 | glVec2 | float32
+	glVec4 | glVec3 | glVec2 | float32 | int32 | glUByte4 | glUShort2
 }
 
 type SubBuffer[T SupportedSubBuffers] struct {
@@ -85,8 +91,9 @@ func (b *SubBuffer[T]) Buffer() []byte {
 // 	}
 // }
 
+// Returns the size of the attribute's region in a planar buffer
 func (b *SubBuffer[T]) Offset() int {
-	return sof * int(b.attr.Size()) * b.maxVerts
+	return b.sliceScale * b.maxVerts
 }
 
 func (b *SubBuffer[T]) SetData(data any) {
@@ -102,78 +109,151 @@ func (b *SubBuffer[T]) Reserve(count int) []T {
 	return b.buffer[start:end]
 }
 
+func (b *SubBuffer[T]) reserveTo(count int, dest any) {
+	*dest.(*[]T) = b.Reserve(count)
+}
+
+func (b *SubBuffer[T]) sliceTo(start, count int, dest any) {
+	*dest.(*[]T) = b.buffer[start : start+count]
+}
+
+func (b *SubBuffer[T]) attribute() (shaders.Attr, int) {
+	return b.attr, b.offset
+}
+
+func (b *SubBuffer[T]) setOffset(offset int) {
+	b.offset = offset
+}
+
+func newSubBuffer[T SupportedSubBuffers](attr shaders.Attr, numVerts int) *SubBuffer[T] {
+	return &SubBuffer[T]{
+		attr:       attr,
+		maxVerts:   numVerts,
+		buffer:     make([]T, numVerts),
+		sliceScale: attr.ByteSize(),
+	}
+}
+
+// Creates the cpu side buffer for an attribute. The slice type matches what getBuffer returns for the attribute
+func newAttrSubBuffer(attr shaders.Attr, numVerts int) ISubBuffer {
+	switch attr.Type {
+	case shaders.AttrFloat:
+		return newSubBuffer[float32](attr, numVerts)
+	case shaders.AttrVec2:
+		return newSubBuffer[glVec2](attr, numVerts)
+	case shaders.AttrVec3:
+		return newSubBuffer[glVec3](attr, numVerts)
+	case shaders.AttrVec4:
+		return newSubBuffer[glVec4](attr, numVerts)
+	case shaders.AttrInt:
+		return newSubBuffer[int32](attr, numVerts)
+	case shaders.AttrUByte4, shaders.AttrUByte4Norm:
+		return newSubBuffer[glUByte4](attr, numVerts)
+	case shaders.AttrUShort2, shaders.AttrUShort2Norm:
+		return newSubBuffer[glUShort2](attr, numVerts)
+	default:
+		panic(fmt.Sprintf("Unknown format: %v", attr))
+	}
+}
+
+// Returns the gl component type of an attribute and whether it is normalized
+func attrComponentType(attr shaders.Attr) (gl.Enum, bool) {
+	switch attr.Type {
+	case shaders.AttrInt:
+		return gl.INT, false
+	case shaders.AttrUByte4:
+		return gl.UNSIGNED_BYTE, false
+	case shaders.AttrUByte4Norm:
+		return gl.UNSIGNED_BYTE, true
+	case shaders.AttrUShort2:
+		return gl.UNSIGNED_SHORT, false
+	case shaders.AttrUShort2Norm:
+		return gl.UNSIGNED_SHORT, true
+	default:
+		return gl.FLOAT, false
+	}
+}
+
 type VertexBuffer struct {
 	vao, vbo, ebo gl.Buffer
 
 	// materialSet bool
 	// state BufferState
-	format shaders.VertexFormat
-	stride int
+	format    shaders.VertexFormat
+	stride    int // The number of bytes per vertex
+	layout    shaders.VertexLayout
+	indexType shaders.IndexType
 
 	buffers            []ISubBuffer
 	indices            []uint32
-	numVerts           uint32 // The number of vertices we currently have buffered
-	numIndicesToDraw   int    // The number of indices we are currently drawing
-	bufferedToGPU      bool   // Tracks whether the data has been written to the GPU
-	deallocAfterBuffer bool   // If set true, once we write data to the GPU we deallocate CPU buffers
-	deleted            bool   // If true, we've already deleted this
+	primitive          PrimitiveMode // How the indices are drawn. Set by the first reserve after the buffer is cleared
+	numVerts           uint32        // The number of vertices we currently have buffered
+	numIndicesToDraw   int           // The number of indices we are currently drawing
+	bufferedToGPU      bool          // Tracks whether the data has been written to the GPU
+	deallocAfterBuffer bool          // If set true, once we write data to the GPU we deallocate CPU buffers
+	deleted            bool          // If true, we've already deleted this
+	trackID            uint64
+
+	// The vertex and index ranges [start, end) that were rewritten after the buffer was uploaded. See rewrite
+	dirtyVerts, dirtyIndices [2]int
+
+	interleaved []byte   // Staging for interleaved uploads
+	indices16   []uint16 // Staging for 16 bit index uploads
+
+	// Streaming buffers are refilled every frame. They are either persistently mapped, or orphaned before each full upload
+	streaming                  bool
+	vertBytes, indexBytes      int    // The sizes of the GPU buffers
+	mappedVerts, mappedIndices []byte // Set if the buffers are persistently mapped
+
+	// Per instance data, only used if the shader has per instance attributes
+	instanceVbo    gl.Buffer
+	instanceFormat shaders.VertexFormat
+	instanceData   []float32 // Interleaved per instance attributes
+	numInstances   int
+	instancesDirty bool
+	instanceBytes  int // The size of the instance buffer on the GPU
 }
 
 func NewVertexBuffer(shader *Shader, numVerts, numIndices int) *VertexBuffer {
+	b := newVertexBuffer(shader, numVerts, numIndices, false)
+	b.trackID = trackResource("VertexBuffer")
+	return b
+}
+
+func newVertexBuffer(shader *Shader, numVerts, numIndices int, streaming bool) *VertexBuffer {
+	if shader.indexType == shaders.Index16 && numVerts > math.MaxUint16+1 {
+		panic(fmt.Sprintf("VertexBuffer with 16 bit indices can't hold %d vertices", numVerts))
+	}
+
 	format := shader.attrFmt // TODO - cleanup this variable
 	b := &VertexBuffer{
-		format:  format,
-		buffers: make([]ISubBuffer, len(format)),
-		indices: make([]uint32, numIndices),
+		format:         format,
+		layout:         shader.layout,
+		indexType:      shader.indexType,
+		buffers:        make([]ISubBuffer, len(format)),
+		indices:        make([]uint32, numIndices),
+		instanceFormat: shader.instanceFmt,
+		streaming:      streaming,
 	}
 
-	b.stride = 0
+	b.stride = format.Stride()
 	offset := 0
 	for i := range format {
-		b.stride += (format[i].Size() * sof)
-
-		if format[i].Type == shaders.AttrVec4 {
-			b.buffers[i] = &SubBuffer[glVec4]{
-				attr:        format[i].Attr,
-				maxVerts:    numVerts,
-				vertexCount: 0,
-				offset:      offset,
-				buffer:      make([]glVec4, numVerts),
-				sliceScale:  format[i].Size() * sof,
-			}
-		} else if format[i].Type == shaders.AttrVec3 {
-			b.buffers[i] = &SubBuffer[glVec3]{
-				attr:        format[i].Attr,
-				maxVerts:    numVerts,
-				vertexCount: 0,
-				offset:      offset,
-				buffer:      make([]glVec3, numVerts),
-				sliceScale:  format[i].Size() * sof,
-			}
-		} else if format[i].Type == shaders.AttrVec2 {
-			b.buffers[i] = &SubBuffer[glVec2]{
-				attr:        format[i].Attr,
-				maxVerts:    numVerts,
-				vertexCount: 0,
-				offset:      offset,
-				buffer:      make([]glVec2, numVerts),
-				sliceScale:  format[i].Size() * sof,
-			}
-		} else if format[i].Type == shaders.AttrFloat {
-			b.buffers[i] = &SubBuffer[float32]{
-				attr:        format[i].Attr,
-				maxVerts:    numVerts,
-				vertexCount: 0,
-				offset:      offset,
-				buffer:      make([]float32, numVerts),
-				sliceScale:  format[i].Size() * sof,
-			}
+		b.buffers[i] = newAttrSubBuffer(format[i].Attr, numVerts)
+		b.buffers[i].setOffset(offset)
+
+		if b.layout == shaders.LayoutInterleaved {
+			offset += format[i].ByteSize()
 		} else {
-			panic(fmt.Sprintf("Unknown format: %v", format[i]))
+			offset += format[i].ByteSize() * numVerts
 		}
-
-		offset += sof * int(format[i].Size()) * numVerts
 	}
+	if b.layout == shaders.LayoutInterleaved {
+		b.interleaved = make([]byte, b.stride*numVerts)
+	}
+	b.vertBytes = numVerts * b.stride
+	b.indexBytes = numIndices * b.indexSize()
+	bufferMemory.Add(int64(b.vertBytes + b.indexBytes))
 
 	mainthread.Call(func() {
 		b.vao = gl.GenVertexArrays()
@@ -182,63 +262,139 @@ func NewVertexBuffer(shader *Shader, numVerts, numIndices int) *VertexBuffer {
 
 		gl.BindVertexArray(b.vao)
 
-		gl.BindBuffer(gl.ARRAY_BUFFER, b.vbo)
-		gl.BufferData(gl.ARRAY_BUFFER, sof*numVerts*b.stride, nil, gl.DYNAMIC_DRAW)
+		persistent := b.streaming && gl.SupportsPersistentMapping() && b.vertBytes > 0 && b.indexBytes > 0
+		if persistent {
+			gl.BindBuffer(gl.ARRAY_BUFFER, b.vbo)
+			b.mappedVerts = gl.BufferStoragePersistent(gl.ARRAY_BUFFER, b.vertBytes)
+			gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, b.ebo)
+			b.mappedIndices = gl.BufferStoragePersistent(gl.ELEMENT_ARRAY_BUFFER, b.indexBytes)
+
+			if b.mappedVerts == nil || b.mappedIndices == nil {
+				// Buffer storage can't be reallocated, so it couldn't be orphaned on upload. Instead both buffers are
+				// recreated as regular buffers, so that they are never half mapped
+				gl.DeleteBuffers(b.vbo)
+				gl.DeleteBuffers(b.ebo)
+				b.vbo = gl.GenBuffers()
+				b.ebo = gl.GenBuffers()
+				b.mappedVerts, b.mappedIndices = nil, nil
+				persistent = false
+			}
+		}
 
-		indexSize := 4 // uint32 // TODO - make this modifiable?
-		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, b.ebo)
-		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, indexSize*len(b.indices), nil, gl.DYNAMIC_DRAW)
+		if !persistent {
+			gl.BindBuffer(gl.ARRAY_BUFFER, b.vbo)
+			gl.BufferData(gl.ARRAY_BUFFER, b.vertBytes, nil, b.usage())
+			gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, b.ebo)
+			gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, b.indexBytes, nil, b.usage())
+		}
 
 		for i := range b.buffers {
-			switch subBuffer := b.buffers[i].(type) {
-			case *SubBuffer[float32]:
-				loc := gl.GetAttribLocation(shader.program, subBuffer.attr.Name)
-				size := int(subBuffer.attr.Size())
-				gl.VertexAttribPointer(loc, size, gl.FLOAT, false, size*sof, subBuffer.offset)
-				gl.EnableVertexAttribArray(loc)
-			case *SubBuffer[glVec2]:
-				loc := gl.GetAttribLocation(shader.program, subBuffer.attr.Name)
-				size := int(subBuffer.attr.Size())
-				gl.VertexAttribPointer(loc, size, gl.FLOAT, false, size*sof, subBuffer.offset)
-				gl.EnableVertexAttribArray(loc)
-			case *SubBuffer[glVec3]:
-				loc := gl.GetAttribLocation(shader.program, subBuffer.attr.Name)
-				size := int(subBuffer.attr.Size())
-				gl.VertexAttribPointer(loc, size, gl.FLOAT, false, size*sof, subBuffer.offset)
-				gl.EnableVertexAttribArray(loc)
-			case *SubBuffer[glVec4]:
-				loc := gl.GetAttribLocation(shader.program, subBuffer.attr.Name)
-				size := int(subBuffer.attr.Size())
-				// TODO!!! - gl.VertexAttribPointerWithOffset: https://github.com/go-gl/gl/pull/135/files#diff-b335630551682c19a781afebcf4d07bf978fb1f8ac04c6bf87428ed5106870f5R67
-				gl.VertexAttribPointer(loc, size, gl.FLOAT, false, size*sof, subBuffer.offset)
-				gl.EnableVertexAttribArray(loc)
-			default:
-				panic("Unknown!")
+			attr, offset := b.buffers[i].attribute()
+			loc := gl.GetAttribLocation(shader.program, attr.Name)
+			size := attr.Size()
+			stride := attr.ByteSize()
+			if b.layout == shaders.LayoutInterleaved {
+				stride = b.stride
+			}
+
+			// TODO!!! - gl.VertexAttribPointerWithOffset: https://github.com/go-gl/gl/pull/135/files#diff-b335630551682c19a781afebcf4d07bf978fb1f8ac04c6bf87428ed5106870f5R67
+			ty, normalized := attrComponentType(attr)
+			if attr.Integer() {
+				gl.VertexAttribIPointer(loc, size, ty, stride, offset)
+			} else {
+				gl.VertexAttribPointer(loc, size, ty, normalized, stride, offset)
 			}
+			gl.EnableVertexAttribArray(loc)
+		}
+
+		if len(b.instanceFormat) > 0 {
+			b.mainthreadSetupInstances(shader)
 		}
 	})
 
 	b.Clear() // TODO - fix
 
-	runtime.SetFinalizer(b, (*VertexBuffer).delete)
+	runtime.SetFinalizer(b, (*VertexBuffer).finalize)
 
 	return b
 }
 
-func (v *VertexBuffer) delete() {
+// Deletes the buffer from the GPU. The buffer can't be drawn after it is destroyed, and destroying it again does nothing
+func (v *VertexBuffer) Destroy() {
+	runtime.SetFinalizer(v, nil)
+	global.flush() // The buffer might be the last one that was filled
+	v.release(mainthread.Call)
+}
+
+func (v *VertexBuffer) finalize() {
+	v.release(mainthread.CallNonBlock)
+}
+
+func (v *VertexBuffer) release(call func(func())) {
 	if v.deleted {
 		return
 	}
 	v.deleted = true
-
-	mainthread.CallNonBlock(func() {
-		gl.DeleteVertexArrays(v.vao)
-		gl.DeleteBuffers(v.vbo)
+	untrackResource(v.trackID)
+	bufferMemory.Add(int64(-v.vertBytes - v.indexBytes - v.instanceBytes))
+	v.mappedVerts = nil
+	v.mappedIndices = nil
+
+	vao, vbo, ebo, instanceVbo := v.vao, v.vbo, v.ebo, v.instanceVbo
+	instanced := len(v.instanceFormat) > 0
+	call(func() {
+		gl.DeleteVertexArrays(vao)
+		gl.DeleteBuffers(vbo)
+		gl.DeleteBuffers(ebo)
+		if instanced {
+			gl.DeleteBuffers(instanceVbo)
+		}
 	})
 }
 
+// Creates the instance buffer and interleaves the per instance attributes into it. Matrices take up one attribute location per column
+func (v *VertexBuffer) mainthreadSetupInstances(shader *Shader) {
+	v.instanceVbo = gl.GenBuffers()
+	gl.BindBuffer(gl.ARRAY_BUFFER, v.instanceVbo)
+
+	stride := v.instanceStride() * sof
+	offset := 0
+	for _, attr := range v.instanceFormat {
+		loc := gl.GetAttribLocation(shader.program, attr.Name)
+		columns, size := 1, attr.Size()
+		if attr.Type == shaders.AttrMat4 {
+			columns, size = 4, 4
+		}
+		for c := 0; c < columns; c++ {
+			colLoc := gl.Attrib{Value: loc.Value + c}
+			gl.VertexAttribPointer(colLoc, size, gl.FLOAT, false, stride, offset)
+			gl.EnableVertexAttribArray(colLoc)
+			gl.VertexAttribDivisor(colLoc, attr.Divisor)
+			offset += size * sof
+		}
+	}
+}
+
+// Returns the number of floats per instance
+func (v *VertexBuffer) instanceStride() int {
+	stride := 0
+	for _, attr := range v.instanceFormat {
+		stride += attr.Size()
+	}
+	return stride
+}
+
+// Sets the per instance data that is drawn with this buffer. If count is zero, the buffer is drawn without instancing
+func (v *VertexBuffer) setInstances(data []float32, count int) {
+	v.instanceData = append(v.instanceData[:0], data...)
+	v.numInstances = count
+	v.instancesDirty = true
+}
+
 func (v *VertexBuffer) deallocCPUBuffers() {
 	v.indices = nil
+	v.indices16 = nil
+	v.interleaved = nil
 	for i := range v.buffers {
 		v.buffers[i] = nil
 	}
@@ -257,6 +413,14 @@ func (v *VertexBuffer) Clear() {
 }
 
 func (v *VertexBuffer) Reserve(indices []uint32, numVerts int, dests []interface{}) bool {
+	return v.ReservePrimitive(PrimitiveTriangles, indices, numVerts, dests)
+}
+
+// Reserves indices that are drawn with the primitive mode. This fails if the buffer already holds a different primitive
+func (v *VertexBuffer) ReservePrimitive(mode PrimitiveMode, indices []uint32, numVerts int, dests []interface{}) bool {
+	if len(v.indices) > 0 && v.primitive != mode {
+		return false
+	}
 	// // If material is set and it doesn't match the reserved material
 	// if v.materialSet && v.state != state {
 	// 	// fmt.Println("VertexBuffer.Reserve - Material Doesn't match")
@@ -272,6 +436,7 @@ func (v *VertexBuffer) Reserve(indices []uint32, numVerts int, dests []interface
 	}
 
 	v.bufferedToGPU = false
+	v.primitive = mode
 
 	// v.materialSet = true
 	// v.state = state
@@ -285,22 +450,7 @@ func (v *VertexBuffer) Reserve(indices []uint32, numVerts int, dests []interface
 	v.numIndicesToDraw = len(v.indices)
 
 	for i := range v.buffers {
-		switch subBuffer := v.buffers[i].(type) {
-		case *SubBuffer[float32]:
-			d := dests[i].(*[]float32)
-			*d = subBuffer.Reserve(numVerts)
-		case *SubBuffer[glVec2]:
-			d := dests[i].(*[]glVec2)
-			*d = subBuffer.Reserve(numVerts)
-		case *SubBuffer[glVec3]:
-			d := dests[i].(*[]glVec3)
-			*d = subBuffer.Reserve(numVerts)
-		case *SubBuffer[glVec4]:
-			d := dests[i].(*[]glVec4)
-			*d = subBuffer.Reserve(numVerts)
-		default:
-			panic("Unknown!")
-		}
+		v.buffers[i].reserveTo(numVerts, dests[i])
 	}
 	return true
 }
@@ -310,27 +460,174 @@ func (v *VertexBuffer) mainthreadDraw() {
 
 	if !v.bufferedToGPU {
 		gl.BindBuffer(gl.ARRAY_BUFFER, v.vbo)
-		offset := 0
-		var buf []byte
-		for i := range v.buffers {
-			buf = v.buffers[i].Buffer()
-			gl.BufferSubDataByte(gl.ARRAY_BUFFER, offset, buf)
-			offset += v.buffers[i].Offset()
+		if v.streaming && v.mappedVerts == nil {
+			// Orphan the old storage so that the driver can hand us new memory instead of waiting on draws that still read it
+			gl.BufferData(gl.ARRAY_BUFFER, v.vertBytes, nil, gl.STREAM_DRAW)
 		}
+		v.mainthreadUploadVerts(0, int(v.numVerts))
 
 		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, v.ebo)
-		gl.BufferSubDataUint32(gl.ELEMENT_ARRAY_BUFFER, 0, v.indices)
+		if v.streaming && v.mappedIndices == nil {
+			gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, v.indexBytes, nil, gl.STREAM_DRAW)
+		}
+		v.mainthreadUploadIndices(0, len(v.indices))
 
-		gl.DrawElements(gl.TRIANGLES, v.numIndicesToDraw, gl.UNSIGNED_INT, 0)
+		v.mainthreadDrawElements()
 
 		if v.deallocAfterBuffer {
 			v.deallocCPUBuffers()
 		}
 		v.bufferedToGPU = true
+		v.dirtyVerts, v.dirtyIndices = [2]int{}, [2]int{}
 	} else {
 		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, v.ebo)
-		gl.DrawElements(gl.TRIANGLES, v.numIndicesToDraw, gl.UNSIGNED_INT, 0)
+		if v.dirtyVerts[1] > 0 || v.dirtyIndices[1] > 0 {
+			v.mainthreadUploadDirty()
+		}
+		v.mainthreadDrawElements()
+	}
+}
+
+// Uploads only the ranges that were rewritten since the last upload
+func (v *VertexBuffer) mainthreadUploadDirty() {
+	if v.dirtyVerts[1] > v.dirtyVerts[0] {
+		gl.BindBuffer(gl.ARRAY_BUFFER, v.vbo)
+		v.mainthreadUploadVerts(v.dirtyVerts[0], v.dirtyVerts[1])
+	}
+	if v.dirtyIndices[1] > v.dirtyIndices[0] {
+		v.mainthreadUploadIndices(v.dirtyIndices[0], v.dirtyIndices[1])
+	}
+
+	v.dirtyVerts, v.dirtyIndices = [2]int{}, [2]int{}
+}
+
+// Uploads the vertices [start, end) to the bound array buffer
+func (v *VertexBuffer) mainthreadUploadVerts(start, end int) {
+	if end <= start {
+		return
+	}
+
+	if v.layout == shaders.LayoutInterleaved {
+		// Copy each attribute into its place in the vertices, then upload them all at once
+		for i := range v.buffers {
+			attr, offset := v.buffers[i].attribute()
+			size := attr.ByteSize()
+			buf := v.buffers[i].Buffer()
+			for vert := start; vert < end; vert++ {
+				dst := vert*v.stride + offset
+				copy(v.interleaved[dst:dst+size], buf[vert*size:(vert+1)*size])
+			}
+		}
+		mainthreadWriteBuffer(gl.ARRAY_BUFFER, v.mappedVerts, start*v.stride, v.interleaved[start*v.stride:end*v.stride])
+		return
+	}
+
+	for i := range v.buffers {
+		attr, offset := v.buffers[i].attribute()
+		size := attr.ByteSize()
+		buf := v.buffers[i].Buffer()
+		mainthreadWriteBuffer(gl.ARRAY_BUFFER, v.mappedVerts, offset+start*size, buf[start*size:end*size])
+	}
+}
+
+// Uploads the indices [start, end) to the bound element array buffer
+func (v *VertexBuffer) mainthreadUploadIndices(start, end int) {
+	if end <= start {
+		return
+	}
+
+	if v.indexType != shaders.Index16 {
+		data := unsafe.Slice((*byte)(unsafe.Pointer(&v.indices[start])), 4*(end-start))
+		mainthreadWriteBuffer(gl.ELEMENT_ARRAY_BUFFER, v.mappedIndices, 4*start, data)
+		return
+	}
+
+	if cap(v.indices16) < len(v.indices) {
+		v.indices16 = make([]uint16, cap(v.indices))
+	}
+	v.indices16 = v.indices16[:len(v.indices)]
+	for i := start; i < end; i++ {
+		v.indices16[i] = uint16(v.indices[i])
 	}
+	data := unsafe.Slice((*byte)(unsafe.Pointer(&v.indices16[start])), 2*(end-start))
+	mainthreadWriteBuffer(gl.ELEMENT_ARRAY_BUFFER, v.mappedIndices, 2*start, data)
+}
+
+// Writes data into the bound buffer at the offset, either through its persistent mapping or by uploading it
+func mainthreadWriteBuffer(target gl.Enum, mapped []byte, offset int, data []byte) {
+	global.metric.UploadBytes += len(data)
+	if mapped != nil {
+		copy(mapped[offset:], data)
+		return
+	}
+	gl.BufferSubDataByte(target, offset, data)
+}
+
+func (v *VertexBuffer) usage() gl.Enum {
+	if v.streaming {
+		return gl.STREAM_DRAW
+	}
+	return gl.DYNAMIC_DRAW
+}
+
+// Returns the number of bytes per index
+func (v *VertexBuffer) indexSize() int {
+	if v.indexType == shaders.Index16 {
+		return 2
+	}
+	return 4
+}
+
+func (v *VertexBuffer) indexEnum() gl.Enum {
+	if v.indexType == shaders.Index16 {
+		return gl.UNSIGNED_SHORT
+	}
+	return gl.UNSIGNED_INT
+}
+
+// Points dests at a range of vertices that was already reserved, and rewrites the indices that follow indexStart.
+// The rewritten ranges are the only parts that get uploaded on the next draw
+func (v *VertexBuffer) rewrite(vertStart, numVerts, indexStart int, indices []uint32, dests []any) {
+	for i := range v.buffers {
+		v.buffers[i].sliceTo(vertStart, numVerts, dests[i])
+	}
+
+	for i := range indices {
+		v.indices[indexStart+i] = uint32(vertStart) + indices[i]
+	}
+
+	if !v.bufferedToGPU {
+		return // Everything gets uploaded anyways
+	}
+	v.dirtyVerts = expandRange(v.dirtyVerts, vertStart, vertStart+numVerts)
+	v.dirtyIndices = expandRange(v.dirtyIndices, indexStart, indexStart+len(indices))
+}
+
+func expandRange(r [2]int, start, end int) [2]int {
+	if end <= start {
+		return r
+	}
+	if r[1] <= r[0] {
+		return [2]int{start, end}
+	}
+	return [2]int{min(r[0], start), max(r[1], end)}
+}
+
+func (v *VertexBuffer) mainthreadDrawElements() {
+	if v.numInstances <= 0 {
+		gl.DrawElements(v.primitive.glEnum(), v.numIndicesToDraw, v.indexEnum(), 0)
+		return
+	}
+
+	if v.instancesDirty {
+		gl.BindBuffer(gl.ARRAY_BUFFER, v.instanceVbo)
+		gl.BufferData(gl.ARRAY_BUFFER, sof*len(v.instanceData), v.instanceData, gl.DYNAMIC_DRAW)
+		global.metric.UploadBytes += sof * len(v.instanceData)
+		bufferMemory.Add(int64(sof*len(v.instanceData) - v.instanceBytes))
+		v.instanceBytes = sof * len(v.instanceData)
+		v.instancesDirty = false
+	}
+	gl.DrawElementsInstanced(v.primitive.glEnum(), v.numIndicesToDraw, v.indexEnum(), 0, v.numInstances)
 }
 
 func (v *VertexBuffer) Draw() {
@@ -341,18 +638,39 @@ func (v *VertexBuffer) Draw() {
 	state.drawVertBuffer(v)
 }
 
+// The number of frames of buffers that a BufferPool cycles through. The GPU can still be drawing the last frame or two
+// while the CPU fills the next one, so each frame gets its own buffers and the CPU never waits on the GPU
+const bufferPoolFrames = 3
+
 // BufferPool
 // TODO - Idea Improvements: You'd be able to calculate in the pass how many draws with the same material you'd be doing. Based on that you could have really well sized buffers. Also in here you could have different VertexBuffer sizes and order them as needed into a final draw slice
 type BufferPool struct {
 	shader            *Shader
 	triangleBatchSize int
 	triangleCount     int
-	buffers           []*VertexBuffer
+	buffers           []*VertexBuffer // The buffers of the current frame
 	currentIndex      int
 	nextClean         int // Tracks the next clean vertex buffer (ie clean = buffers that haven't been written reserved on in this case
+
+	ring  []bufferPoolFrame // The buffers of every frame, the current frame's buffers are kept in buffers while it is filled
+	frame int               // The current index into the ring
+
+	untracked bool // Set for a shader's own pool, whose buffers are reported as part of the shader
 }
 
+type bufferPoolFrame struct {
+	buffers []*VertexBuffer
+	fence   gl.Sync // Signaled once the GPU has finished drawing the frame. Only used with persistently mapped buffers
+	fenced  bool
+}
+
+// Creates a pool that is refilled every frame. Call NextFrame once each frame is drawn
 func NewBufferPool(shader *Shader, triangleBatchSize int) *BufferPool {
+	return newBufferPool(shader, triangleBatchSize, bufferPoolFrames)
+}
+
+// A pool with one frame reuses the same buffers every time it is cleared, which is what persistent geometry wants
+func newBufferPool(shader *Shader, triangleBatchSize int, frames int) *BufferPool {
 	return &BufferPool{
 		shader:            shader,
 		triangleBatchSize: triangleBatchSize,
@@ -360,7 +678,67 @@ func NewBufferPool(shader *Shader, triangleBatchSize int) *BufferPool {
 		buffers:           make([]*VertexBuffer, 0),
 		currentIndex:      0,
 		nextClean:         0,
+		ring:              make([]bufferPoolFrame, frames),
+	}
+}
+
+// Moves on to the next frame's buffers and clears them. If those buffers are mapped, this waits for the GPU to finish
+// drawing them, which only happens if the CPU gets more than a couple frames ahead
+func (b *BufferPool) NextFrame() {
+	if len(b.ring) <= 1 {
+		b.Clear()
+		return
+	}
+
+	current := &b.ring[b.frame]
+	current.buffers = b.buffers
+	if b.persistent() {
+		mainthread.Call(func() {
+			current.fence = gl.FenceSync()
+		})
+		current.fenced = true
+	}
+
+	b.frame = (b.frame + 1) % len(b.ring)
+	next := &b.ring[b.frame]
+	if next.fenced {
+		mainthread.Call(func() {
+			gl.ClientWaitSync(next.fence)
+			gl.DeleteSync(next.fence)
+		})
+		next.fenced = false
 	}
+	b.buffers = next.buffers
+	b.Clear()
+}
+
+// Destroys the buffers of every frame in the pool. The pool can still be used afterwards, it just starts from new buffers
+func (b *BufferPool) Destroy() {
+	for i := range b.ring {
+		frame := &b.ring[i]
+		if frame.fenced {
+			mainthread.Call(func() {
+				gl.DeleteSync(frame.fence)
+			})
+			frame.fenced = false
+		}
+		for _, buf := range frame.buffers {
+			buf.Destroy()
+		}
+		frame.buffers = nil
+	}
+	for _, buf := range b.buffers {
+		buf.Destroy() // The current frame's buffers might not be in the ring yet
+	}
+	b.buffers = b.buffers[:0]
+	b.triangleCount = 0
+	b.currentIndex = 0
+	b.nextClean = 0
+}
+
+// Returns true if the pool writes directly into mapped buffers
+func (b *BufferPool) persistent() bool {
+	return len(b.buffers) > 0 && b.buffers[0].mappedVerts != nil
 }
 
 func (b *BufferPool) Clear() {
@@ -379,8 +757,14 @@ func (b *BufferPool) gotoNextClean() {
 
 // Returns the vertexbuffer that we reserved to
 func (b *BufferPool) Reserve(indices []uint32, numVerts int, dests []interface{}) *VertexBuffer {
+	return b.ReservePrimitive(PrimitiveTriangles, indices, numVerts, dests)
+}
+
+// Returns the vertexbuffer that we reserved the primitives to. Different primitives can't share a buffer,
+// so changing the primitive moves on to the next buffer, which breaks the batch
+func (b *BufferPool) ReservePrimitive(mode PrimitiveMode, indices []uint32, numVerts int, dests []interface{}) *VertexBuffer {
 	for i := b.currentIndex; i < len(b.buffers); i++ {
-		success := b.buffers[i].Reserve(indices, numVerts, dests)
+		success := b.buffers[i].ReservePrimitive(mode, indices, numVerts, dests)
 		if success {
 			b.triangleCount += len(indices) / 3
 			b.currentIndex = i
@@ -394,8 +778,11 @@ func (b *BufferPool) Reserve(indices []uint32, numVerts int, dests []interface{}
 	vertBatchSize := max(numVerts, b.triangleBatchSize)
 	indexBatchSize := max(len(indices), 3*b.triangleBatchSize)
 
-	newBuff := NewVertexBuffer(b.shader, vertBatchSize, indexBatchSize)
-	success := newBuff.Reserve(indices, numVerts, dests)
+	newBuff := newVertexBuffer(b.shader, vertBatchSize, indexBatchSize, len(b.ring) > 1)
+	if !b.untracked {
+		newBuff.trackID = trackResource("VertexBuffer")
+	}
+	success := newBuff.ReservePrimitive(mode, indices, numVerts, dests)
 	if !success {
 		panic(fmt.Sprintf("Failed to reserve on freshly created buffer:\nReserve: %v, %v, %v\nOn: %v %v",
 			len(indices), numVerts, len(dests), vertBatchSize, indexBatchSize,
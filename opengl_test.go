@@ -0,0 +1,101 @@
+//go:build headless
+// +build headless
+
+package glitch
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/unitoftime/glitch/shaders"
+)
+
+func TestHeadlessPackedVertexFormat(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	shader, err := NewShader(shaders.SpritePackedShader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stride := shader.attrFmt.Stride(); stride != 20 {
+		t.Errorf("expected 20 bytes per vertex, got %d", stride)
+	}
+
+	pixels := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	pixels.SetRGBA(0, 0, color.RGBA{255, 0, 0, 255})
+	pixels.SetRGBA(1, 0, color.RGBA{0, 255, 0, 255})
+	texture := NewTexture(pixels, false)
+	sprite := NewSprite(texture, texture.Bounds())
+
+	draw := func(material Material) *image.RGBA {
+		Clear(win, RGBA{})
+		for i := 0; i < 2; i++ {
+			mat := Mat4Ident
+			mat.Scale(4, 4, 1).Translate(float64(4+i*8), 8, 0)
+			win.Add(sprite.mesh, glm4(mat), RGBA{1, 1, float64(i), 1}, material, false)
+		}
+		win.Update()
+		return win.Image()
+	}
+
+	expected := draw(sprite.material)
+	packed := NewMaterial(shader)
+	packed.textures[0] = texture
+	got := draw(packed)
+
+	if expected.RGBAAt(4, 7) == (color.RGBA{}) {
+		t.Fatalf("expected the planar sprite to draw")
+	}
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			if got.RGBAAt(x, y) != expected.RGBAAt(x, y) {
+				t.Fatalf("pixel (%d, %d): expected %v, got %v", x, y, expected.RGBAAt(x, y), got.RGBAAt(x, y))
+			}
+		}
+	}
+}
+
+func TestHeadlessBufferPoolRing(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	white := NewRGBATexture(4, 4, color.RGBA{255, 255, 255, 255}, false)
+	sprite := NewSprite(white, white.Bounds())
+	pool := sprite.material.shader.pool
+
+	// Each frame fills a different buffer, until the ring wraps around
+	var frames []*VertexBuffer
+	for i := 0; i < bufferPoolFrames+1; i++ {
+		GetMetrics()
+		Clear(win, RGBA{})
+		for _, x := range []float64{4, 12} {
+			mat := Mat4Ident
+			mat.Translate(x, 8, 0)
+			sprite.Draw(win, mat)
+		}
+		frames = append(frames, pool.buffers[0])
+		win.Update()
+
+		// Two quads of 4 vertices (a vec3, vec4 and vec2 each) and 6 indices
+		if metrics := GetMetrics(); metrics.UploadBytes != 2*(4*36+6*4) {
+			t.Errorf("frame %d: expected %d upload bytes, got %d", i, 2*(4*36+6*4), metrics.UploadBytes)
+		}
+		for _, x := range []int{4, 12} {
+			if got := win.Image().RGBAAt(x, 16-1-8); got != (color.RGBA{255, 255, 255, 255}) {
+				t.Errorf("frame %d: expected the sprite at %d to draw, got %v", i, x, got)
+			}
+		}
+	}
+
+	for i := 1; i < bufferPoolFrames; i++ {
+		if frames[i] == frames[0] {
+			t.Errorf("expected frame %d to fill a different buffer than frame 0", i)
+		}
+	}
+	if frames[bufferPoolFrames] != frames[0] {
+		t.Errorf("expected the ring to wrap around and reuse the first frame's buffer")
+	}
+	if frames[0].mappedVerts == nil {
+		t.Errorf("expected the pool's buffers to be persistently mapped")
+	}
+}
@@ -22,12 +22,36 @@ type GeometryFiller interface {
 	Bounds() glm.Box
 }
 
+// Passes a prebuilt buffer through a BatchTarget, for things like InstanceBatch and StaticBatch that manage their own buffers
+type bufferFiller struct {
+	buffer *VertexBuffer
+	bounds Box
+}
+
+func (f *bufferFiller) GetBuffer() *VertexBuffer {
+	return f.buffer
+}
+
+// The buffer can't be batched into another one, so this returns it as is
+func (f *bufferFiller) Fill(pool *BufferPool, mat glMat4, mask RGBA) *VertexBuffer {
+	return f.buffer
+}
+
+func (f *bufferFiller) Bounds() Box {
+	return f.bounds
+}
+
 type BatchTarget interface {
 	Add(GeometryFiller, glMat4, RGBA, Material, bool)
 	// Add(*Mesh, glMat4, RGBA, Material, bool)
 	// SetShader(*Shader)
 }
 
+// Anything that can draw itself into a BatchTarget, such as a Sprite or Mesh
+type Drawer interface {
+	Draw(BatchTarget, Mat4)
+}
+
 type Target interface {
 	// TODO - Should this be differentiated from being a source Vs a target binding. For example, I'm using this now to bind the target that we draw to. But If I want to have another function on frambuffers to use them as image texture inputs, what would that API be called?
 	Bind()
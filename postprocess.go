@@ -0,0 +1,253 @@
+package glitch
+
+import (
+	"image"
+	"time"
+
+	"github.com/unitoftime/glitch/shaders"
+)
+
+// A PostProcess applies an ordered chain of fullscreen shader passes to everything drawn into its Target.
+// The chain renders through a pair of ping-pong frames that are kept the same size as the window.
+//
+//	post := glitch.NewPostProcess(win)
+//	post.Add(glitch.NewBloomPasses(0.7, 1.0)...)
+//	post.Add(glitch.NewVignettePass(0.5))
+//
+//	// Every frame
+//	glitch.Clear(post.Target(), glitch.Black)
+//	sorter.Draw(post.Target())
+//	post.Draw(win)
+type PostProcess struct {
+	win    *Window
+	scene  *Frame    // The frame that the scene is drawn into
+	swap   [2]*Frame // Intermediate results, alternated between each pass
+	camera *CameraOrtho
+	passes []*PostPass
+	start  time.Time
+}
+
+// A single fullscreen pass of a PostProcess. The previous pass's output is bound to the shader's main
+// texture (texture1). Every frame, the uniforms below are set if the shader's UniformFormat declares them:
+//   - u_time (float): Seconds since the PostProcess was created
+//   - u_resolution (vec2): The size of the source in pixels
+//   - u_scene (sampler2D): The unprocessed scene, for passes that composite over the original
+type PostPass struct {
+	Material Material
+	Disabled bool // Skips the pass without removing it from the chain
+}
+
+// Creates a pass that draws with the shader. The material replaces the destination, so passes don't need to clear their target
+func NewPostPass(shader *Shader) *PostPass {
+	material := NewMaterial(shader)
+	material.SetBlendMode(BlendModeReplace)
+	return &PostPass{
+		Material: material,
+	}
+}
+
+func NewPostProcess(win *Window) *PostProcess {
+	p := &PostProcess{
+		win:    win,
+		camera: NewCameraOrtho(),
+		start:  time.Now(),
+	}
+	p.resize()
+	return p
+}
+
+// Appends passes to the end of the chain
+func (p *PostProcess) Add(passes ...*PostPass) {
+	p.passes = append(p.passes, passes...)
+}
+
+func (p *PostProcess) Passes() []*PostPass {
+	return p.passes
+}
+
+// Returns the frame that the scene should be drawn into. It is resized if the window has changed size
+func (p *PostProcess) Target() *Frame {
+	p.resize()
+	return p.scene
+}
+
+// Creates the frames on first use, and afterwards resizes them in place so that no GL objects are left behind
+func (p *PostProcess) resize() {
+	bounds := p.win.Bounds()
+	if p.scene == nil {
+		p.scene = NewFrame(bounds, true)
+		p.swap[0] = NewFrame(bounds, true)
+		p.swap[1] = NewFrame(bounds, true)
+	} else if p.scene.Bounds() == bounds {
+		return
+	}
+
+	p.scene.Resize(bounds)
+	p.swap[0].Resize(bounds)
+	p.swap[1].Resize(bounds)
+
+	p.camera.SetOrtho2D(bounds)
+	p.camera.SetView2D(0, 0, 1, 1)
+}
+
+// Deletes the frames that the chain renders through. The passes' shaders are owned by the caller and aren't destroyed
+func (p *PostProcess) Destroy() {
+	p.scene.Destroy()
+	p.swap[0].Destroy()
+	p.swap[1].Destroy()
+}
+
+// Runs every enabled pass over the scene, drawing the last one into target
+func (p *PostProcess) Draw(target BatchTarget) {
+	p.resize()
+
+	// The cull volume is restored too, because SetCameraMaterial would replace an ortho camera's rectangle with a frustum
+	lastCamera, lastCull := global.camera, global.cull
+	SetCamera(p.camera)
+	defer func() {
+		SetCameraMaterial(lastCamera)
+		global.cull = lastCull
+	}()
+
+	last := len(p.passes) - 1
+	for last >= 0 && p.passes[last].Disabled {
+		last--
+	}
+	if last < 0 {
+		// Nothing to apply, so just copy the scene over
+		p.scene.Draw(target, Mat4Ident)
+		return
+	}
+
+	src := p.scene
+	next := 0
+	for i := 0; i <= last; i++ {
+		pass := p.passes[i]
+		if pass.Disabled {
+			continue
+		}
+
+		if i == last {
+			p.apply(pass, src, target)
+			return
+		}
+
+		dst := p.swap[next]
+		p.apply(pass, src, dst)
+		src = dst
+		next = 1 - next
+	}
+}
+
+func (p *PostProcess) apply(pass *PostPass, src *Frame, dst BatchTarget) {
+	shader := pass.Material.shader
+	if _, ok := shader.uniformLocs["u_time"]; ok {
+		pass.Material.SetUniform("u_time", float32(time.Since(p.start).Seconds()))
+	}
+	if _, ok := shader.uniformLocs["u_resolution"]; ok {
+		pass.Material.SetUniform("u_resolution", src.bounds.Size())
+	}
+
+	material := pass.Material
+	material.textures[0] = src.tex
+	if unit, ok := shader.samplers["u_scene"]; ok {
+		material.textures[unit] = p.scene.tex
+	}
+
+	dst.Add(src.mesh, glMat4Ident, White, material, false)
+}
+
+// --------------------------------------------------------------------------------
+// Built in passes
+
+var postShaderCache = make(map[string]*Shader)
+
+func getPostShader(cfg shaders.ShaderConfig) *Shader {
+	shader, ok := postShaderCache[cfg.FragmentShader]
+	if ok {
+		return shader
+	}
+
+	shader, err := NewShader(cfg)
+	if err != nil {
+		panic(err)
+	}
+	postShaderCache[cfg.FragmentShader] = shader
+	return shader
+}
+
+// Returns a horizontal and a vertical gaussian blur pass. Radius is the spacing between samples in pixels
+func NewBlurPasses(radius float64) []*PostPass {
+	shader := getPostShader(shaders.BlurShader)
+
+	horizontal := NewPostPass(shader)
+	horizontal.Material.SetUniform("u_direction", Vec2{radius, 0})
+
+	vertical := NewPostPass(shader)
+	vertical.Material.SetUniform("u_direction", Vec2{0, radius})
+
+	return []*PostPass{horizontal, vertical}
+}
+
+// Returns the passes for a bloom: The parts of the scene brighter than threshold are blurred and then added back over the scene
+func NewBloomPasses(threshold, intensity float64) []*PostPass {
+	bright := NewPostPass(getPostShader(shaders.BrightPassShader))
+	bright.Material.SetUniform("u_threshold", threshold)
+
+	combine := NewPostPass(getPostShader(shaders.BloomShader))
+	combine.Material.SetUniform("u_intensity", intensity)
+
+	passes := []*PostPass{bright}
+	passes = append(passes, NewBlurPasses(2)...)
+	passes = append(passes, combine)
+	return passes
+}
+
+// Returns a pass that color grades through a lookup table. The table has size entries per channel, laid out like IdentityLUT.
+// Edit an identity table in an image editor to create a grade. The lut texture should be smooth so that colors between entries are interpolated
+func NewColorGradePass(lut *Texture, size int) *PostPass {
+	pass := NewPostPass(getPostShader(shaders.ColorGradeShader))
+	pass.Material.SetSampler("u_lut", lut)
+	pass.Material.
+		SetUniform("u_lutSize", float64(size)).
+		SetUniform("u_strength", 1.0)
+	return pass
+}
+
+// Returns a pass that darkens the edges of the screen. A strength of 1 fades the corners to black
+func NewVignettePass(strength float64) *PostPass {
+	pass := NewPostPass(getPostShader(shaders.VignetteShader))
+	pass.Material.
+		SetUniform("u_radius", 0.75).
+		SetUniform("u_softness", 0.45).
+		SetUniform("u_strength", strength)
+	return pass
+}
+
+// Returns a pass that imitates a CRT monitor with a curved screen and scanlines
+func NewCRTPass(curvature, scanline float64) *PostPass {
+	pass := NewPostPass(getPostShader(shaders.CRTShader))
+	pass.Material.
+		SetUniform("u_curvature", curvature).
+		SetUniform("u_scanline", scanline)
+	return pass
+}
+
+// Returns a color lookup table that maps every color to itself. The table is a strip of size blue slices,
+// each size by size pixels, with red increasing to the right and green increasing downwards
+func IdentityLUT(size int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size*size, size))
+	scale := 255.0 / float64(size-1)
+	for b := 0; b < size; b++ {
+		for g := 0; g < size; g++ {
+			for r := 0; r < size; r++ {
+				i := img.PixOffset(b*size+r, g)
+				img.Pix[i+0] = uint8(float64(r)*scale + 0.5)
+				img.Pix[i+1] = uint8(float64(g)*scale + 0.5)
+				img.Pix[i+2] = uint8(float64(b)*scale + 0.5)
+				img.Pix[i+3] = 255
+			}
+		}
+	}
+	return img
+}
@@ -0,0 +1,152 @@
+//go:build headless
+// +build headless
+
+package glitch
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/unitoftime/flow/glm"
+)
+
+func TestHeadlessPostProcess(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	post := NewPostProcess(win)
+	post.Add(NewBlurPasses(0)...) // A blur with no spread doesn't change the image, but still runs through the ping pong frames
+	vignette := NewVignettePass(1)
+	post.Add(vignette)
+
+	// Fill the scene with white, except for a red top right quadrant
+	Clear(post.Target(), RGBA{1, 1, 1, 1})
+	NewQuadMesh(glm.R(8, 8, 16, 16), glm.R(0, 0, 1, 1)).DrawColorMask(post.Target(), Mat4Ident, RGBA{1, 0, 0, 1})
+
+	Clear(win, RGBA{})
+	post.Draw(win)
+	img := win.Image()
+
+	// Image rows are top to bottom
+	center := img.RGBAAt(6, 9)
+	if center.R < 250 || center.G < 250 {
+		t.Errorf("expected the center to be untouched by the vignette, got %v", center)
+	}
+	corner := img.RGBAAt(0, 15)
+	if corner.R > 64 || corner.A != 255 {
+		t.Errorf("expected the corner to be darkened by the vignette, got %v", corner)
+	}
+	red := img.RGBAAt(10, 5)
+	if red.R < 250 || red.G > 5 {
+		t.Errorf("expected the top right to stay red, got %v", red)
+	}
+
+	vignette.Disabled = true
+	post.Draw(win)
+	if got := win.Image().RGBAAt(0, 15); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("expected disabling the vignette to leave the corner white, got %v", got)
+	}
+	win.Update()
+}
+
+func TestHeadlessPostProcessDestroy(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	SetLeakTracking(true)
+	defer SetLeakTracking(false)
+
+	post := NewPostProcess(win)
+	if len(TrackedResources()) == 0 {
+		t.Fatalf("expected the post process frames to be tracked")
+	}
+	frames := []*Frame{post.scene, post.swap[0], post.swap[1]}
+	for _, f := range frames {
+		if frameMemory(f) == 0 {
+			t.Errorf("expected the post process frame memory to be counted")
+		}
+	}
+
+	post.Destroy()
+	if left := TrackedResources(); len(left) != 0 {
+		t.Errorf("expected destroying the post process to release its frames, got %d resources", len(left))
+	}
+	for _, f := range frames {
+		if bytes := frameMemory(f); bytes != 0 {
+			t.Errorf("expected the post process frame memory to be released, got %d", bytes)
+		}
+	}
+	win.Update()
+}
+
+func TestHeadlessPostProcessPasses(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	// Draws the scene through the passes and returns the result. Image rows are top to bottom
+	run := func(scene func(target *Frame), passes ...*PostPass) *image.RGBA {
+		post := NewPostProcess(win)
+		defer post.Destroy()
+		post.Add(passes...)
+
+		Clear(post.Target(), RGBA{0, 0, 0, 1})
+		scene(post.Target())
+		Clear(win, RGBA{})
+		post.Draw(win)
+		return win.Image()
+	}
+	rect := func(target *Frame, r Rect, col RGBA) {
+		NewQuadMesh(r, glm.R(0, 0, 1, 1)).DrawColorMask(target, Mat4Ident, col)
+	}
+
+	// A blur softens the edge between a white left half and a black right half, but leaves pixels far from it alone
+	img := run(func(target *Frame) { rect(target, glm.R(0, 0, 8, 16), White) }, NewBlurPasses(1)...)
+	if got := img.RGBAAt(7, 8); got.R > 250 {
+		t.Errorf("expected the blur to darken the white side of the edge, got %v", got)
+	}
+	if got := img.RGBAAt(8, 8); got.R < 5 {
+		t.Errorf("expected the blur to lighten the black side of the edge, got %v", got)
+	}
+	if got := img.RGBAAt(0, 8); got.R < 250 {
+		t.Errorf("expected the blur to leave pixels far from the edge white, got %v", got)
+	}
+	if got := img.RGBAAt(15, 8); got.R > 5 {
+		t.Errorf("expected the blur to leave pixels far from the edge black, got %v", got)
+	}
+
+	// A bloom spreads a glow around a bright square
+	img = run(func(target *Frame) { rect(target, glm.R(6, 6, 10, 10), White) }, NewBloomPasses(0.5, 1)...)
+	if got := img.RGBAAt(10, 8); got.R < 5 {
+		t.Errorf("expected the bloom to glow next to the square, got %v", got)
+	}
+	if got := img.RGBAAt(8, 8); got.R < 250 {
+		t.Errorf("expected the square to stay white, got %v", got)
+	}
+	if got := img.RGBAAt(0, 0); got.R > 5 {
+		t.Errorf("expected the bloom to leave the far corner black, got %v", got)
+	}
+
+	// A color grade through an inverted table turns red into cyan
+	lut := IdentityLUT(4)
+	for i := 0; i < len(lut.Pix); i += 4 {
+		lut.Pix[i+0], lut.Pix[i+1], lut.Pix[i+2] = 255-lut.Pix[i+0], 255-lut.Pix[i+1], 255-lut.Pix[i+2]
+	}
+	lutTexture := NewTexture(lut, true)
+	defer lutTexture.Destroy()
+	img = run(func(target *Frame) { rect(target, glm.R(0, 0, 16, 16), RGBA{1, 0, 0, 1}) }, NewColorGradePass(lutTexture, 4))
+	if got := img.RGBAAt(8, 8); got.R > 5 || got.G < 250 || got.B < 250 {
+		t.Errorf("expected the color grade to turn red into cyan, got %v", got)
+	}
+
+	// A curved screen leaves the corners black, and scanlines darken every other row
+	img = run(func(target *Frame) { rect(target, glm.R(0, 0, 16, 16), White) }, NewCRTPass(0.5, 0))
+	if got := img.RGBAAt(0, 0); got.R > 5 {
+		t.Errorf("expected the curved screen to leave the corner black, got %v", got)
+	}
+	if got := img.RGBAAt(8, 8); got.R < 240 {
+		t.Errorf("expected the center of the screen to stay white, got %v", got)
+	}
+	img = run(func(target *Frame) { rect(target, glm.R(0, 0, 16, 16), White) }, NewCRTPass(0, 1))
+	if a, b := img.RGBAAt(8, 7), img.RGBAAt(8, 8); absDiff(a.R, b.R) < 200 {
+		t.Errorf("expected neighbouring rows to alternate between scanlines, got %v and %v", a, b)
+	}
+	win.Update()
+}
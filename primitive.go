@@ -0,0 +1,91 @@
+package glitch
+
+import "github.com/unitoftime/glitch/internal/gl"
+
+// The kind of primitive that a mesh's indices describe
+type PrimitiveMode uint8
+
+const (
+	PrimitiveTriangles     PrimitiveMode = iota // Every 3 indices are a triangle. This is the default
+	PrimitivePoints                             // Every index is a point. The size comes from the shader, see shaders.PointShader
+	PrimitiveLines                              // Every 2 indices are a line
+	PrimitiveLineStrip                          // Each index is connected to the one before it
+	PrimitiveTriangleStrip                      // Each index makes a triangle with the two before it
+	PrimitiveTriangleFan                        // Each index makes a triangle with the one before it and the first index
+)
+
+func (p PrimitiveMode) String() string {
+	switch p {
+	case PrimitiveTriangles:
+		return "Triangles"
+	case PrimitivePoints:
+		return "Points"
+	case PrimitiveLines:
+		return "Lines"
+	case PrimitiveLineStrip:
+		return "LineStrip"
+	case PrimitiveTriangleStrip:
+		return "TriangleStrip"
+	case PrimitiveTriangleFan:
+		return "TriangleFan"
+	}
+	return "Unknown"
+}
+
+func (p PrimitiveMode) glEnum() gl.Enum {
+	switch p {
+	case PrimitivePoints:
+		return gl.POINTS
+	case PrimitiveLines:
+		return gl.LINES
+	case PrimitiveLineStrip:
+		return gl.LINE_STRIP
+	case PrimitiveTriangleStrip:
+		return gl.TRIANGLE_STRIP
+	case PrimitiveTriangleFan:
+		return gl.TRIANGLE_FAN
+	}
+	return gl.TRIANGLES
+}
+
+// Strips and fans can't be batched together because every mesh would be connected to the one before it.
+// So when they are batched they are converted to the list mode that draws the same thing
+func (p PrimitiveMode) listMode() PrimitiveMode {
+	switch p {
+	case PrimitiveLineStrip:
+		return PrimitiveLines
+	case PrimitiveTriangleStrip, PrimitiveTriangleFan:
+		return PrimitiveTriangles
+	}
+	return p
+}
+
+// Converts indices of the primitive mode into indices of its list mode
+func listIndices(mode PrimitiveMode, indices []uint32) []uint32 {
+	switch mode {
+	case PrimitiveLineStrip:
+		ret := make([]uint32, 0, 2*max(len(indices)-1, 0))
+		for i := 1; i < len(indices); i++ {
+			ret = append(ret, indices[i-1], indices[i])
+		}
+		return ret
+	case PrimitiveTriangleStrip:
+		ret := make([]uint32, 0, 3*max(len(indices)-2, 0))
+		for i := 2; i < len(indices); i++ {
+			// Every other triangle is flipped so that they all keep the same winding
+			if i%2 == 0 {
+				ret = append(ret, indices[i-2], indices[i-1], indices[i])
+			} else {
+				ret = append(ret, indices[i-1], indices[i-2], indices[i])
+			}
+		}
+		return ret
+	case PrimitiveTriangleFan:
+		ret := make([]uint32, 0, 3*max(len(indices)-2, 0))
+		for i := 2; i < len(indices); i++ {
+			ret = append(ret, indices[0], indices[i-1], indices[i])
+		}
+		return ret
+	}
+	return indices
+}
@@ -0,0 +1,87 @@
+//go:build headless
+// +build headless
+
+package glitch
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/unitoftime/glitch/shaders"
+)
+
+func TestHeadlessPrimitiveModes(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	white := NewRGBATexture(4, 4, color.RGBA{255, 255, 255, 255}, false)
+	newMesh := func(mode PrimitiveMode, points ...Vec3) *Mesh {
+		mesh := NewMesh()
+		mesh.SetPrimitive(mode)
+		for i, p := range points {
+			mesh.positions = append(mesh.positions, glv3(p))
+			mesh.colors = append(mesh.colors, glVec4{1, 1, 1, 1})
+			mesh.texCoords = append(mesh.texCoords, glVec2{})
+			mesh.indices = append(mesh.indices, uint32(i))
+			mesh.bounds = mesh.bounds.Union(Box{Min: p, Max: p})
+		}
+		return mesh
+	}
+
+	// A horizontal line strip with a corner, and a triangle fan
+	strip := newMesh(PrimitiveLineStrip, Vec3{1.5, 2.5, 0}, Vec3{6.5, 2.5, 0}, Vec3{6.5, 5.5, 0})
+	fan := newMesh(PrimitiveTriangleFan, Vec3{10, 1, 0}, Vec3{14, 1, 0}, Vec3{14, 5, 0}, Vec3{10, 5, 0})
+	material := DefaultMaterial(white)
+
+	Clear(win, RGBA{})
+	GetMetrics()
+	win.Add(strip, glMat4Ident, White, material, false)
+	up := Mat4Ident
+	up.Translate(0, 1, 0)
+	win.Add(strip, glm4(up), White, material, false)
+	win.Add(fan, glMat4Ident, White, material, false)
+
+	shader, err := NewShader(shaders.PointShader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pointMaterial := NewMaterial(shader)
+	pointMaterial.SetTexture(white)
+	pointMaterial.SetUniform("pointSize", float32(4))
+	points := newMesh(PrimitivePoints, Vec3{4, 12, 0}, Vec3{12, 12, 0})
+	win.Add(points, glMat4Ident, White, pointMaterial, false)
+	win.Update()
+
+	// The two strips are batched as lines, and the fan and points each need their own draw
+	if metrics := GetMetrics(); metrics.Draw != 3 {
+		t.Errorf("expected 3 draws, got %d", metrics.Draw)
+	}
+
+	img := win.Image()
+	on := color.RGBA{255, 255, 255, 255}
+	check := func(x, y int, expected color.RGBA) {
+		t.Helper()
+		if got := img.RGBAAt(x, 16-1-y); got != expected {
+			t.Errorf("pixel (%d, %d): expected %v, got %v", x, y, expected, got)
+		}
+	}
+	check(4, 2, on) // First strip
+	check(4, 3, on) // Second strip
+	check(6, 4, on) // Strip corner
+	check(4, 4, color.RGBA{})
+	check(12, 3, on) // Fan
+	check(12, 7, color.RGBA{})
+	for _, x := range []int{2, 3, 4, 5, 10, 11, 12, 13} {
+		check(x, 12, on) // Points are 4 pixels wide
+	}
+	check(7, 12, color.RGBA{})
+
+	// Strips and fans are converted to lists when they are batched
+	batch := NewBatch()
+	batch.Add(fan, glMat4Ident, White, material, false)
+	if got := batch.mesh.Primitive(); got != PrimitiveTriangles {
+		t.Errorf("expected the fan to be batched as triangles, got %v", got)
+	}
+	if got := len(batch.mesh.indices); got != 6 {
+		t.Errorf("expected the fan to be batched as 2 triangles, got %d indices", got)
+	}
+}
@@ -0,0 +1,263 @@
+package glitch
+
+import "math"
+
+// A RenderQueue is an alternative to the Sorter that orders its commands with a single 64 bit sort key per command.
+// The key packs the layer, translucency, depth and render state, so that one radix sort both orders the commands
+// correctly and groups together commands that share a shader, material and texture. This minimizes the number of
+// material and shader switches made while drawing. Compare GetMetrics between the two to see the difference.
+//
+// Key layout, from the most significant bit:
+//   - Opaque:      0 | shader (10) | material (12) | texture (9) | layer (8) | depth (24)
+//   - Translucent: 1 | layer (8) | depth (24) | shader (10) | material (12) | texture (9)
+//
+// Like the Sorter, every opaque command is drawn before the translucent ones, so translucent commands blend over
+// all of the opaque ones regardless of their layer. Opaque commands are ordered by their state first because the
+// depth buffer resolves their order. Translucent commands are ordered by layer and depth first so that they blend
+// correctly, and only commands at the same depth are grouped by state. Layers are drawn like the Sorter: Highest
+// layer first, so lower layers are drawn on top. Without DepthTest every command is translucent, so the layers
+// are drawn in order.
+// Note: Masks aren't supported, use a Sorter for masked content
+type RenderQueue struct {
+	DepthTest    bool             // If set true, opaque commands rely on hardware depth testing. Otherwise every command is treated as translucent
+	SoftwareSort SoftwareSortMode // The position that translucent commands are ordered by. SoftwareSortNone keeps the order that they were added in
+	DepthBump    bool
+	depthBump    float32
+	currentLayer int8
+	scissor      scissor
+
+	commands  []drawCommand
+	keys, tmp []sortKey
+
+	// Ids are assigned in the order that things are first seen each frame, and wrap if there are more than the key has room for.
+	// Wrapping only costs batching, because the depth bits still keep translucent commands in order
+	shaderIDs   map[*Shader]uint64
+	materialIDs map[Material]uint64 // Keyed without the main texture, which is grouped by the texture id
+	textureIDs  map[*Texture]uint64
+}
+
+type sortKey struct {
+	key   uint64
+	index uint32
+}
+
+const (
+	queueTranslucentBit        = 1 << 63
+	queueLayerBits             = 8
+	queueDepthBits             = 24
+	queueShaderBits            = 10
+	queueMaterialBits          = 12
+	queueTextureBits           = 9
+	queueStateBits             = queueShaderBits + queueMaterialBits + queueTextureBits
+	queueDepthMask             = 1<<queueDepthBits - 1
+	queueStateMask      uint64 = 1<<queueStateBits - 1
+)
+
+func NewRenderQueue() *RenderQueue {
+	return &RenderQueue{
+		shaderIDs:   make(map[*Shader]uint64),
+		materialIDs: make(map[Material]uint64),
+		textureIDs:  make(map[*Texture]uint64),
+	}
+}
+
+func (q *RenderQueue) SetLayer(layer int8) {
+	q.currentLayer = layer
+}
+
+func (q *RenderQueue) Layer() int8 {
+	return q.currentLayer
+}
+
+// Clips everything added after this call to a rectangle, in the same space as the draw matrices.
+// The scissor is stored in each draw's material, so it is kept when this is drawn into a Batch or CommandRecorder.
+// See glitch.SetScissor for how the rectangle is transformed
+func (q *RenderQueue) SetScissor(rect Rect) {
+	q.scissor = scissor{rect, true}
+}
+
+// Stops clipping things that are added after this call
+func (q *RenderQueue) DisableScissor() {
+	q.scissor = scissor{}
+}
+
+// Returns the current scissor rectangle and whether it is enabled
+func (q *RenderQueue) Scissor() (Rect, bool) {
+	return q.scissor.rect, q.scissor.enabled
+}
+
+func (q *RenderQueue) Clear() {
+	q.depthBump = 0
+	q.scissor = scissor{}
+
+	// Clear the commands so that we don't hold onto the geometry
+	clear(q.commands)
+	q.commands = q.commands[:0]
+	q.keys = q.keys[:0]
+
+	clear(q.shaderIDs)
+	clear(q.materialIDs)
+	clear(q.textureIDs)
+}
+
+func (q *RenderQueue) Add(filler GeometryFiller, mat glMat4, mask RGBA, material Material, translucent bool) {
+	if mask.A == 0 {
+		return
+	} // discard b/c its completely transparent
+
+	if mask.A != 1 {
+		translucent = true
+	}
+
+	if q.DepthTest {
+		if q.DepthBump {
+			q.depthBump -= 0.00001 // TODO: Very very arbitrary, matches the Sorter
+		}
+		mat[i4_3_2] -= float32(q.currentLayer) + q.depthBump
+		material.depth = DepthModeLess
+	} else {
+		translucent = true
+		mat[i4_3_2] -= float32(q.currentLayer)
+	}
+
+	// The scissor is stored in the material, so that it is kept by targets that store the draw for later
+	material.scissor = q.scissor.intersect(material.scissor)
+
+	cmd := drawCommand{
+		filler:   filler,
+		matrix:   mat,
+		mask:     mask,
+		material: material,
+	}
+
+	layer := uint64(uint8(127 - int(q.currentLayer)))
+	state := q.stateBits(cmd)
+	var key uint64
+	if translucent {
+		key = queueTranslucentBit |
+			layer<<(queueDepthBits+queueStateBits) |
+			q.depthBits(cmd)<<queueStateBits |
+			state
+	} else {
+		key = state<<(queueLayerBits+queueDepthBits) |
+			layer<<queueDepthBits |
+			q.depthBits(cmd)
+	}
+
+	q.keys = append(q.keys, sortKey{key, uint32(len(q.commands))})
+	q.commands = append(q.commands, cmd)
+}
+
+// Returns the shader, material and texture ids packed together
+func (q *RenderQueue) stateBits(cmd drawCommand) uint64 {
+	shader := queueID(q.shaderIDs, cmd.material.shader, queueShaderBits)
+
+	texture := queueID(q.textureIDs, cmd.material.textures[0], queueTextureBits)
+
+	state := cmd.material
+	state.textures[0] = nil // The main texture is grouped by the texture id instead
+	material := queueID(q.materialIDs, state, queueMaterialBits)
+
+	return (shader<<(queueMaterialBits+queueTextureBits) | material<<queueTextureBits | texture) & queueStateMask
+}
+
+// Returns the id for v, assigning the next one if it hasn't been seen yet
+func queueID[K comparable](ids map[K]uint64, v K, bits int) uint64 {
+	id, ok := ids[v]
+	if !ok {
+		id = uint64(len(ids)) & (1<<bits - 1)
+		ids[v] = id
+	}
+	return id
+}
+
+// Returns the position that the command is ordered by, quantized so that ascending values are drawn first
+func (q *RenderQueue) depthBits(cmd drawCommand) uint64 {
+	var v float32
+	switch q.SoftwareSort {
+	case SoftwareSortX:
+		v = -cmd.matrix[i4_3_0]
+	case SoftwareSortY:
+		v = -cmd.matrix[i4_3_1]
+	case SoftwareSortZ:
+		v = cmd.matrix[i4_3_2]
+	case SoftwareSortZNegative:
+		v = -cmd.matrix[i4_3_2]
+	default:
+		// Keep the order that commands were added in
+		return uint64(len(q.commands)) & queueDepthMask
+	}
+
+	// Flip the float's bits so that they compare like unsigned ints, then keep the most significant ones
+	bits := math.Float32bits(v)
+	if bits&(1<<31) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 31
+	}
+	return uint64(bits >> (32 - queueDepthBits))
+}
+
+func (q *RenderQueue) sort() {
+	// Count the switches that drawing in the added order would have made, for comparison in the metrics
+	for i := 1; i < len(q.commands); i++ {
+		a, b := &q.commands[i-1], &q.commands[i]
+		if a.material != b.material {
+			global.metric.QueueUnsortedSwitches++
+		}
+	}
+	global.metric.QueueSorted += len(q.commands)
+
+	q.tmp = radixSort(q.keys, q.tmp)
+}
+
+func (q *RenderQueue) Draw(target BatchTarget) {
+	q.sort()
+
+	for _, k := range q.keys {
+		c := &q.commands[k.index]
+		target.Add(c.filler, c.matrix, c.mask, c.material, true)
+	}
+
+	q.Clear()
+}
+
+// Sorts keys in place with a stable, least significant digit first, radix sort. Digits that are the same for
+// every key are skipped. Tmp is used as scratch space and is returned so that it can be reused
+func radixSort(keys, tmp []sortKey) []sortKey {
+	if len(keys) <= 1 {
+		return tmp
+	}
+	if cap(tmp) < len(keys) {
+		tmp = make([]sortKey, len(keys))
+	}
+	tmp = tmp[:len(keys)]
+
+	src, dst := keys, tmp
+	for shift := 0; shift < 64; shift += 8 {
+		var counts [256]int
+		for _, k := range src {
+			counts[(k.key>>shift)&0xFF]++
+		}
+		if counts[(src[0].key>>shift)&0xFF] == len(src) {
+			continue
+		}
+
+		offset := 0
+		for i, c := range counts {
+			counts[i] = offset
+			offset += c
+		}
+		for _, k := range src {
+			digit := (k.key >> shift) & 0xFF
+			dst[counts[digit]] = k
+			counts[digit]++
+		}
+		src, dst = dst, src
+	}
+
+	if &src[0] != &keys[0] {
+		copy(keys, src)
+	}
+	return tmp
+}
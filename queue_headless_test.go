@@ -0,0 +1,110 @@
+//go:build headless
+// +build headless
+
+package glitch
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/unitoftime/flow/glm"
+)
+
+func TestHeadlessRenderQueue(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	red := NewRGBATexture(4, 4, color.RGBA{255, 0, 0, 255}, false)
+	green := NewRGBATexture(4, 4, color.RGBA{0, 255, 0, 255}, false)
+	sprites := []*Sprite{NewSprite(red, red.Bounds()), NewSprite(green, green.Bounds())}
+
+	// Alternate textures so that drawing in the added order switches material on every command
+	drawScene := func(target BatchTarget) {
+		for i := 0; i < 64; i++ {
+			mat := Mat4Ident
+			mat.Translate(float64(2+i%4*4), float64(2+i/4%4*4), 0)
+			sprites[i%2].Draw(target, mat)
+		}
+	}
+
+	sorter := NewSorter()
+	sorter.DepthTest = true
+	drawScene(sorter)
+	Clear(win, RGBA{})
+	GetMetrics()
+	sorter.Draw(win)
+	sorterMetrics := GetMetrics()
+
+	queue := NewRenderQueue()
+	queue.DepthTest = true
+	drawScene(queue)
+	Clear(win, RGBA{})
+	GetMetrics()
+	queue.Draw(win)
+	queueMetrics := GetMetrics()
+
+	if queueMetrics.SetMaterial != 2 || sorterMetrics.SetMaterial <= queueMetrics.SetMaterial {
+		t.Errorf("expected the queue to group by texture, sorter switched %d times and queue switched %d times",
+			sorterMetrics.SetMaterial, queueMetrics.SetMaterial)
+	}
+	if queueMetrics.QueueUnsortedSwitches != 63 || queueMetrics.QueueSorted != 64 {
+		t.Errorf("unexpected queue metrics %+v", queueMetrics)
+	}
+
+	// Translucent commands still draw back to front: the lower sprite is drawn last and covers the higher one
+	queue.DepthTest = false
+	queue.SoftwareSort = SoftwareSortY
+	low, high := Mat4Ident, Mat4Ident
+	low.Translate(8, 7, 0)
+	high.Translate(8, 9, 0)
+	sprites[0].Draw(queue, low)
+	sprites[1].Draw(queue, high)
+	Clear(win, RGBA{})
+	queue.Draw(win)
+	if got := win.Image().RGBAAt(8, 8); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("expected the lower sprite on top, got %v", got)
+	}
+	win.Update()
+}
+
+// With depth testing, every opaque command is drawn before the translucent ones, the same as the Sorter
+func TestHeadlessRenderQueueMatchesSorter(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	drawScene := func(target interface {
+		BatchTarget
+		SetLayer(int8)
+	}) {
+		// The translucent quad is on a layer that is drawn first, but it is moved in front of the opaque one,
+		// so it only blends correctly if the opaque quad is drawn before it
+		quad := NewQuadMesh(glm.R(0, 0, 16, 16), glm.R(0, 0, 1, 1))
+		front := Mat4Ident
+		front.Translate(0, 0, 1.5)
+		target.SetLayer(1)
+		quad.DrawColorMask(target, front, RGBA{0, 0.5, 0, 0.5})
+		target.SetLayer(0)
+		quad.DrawColorMask(target, Mat4Ident, RGBA{0, 0, 1, 1})
+	}
+
+	sorter := NewSorter()
+	sorter.DepthTest = true
+	drawScene(sorter)
+	Clear(win, RGBA{})
+	sorter.Draw(win)
+	want := win.Image()
+
+	queue := NewRenderQueue()
+	queue.DepthTest = true
+	drawScene(queue)
+	Clear(win, RGBA{})
+	queue.Draw(win)
+	got := win.Image()
+
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			if a, b := got.RGBAAt(x, y), want.RGBAAt(x, y); a != b {
+				t.Fatalf("pixel (%d, %d): expected %v like the sorter, got %v", x, y, b, a)
+			}
+		}
+	}
+	win.Update()
+}
@@ -0,0 +1,34 @@
+package glitch
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestRadixSort(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	keys := make([]sortKey, 1000)
+	for i := range keys {
+		// Leave some digits the same in every key, and make duplicates to check that the sort is stable
+		keys[i] = sortKey{rng.Uint64() & 0xFF00FFFF000000FF, uint32(i)}
+		if i%10 == 0 {
+			keys[i].key = keys[0].key
+		}
+	}
+
+	expected := slices.Clone(keys)
+	slices.SortStableFunc(expected, func(a, b sortKey) int {
+		if a.key < b.key {
+			return -1
+		} else if a.key > b.key {
+			return 1
+		}
+		return 0
+	})
+
+	radixSort(keys, nil)
+	if !slices.Equal(keys, expected) {
+		t.Errorf("radix sort doesn't match a stable sort")
+	}
+}
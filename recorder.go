@@ -0,0 +1,681 @@
+package glitch
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// A single call to BatchTarget.Add captured by a CommandRecorder
+type RecordedCommand struct {
+	Filler      GeometryFiller
+	Matrix      Mat4
+	Mask        RGBA
+	Material    Material
+	Translucent bool
+}
+
+// CommandRecorder is a BatchTarget that stores every draw instead of rendering it. It doesn't touch the GPU,
+// so it can be used to test render code, and the recorded commands can be replayed into any other BatchTarget.
+//
+// Recordings can be serialized to JSON or to a compact binary format. Geometry is stored inline, but textures
+// and shaders are GPU objects, so they are stored by index into Resources(). When decoding, call SetResources
+// first to map those indices back to live objects; any that can't be resolved are left nil.
+type CommandRecorder struct {
+	commands  []RecordedCommand
+	resources RecordingResources
+}
+
+func NewCommandRecorder() *CommandRecorder {
+	return &CommandRecorder{
+		commands: make([]RecordedCommand, 0),
+	}
+}
+
+func (r *CommandRecorder) Add(filler GeometryFiller, mat glMat4, mask RGBA, material Material, translucent bool) {
+	r.commands = append(r.commands, RecordedCommand{
+		Filler:      filler,
+		Matrix:      mat.Mat4(),
+		Mask:        mask,
+		Material:    material,
+		Translucent: translucent,
+	})
+}
+
+// Returns the recorded commands in the order they were added. The slice is reused after Clear
+func (r *CommandRecorder) Commands() []RecordedCommand {
+	return r.commands
+}
+
+func (r *CommandRecorder) Len() int {
+	return len(r.commands)
+}
+
+func (r *CommandRecorder) Clear() {
+	r.commands = r.commands[:0]
+}
+
+// Adds every recorded command to the target in the order they were recorded
+func (r *CommandRecorder) Replay(target BatchTarget) {
+	for i := range r.commands {
+		c := &r.commands[i]
+		target.Add(c.Filler, glm4(c.Matrix), c.Mask, c.Material, c.Translucent)
+	}
+}
+
+// Textures and shaders referenced by a serialized recording, indexed by the ids stored in the recording
+type RecordingResources struct {
+	Textures []*Texture
+	Shaders  []*Shader
+}
+
+// Returns the textures and shaders used by the recorded commands, in the order that serialization assigns their ids
+func (r *CommandRecorder) Resources() RecordingResources {
+	_, res, _ := r.encode()
+	return res
+}
+
+// Sets the resources used to resolve texture and shader ids when unmarshaling
+func (r *CommandRecorder) SetResources(res RecordingResources) {
+	r.resources = res
+}
+
+// --------------------------------------------------------------------------------
+// Serialization
+
+// Version 2 added the material scissor and version 3 added the mesh primitive, mesh bones and material stencil.
+// Older recordings are still decoded, without a scissor or stencil and with triangle meshes
+const recordingVersion = 3
+
+var recordingMagic = [4]byte{'G', 'L', 'R', 'C'}
+
+type recordingData struct {
+	Version   int                 `json:"version"`
+	Meshes    []recordedMesh      `json:"meshes"`
+	Materials []recordedMaterial  `json:"materials"`
+	Commands  []recordedCommandID `json:"commands"`
+}
+
+type recordedMesh struct {
+	Positions []glVec3      `json:"positions"`
+	Normals   []glVec3      `json:"normals"`
+	Colors    []glVec4      `json:"colors"`
+	TexCoords []glVec2      `json:"texCoords"`
+	Indices   []uint32      `json:"indices"`
+	Bounds    [6]float64    `json:"bounds"`
+	Origin    Vec3          `json:"origin"`
+	Primitive PrimitiveMode `json:"primitive,omitempty"`
+	Bones     []glUByte4    `json:"bones,omitempty"`
+}
+
+type recordedMaterial struct {
+	Shader   int               `json:"shader"`   // -1 for nil
+	Textures []int             `json:"textures"` // One per texture unit, -1 for nil
+	Uniforms []recordedUniform `json:"uniforms,omitempty"`
+	Blend    BlendMode         `json:"blend"`
+	Depth    DepthMode         `json:"depth"`
+	Cull     CullMode          `json:"cull"`
+	Scissor  *[4]float64       `json:"scissor,omitempty"` // Min x, min y, max x, max y. Nil if disabled
+	Stencil  *StencilMode      `json:"stencil,omitempty"` // Nil if disabled
+}
+
+type uniformKind uint8
+
+const (
+	uniformFloat32 uniformKind = iota
+	uniformFloat64
+	uniformVec3
+	uniformVec4
+	uniformRGBA
+	uniformMat4
+	uniformGlMat4
+	uniformVec2
+)
+
+type recordedUniform struct {
+	Name   string      `json:"name"`
+	Kind   uniformKind `json:"kind"`
+	Values []float64   `json:"values"`
+}
+
+type recordedCommandID struct {
+	Mesh        int        `json:"mesh"`
+	Material    int        `json:"material"`
+	Matrix      glMat4     `json:"matrix"`
+	Mask        [4]float64 `json:"mask"`
+	Translucent bool       `json:"translucent"`
+}
+
+// Converts the commands to their serializable form, deduplicating meshes, materials, textures and shaders
+func (r *CommandRecorder) encode() (recordingData, RecordingResources, error) {
+	data := recordingData{
+		Version:   recordingVersion,
+		Meshes:    make([]recordedMesh, 0),
+		Materials: make([]recordedMaterial, 0),
+		Commands:  make([]recordedCommandID, 0, len(r.commands)),
+	}
+	res := RecordingResources{
+		Textures: make([]*Texture, 0),
+		Shaders:  make([]*Shader, 0),
+	}
+
+	meshIDs := make(map[*Mesh]int)
+	materialIDs := make(map[Material]int)
+	textureIDs := make(map[*Texture]int)
+	shaderIDs := make(map[*Shader]int)
+
+	for i := range r.commands {
+		c := &r.commands[i]
+
+		mesh, ok := c.Filler.(*Mesh)
+		if !ok {
+			return data, res, fmt.Errorf("glitch: cannot serialize geometry of type %T", c.Filler)
+		}
+		if mesh.buffer != nil && len(mesh.positions) == 0 {
+			return data, res, errors.New("glitch: cannot serialize a mesh that only exists in a gpu buffer")
+		}
+		meshID, ok := meshIDs[mesh]
+		if !ok {
+			meshID = len(data.Meshes)
+			meshIDs[mesh] = meshID
+			data.Meshes = append(data.Meshes, recordMesh(mesh))
+		}
+
+		materialID, ok := materialIDs[c.Material]
+		if !ok {
+			mat := recordedMaterial{
+				Shader:   resourceID(shaderIDs, &res.Shaders, c.Material.shader),
+				Textures: make([]int, len(c.Material.textures)),
+				Blend:    c.Material.blend,
+				Depth:    c.Material.depth,
+				Cull:     c.Material.cull,
+			}
+			if s := c.Material.scissor; s.enabled {
+				mat.Scissor = &[4]float64{s.rect.Min.X, s.rect.Min.Y, s.rect.Max.X, s.rect.Max.Y}
+			}
+			if s := c.Material.stencil; s != (StencilMode{}) {
+				mat.Stencil = &s
+			}
+			for unit, texture := range c.Material.textures {
+				mat.Textures[unit] = resourceID(textureIDs, &res.Textures, texture)
+			}
+			uniforms, err := recordUniforms(c.Material.uniforms)
+			if err != nil {
+				return data, res, err
+			}
+			mat.Uniforms = uniforms
+			materialID = len(data.Materials)
+			materialIDs[c.Material] = materialID
+			data.Materials = append(data.Materials, mat)
+		}
+
+		data.Commands = append(data.Commands, recordedCommandID{
+			Mesh:        meshID,
+			Material:    materialID,
+			Matrix:      glm4(c.Matrix),
+			Mask:        [4]float64{c.Mask.R, c.Mask.G, c.Mask.B, c.Mask.A},
+			Translucent: c.Translucent,
+		})
+	}
+	return data, res, nil
+}
+
+func resourceID[T any](ids map[*T]int, list *[]*T, val *T) int {
+	if val == nil {
+		return -1
+	}
+	id, ok := ids[val]
+	if !ok {
+		id = len(*list)
+		ids[val] = id
+		*list = append(*list, val)
+	}
+	return id
+}
+
+func resolveResource[T any](list []*T, id int) *T {
+	if id < 0 || id >= len(list) {
+		return nil
+	}
+	return list[id]
+}
+
+func recordMesh(m *Mesh) recordedMesh {
+	return recordedMesh{
+		Positions: m.positions,
+		Normals:   m.normals,
+		Colors:    m.colors,
+		TexCoords: m.texCoords,
+		Indices:   m.indices,
+		Bounds: [6]float64{
+			m.bounds.Min.X, m.bounds.Min.Y, m.bounds.Min.Z,
+			m.bounds.Max.X, m.bounds.Max.Y, m.bounds.Max.Z,
+		},
+		Origin:    m.origin,
+		Primitive: m.primitive,
+		Bones:     m.bones,
+	}
+}
+
+func (m recordedMesh) mesh() *Mesh {
+	mesh := &Mesh{
+		positions: m.Positions,
+		normals:   m.Normals,
+		colors:    m.Colors,
+		texCoords: m.TexCoords,
+		bones:     m.Bones,
+		indices:   m.Indices,
+		bounds: Box{
+			Min: Vec3{m.Bounds[0], m.Bounds[1], m.Bounds[2]},
+			Max: Vec3{m.Bounds[3], m.Bounds[4], m.Bounds[5]},
+		},
+		origin: m.Origin,
+	}
+	mesh.SetPrimitive(m.Primitive)
+	return mesh
+}
+
+func recordUniforms(u *Uniforms) ([]recordedUniform, error) {
+	if u == nil {
+		return nil, nil
+	}
+
+	ret := make([]recordedUniform, 0, len(u.set))
+	for name, value := range u.set {
+		ru := recordedUniform{Name: name}
+		switch val := value.(type) {
+		case float32:
+			ru.Kind = uniformFloat32
+			ru.Values = []float64{float64(val)}
+		case float64:
+			ru.Kind = uniformFloat64
+			ru.Values = []float64{val}
+		case Vec2:
+			ru.Kind = uniformVec2
+			ru.Values = []float64{val.X, val.Y}
+		case Vec3:
+			ru.Kind = uniformVec3
+			ru.Values = []float64{val.X, val.Y, val.Z}
+		case Vec4:
+			ru.Kind = uniformVec4
+			ru.Values = []float64{val.X, val.Y, val.Z, val.W}
+		case RGBA:
+			ru.Kind = uniformRGBA
+			ru.Values = []float64{val.R, val.G, val.B, val.A}
+		case Mat4:
+			ru.Kind = uniformMat4
+			ru.Values = val[:]
+		case *Mat4:
+			ru.Kind = uniformMat4
+			ru.Values = val[:]
+		case glMat4:
+			ru.Kind = uniformGlMat4
+			ru.Values = make([]float64, 16)
+			for i := range val {
+				ru.Values[i] = float64(val[i])
+			}
+		case *glMat4:
+			ru.Kind = uniformGlMat4
+			ru.Values = make([]float64, 16)
+			for i := range val {
+				ru.Values[i] = float64(val[i])
+			}
+		default:
+			return nil, fmt.Errorf("glitch: cannot serialize uniform %s of type %T", name, value)
+		}
+		ret = append(ret, ru)
+	}
+
+	// Map iteration is random, so sort to keep the output stable
+	slices.SortFunc(ret, func(a, b recordedUniform) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return ret, nil
+}
+
+func (ru recordedUniform) value() (any, error) {
+	want := map[uniformKind]int{
+		uniformFloat32: 1,
+		uniformFloat64: 1,
+		uniformVec2:    2,
+		uniformVec3:    3,
+		uniformVec4:    4,
+		uniformRGBA:    4,
+		uniformMat4:    16,
+		uniformGlMat4:  16,
+	}
+	n, ok := want[ru.Kind]
+	if !ok || len(ru.Values) != n {
+		return nil, fmt.Errorf("glitch: invalid recorded uniform %s", ru.Name)
+	}
+
+	v := ru.Values
+	switch ru.Kind {
+	case uniformFloat32:
+		return float32(v[0]), nil
+	case uniformFloat64:
+		return v[0], nil
+	case uniformVec2:
+		return Vec2{v[0], v[1]}, nil
+	case uniformVec3:
+		return Vec3{v[0], v[1], v[2]}, nil
+	case uniformVec4:
+		return Vec4{v[0], v[1], v[2], v[3]}, nil
+	case uniformRGBA:
+		return RGBA{v[0], v[1], v[2], v[3]}, nil
+	case uniformMat4:
+		var m Mat4
+		copy(m[:], v)
+		return m, nil
+	default:
+		var m glMat4
+		for i := range m {
+			m[i] = float32(v[i])
+		}
+		return m, nil
+	}
+}
+
+// Rebuilds the commands from their serializable form
+func (r *CommandRecorder) decode(data recordingData) error {
+	if data.Version < 1 || data.Version > recordingVersion {
+		return fmt.Errorf("glitch: unsupported recording version %d", data.Version)
+	}
+
+	meshes := make([]*Mesh, len(data.Meshes))
+	for i := range data.Meshes {
+		meshes[i] = data.Meshes[i].mesh()
+	}
+
+	materials := make([]Material, len(data.Materials))
+	for i, m := range data.Materials {
+		if len(m.Textures) > MaxTextureUnits {
+			return fmt.Errorf("glitch: recorded material uses %d texture units", len(m.Textures))
+		}
+		materials[i] = Material{
+			shader: resolveResource(r.resources.Shaders, m.Shader),
+			blend:  m.Blend,
+			depth:  m.Depth,
+			cull:   m.Cull,
+		}
+		if s := m.Scissor; s != nil {
+			materials[i].SetScissor(Rect{Min: Vec2{s[0], s[1]}, Max: Vec2{s[2], s[3]}})
+		}
+		if m.Stencil != nil {
+			materials[i].SetStencilMode(*m.Stencil)
+		}
+		for unit, id := range m.Textures {
+			materials[i].textures[unit] = resolveResource(r.resources.Textures, id)
+		}
+		for _, ru := range m.Uniforms {
+			val, err := ru.value()
+			if err != nil {
+				return err
+			}
+			materials[i].SetUniform(ru.Name, val)
+		}
+	}
+
+	commands := make([]RecordedCommand, 0, len(data.Commands))
+	for _, c := range data.Commands {
+		if c.Mesh < 0 || c.Mesh >= len(meshes) || c.Material < 0 || c.Material >= len(materials) {
+			return errors.New("glitch: recorded command references a missing mesh or material")
+		}
+		commands = append(commands, RecordedCommand{
+			Filler:      meshes[c.Mesh],
+			Matrix:      c.Matrix.Mat4(),
+			Mask:        RGBA{c.Mask[0], c.Mask[1], c.Mask[2], c.Mask[3]},
+			Material:    materials[c.Material],
+			Translucent: c.Translucent,
+		})
+	}
+	r.commands = commands
+	return nil
+}
+
+func (r *CommandRecorder) MarshalJSON() ([]byte, error) {
+	data, _, err := r.encode()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(data)
+}
+
+func (r *CommandRecorder) UnmarshalJSON(b []byte) error {
+	var data recordingData
+	err := json.Unmarshal(b, &data)
+	if err != nil {
+		return err
+	}
+	return r.decode(data)
+}
+
+// Encodes the recording in a little endian binary format
+func (r *CommandRecorder) MarshalBinary() ([]byte, error) {
+	data, _, err := r.encode()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &binaryWriter{}
+	w.write(recordingMagic)
+	w.write(uint16(data.Version))
+
+	w.write(uint32(len(data.Meshes)))
+	for _, m := range data.Meshes {
+		w.writeSlice(m.Positions)
+		w.writeSlice(m.Normals)
+		w.writeSlice(m.Colors)
+		w.writeSlice(m.TexCoords)
+		w.writeSlice(m.Indices)
+		w.write(m.Bounds)
+		w.write([3]float64{m.Origin.X, m.Origin.Y, m.Origin.Z})
+		w.write(uint8(m.Primitive))
+		w.writeSlice(m.Bones)
+	}
+
+	w.write(uint32(len(data.Materials)))
+	for _, m := range data.Materials {
+		w.write(int32(m.Shader))
+		textures := make([]int32, len(m.Textures))
+		for unit, id := range m.Textures {
+			textures[unit] = int32(id)
+		}
+		w.writeSlice(textures)
+		w.write([3]uint8{uint8(m.Blend), uint8(m.Depth), uint8(m.Cull)})
+		w.write(m.Scissor != nil)
+		if m.Scissor != nil {
+			w.write(*m.Scissor)
+		}
+		w.write(m.Stencil != nil)
+		if m.Stencil != nil {
+			w.write(*m.Stencil)
+		}
+		w.write(uint32(len(m.Uniforms)))
+		for _, u := range m.Uniforms {
+			w.writeSlice([]byte(u.Name))
+			w.write(uint8(u.Kind))
+			w.writeSlice(u.Values)
+		}
+	}
+
+	w.write(uint32(len(data.Commands)))
+	for _, c := range data.Commands {
+		w.write(uint32(c.Mesh))
+		w.write(uint32(c.Material))
+		w.write(c.Matrix)
+		w.write(c.Mask)
+		w.write(c.Translucent)
+	}
+
+	return w.buf.Bytes(), w.err
+}
+
+func (r *CommandRecorder) UnmarshalBinary(b []byte) error {
+	rd := &binaryReader{r: bytes.NewReader(b)}
+
+	var magic [4]byte
+	rd.read(&magic)
+	if rd.err == nil && magic != recordingMagic {
+		return errors.New("glitch: data is not a binary command recording")
+	}
+
+	var version uint16
+	rd.read(&version)
+	data := recordingData{Version: int(version)}
+
+	numMeshes := rd.readLen()
+	for i := 0; i < numMeshes && rd.err == nil; i++ {
+		var m recordedMesh
+		m.Positions = readSlice[glVec3](rd)
+		m.Normals = readSlice[glVec3](rd)
+		m.Colors = readSlice[glVec4](rd)
+		m.TexCoords = readSlice[glVec2](rd)
+		m.Indices = readSlice[uint32](rd)
+		rd.read(&m.Bounds)
+		var origin [3]float64
+		rd.read(&origin)
+		m.Origin = Vec3{origin[0], origin[1], origin[2]}
+		if version >= 3 {
+			var primitive uint8
+			rd.read(&primitive)
+			m.Primitive = PrimitiveMode(primitive)
+			m.Bones = readSlice[glUByte4](rd)
+		}
+		data.Meshes = append(data.Meshes, m)
+	}
+
+	numMaterials := rd.readLen()
+	for i := 0; i < numMaterials && rd.err == nil; i++ {
+		var shader int32
+		var modes [3]uint8
+		rd.read(&shader)
+		textures := readSlice[int32](rd)
+		rd.read(&modes)
+		m := recordedMaterial{
+			Shader:   int(shader),
+			Textures: make([]int, len(textures)),
+			Blend:    BlendMode(modes[0]),
+			Depth:    DepthMode(modes[1]),
+			Cull:     CullMode(modes[2]),
+		}
+		if version >= 2 {
+			var hasScissor bool
+			rd.read(&hasScissor)
+			if hasScissor {
+				m.Scissor = new([4]float64)
+				rd.read(m.Scissor)
+			}
+		}
+		if version >= 3 {
+			var hasStencil bool
+			rd.read(&hasStencil)
+			if hasStencil {
+				m.Stencil = new(StencilMode)
+				rd.read(m.Stencil)
+			}
+		}
+		for unit, id := range textures {
+			m.Textures[unit] = int(id)
+		}
+
+		numUniforms := rd.readLen()
+		for j := 0; j < numUniforms && rd.err == nil; j++ {
+			var u recordedUniform
+			u.Name = string(readSlice[byte](rd))
+			var kind uint8
+			rd.read(&kind)
+			u.Kind = uniformKind(kind)
+			u.Values = readSlice[float64](rd)
+			m.Uniforms = append(m.Uniforms, u)
+		}
+		data.Materials = append(data.Materials, m)
+	}
+
+	numCommands := rd.readLen()
+	for i := 0; i < numCommands && rd.err == nil; i++ {
+		var mesh, material uint32
+		var c recordedCommandID
+		rd.read(&mesh)
+		rd.read(&material)
+		rd.read(&c.Matrix)
+		rd.read(&c.Mask)
+		rd.read(&c.Translucent)
+		c.Mesh = int(mesh)
+		c.Material = int(material)
+		data.Commands = append(data.Commands, c)
+	}
+
+	if rd.err != nil {
+		if errors.Is(rd.err, io.EOF) {
+			return io.ErrUnexpectedEOF
+		}
+		return rd.err
+	}
+	return r.decode(data)
+}
+
+type binaryWriter struct {
+	buf bytes.Buffer
+	err error
+}
+
+func (w *binaryWriter) write(v any) {
+	if w.err != nil {
+		return
+	}
+	w.err = binary.Write(&w.buf, binary.LittleEndian, v)
+}
+
+// Writes a length prefixed slice
+func (w *binaryWriter) writeSlice(v any) {
+	w.write(uint32(binary.Size(v)))
+	w.write(v)
+}
+
+type binaryReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (rd *binaryReader) read(v any) {
+	if rd.err != nil {
+		return
+	}
+	rd.err = binary.Read(rd.r, binary.LittleEndian, v)
+}
+
+// Reads an element count, guarding against counts that couldn't possibly fit in the remaining data
+func (rd *binaryReader) readLen() int {
+	var n uint32
+	rd.read(&n)
+	if rd.err == nil && int64(n) > int64(rd.r.Len()) {
+		rd.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	return int(n)
+}
+
+func readSlice[T any](rd *binaryReader) []T {
+	var size uint32
+	rd.read(&size)
+	if rd.err != nil {
+		return nil
+	}
+
+	var zero T
+	elemSize := binary.Size(zero)
+	if int64(size) > int64(rd.r.Len()) || elemSize <= 0 || int(size)%elemSize != 0 {
+		rd.err = io.ErrUnexpectedEOF
+		return nil
+	}
+
+	ret := make([]T, int(size)/elemSize)
+	rd.read(ret)
+	return ret
+}
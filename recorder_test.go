@@ -0,0 +1,124 @@
+package glitch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/unitoftime/flow/glm"
+)
+
+func recordTestScene() (*CommandRecorder, *Texture, *Shader) {
+	texture := &Texture{width: 4, height: 4}
+	shader := &Shader{}
+
+	material := NewMaterial(shader)
+	material.SetTexture(texture)
+	material.SetUniform("brightness", float32(0.5))
+	material.SetUniform("u_direction", Vec2{2, 0})
+	material.SetBlendMode(BlendModeMultiply)
+	material.SetScissor(glm.R(1, 2, 3, 4))
+	material.SetStencilMode(StencilModeTest(3))
+
+	mesh := NewQuadMesh(glm.R(0, 0, 4, 4), glm.R(0, 0, 1, 1))
+	strip := NewQuadMesh(glm.R(0, 0, 4, 4), glm.R(0, 0, 1, 1))
+	strip.SetPrimitive(PrimitiveLineStrip)
+	strip.SetBones([][4]uint8{{0, 1, 2, 3}, {1, 0, 0, 0}, {2, 0, 0, 0}, {3, 0, 0, 0}})
+
+	rec := NewCommandRecorder()
+	mat := Mat4Ident
+	mat.Translate(10, 20, 0)
+	rec.Add(strip, glm4(mat), RGBA{1, 0, 0, 1}, NewMaterial(shader), false)
+	rec.Add(mesh, glm4(Mat4Ident), White, material, true)
+	return rec, texture, shader
+}
+
+func TestCommandRecorderReplay(t *testing.T) {
+	rec, _, _ := recordTestScene()
+	if rec.Len() != 2 {
+		t.Fatalf("expected 2 commands, got %d", rec.Len())
+	}
+	if pos := rec.Commands()[0].Matrix.Apply(Vec3{}); pos != (Vec3{10, 20, 0}) {
+		t.Errorf("expected first command to be translated to (10, 20), got %v", pos)
+	}
+
+	replayed := NewCommandRecorder()
+	rec.Replay(replayed)
+	if !reflect.DeepEqual(rec.Commands(), replayed.Commands()) {
+		t.Errorf("replayed commands don't match the recording")
+	}
+}
+
+func TestCommandRecorderSerialize(t *testing.T) {
+	rec, texture, shader := recordTestScene()
+
+	res := rec.Resources()
+	if len(res.Textures) != 1 || len(res.Shaders) != 1 {
+		t.Fatalf("expected one texture and one shader, got %d and %d", len(res.Textures), len(res.Shaders))
+	}
+
+	jsonData, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binData, err := rec.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fromJSON := NewCommandRecorder()
+	fromJSON.SetResources(res)
+	if err := json.Unmarshal(jsonData, fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	fromBinary := NewCommandRecorder()
+	fromBinary.SetResources(res)
+	if err := fromBinary.UnmarshalBinary(binData); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, decoded := range map[string]*CommandRecorder{"json": fromJSON, "binary": fromBinary} {
+		if decoded.Len() != rec.Len() {
+			t.Fatalf("%s: expected %d commands, got %d", name, rec.Len(), decoded.Len())
+		}
+		for i, want := range rec.Commands() {
+			got := decoded.Commands()[i]
+			if got.Matrix != want.Matrix || got.Mask != want.Mask || got.Translucent != want.Translucent {
+				t.Errorf("%s: command %d: got %+v, expected %+v", name, i, got, want)
+			}
+			if !reflect.DeepEqual(got.Filler.(*Mesh).positions, want.Filler.(*Mesh).positions) {
+				t.Errorf("%s: command %d: mesh positions don't match", name, i)
+			}
+			if got, want := got.Filler.(*Mesh).Primitive(), want.Filler.(*Mesh).Primitive(); got != want {
+				t.Errorf("%s: command %d: expected a %v mesh, got %v", name, i, want, got)
+			}
+			if got, want := got.Filler.(*Mesh).bones, want.Filler.(*Mesh).bones; len(got) != len(want) || len(want) > 0 && !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: command %d: mesh bones don't match", name, i)
+			}
+		}
+
+		m := decoded.Commands()[1].Material
+		if m.textures[0] != texture || m.shader != shader || m.blend != BlendModeMultiply {
+			t.Errorf("%s: material wasn't restored: %+v", name, m)
+		}
+		if m.scissor != (scissor{glm.R(1, 2, 3, 4), true}) {
+			t.Errorf("%s: expected scissor to be restored, got %+v", name, m.scissor)
+		}
+		if m.stencil != StencilModeTest(3) {
+			t.Errorf("%s: expected stencil to be restored, got %+v", name, m.stencil)
+		}
+		if m := decoded.Commands()[0].Material; m.scissor.enabled || m.stencil != (StencilMode{}) {
+			t.Errorf("%s: expected the plain material to stay unscissored and unstenciled", name)
+		}
+		if v := m.uniforms.set["brightness"]; v != float32(0.5) {
+			t.Errorf("%s: expected uniform to be restored, got %v", name, v)
+		}
+		if v := m.uniforms.set["u_direction"]; v != (Vec2{2, 0}) {
+			t.Errorf("%s: expected vec2 uniform to be restored, got %v", name, v)
+		}
+	}
+
+	if err := NewCommandRecorder().UnmarshalBinary(binData[:len(binData)/2]); err == nil {
+		t.Errorf("expected an error decoding truncated data")
+	}
+}
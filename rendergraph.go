@@ -0,0 +1,552 @@
+package glitch
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// A RenderGraph runs a set of passes that declare which targets they read and write. The passes are ordered so that
+// each one runs after the passes that write what it reads, and passes that don't contribute to an imported target are
+// skipped. Transient targets are frames borrowed from a FramePool only while the passes that use them run, so
+// transient targets that are never needed at the same time share the same frame.
+//
+//	graph := glitch.NewRenderGraph()
+//	screen := graph.Import("screen", win)
+//	graph.SetClearColor(screen, glitch.Black)
+//	world := graph.Create("world", win.Bounds(), cfg)
+//	lights := graph.Create("lights", win.Bounds(), cfg)
+//
+//	graph.AddPass("composite", func(ctx *glitch.RenderPassContext) {
+//		ctx.Frame(world).Draw(ctx.Target(screen), glitch.Mat4Ident)
+//		ctx.Frame(lights).Draw(ctx.Target(screen), glitch.Mat4Ident)
+//	}).Reads(world, lights).Writes(screen)
+//	graph.AddPass("world", func(ctx *glitch.RenderPassContext) {
+//		sorter.Draw(ctx.Target(world))
+//	}).Writes(world)
+//	graph.AddPass("lights", drawLights).Writes(lights)
+//
+//	// Every frame
+//	err := graph.Execute()
+//
+// Passes that write the same target run in the order they were added, and passes that only read a target run after all of
+// its writers. Each target is cleared right before the first pass that writes it, so passes never need to call Clear
+type RenderGraph struct {
+	pool      *FramePool
+	resources []graphResource
+	passes    []*RenderPass
+
+	compiled bool
+	err      error         // Why the graph can't be executed, if it can't
+	order    []*RenderPass // The passes that run, in order
+	ctx      RenderPassContext
+}
+
+// Identifies a target in a RenderGraph
+type RenderResource int
+
+// A target that can be drawn into and bound, like a Window or a Frame
+type RenderTarget interface {
+	Target
+	BatchTarget
+}
+
+type graphResource struct {
+	name     string
+	imported RenderTarget // Nil for transient resources
+	bounds   Rect
+	config   FrameConfig
+
+	clear      bool
+	clearColor RGBA
+
+	first, last int    // The positions in the order of the first and last passes that use the resource. -1 if it is unused
+	frame       *Frame // The frame borrowed for a transient resource while it is alive
+	cleared     bool   // Set once the resource has been cleared during the current Execute
+}
+
+// A pass of a RenderGraph. Declare what it reads and writes with Reads and Writes
+type RenderPass struct {
+	graph  *RenderGraph
+	name   string
+	run    func(*RenderPassContext)
+	index  int // The order that the pass was added in
+	reads  []RenderResource
+	writes []RenderResource
+}
+
+// Gives a running pass access to the targets that it declared
+type RenderPassContext struct {
+	graph *RenderGraph
+	pass  *RenderPass
+}
+
+// Creates a graph that borrows its transient frames from a new FramePool
+func NewRenderGraph() *RenderGraph {
+	return NewRenderGraphWithPool(NewFramePool())
+}
+
+// Creates a graph that borrows its transient frames from pool, which can be shared between graphs that execute one after another
+func NewRenderGraphWithPool(pool *FramePool) *RenderGraph {
+	g := &RenderGraph{
+		pool: pool,
+	}
+	g.ctx.graph = g
+	return g
+}
+
+// Adds a transient target. Its frame is taken from the pool when the first pass that uses it runs, and returned once the
+// last one has run, so its contents don't last between executions. It is cleared to transparent black unless changed with SetClearColor
+func (g *RenderGraph) Create(name string, bounds Rect, cfg FrameConfig) RenderResource {
+	g.compiled = false
+	g.resources = append(g.resources, graphResource{
+		name:   name,
+		bounds: bounds,
+		config: cfg,
+		clear:  true,
+	})
+	return RenderResource(len(g.resources) - 1)
+}
+
+// Adds a target that the graph doesn't own, like the Window. Only passes that lead to imported targets are run.
+// Imported targets aren't cleared unless SetClearColor is called
+func (g *RenderGraph) Import(name string, target RenderTarget) RenderResource {
+	g.compiled = false
+	g.resources = append(g.resources, graphResource{
+		name:     name,
+		imported: target,
+	})
+	return RenderResource(len(g.resources) - 1)
+}
+
+// Changes the size of a transient target, ie when the window is resized
+func (g *RenderGraph) SetBounds(r RenderResource, bounds Rect) {
+	g.resource(r).bounds = bounds
+}
+
+// Clears the target to color before the first pass that writes it
+func (g *RenderGraph) SetClearColor(r RenderResource, color RGBA) {
+	res := g.resource(r)
+	res.clear = true
+	res.clearColor = color
+}
+
+// Stops the target from being cleared, for when the first pass that writes it covers every pixel anyway.
+// Transient frames are reused, so they will hold whatever was last drawn into them
+func (g *RenderGraph) SkipClear(r RenderResource) {
+	g.resource(r).clear = false
+}
+
+// Adds a pass that calls run when it is executed
+func (g *RenderGraph) AddPass(name string, run func(*RenderPassContext)) *RenderPass {
+	g.compiled = false
+	pass := &RenderPass{
+		graph: g,
+		name:  name,
+		run:   run,
+		index: len(g.passes),
+	}
+	g.passes = append(g.passes, pass)
+	return pass
+}
+
+// Declares targets that the pass draws from
+func (p *RenderPass) Reads(resources ...RenderResource) *RenderPass {
+	for _, r := range resources {
+		p.graph.resource(r)
+	}
+	p.graph.compiled = false
+	p.reads = append(p.reads, resources...)
+	return p
+}
+
+// Declares targets that the pass draws into
+func (p *RenderPass) Writes(resources ...RenderResource) *RenderPass {
+	for _, r := range resources {
+		p.graph.resource(r)
+	}
+	p.graph.compiled = false
+	p.writes = append(p.writes, resources...)
+	return p
+}
+
+func (p *RenderPass) Name() string {
+	return p.name
+}
+
+// Returns the names of the passes that Execute runs, in the order that they run
+func (g *RenderGraph) ExecutionOrder() ([]string, error) {
+	g.compile()
+	if g.err != nil {
+		return nil, g.err
+	}
+	names := make([]string, len(g.order))
+	for i, pass := range g.order {
+		names[i] = pass.name
+	}
+	return names, nil
+}
+
+// Runs the passes. An error is returned, without running anything, if the passes depend on each other in a cycle or
+// read a transient target that nothing writes
+func (g *RenderGraph) Execute() error {
+	g.compile()
+	if g.err != nil {
+		return g.err
+	}
+
+	for i := range g.resources {
+		g.resources[i].cleared = false
+	}
+
+	for i, pass := range g.order {
+		if err := g.acquire(i, pass.reads); err != nil {
+			return err
+		}
+		if err := g.acquire(i, pass.writes); err != nil {
+			return err
+		}
+
+		for _, r := range pass.writes {
+			res := &g.resources[r]
+			if res.clear && !res.cleared {
+				Clear(res.target(), res.clearColor)
+			}
+			res.cleared = true
+		}
+
+		g.ctx.pass = pass
+		pass.run(&g.ctx)
+		g.ctx.pass = nil
+
+		g.release(i, pass.reads)
+		g.release(i, pass.writes)
+	}
+
+	g.pool.NextFrame()
+	return nil
+}
+
+// Destroys every frame in the graph's pool
+func (g *RenderGraph) Destroy() {
+	g.pool.Destroy()
+}
+
+// Borrows frames for the transient resources that are first used by the pass at position i
+func (g *RenderGraph) acquire(i int, resources []RenderResource) error {
+	for _, r := range resources {
+		res := &g.resources[r]
+		if res.imported != nil || res.first != i || res.frame != nil {
+			continue
+		}
+		frame, err := g.pool.Get(res.bounds, res.config)
+		if err != nil {
+			g.releaseAll()
+			return fmt.Errorf("glitch: render graph target %q: %w", res.name, err)
+		}
+		res.frame = frame
+	}
+	return nil
+}
+
+// Returns the frames of the transient resources that are last used by the pass at position i
+func (g *RenderGraph) release(i int, resources []RenderResource) {
+	for _, r := range resources {
+		res := &g.resources[r]
+		if res.frame == nil || res.last != i {
+			continue
+		}
+		g.pool.Put(res.frame)
+		res.frame = nil
+	}
+}
+
+func (g *RenderGraph) releaseAll() {
+	for i := range g.resources {
+		res := &g.resources[i]
+		if res.frame != nil {
+			g.pool.Put(res.frame)
+			res.frame = nil
+		}
+	}
+}
+
+func (g *RenderGraph) resource(r RenderResource) *graphResource {
+	if r < 0 || int(r) >= len(g.resources) {
+		panic(fmt.Sprintf("glitch: invalid render resource %d", r))
+	}
+	return &g.resources[r]
+}
+
+func (r *graphResource) target() RenderTarget {
+	if r.imported != nil {
+		return r.imported
+	}
+	return r.frame
+}
+
+// Culls and orders the passes, then finds how long each transient resource is alive for
+func (g *RenderGraph) compile() {
+	if g.compiled {
+		return
+	}
+	g.compiled = true
+	g.err = nil
+	g.order = g.order[:0]
+
+	writers := make([][]*RenderPass, len(g.resources))
+	for _, pass := range g.passes {
+		for _, r := range pass.writes {
+			if !slices.Contains(writers[r], pass) {
+				writers[r] = append(writers[r], pass)
+			}
+		}
+	}
+
+	// A pass runs if it writes a resource that is needed, which is any imported resource or anything read by a pass that runs
+	live := make([]bool, len(g.passes))
+	needed := make([]bool, len(g.resources))
+	var stack []RenderResource
+	for r := range g.resources {
+		if g.resources[r].imported != nil {
+			needed[r] = true
+			stack = append(stack, RenderResource(r))
+		}
+	}
+	for len(stack) > 0 {
+		r := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, pass := range writers[r] {
+			if live[pass.index] {
+				continue
+			}
+			live[pass.index] = true
+			for _, read := range pass.reads {
+				if !needed[read] {
+					needed[read] = true
+					stack = append(stack, read)
+				}
+			}
+		}
+	}
+
+	// Writers of a resource run in the order they were added, and passes that only read it run after all of its writers
+	after := make([][]int, len(g.passes)) // The live passes that must run after each pass
+	deps := make([]int, len(g.passes))    // The number of passes that each pass waits on
+	addEdge := func(from, to *RenderPass) {
+		if from == to || slices.Contains(after[from.index], to.index) {
+			return
+		}
+		after[from.index] = append(after[from.index], to.index)
+		deps[to.index]++
+	}
+	for _, pass := range g.passes {
+		if !live[pass.index] {
+			continue
+		}
+		for _, r := range pass.reads {
+			if len(writers[r]) == 0 && g.resources[r].imported == nil {
+				g.err = fmt.Errorf("glitch: render pass %q reads %q, which no pass writes", pass.name, g.resources[r].name)
+				return
+			}
+			if slices.Contains(pass.writes, r) {
+				continue // Covered by the order of the writers
+			}
+			for _, writer := range writers[r] {
+				addEdge(writer, pass)
+			}
+		}
+	}
+	for r := range writers {
+		for i := 1; i < len(writers[r]); i++ {
+			if live[writers[r][i-1].index] && live[writers[r][i].index] {
+				addEdge(writers[r][i-1], writers[r][i])
+			}
+		}
+	}
+
+	// Whenever more than one pass is ready, the one that was added first runs first, so the order is deterministic
+	done := make([]bool, len(g.passes))
+	count := 0
+	for _, l := range live {
+		if l {
+			count++
+		}
+	}
+	for len(g.order) < count {
+		next := -1
+		for i, pass := range g.passes {
+			if live[i] && !done[i] && deps[i] == 0 {
+				next = pass.index
+				break
+			}
+		}
+		if next < 0 {
+			var names []string
+			for i, pass := range g.passes {
+				if live[i] && !done[i] {
+					names = append(names, fmt.Sprintf("%q", pass.name))
+				}
+			}
+			g.err = fmt.Errorf("glitch: render passes depend on each other in a cycle: %s", strings.Join(names, ", "))
+			g.order = g.order[:0]
+			return
+		}
+		done[next] = true
+		g.order = append(g.order, g.passes[next])
+		for _, i := range after[next] {
+			deps[i]--
+		}
+	}
+
+	for r := range g.resources {
+		g.resources[r].first, g.resources[r].last = -1, -1
+	}
+	for i, pass := range g.order {
+		for _, list := range [][]RenderResource{pass.reads, pass.writes} {
+			for _, r := range list {
+				res := &g.resources[r]
+				if res.first < 0 {
+					res.first = i
+				}
+				res.last = i
+			}
+		}
+	}
+}
+
+// Returns a target that the pass declared that it writes
+func (c *RenderPassContext) Target(r RenderResource) RenderTarget {
+	if !slices.Contains(c.pass.writes, r) {
+		panic(fmt.Sprintf("glitch: render pass %q doesn't declare that it writes %q", c.pass.name, c.graph.resource(r).name))
+	}
+	return c.graph.resources[r].target()
+}
+
+// Returns the frame of a target that the pass declared that it reads or writes. Returns nil if the target is imported and isn't a Frame
+func (c *RenderPassContext) Frame(r RenderResource) *Frame {
+	if !slices.Contains(c.pass.reads, r) && !slices.Contains(c.pass.writes, r) {
+		panic(fmt.Sprintf("glitch: render pass %q doesn't declare that it uses %q", c.pass.name, c.graph.resource(r).name))
+	}
+	res := &c.graph.resources[r]
+	if res.imported != nil {
+		frame, _ := res.imported.(*Frame)
+		return frame
+	}
+	return res.frame
+}
+
+// Returns the pass that is running
+func (c *RenderPassContext) Pass() *RenderPass {
+	return c.pass
+}
+
+// --------------------------------------------------------------------------------
+
+// Frames in a FramePool that haven't been borrowed for this many calls to NextFrame are destroyed
+const framePoolMaxIdle = 60
+
+// A FramePool reuses frames so that temporary targets don't allocate new textures every frame. Frames are matched by
+// their configuration, and a free frame of a different size is resized rather than creating another one
+type FramePool struct {
+	free  []pooledFrame
+	keys  map[*Frame]framePoolKey // Every frame that the pool created
+	frame int                     // Incremented by NextFrame
+}
+
+type pooledFrame struct {
+	frame    *Frame
+	key      framePoolKey
+	lastUsed int
+}
+
+// FrameConfig can't be compared because of its slice, so the color formats are packed into a string
+type framePoolKey struct {
+	smooth  bool
+	colors  string
+	depth   FrameDepth
+	samples int
+}
+
+func newFramePoolKey(cfg FrameConfig) framePoolKey {
+	colors := make([]byte, len(cfg.Color))
+	for i, format := range cfg.Color {
+		colors[i] = byte(format)
+	}
+	return framePoolKey{
+		smooth:  cfg.Smooth,
+		colors:  string(colors),
+		depth:   cfg.Depth,
+		samples: cfg.Samples,
+	}
+}
+
+func NewFramePool() *FramePool {
+	return &FramePool{
+		keys: make(map[*Frame]framePoolKey),
+	}
+}
+
+// Borrows a frame with the bounds and configuration. Its contents are whatever was last drawn into it. Return it with Put
+func (p *FramePool) Get(bounds Rect, cfg FrameConfig) (*Frame, error) {
+	key := newFramePoolKey(cfg)
+
+	// Prefer a frame that is already the right size, then the most recently used one
+	match := -1
+	for i := len(p.free) - 1; i >= 0; i-- {
+		if p.free[i].key != key {
+			continue
+		}
+		if match < 0 {
+			match = i
+		}
+		if p.free[i].frame.Bounds() == bounds {
+			match = i
+			break
+		}
+	}
+	if match >= 0 {
+		frame := p.free[match].frame
+		p.free = slices.Delete(p.free, match, match+1)
+		frame.Resize(bounds)
+		return frame, nil
+	}
+
+	frame, err := NewFrameWithConfig(bounds, cfg)
+	if err != nil {
+		return nil, err
+	}
+	p.keys[frame] = key
+	return frame, nil
+}
+
+// Returns a frame that was borrowed with Get
+func (p *FramePool) Put(frame *Frame) {
+	key, ok := p.keys[frame]
+	if !ok {
+		panic("glitch: frame was not created by this pool")
+	}
+	p.free = append(p.free, pooledFrame{frame, key, p.frame})
+}
+
+// Destroys the frames that haven't been borrowed recently
+func (p *FramePool) NextFrame() {
+	p.frame++
+	p.free = slices.DeleteFunc(p.free, func(f pooledFrame) bool {
+		if p.frame-f.lastUsed <= framePoolMaxIdle {
+			return false
+		}
+		delete(p.keys, f.frame)
+		f.frame.Destroy()
+		return true
+	})
+}
+
+// Destroys every frame that the pool created, including any that are still borrowed
+func (p *FramePool) Destroy() {
+	for frame := range p.keys {
+		frame.Destroy()
+	}
+	clear(p.keys)
+	p.free = p.free[:0]
+}
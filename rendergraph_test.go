@@ -0,0 +1,94 @@
+//go:build headless
+// +build headless
+
+package glitch
+
+import (
+	"image/color"
+	"slices"
+	"testing"
+)
+
+func TestHeadlessRenderGraph(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	white := NewRGBATexture(4, 4, color.RGBA{255, 255, 255, 255}, false)
+	defer white.Destroy()
+	sprite := NewSprite(white, white.Bounds())
+
+	cfg := FrameConfig{Color: []FrameFormat{FrameFormatRGBA8}}
+	graph := NewRenderGraph()
+	defer graph.Destroy()
+	screen := graph.Import("screen", win)
+	graph.SetClearColor(screen, Black)
+	world := graph.Create("world", win.Bounds(), cfg)
+	blur := graph.Create("blur", win.Bounds(), cfg)
+	final := graph.Create("final", win.Bounds(), cfg)
+	unused := graph.Create("unused", win.Bounds(), cfg)
+
+	// Added out of order, so that the graph has to sort them
+	frames := make(map[string]*Frame)
+	copyPass := func(src, dst RenderResource) func(*RenderPassContext) {
+		return func(ctx *RenderPassContext) {
+			frames[ctx.Pass().Name()] = ctx.Frame(src)
+			ctx.Frame(src).Draw(ctx.Target(dst), Mat4Ident)
+		}
+	}
+	graph.AddPass("composite", copyPass(final, screen)).Reads(final).Writes(screen)
+	graph.AddPass("blur", copyPass(world, blur)).Reads(world).Writes(blur)
+	graph.AddPass("final", copyPass(blur, final)).Reads(blur).Writes(final)
+	graph.AddPass("unused", func(ctx *RenderPassContext) {
+		t.Error("expected the pass with an unused output to be skipped")
+	}).Writes(unused)
+	graph.AddPass("world", func(ctx *RenderPassContext) {
+		mat := Mat4Ident
+		mat.Translate(8, 8, 0)
+		sprite.Draw(ctx.Target(world), mat)
+	}).Writes(world)
+
+	order, err := graph.ExecutionOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"world", "blur", "final", "composite"}; !slices.Equal(order, want) {
+		t.Fatalf("expected the order %v, got %v", want, order)
+	}
+
+	if err := graph.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if got := win.Image().RGBAAt(8, 16-1-8); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("expected the sprite to reach the window, got %v", got)
+	}
+	if got := win.Image().RGBAAt(1, 16-1-1); got != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("expected the window to be cleared, got %v", got)
+	}
+	win.Update()
+
+	// World is done with before final is first written, so they share a frame
+	if frames["blur"] != frames["composite"] {
+		t.Errorf("expected the world and final targets to alias the same frame")
+	}
+	if frames["blur"] == frames["final"] {
+		t.Errorf("expected targets that are alive at the same time to use different frames")
+	}
+
+	// The frames are reused by the next execution
+	if err := graph.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if frames["blur"] != frames["composite"] || len(graph.pool.keys) != 2 {
+		t.Errorf("expected the pool to reuse its 2 frames, it has %d", len(graph.pool.keys))
+	}
+	win.Update()
+
+	cycle := NewRenderGraph()
+	a := cycle.Create("a", win.Bounds(), cfg)
+	b := cycle.Create("b", win.Bounds(), cfg)
+	out := cycle.Import("screen", win)
+	cycle.AddPass("first", func(*RenderPassContext) {}).Reads(a).Writes(b)
+	cycle.AddPass("second", func(*RenderPassContext) {}).Reads(b).Writes(a, out)
+	if err := cycle.Execute(); err == nil {
+		t.Error("expected passes that depend on each other to fail")
+	}
+}
@@ -0,0 +1,109 @@
+package glitch
+
+import (
+	"log"
+	"runtime/debug"
+	"sort"
+	"sync"
+)
+
+// A GPU object that hasn't been destroyed yet, see SetLeakTracking
+type TrackedResource struct {
+	Kind  string // Texture, Frame, Shader or VertexBuffer
+	Stack string // Where the resource was created
+	id    uint64
+}
+
+var leakTracker = struct {
+	sync.Mutex // Finalizers untrack resources from their own goroutine
+	enabled    bool
+	nextID     uint64
+	live       map[uint64]TrackedResource
+}{
+	live: make(map[uint64]TrackedResource),
+}
+
+// Enables tracking every texture, frame, shader and vertex buffer that is created, along with the stack trace of where
+// it was created. Anything that hasn't been destroyed when a window is closed is logged as a leak. The default shaders,
+// WhiteTexture and PlaceholderTexture are cached for the whole program, so they are never reported. The buffers that a
+// shader draws with are released along with it, so they are reported as part of the shader rather than on their own.
+// Capturing stack traces is slow, so this is meant for debugging. Only resources created while it is enabled are tracked
+func SetLeakTracking(enabled bool) {
+	leakTracker.Lock()
+	defer leakTracker.Unlock()
+
+	leakTracker.enabled = enabled
+	if !enabled {
+		clear(leakTracker.live)
+	}
+}
+
+// Returns the tracked resources that haven't been destroyed, oldest first. Resources that were garbage collected
+// are released by their finalizers, so call runtime.GC first to only see the resources that are still reachable
+func TrackedResources() []TrackedResource {
+	leakTracker.Lock()
+	defer leakTracker.Unlock()
+
+	owned := libraryResources()
+	ret := make([]TrackedResource, 0, len(leakTracker.live))
+	for id, r := range leakTracker.live {
+		if owned[id] {
+			continue
+		}
+		ret = append(ret, r)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].id < ret[j].id
+	})
+	return ret
+}
+
+// Returns the id that the resource keeps to untrack itself, or zero if tracking is disabled. Resources are keyed by an
+// id rather than their address, addresses can be reused once a resource is collected
+func trackResource(kind string) uint64 {
+	leakTracker.Lock()
+	defer leakTracker.Unlock()
+
+	if !leakTracker.enabled {
+		return 0
+	}
+	leakTracker.nextID++
+	leakTracker.live[leakTracker.nextID] = TrackedResource{
+		Kind:  kind,
+		Stack: string(debug.Stack()),
+		id:    leakTracker.nextID,
+	}
+	return leakTracker.nextID
+}
+
+func untrackResource(id uint64) {
+	if id == 0 {
+		return
+	}
+	leakTracker.Lock()
+	defer leakTracker.Unlock()
+
+	delete(leakTracker.live, id)
+}
+
+// Returns the ids of the singletons that the library caches, see SetLeakTracking
+func libraryResources() map[uint64]bool {
+	owned := make(map[uint64]bool)
+	for _, shader := range []*Shader{defaultSpriteShader, defaultMsdfShader, defaultInstancedShader} {
+		if shader != nil {
+			owned[shader.trackID] = true
+		}
+	}
+	for _, tex := range []*Texture{whiteTexture, placeholderTexture} {
+		if tex != nil {
+			owned[tex.trackID] = true
+		}
+	}
+	return owned
+}
+
+func reportLeaks() {
+	for _, r := range TrackedResources() {
+		log.Printf("glitch: %s was never destroyed, it was created at:\n%s", r.Kind, r.Stack)
+	}
+}
@@ -0,0 +1,160 @@
+//go:build headless
+// +build headless
+
+package glitch
+
+import (
+	"bytes"
+	"image/color"
+	"log"
+	"testing"
+
+	"github.com/unitoftime/glitch/shaders"
+)
+
+func TestHeadlessDestroy(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	SetLeakTracking(true)
+	defer SetLeakTracking(false)
+
+	tex := NewRGBATexture(4, 4, color.RGBA{255, 255, 255, 255}, false)
+	frame := NewFrame(win.Bounds(), false)
+	shader, err := NewShader(shaders.SpriteShader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := make(map[string]int)
+	for _, r := range TrackedResources() {
+		if r.Stack == "" {
+			t.Errorf("expected the %s to have a stack trace", r.Kind)
+		}
+		kinds[r.Kind]++
+	}
+	// The frame's color and depth textures are tracked too, and the shader's buffers are only created once it draws
+	if kinds["Texture"] != 3 || kinds["Frame"] != 1 || kinds["Shader"] != 1 {
+		t.Errorf("unexpected tracked resources %v", kinds)
+	}
+
+	if tex.bytes == 0 || frameMemory(frame) == 0 {
+		t.Errorf("expected the texture and frame memory to be counted, got %d and %d", tex.bytes, frameMemory(frame))
+	}
+
+	tex.Destroy()
+	tex.Destroy() // Double frees are ignored
+	frame.Destroy()
+	frame.Destroy()
+	shader.Destroy()
+	shader.Destroy()
+
+	if left := TrackedResources(); len(left) != 0 {
+		t.Errorf("expected nothing to be tracked after destroying everything, got %d resources", len(left))
+	}
+	if tex.bytes != 0 || frameMemory(frame) != 0 {
+		t.Errorf("expected the texture and frame memory to be released, got %d and %d", tex.bytes, frameMemory(frame))
+	}
+
+	// The window still draws with other shaders after one is destroyed
+	white := NewRGBATexture(4, 4, color.RGBA{255, 255, 255, 255}, false)
+	defer white.Destroy()
+	Clear(win, RGBA{})
+	mat := Mat4Ident
+	mat.Translate(8, 8, 0)
+	NewSprite(white, white.Bounds()).Draw(win, mat)
+	win.Update()
+
+	if got := win.Image().RGBAAt(8, 16-1-8); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("expected the sprite to be drawn, got %v", got)
+	}
+}
+
+func TestHeadlessLeakReportSkipsSingletons(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	SetLeakTracking(true)
+	defer SetLeakTracking(false)
+
+	// Recreate the singletons while tracking, as if this was the first window
+	lastSprite, lastMsdf, lastInstanced := defaultSpriteShader, defaultMsdfShader, defaultInstancedShader
+	lastWhite, lastPlaceholder := whiteTexture, placeholderTexture
+	defaultSpriteShader, defaultMsdfShader, defaultInstancedShader = nil, nil, nil
+	whiteTexture, placeholderTexture = nil, nil
+	defer func() {
+		for _, shader := range []*Shader{defaultSpriteShader, defaultMsdfShader, defaultInstancedShader} {
+			shader.Destroy()
+		}
+		whiteTexture.Destroy()
+		placeholderTexture.Destroy()
+		defaultSpriteShader, defaultMsdfShader, defaultInstancedShader = lastSprite, lastMsdf, lastInstanced
+		whiteTexture, placeholderTexture = lastWhite, lastPlaceholder
+	}()
+
+	GetDefaultMsdfShader()
+	GetDefaultInstancedShader()
+	PlaceholderTexture()
+	white := WhiteTexture()
+	Clear(win, RGBA{})
+	NewSprite(white, white.Bounds()).Draw(win, Mat4Ident)
+	win.Update()
+
+	leaked := NewRGBATexture(1, 1, color.RGBA{}, false)
+	defer leaked.Destroy()
+
+	var out bytes.Buffer
+	lastOutput := log.Writer()
+	log.SetOutput(&out)
+	reportLeaks()
+	log.SetOutput(lastOutput)
+
+	if left := TrackedResources(); len(left) != 1 || left[0].Kind != "Texture" {
+		t.Errorf("expected only the leaked texture to be tracked, got %+v", left)
+	}
+	if n := bytes.Count(out.Bytes(), []byte("was never destroyed")); n != 1 {
+		t.Errorf("expected one leak to be reported, got %d:\n%s", n, out.String())
+	}
+}
+
+func TestHeadlessLeakReportIncludesShaderBuffers(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	white := NewRGBATexture(4, 4, color.RGBA{255, 255, 255, 255}, false)
+	defer white.Destroy()
+
+	SetLeakTracking(true)
+	defer SetLeakTracking(false)
+
+	shader, err := NewShader(shaders.SpriteShader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shader.Destroy()
+	material := NewMaterial(shader)
+	material.SetTexture(white)
+
+	// Drawing with the shader fills its pool, whose buffers belong to the shader
+	sprite := NewSprite(white, white.Bounds())
+	Clear(win, RGBA{})
+	win.Add(sprite.mesh, glm4(Mat4Ident), White, material, false)
+	win.Update()
+	if len(shader.pool.ring[0].buffers)+len(shader.pool.buffers) == 0 {
+		t.Fatal("expected the shader to have created buffers")
+	}
+
+	if left := TrackedResources(); len(left) != 1 || left[0].Kind != "Shader" {
+		t.Errorf("expected only the shader to be tracked, got %+v", left)
+	}
+}
+
+// Returns the texture memory counted for a frame and its textures. Tests check this rather than the global total,
+// which the finalizers of resources left over from other tests can change at any time
+func frameMemory(f *Frame) int {
+	bytes := f.renderbufferBytes
+	for _, tex := range f.textures {
+		bytes += tex.bytes
+	}
+	if f.depth != nil {
+		bytes += f.depth.bytes
+	}
+	return bytes
+}
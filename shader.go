@@ -15,7 +15,11 @@ type Shader struct {
 	uniformLocs     map[string]Uniform
 	uniformsMat4    map[string]glMat4 // All uniforms that are glMat4
 	uniforms        map[string]any    // All other uniforms
+	samplers        map[string]int    // Maps sampler uniforms to their texture unit
 	attrFmt         shaders.VertexFormat
+	instanceFmt     shaders.VertexFormat // The per instance attributes, which are stored separately from the vertex attributes
+	layout          shaders.VertexLayout
+	indexType       shaders.IndexType
 	tmpBuffers      []any
 	tmpFloat32Slice []float32
 	mainthreadBind  func()
@@ -24,6 +28,9 @@ type Shader struct {
 
 	// TODO: You may be able to do a memory optimization here. where instead of allocating enough for the entire frame to be rendered through this shader, you can make a ringbuffer of VertexBuffers and cycle through those, drawing as you need to. The downside here is that there may be some performance impact if the ringbuffer is too small causing contention between filling the next VertexBuffer and rendering it on the GPU
 	pool *BufferPool
+
+	destroyed bool
+	trackID   uint64
 }
 
 type Uniform struct {
@@ -33,15 +40,47 @@ type Uniform struct {
 }
 
 func NewShader(cfg shaders.ShaderConfig) (*Shader, error) {
-	return NewShaderExt(cfg.VertexShader, cfg.FragmentShader, cfg.VertexFormat, cfg.UniformFormat)
+	return newShader(cfg.VertexShader, cfg.FragmentShader, cfg.VertexFormat, cfg.UniformFormat, cfg.Layout, cfg.Indices)
 }
 
+// Creates a shader with a planar vertex layout and 32 bit indices. Use NewShader to configure those
 func NewShaderExt(vertexSource, fragmentSource string, attrFmt shaders.VertexFormat, uniformFmt shaders.UniformFormat) (*Shader, error) {
+	return newShader(vertexSource, fragmentSource, attrFmt, uniformFmt, shaders.LayoutPlanar, shaders.Index32)
+}
+
+func newShader(vertexSource, fragmentSource string, attrFmt shaders.VertexFormat, uniformFmt shaders.UniformFormat, layout shaders.VertexLayout, indexType shaders.IndexType) (*Shader, error) {
+	// Samplers are assigned units in declaration order. Unit 0 is reserved for the material's main texture
+	samplers := make(map[string]int)
+	for _, uniform := range uniformFmt {
+		if uniform.Type != shaders.AttrSampler2D {
+			continue
+		}
+		unit := len(samplers) + 1
+		if unit >= MaxTextureUnits {
+			return nil, fmt.Errorf("shader declares more than %d samplers", MaxTextureUnits-1)
+		}
+		samplers[uniform.Name] = unit
+	}
+
+	// Per instance attributes are buffered by an InstanceBatch, rather than filled per vertex
+	var vertexFmt, instanceFmt shaders.VertexFormat
+	for _, attr := range attrFmt {
+		if attr.Divisor > 0 {
+			instanceFmt = append(instanceFmt, attr)
+		} else {
+			vertexFmt = append(vertexFmt, attr)
+		}
+	}
+
 	shader := &Shader{
 		uniformLocs:     make(map[string]Uniform),
 		uniformsMat4:    make(map[string]glMat4),
 		uniforms:        make(map[string]any),
-		attrFmt:         attrFmt,
+		samplers:        samplers,
+		attrFmt:         vertexFmt,
+		instanceFmt:     instanceFmt,
+		layout:          layout,
+		indexType:       indexType,
 		tmpFloat32Slice: make([]float32, 0),
 	}
 	err := mainthread.CallErr(func() error {
@@ -74,9 +113,16 @@ func NewShaderExt(vertexSource, fragmentSource string, attrFmt shaders.VertexFor
 	// Loop through and set all matrices to identity matrices
 	// shader.Bind()
 	setShader(shader)
+	mainthread.Call(func() {
+		for name, unit := range shader.samplers {
+			gl.Uniform1i(shader.uniformLocs[name].loc, unit)
+		}
+	})
+
 	for _, uniform := range uniformFmt {
 		// TODO handle other matrices
-		if uniform.Type == shaders.AttrMat4 {
+		// Note: projection and view were already set to the current camera by setShader
+		if uniform.Type == shaders.AttrMat4 && uniform.Name != "projection" && uniform.Name != "view" {
 			// Setting uniform
 			shader.setUniformMat4(uniform.Name, glMat4Ident)
 		}
@@ -90,10 +136,38 @@ func NewShaderExt(vertexSource, fragmentSource string, attrFmt shaders.VertexFor
 
 	defaultBatchSize := 1024 * 8 // 10000 // TODO: arbitrary. make configurable
 	shader.pool = NewBufferPool(shader, defaultBatchSize)
+	shader.pool.untracked = true
 
+	shader.trackID = trackResource("Shader")
 	return shader, nil
 }
 
+// Deletes the shader's program and its buffers from the GPU. Materials that use the shader can't be drawn after it is destroyed,
+// and destroying it again does nothing.
+// Note: Shaders don't have finalizers, the batcher keeps every shader it has bound, so they must be destroyed explicitly
+func (s *Shader) Destroy() {
+	if s.destroyed {
+		return
+	}
+	global.flush()
+	s.destroyed = true
+	untrackResource(s.trackID)
+
+	s.pool.Destroy()
+	delete(global.shaderCache, s)
+	if global.shader == s {
+		global.shader = nil
+	}
+	if global.material.shader == s {
+		global.material = Material{}
+	}
+
+	program := s.program
+	mainthread.Call(func() {
+		gl.DeleteProgram(program)
+	})
+}
+
 // func (s *Shader) Bind() {
 // 	mainthread.Call(s.mainthreadBind)
 // }
@@ -247,6 +321,9 @@ func (u *uniformSetter) Func() {
 	case glMat4:
 		gl.UniformMatrix4fv(uniform.loc, []float32(val[:]))
 
+	case Vec2:
+		vec := glv2(val)
+		gl.Uniform2fv(uniform.loc, vec[:])
 	case Vec3:
 		vec := glv3(val)
 		gl.Uniform3fv(uniform.loc, vec[:])
@@ -279,6 +356,12 @@ func getBuffer(a shaders.Attr) any {
 		return &[]glVec3{}
 	case shaders.AttrVec4:
 		return &[]glVec4{}
+	case shaders.AttrInt:
+		return &[]int32{}
+	case shaders.AttrUByte4, shaders.AttrUByte4Norm:
+		return &[]glUByte4{}
+	case shaders.AttrUShort2, shaders.AttrUShort2Norm:
+		return &[]glUShort2{}
 	default:
 		panic(fmt.Sprintf("Attr not valid for GetBuffer: %v", a))
 	}
@@ -307,14 +390,15 @@ func (u *uniformSetterMat4) Func() {
 func (shader *Shader) BufferMesh(mesh *Mesh, translucent bool) *VertexBuffer {
 	// bufferState := BufferState{material, BlendModeNormal} // TODO: Blendmode used to come from renderpass
 
-	if len(mesh.indices)%3 != 0 {
+	if mesh.primitive == PrimitiveTriangles && len(mesh.indices)%3 != 0 {
 		panic("Cmd.Mesh indices must have 3 indices per triangle!")
 	}
 	numVerts := len(mesh.positions)
 	buffer := NewVertexBuffer(shader, numVerts, len(mesh.indices))
 	buffer.deallocAfterBuffer = true
 
-	success := buffer.Reserve(mesh.indices, numVerts, shader.tmpBuffers)
+	// The mesh gets its own buffer, so strips and fans can be drawn as they are
+	success := buffer.ReservePrimitive(mesh.primitive, mesh.indices, numVerts, shader.tmpBuffers)
 	if !success {
 		panic("Something went wrong")
 	}
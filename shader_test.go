@@ -0,0 +1,46 @@
+//go:build headless
+// +build headless
+
+package glitch
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/unitoftime/glitch/shaders"
+)
+
+func TestHeadlessShaderKeepsCamera(t *testing.T) {
+	win, camera := newTestWindow(t, 16, 16, WindowConfig{})
+
+	white := NewRGBATexture(4, 4, color.RGBA{255, 255, 255, 255}, false)
+	sprite := NewSprite(white, white.Bounds())
+	mat := Mat4Ident
+	mat.Translate(8, 8, 0)
+	draw := func(material Material) color.RGBA {
+		Clear(win, RGBA{})
+		win.Add(sprite.mesh, glm4(mat), White, material, false)
+		win.Update()
+		return readWindowPixel(win, 4, 8)
+	}
+
+	// A camera that is set while no shader is bound is applied to the next shader that binds
+	global.flush()
+	global.shader = nil
+	camera.SetView2D(4, 0, 1, 1)
+	SetCamera(camera)
+	if got := draw(sprite.material); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("expected the sprite to be moved by the camera, got %v", got)
+	}
+
+	// Creating a shader binds it, so it must get the current camera rather than identity matrices
+	shader, err := NewShader(shaders.SpriteShader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	material := NewMaterial(shader)
+	material.SetTexture(white)
+	if got := draw(material); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("expected the new shader to use the camera, got %v", got)
+	}
+}
@@ -0,0 +1,26 @@
+#version 300 es
+
+// Required for webgl
+#ifdef GL_ES
+precision highp float;
+#endif
+
+out vec4 FragColor;
+
+in vec4 ourColor;
+in vec2 TexCoord;
+
+// The blurred bright pass
+uniform sampler2D texture1;
+
+// The unprocessed scene
+uniform sampler2D u_scene;
+
+uniform float u_intensity;
+
+void main()
+{
+  vec4 scene = texture(u_scene, TexCoord);
+  vec4 bloom = texture(texture1, TexCoord);
+  FragColor = vec4(scene.rgb + bloom.rgb * u_intensity, scene.a);
+}
@@ -0,0 +1,33 @@
+#version 300 es
+
+// Required for webgl
+#ifdef GL_ES
+precision highp float;
+#endif
+
+out vec4 FragColor;
+
+in vec4 ourColor;
+in vec2 TexCoord;
+
+// The image being blurred
+uniform sampler2D texture1;
+
+uniform vec2 u_resolution; // The size of the source in pixels
+uniform vec2 u_direction;  // The blur direction, scaled by the spacing between samples in pixels
+
+// A 9 tap gaussian blur along one axis, done in 5 samples by sampling between texels with linear filtering
+// https://www.rastergrid.com/blog/2010/09/efficient-gaussian-blur-with-linear-sampling/
+void main()
+{
+  vec2 texel = u_direction / u_resolution;
+  vec2 off1 = texel * 1.3846153846;
+  vec2 off2 = texel * 3.2307692308;
+
+  vec4 sum = texture(texture1, TexCoord) * 0.2270270270;
+  sum += texture(texture1, TexCoord + off1) * 0.3162162162;
+  sum += texture(texture1, TexCoord - off1) * 0.3162162162;
+  sum += texture(texture1, TexCoord + off2) * 0.0702702703;
+  sum += texture(texture1, TexCoord - off2) * 0.0702702703;
+  FragColor = sum;
+}
@@ -0,0 +1,24 @@
+#version 300 es
+
+// Required for webgl
+#ifdef GL_ES
+precision highp float;
+#endif
+
+out vec4 FragColor;
+
+in vec4 ourColor;
+in vec2 TexCoord;
+
+uniform sampler2D texture1;
+
+uniform float u_threshold; // The luminance that a pixel must exceed to bloom
+
+// Keeps only the parts of the image that are brighter than the threshold
+void main()
+{
+  vec4 color = texture(texture1, TexCoord);
+  float lum = dot(color.rgb, vec3(0.2126, 0.7152, 0.0722));
+  float amount = max(lum - u_threshold, 0.0) / max(lum, 0.0001);
+  FragColor = color * amount;
+}
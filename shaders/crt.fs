@@ -0,0 +1,41 @@
+#version 300 es
+
+// Required for webgl
+#ifdef GL_ES
+precision highp float;
+#endif
+
+out vec4 FragColor;
+
+in vec4 ourColor;
+in vec2 TexCoord;
+
+uniform sampler2D texture1;
+
+uniform float u_time;
+uniform vec2 u_resolution;
+uniform float u_curvature; // How much the screen bulges outwards, 0 is flat
+uniform float u_scanline;  // How dark the gaps between scanlines are
+
+void main()
+{
+  // Bend the uvs outwards from the center to fake a curved screen
+  vec2 uv = TexCoord * 2.0 - 1.0;
+  uv += uv * (uv.yx * uv.yx) * u_curvature;
+  uv = uv * 0.5 + 0.5;
+  if (uv.x < 0.0 || uv.x > 1.0 || uv.y < 0.0 || uv.y > 1.0) {
+    FragColor = vec4(0.0, 0.0, 0.0, 1.0);
+    return;
+  }
+
+  vec4 color = texture(texture1, uv);
+
+  // One scanline for every two pixels of the source
+  float line = sin(uv.y * u_resolution.y * 3.14159265) * 0.5 + 0.5;
+  color.rgb *= 1.0 - u_scanline * (1.0 - line);
+
+  // A subtle flicker
+  color.rgb *= 0.98 + 0.02 * sin(u_time * 110.0);
+
+  FragColor = color;
+}
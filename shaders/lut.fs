@@ -0,0 +1,41 @@
+#version 300 es
+
+// Required for webgl
+#ifdef GL_ES
+precision highp float;
+#endif
+
+out vec4 FragColor;
+
+in vec4 ourColor;
+in vec2 TexCoord;
+
+uniform sampler2D texture1;
+
+// A color lookup table laid out as a horizontal strip of blue slices (see glitch.IdentityLUT)
+uniform sampler2D u_lut;
+
+uniform float u_lutSize;  // The number of entries per color channel
+uniform float u_strength; // Blends between the ungraded (0) and graded (1) image
+
+vec3 lookup(vec3 c, float slice)
+{
+  float size = u_lutSize;
+  vec2 uv = vec2((c.r * (size - 1.0) + 0.5 + slice * size) / (size * size), (c.g * (size - 1.0) + 0.5) / size);
+  return texture(u_lut, uv).rgb;
+}
+
+void main()
+{
+  vec4 color = texture(texture1, TexCoord);
+
+  // Colors are premultiplied, so they are graded in straight alpha and then multiplied back
+  vec3 c = clamp(color.rgb / max(color.a, 0.0001), 0.0, 1.0);
+
+  float slice = c.b * (u_lutSize - 1.0);
+  float s0 = floor(slice);
+  float s1 = min(s0 + 1.0, u_lutSize - 1.0);
+  vec3 graded = mix(lookup(c, s0), lookup(c, s1), slice - s0);
+
+  FragColor = vec4(mix(c, graded, u_strength) * color.a, color.a);
+}
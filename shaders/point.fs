@@ -0,0 +1,22 @@
+#version 300 es
+
+// Required for webgl
+#ifdef GL_ES
+precision highp float;
+#endif
+
+out vec4 FragColor;
+
+in vec4 ourColor;
+
+uniform sampler2D texture1;
+
+void main()
+{
+  // The whole texture is stretched over each point
+  vec4 tex = texture(texture1, gl_PointCoord);
+  if (tex.a == 0.0) {
+    discard;
+  }
+  FragColor = ourColor * tex;
+}
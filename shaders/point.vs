@@ -0,0 +1,19 @@
+#version 300 es
+
+layout (location = 0) in vec3 positionIn;
+layout (location = 1) in vec4 colorIn;
+
+out vec4 ourColor;
+
+uniform mat4 model;
+uniform mat4 projection;
+uniform mat4 view;
+uniform float pointSize;
+
+void main()
+{
+  gl_Position = projection * view * model * vec4(positionIn, 1.0);
+  gl_PointSize = pointSize;
+
+  ourColor = colorIn;
+}
@@ -9,15 +9,42 @@ type ShaderConfig struct {
 	VertexShader, FragmentShader string
 	VertexFormat                 VertexFormat
 	UniformFormat                UniformFormat
+	Layout                       VertexLayout // How the vertex attributes are laid out in the GPU buffer
+	Indices                      IndexType    // The size of each index in the GPU buffer
 }
 
-// TODO - right now we only support floats (for simplicity)
+// How the vertex attributes are laid out in the GPU buffer
+type VertexLayout uint8
+
+const (
+	LayoutPlanar      VertexLayout = iota // Each attribute is stored in its own region of the buffer
+	LayoutInterleaved                     // All of the attributes of a vertex are stored next to each other
+)
+
+type IndexType uint8
+
+const (
+	Index32 IndexType = iota
+	Index16           // Halves the size of the indices, but limits each buffer to 65536 vertices. Good for WebGL
+)
+
 type VertexFormat []VertexAttr
+
+// Returns the number of bytes that each vertex takes up
+func (f VertexFormat) Stride() int {
+	stride := 0
+	for _, attr := range f {
+		stride += attr.ByteSize()
+	}
+	return stride
+}
+
 type UniformFormat []Attr
 
 type VertexAttr struct {
 	Attr                // The underlying Attribute
 	Swizzle SwizzleType // This defines how the shader wants to map a generic object (like a mesh, to the shader buffers)
+	Divisor int         // If nonzero, this is a per instance attribute which advances once every Divisor instances. See InstanceBatch
 }
 
 type Attr struct {
@@ -56,11 +83,38 @@ func (a Attr) Size() int {
 		return 4 * 2
 	case AttrMat43:
 		return 4 * 3
+	case AttrSampler2D:
+		return 1
+	case AttrUByte4, AttrUByte4Norm:
+		return 4
+	case AttrUShort2, AttrUShort2Norm:
+		return 2
 	default:
 		panic(fmt.Sprintf("Invalid Attribute: %v", a))
 	}
 }
 
+// Returns the number of bytes that the attribute takes up in a vertex buffer
+func (a Attr) ByteSize() int {
+	switch a.Type {
+	case AttrUByte4, AttrUByte4Norm:
+		return 4 * 1
+	case AttrUShort2, AttrUShort2Norm:
+		return 2 * 2
+	default:
+		return 4 * a.Size() // Floats and 32 bit ints
+	}
+}
+
+// Returns true if the shader reads the attribute as integers (int, uvec2, uvec4) rather than floats
+func (a Attr) Integer() bool {
+	switch a.Type {
+	case AttrInt, AttrUByte4, AttrUShort2:
+		return true
+	}
+	return false
+}
+
 // This type is used to define the underlying data type of a vertex attribute or uniform attribute
 type AttrType uint8
 
@@ -80,6 +134,13 @@ const (
 	AttrMat4
 	AttrMat42
 	AttrMat43
+	AttrSampler2D // A texture sampler. These are assigned texture units in the order they are declared, starting at 1
+
+	// Packed vertex attribute types, which take up less space in vertex buffers than floats
+	AttrUByte4      // Four uint8s, read as a uvec4 in the shader. Eg bone indices
+	AttrUByte4Norm  // Four uint8s normalized to (0, 1), read as a vec4 in the shader. Eg colors
+	AttrUShort2     // Two uint16s, read as a uvec2 in the shader
+	AttrUShort2Norm // Two uint16s normalized to (0, 1), read as a vec2 in the shader. Eg texture coordinates that don't repeat
 )
 
 // This type is used to define how generic meshes map into specific shader buffers
@@ -96,6 +157,12 @@ const (
 	ColorRGBA
 	TexCoordXY
 	// TexCoordXYZ // Is this a thing?
+	BoneIndices // The four bone indices of each vertex, see Mesh.SetBones (AttrUByte4)
+
+	// Per instance swizzles, which map the data of each instance added to an InstanceBatch
+	InstanceModel // The model matrix (AttrMat4)
+	InstanceColor // The color mask (AttrVec4)
+	InstanceUV    // The uv rect, packed as offset xy and scale zw (AttrVec4)
 )
 
 func VertexAttribute(name string, Type AttrType, swizzle SwizzleType) VertexAttr {
@@ -108,6 +175,13 @@ func VertexAttribute(name string, Type AttrType, swizzle SwizzleType) VertexAttr
 	}
 }
 
+// Returns a per instance attribute, which advances once per instance rather than once per vertex
+func InstanceAttribute(name string, Type AttrType, swizzle SwizzleType) VertexAttr {
+	attr := VertexAttribute(name, Type, swizzle)
+	attr.Divisor = 1
+	return attr
+}
+
 // Commented out: This was for webgl1 mode
 // //go:embed sprite_100.vs
 // var SpriteVertexShaderWebGL1 string;
@@ -150,6 +224,70 @@ var SpriteShader = ShaderConfig{
 	},
 }
 
+//go:embed sprite_instanced.vs
+var SpriteInstancedVertexShader string
+
+// The sprite shader with packed colors and texture coordinates, interleaved into 20 bytes per vertex instead of 36.
+// Texture coordinates must be between 0 and 1, so it doesn't support repeating textures
+var SpritePackedShader = ShaderConfig{
+	VertexShader:   SpriteVertexShader,
+	FragmentShader: SpriteFragmentShader,
+	VertexFormat: VertexFormat{
+		VertexAttribute("positionIn", AttrVec3, PositionXYZ),
+		VertexAttribute("colorIn", AttrUByte4Norm, ColorRGBA),
+		VertexAttribute("texCoordIn", AttrUShort2Norm, TexCoordXY),
+	},
+	UniformFormat: UniformFormat{
+		Attr{"model", AttrMat4},
+		Attr{"projection", AttrMat4},
+		Attr{"view", AttrMat4},
+	},
+	Layout:  LayoutInterleaved,
+	Indices: Index16,
+}
+
+// A sprite shader for InstanceBatch. The geometry is shared between every instance, and each instance has its own model matrix, color mask and uv rect
+var SpriteInstancedShader = ShaderConfig{
+	VertexShader:   SpriteInstancedVertexShader,
+	FragmentShader: SpriteFragmentShader,
+	VertexFormat: VertexFormat{
+		VertexAttribute("positionIn", AttrVec3, PositionXYZ),
+		VertexAttribute("colorIn", AttrVec4, ColorRGBA),
+		VertexAttribute("texCoordIn", AttrVec2, TexCoordXY),
+		InstanceAttribute("instanceModel", AttrMat4, InstanceModel),
+		InstanceAttribute("instanceColor", AttrVec4, InstanceColor),
+		InstanceAttribute("instanceUV", AttrVec4, InstanceUV),
+	},
+	UniformFormat: UniformFormat{
+		Attr{"model", AttrMat4},
+		Attr{"projection", AttrMat4},
+		Attr{"view", AttrMat4},
+	},
+}
+
+//go:embed point.vs
+var PointVertexShader string
+
+//go:embed point.fs
+var PointFragmentShader string
+
+// A shader for meshes drawn with points, like particle systems. Each point is a square that is pointSize pixels wide
+// and has the whole texture stretched over it
+var PointShader = ShaderConfig{
+	VertexShader:   PointVertexShader,
+	FragmentShader: PointFragmentShader,
+	VertexFormat: VertexFormat{
+		VertexAttribute("positionIn", AttrVec3, PositionXYZ),
+		VertexAttribute("colorIn", AttrVec4, ColorRGBA),
+	},
+	UniformFormat: UniformFormat{
+		Attr{"model", AttrMat4},
+		Attr{"projection", AttrMat4},
+		Attr{"view", AttrMat4},
+		Attr{"pointSize", AttrFloat},
+	},
+}
+
 //go:embed msdf.fs
 var MSDFFragmentShader string
 
@@ -282,3 +420,72 @@ var DiffuseShader = ShaderConfig{
 		Attr{"dirLight.specular", AttrVec3},
 	},
 }
+
+// Post processing shaders. These draw a fullscreen quad with the sprite vertex shader and sample the previous pass through texture1
+func postProcessShader(fragmentShader string, uniforms ...Attr) ShaderConfig {
+	return ShaderConfig{
+		VertexShader:   SpriteVertexShader,
+		FragmentShader: fragmentShader,
+		VertexFormat: VertexFormat{
+			VertexAttribute("positionIn", AttrVec3, PositionXYZ),
+			VertexAttribute("colorIn", AttrVec4, ColorRGBA),
+			VertexAttribute("texCoordIn", AttrVec2, TexCoordXY),
+		},
+		UniformFormat: append(UniformFormat{
+			Attr{"model", AttrMat4},
+			Attr{"projection", AttrMat4},
+			Attr{"view", AttrMat4},
+		}, uniforms...),
+	}
+}
+
+//go:embed blur.fs
+var BlurFragmentShader string
+
+var BlurShader = postProcessShader(BlurFragmentShader,
+	Attr{"u_resolution", AttrVec2},
+	Attr{"u_direction", AttrVec2},
+)
+
+//go:embed bright.fs
+var BrightPassFragmentShader string
+
+var BrightPassShader = postProcessShader(BrightPassFragmentShader,
+	Attr{"u_threshold", AttrFloat},
+)
+
+//go:embed bloom.fs
+var BloomFragmentShader string
+
+var BloomShader = postProcessShader(BloomFragmentShader,
+	Attr{"u_scene", AttrSampler2D},
+	Attr{"u_intensity", AttrFloat},
+)
+
+//go:embed lut.fs
+var ColorGradeFragmentShader string
+
+var ColorGradeShader = postProcessShader(ColorGradeFragmentShader,
+	Attr{"u_lut", AttrSampler2D},
+	Attr{"u_lutSize", AttrFloat},
+	Attr{"u_strength", AttrFloat},
+)
+
+//go:embed vignette.fs
+var VignetteFragmentShader string
+
+var VignetteShader = postProcessShader(VignetteFragmentShader,
+	Attr{"u_radius", AttrFloat},
+	Attr{"u_softness", AttrFloat},
+	Attr{"u_strength", AttrFloat},
+)
+
+//go:embed crt.fs
+var CRTFragmentShader string
+
+var CRTShader = postProcessShader(CRTFragmentShader,
+	Attr{"u_time", AttrFloat},
+	Attr{"u_resolution", AttrVec2},
+	Attr{"u_curvature", AttrFloat},
+	Attr{"u_scanline", AttrFloat},
+)
@@ -0,0 +1,26 @@
+#version 300 es
+
+layout (location = 0) in vec3 positionIn;
+layout (location = 1) in vec4 colorIn;
+layout (location = 2) in vec2 texCoordIn;
+
+// Per instance
+layout (location = 3) in mat4 instanceModel; // Takes up locations 3 to 6
+layout (location = 7) in vec4 instanceColor;
+layout (location = 8) in vec4 instanceUV; // Offset (xy) and scale (zw)
+
+out vec4 ourColor;
+out vec2 TexCoord;
+
+uniform mat4 model;
+uniform mat4 projection;
+uniform mat4 view;
+
+void main()
+{
+  gl_Position = projection * view * model * instanceModel * vec4(positionIn, 1.0);
+
+  ourColor = colorIn * instanceColor;
+
+  TexCoord = instanceUV.xy + texCoordIn * instanceUV.zw;
+}
@@ -0,0 +1,25 @@
+#version 300 es
+
+// Required for webgl
+#ifdef GL_ES
+precision highp float;
+#endif
+
+out vec4 FragColor;
+
+in vec4 ourColor;
+in vec2 TexCoord;
+
+uniform sampler2D texture1;
+
+uniform float u_radius;   // The distance from the center where darkening starts, where 1 is the corner
+uniform float u_softness; // The distance over which the darkening fades in
+uniform float u_strength; // How dark the edges get
+
+void main()
+{
+  vec4 color = texture(texture1, TexCoord);
+  float dist = length(TexCoord - vec2(0.5)) * 1.41421356;
+  float vignette = smoothstep(u_radius, u_radius - u_softness, dist);
+  FragColor = vec4(color.rgb * mix(1.0, vignette, u_strength), color.a);
+}
@@ -12,8 +12,10 @@ type Sorter struct {
 	DepthTest    bool
 	SoftwareSort SoftwareSortMode
 	DepthBump    bool
+	Cull         bool // If set, things that are added outside of the current camera are skipped. Set the camera before adding
 	depthBump    float32
 	currentLayer int8
+	scissor      scissor
 
 	// States that are used for forming the draw command
 	// blendMode BlendMode
@@ -23,6 +25,10 @@ type Sorter struct {
 	// camera *CameraOrtho
 
 	commands []cmdList
+
+	masks      []*maskGroup // The stack of masks pushed with PushMask
+	groupPool  []*maskGroup
+	groupCount int
 }
 
 func NewSorter() *Sorter {
@@ -39,8 +45,31 @@ func (s *Sorter) Layer() int8 {
 	return s.currentLayer
 }
 
+// Clips everything added after this call to a rectangle, in the same space as the draw matrices.
+// The scissor is stored in each draw's material, so it is kept when this is drawn into a Batch or CommandRecorder.
+// See glitch.SetScissor for how the rectangle is transformed
+func (s *Sorter) SetScissor(rect Rect) {
+	s.scissor = scissor{rect, true}
+}
+
+// Stops clipping things that are added after this call
+func (s *Sorter) DisableScissor() {
+	s.scissor = scissor{}
+}
+
+// Returns the current scissor rectangle and whether it is enabled
+func (s *Sorter) Scissor() (Rect, bool) {
+	return s.scissor.rect, s.scissor.enabled
+}
+
 func (s *Sorter) Clear() {
 	s.depthBump = 0
+	s.scissor = scissor{}
+	s.masks = s.masks[:0]
+	for i := 0; i < s.groupCount; i++ {
+		s.groupPool[i].sorter.Clear()
+	}
+	s.groupCount = 0
 
 	// Clear stuff
 	for l := range s.commands {
@@ -95,14 +124,91 @@ func (s *Sorter) Draw(target BatchTarget) {
 }
 
 func (s *Sorter) applyDrawCommand(target BatchTarget, c drawCommand) {
+	if c.group != nil {
+		c.group.Draw(target)
+		return
+	}
 	target.Add(c.filler, c.matrix, c.mask, c.material, true)
 }
 
+// Clips everything added after this call to the shape of the mask drawable, until the matching PopMask.
+// Masks nest, in which case content is clipped to the intersection of every pushed mask.
+// The target that the sorter is drawn to must have a stencil buffer (see WindowConfig.Stencil and NewFrameExt)
+// Note: The mask is drawn with whatever shader its material uses. Fragments that the shader discards are excluded from the mask
+func (s *Sorter) PushMask(mask Drawer, matrix Mat4) {
+	if len(s.masks) >= 255 {
+		panic("Sorter: Too many nested masks, the stencil buffer only holds 255 levels")
+	}
+
+	group := s.nextMaskGroup()
+	group.depth = uint8(len(s.masks) + 1)
+	mask.Draw(&group.mask, matrix)
+
+	// The group is sorted into its parent as a single command, positioned at the mask
+	parent := s
+	if len(s.masks) > 0 {
+		parent = s.masks[len(s.masks)-1].sorter
+		parent.currentLayer = s.currentLayer
+	}
+	parent.addMaskGroup(group, glm4(matrix))
+
+	s.masks = append(s.masks, group)
+}
+
+// Stops clipping to the most recently pushed mask
+func (s *Sorter) PopMask() {
+	if len(s.masks) == 0 {
+		panic("Sorter: PopMask called without a matching PushMask")
+	}
+	s.masks = s.masks[:len(s.masks)-1]
+}
+
+func (s *Sorter) nextMaskGroup() *maskGroup {
+	if s.groupCount >= len(s.groupPool) {
+		s.groupPool = append(s.groupPool, &maskGroup{
+			sorter: NewSorter(),
+		})
+	}
+	group := s.groupPool[s.groupCount]
+	s.groupCount++
+
+	group.mask.Clear()
+	group.sorter.DepthTest = s.DepthTest
+	group.sorter.SoftwareSort = s.SoftwareSort
+	group.sorter.DepthBump = s.DepthBump
+	return group
+}
+
+func (s *Sorter) addMaskGroup(group *maskGroup, mat glMat4) {
+	mat[i4_3_2] -= float32(s.currentLayer)
+	s.commands[s.currentLayer].Add(true, drawCommand{
+		matrix: mat,
+		group:  group,
+	})
+}
+
 func (s *Sorter) Add(filler GeometryFiller, mat glMat4, mask RGBA, material Material, translucent bool) {
 	if mask.A == 0 {
 		return
 	} // discard b/c its completely transparent
 
+	if s.Cull && cullDraw(filler, mat) {
+		return
+	}
+
+	// The scissor is stored in the material, so that it is kept by targets that store the draw for later
+	material.scissor = s.scissor.intersect(material.scissor)
+
+	if len(s.masks) > 0 {
+		group := s.masks[len(s.masks)-1]
+		if material.stencil == (StencilMode{}) {
+			material.stencil = StencilModeTest(group.depth)
+		}
+		group.sorter.currentLayer = s.currentLayer
+		group.sorter.Add(filler, mat, mask, material, translucent)
+		return
+	}
+
 	if mask.A != 1 {
 		translucent = true
 	}
@@ -143,7 +249,10 @@ func (s *Sorter) Add(filler GeometryFiller, mat glMat4, mask RGBA, material Mate
 	// }
 
 	s.commands[s.currentLayer].Add(translucent, drawCommand{
-		filler, mat, mask, material,
+		filler:   filler,
+		matrix:   mat,
+		mask:     mask,
+		material: material,
 	})
 }
 
@@ -215,6 +324,47 @@ type drawCommand struct {
 	matrix   glMat4
 	mask     RGBA
 	material Material
+	group    *maskGroup // If set, this command draws a masked group instead of the filler
+}
+
+// A mask and everything that was added while it was pushed.
+// Masks are drawn by incrementing the stencil buffer where the parent mask passes, so that nested
+// masks intersect. After the contents are drawn the mask is drawn again to decrement it back, which
+// leaves the stencil buffer clean for sibling masks without needing a clear
+type maskGroup struct {
+	depth  uint8
+	mask   CommandRecorder
+	sorter *Sorter
+}
+
+func (g *maskGroup) Draw(target BatchTarget) {
+	g.drawMask(target, StencilMode{
+		Func:      StencilFuncEqual,
+		Ref:       g.depth - 1,
+		ReadMask:  0xFF,
+		WriteMask: 0xFF,
+		Pass:      StencilOpIncr,
+		HideColor: true,
+	})
+
+	g.sorter.Draw(target)
+
+	g.drawMask(target, StencilMode{
+		Func:      StencilFuncEqual,
+		Ref:       g.depth,
+		ReadMask:  0xFF,
+		WriteMask: 0xFF,
+		Pass:      StencilOpDecr,
+		HideColor: true,
+	})
+}
+
+func (g *maskGroup) drawMask(target BatchTarget, stencil StencilMode) {
+	for _, c := range g.mask.Commands() {
+		material := c.Material
+		material.stencil = stencil
+		target.Add(c.Filler, glm4(c.Matrix), c.Mask, material, c.Translucent)
+	}
 }
 
 func SortDrawCommands(buf []drawCommand, sortMode SoftwareSortMode) {
@@ -0,0 +1,169 @@
+//go:build headless
+// +build headless
+
+package glitch
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/unitoftime/flow/glm"
+)
+
+func TestHeadlessStencilMask(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{Stencil: true})
+
+	bounds := glm.R(0, 0, 16, 16)
+	frame := NewFrameExt(bounds, false, true)
+
+	full := NewQuadMesh(bounds, glm.R(0, 0, 1, 1))
+	left := NewQuadMesh(glm.R(0, 0, 8, 16), glm.R(0, 0, 1, 1))
+	bottom := NewQuadMesh(glm.R(0, 0, 16, 8), glm.R(0, 0, 1, 1))
+	corner := NewQuadMesh(glm.R(12, 12, 16, 16), glm.R(0, 0, 1, 1))
+
+	red := RGBA{1, 0, 0, 1}
+	green := RGBA{0, 1, 0, 1}
+	blue := RGBA{0, 0, 1, 1}
+
+	sorter := NewSorter()
+	sorter.PushMask(left, Mat4Ident)
+	full.DrawColorMask(sorter, Mat4Ident, red)
+	sorter.PushMask(bottom, Mat4Ident)
+	full.DrawColorMask(sorter, Mat4Ident, green)
+	sorter.PopMask()
+	sorter.PopMask()
+
+	// A sibling mask only works if the previous masks cleaned up after themselves
+	sorter.PushMask(corner, Mat4Ident)
+	full.DrawColorMask(sorter, Mat4Ident, blue)
+	sorter.PopMask()
+
+	Clear(frame, RGBA{})
+	sorter.Draw(frame)
+	img := frame.Image()
+
+	// Image rows are top to bottom
+	tests := []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{2, 2, color.RGBA{255, 0, 0, 255}},
+		{2, 13, color.RGBA{0, 255, 0, 255}},
+		{12, 13, color.RGBA{}},
+		{14, 1, color.RGBA{0, 0, 255, 255}},
+		{10, 1, color.RGBA{}},
+	}
+	for _, test := range tests {
+		got := img.RGBAAt(test.x, test.y)
+		if got != test.want {
+			t.Errorf("pixel (%d, %d): expected %v, got %v", test.x, test.y, test.want, got)
+		}
+	}
+	win.Update()
+}
+
+func TestHeadlessScissor(t *testing.T) {
+	win, camera := newTestWindow(t, 16, 16, WindowConfig{})
+
+	bounds := glm.R(0, 0, 16, 16)
+	frame := NewFrame(bounds, false)
+
+	// Zoom in around the center, so the scissor must be transformed by the camera to land on the right pixels
+	camera.SetView2D(0, 0, 2, 2)
+	SetCamera(camera)
+
+	full := NewQuadMesh(bounds, glm.R(0, 0, 1, 1))
+	small := NewQuadMesh(glm.R(10, 10, 12, 12), glm.R(0, 0, 1, 1))
+
+	sorter := NewSorter()
+	sorter.SetScissor(glm.R(4, 4, 8, 8))
+	full.DrawColorMask(sorter, Mat4Ident, RGBA{1, 0, 0, 1})
+	sorter.DisableScissor()
+	small.DrawColorMask(sorter, Mat4Ident, RGBA{0, 0, 1, 1})
+
+	Clear(frame, RGBA{})
+	sorter.Draw(frame)
+	img := frame.Image()
+
+	// Image rows are top to bottom
+	tests := []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{2, 13, color.RGBA{255, 0, 0, 255}},
+		{7, 8, color.RGBA{255, 0, 0, 255}},
+		{8, 7, color.RGBA{}},
+		{10, 5, color.RGBA{}},
+		{13, 1, color.RGBA{0, 0, 255, 255}},
+	}
+	for _, test := range tests {
+		got := img.RGBAAt(test.x, test.y)
+		if got != test.want {
+			t.Errorf("pixel (%d, %d): expected %v, got %v", test.x, test.y, test.want, got)
+		}
+	}
+	win.Update()
+}
+
+// The scissor is stored with each draw, so it must survive targets that draw later
+func TestHeadlessScissorStoredTargets(t *testing.T) {
+	win, camera := newTestWindow(t, 16, 16, WindowConfig{})
+
+	full := NewQuadMesh(glm.R(0, 0, 16, 16), glm.R(0, 0, 1, 1))
+	scissored := func(target BatchTarget) {
+		sorter := NewSorter()
+		sorter.SetScissor(glm.R(4, 4, 8, 8))
+		full.DrawColorMask(sorter, Mat4Ident, RGBA{1, 0, 0, 1})
+		sorter.Draw(target)
+	}
+
+	tests := []struct {
+		name   string
+		bounds Rect
+		draw   func(frame *Frame)
+	}{
+		{"recorder", glm.R(0, 0, 16, 16), func(frame *Frame) {
+			rec := NewCommandRecorder()
+			scissored(rec)
+			rec.Replay(frame)
+		}},
+		{"batch", glm.R(0, 0, 16, 16), func(frame *Frame) {
+			batch := NewBatch()
+			scissored(batch)
+			batch.Draw(frame, Mat4Ident)
+		}},
+		{"queue", glm.R(0, 0, 16, 16), func(frame *Frame) {
+			queue := NewRenderQueue()
+			queue.SetScissor(glm.R(4, 4, 8, 8))
+			full.DrawColorMask(queue, Mat4Ident, RGBA{1, 0, 0, 1})
+			queue.Draw(frame)
+		}},
+		// The scissor is placed relative to the framebuffer's pixels, not the frame's bounds
+		{"offset frame", glm.R(16, 16, 32, 32), func(frame *Frame) {
+			scissored(frame)
+		}},
+	}
+	for _, test := range tests {
+		frame := NewFrame(test.bounds, false)
+		camera.SetOrtho2D(test.bounds)
+		camera.SetView2D(0, 0, 1, 1)
+		SetCamera(camera)
+
+		Clear(frame, RGBA{})
+		test.draw(frame)
+		img := frame.Image()
+
+		// Image rows are top to bottom
+		if got := img.RGBAAt(5, 10); got != (color.RGBA{255, 0, 0, 255}) {
+			t.Errorf("%s: expected red inside the scissor, got %v", test.name, got)
+		}
+		if got := img.RGBAAt(2, 13); got != (color.RGBA{}) {
+			t.Errorf("%s: expected nothing outside the scissor, got %v", test.name, got)
+		}
+		frame.Destroy()
+	}
+
+	camera.SetOrtho2D(win.Bounds())
+	SetCamera(camera)
+	win.Update()
+}
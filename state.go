@@ -4,6 +4,7 @@ package glitch
 // TODO - this might lock us up into a single window? That doesn't seem like too bad of a requirement though
 
 import (
+	"github.com/unitoftime/flow/glm"
 	"github.com/unitoftime/glitch/internal/gl"
 	"github.com/unitoftime/glitch/internal/mainthread"
 )
@@ -12,6 +13,7 @@ type stateTracker struct {
 	// FBO
 	fbo       gl.Framebuffer
 	fboBounds Rect
+	fboStale  bool // Set when the bound framebuffer was deleted, because its name can be reused by the next one
 	fboBinder func()
 
 	// Depth Test
@@ -22,8 +24,10 @@ type stateTracker struct {
 	depthMode       DepthMode
 	depthModeBinder func()
 
-	// Texture
-	texture       *Texture
+	// Textures
+	activeUnit    int
+	textureUnit   int // The unit that textureBinder binds to
+	textures      [MaxTextureUnits]*Texture
 	textureBinder func()
 
 	// BlendFunc
@@ -36,6 +40,14 @@ type stateTracker struct {
 	cullMode       CullMode
 	cullModeBinder func()
 
+	// Stencil Mode
+	stencilMode       StencilMode
+	stencilModeBinder func()
+
+	// Scissor
+	scissor       scissorBox
+	scissorBinder func()
+
 	// Vert Buffer
 	vertBuf       *VertexBuffer
 	vertBufDrawer func()
@@ -62,7 +74,11 @@ func init() {
 
 	state.blendModeBinder = func() {
 		data := blendModeLut[state.blendMode]
-		gl.BlendFunc(data.src, data.dst)
+		gl.BlendFuncSeparate(
+			blendFactorLut[data.SrcRGB], blendFactorLut[data.DstRGB],
+			blendFactorLut[data.SrcAlpha], blendFactorLut[data.DstAlpha],
+		)
+		gl.BlendEquationSeparate(blendEquationLut[data.EquationRGB], blendEquationLut[data.EquationAlpha])
 	}
 
 	state.cullModeBinder = func() {
@@ -76,6 +92,29 @@ func init() {
 		}
 	}
 
+	state.stencilModeBinder = func() {
+		mode := state.stencilMode
+		if mode.Func == StencilFuncDisabled {
+			gl.Disable(gl.STENCIL_TEST)
+		} else {
+			gl.Enable(gl.STENCIL_TEST)
+			gl.StencilFunc(stencilFuncLut[mode.Func], int(mode.Ref), uint32(mode.ReadMask))
+			gl.StencilOp(stencilOpLut[mode.Fail], stencilOpLut[mode.DepthFail], stencilOpLut[mode.Pass])
+		}
+		gl.StencilMask(uint32(mode.WriteMask))
+		gl.ColorMask(!mode.HideColor, !mode.HideColor, !mode.HideColor, !mode.HideColor)
+	}
+
+	state.scissorBinder = func() {
+		box := state.scissor
+		if box.enabled {
+			gl.Enable(gl.SCISSOR_TEST)
+			gl.Scissor(box.x, box.y, box.w, box.h)
+		} else {
+			gl.Disable(gl.SCISSOR_TEST)
+		}
+	}
+
 	// state.enableDepthFunc = func() {
 	// 	if state.depthTest {
 	// 		gl.Enable(gl.DEPTH_TEST)
@@ -90,20 +129,23 @@ func init() {
 
 	state.fboBinder = func() {
 		// TODO - Note: I set the viewport when I bind the framebuffer. Is this okay?
-		gl.Viewport(0, 0, int(state.fboBounds.W()), int(state.fboBounds.H()))
+		viewport := state.viewport()
+		gl.Viewport(int(viewport.Min.X), int(viewport.Min.Y), int(viewport.W()), int(viewport.H()))
 		gl.BindFramebuffer(gl.FRAMEBUFFER, state.fbo)
 	}
 
 	state.textureBinder = func() {
-		gl.ActiveTexture(gl.TEXTURE0)
-		// gl.ActiveTexture(gl.TEXTURE0 + position); // TODO - include position
+		if state.activeUnit != state.textureUnit {
+			state.activeUnit = state.textureUnit
+			gl.ActiveTexture(gl.TEXTURE0 + gl.Enum(state.textureUnit))
+		}
 
-		if state.texture == nil {
+		texture := state.textures[state.textureUnit]
+		if texture == nil {
 			gl.BindTexture(gl.TEXTURE_2D, gl.NoTexture)
 		} else {
-			gl.BindTexture(gl.TEXTURE_2D, state.texture.texture)
+			gl.BindTexture(gl.TEXTURE_2D, texture.texture)
 		}
-		// gl.BindTexture(gl.TEXTURE_2D, state.texture.texture)
 	}
 
 	// state.blendFuncBinder = func() {
@@ -126,28 +168,84 @@ func init() {
 
 	state.clearFunc = func() {
 		gl.ClearColor(float32(state.clearColor.R), float32(state.clearColor.G), float32(state.clearColor.B), float32(state.clearColor.A))
+
+		// Note: Clears respect the write masks, so they are opened up and then restored to the stencil mode
+		gl.StencilMask(0xFF)
+		gl.ColorMask(true, true, true, true)
+		if state.scissor.enabled {
+			gl.Disable(gl.SCISSOR_TEST)
+		}
 		// gl.Clear(gl.COLOR_BUFFER_BIT) // Make configurable?
-		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT | gl.STENCIL_BUFFER_BIT)
+		hide := state.stencilMode.HideColor
+		gl.StencilMask(uint32(state.stencilMode.WriteMask))
+		gl.ColorMask(!hide, !hide, !hide, !hide)
+		if state.scissor.enabled {
+			gl.Enable(gl.SCISSOR_TEST)
+		}
 	}
 }
 
-// TODO - maybe allow for more than 15 if the platform supports it? TODO - max texture units
-func (s *stateTracker) bindTexture(texture *Texture) {
-	// TODO: Check if changed
-	s.texture = texture
-	mainthread.Call(state.textureBinder)
+// The number of texture units a material can use. OpenGL ES 3 guarantees at least 16 in the fragment shader
+const MaxTextureUnits = 8
+
+// Marks a binding that the tracker doesn't know, so that the next bind to that unit always goes through
+var unknownTexture = &Texture{}
+
+func (s *stateTracker) bindTexture(unit int, texture *Texture) {
+	if s.textures[unit] == texture {
+		return // Skip: State already matches
+	}
+	s.textures[unit] = texture
+	if texture == unknownTexture {
+		return
+	}
+
+	s.textureUnit = unit
+	mainthread.Call(s.textureBinder)
+}
+
+// Binds a texture to unit 0 and makes that unit active, so that direct gl calls on TEXTURE_2D write to the texture.
+// Unlike bindTexture this always binds, because a material can leave another unit active after binding its samplers
+func (s *stateTracker) bindTextureForWrite(texture *Texture) {
+	s.textures[0] = texture
+	s.textureUnit = 0
+	mainthread.Call(s.textureBinder)
+}
+
+// Must be called after a texture is bound with gl.BindTexture directly, because that replaces the binding on the active unit.
+// Pending draws must be flushed before the direct bind, because they sample whatever is bound when they are drawn
+func (s *stateTracker) invalidateTexture() {
+	s.textures[s.activeUnit] = unknownTexture
+
+	// The batcher skips binding a material that matches the last one, so force it to bind its textures again
+	global.material = Material{}
 }
 
 func (s *stateTracker) bindFramebuffer(fbo gl.Framebuffer, bounds Rect) {
-	if s.fbo.Equal(fbo) && s.fboBounds == bounds {
+	if s.fbo.Equal(fbo) && s.fboBounds == bounds && !s.fboStale {
 		return
 	}
+	state.fboStale = false
 	state.fbo = fbo
 	state.fboBounds = bounds
 
 	mainthread.Call(s.fboBinder)
 }
 
+// Returns the pixels of the bound framebuffer that draws are mapped to. Framebuffers are only as big as their bounds,
+// so the viewport starts at the framebuffer's origin even if the bounds don't start at zero
+func (s *stateTracker) viewport() Rect {
+	return glm.R(0, 0, s.fboBounds.W(), s.fboBounds.H())
+}
+
+// Must be called when a framebuffer is deleted. Deleting the bound framebuffer rebinds the default one
+func (s *stateTracker) invalidateFramebuffer(fbo gl.Framebuffer) {
+	if s.fbo.Equal(fbo) {
+		s.fboStale = true
+	}
+}
+
 func (s *stateTracker) setDepthMode(depth DepthMode) {
 	if s.depthMode == depth {
 		return // Skip: State already matches
@@ -166,6 +264,30 @@ func (s *stateTracker) setBlendMode(blend BlendMode) {
 	mainthread.Call(s.blendModeBinder)
 }
 
+func (s *stateTracker) setStencilMode(stencil StencilMode) {
+	if s.stencilMode == stencil {
+		return // Skip: State already matches
+	}
+	s.stencilMode = stencil
+
+	mainthread.Call(s.stencilModeBinder)
+}
+
+// A scissor rectangle in pixels of the bound framebuffer
+type scissorBox struct {
+	enabled    bool
+	x, y, w, h int32
+}
+
+func (s *stateTracker) setScissor(box scissorBox) {
+	if s.scissor == box {
+		return // Skip: State already matches
+	}
+	s.scissor = box
+
+	mainthread.Call(s.scissorBinder)
+}
+
 func (s *stateTracker) setCullMode(cull CullMode) {
 	if s.cullMode == cull {
 		return // Skip: State already matches
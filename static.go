@@ -0,0 +1,321 @@
+package glitch
+
+import (
+	"fmt"
+	"math"
+)
+
+const staticBatchSize = 1024 * 8 // The number of triangles that each of a chunk's buffers are created with
+
+// Identifies something that was added to a StaticBatch
+type StaticID int
+
+// A StaticBatch keeps geometry that rarely changes, like tilemaps and level geometry, in GPU buffers that persist across frames.
+// Everything added is merged into chunks by material and by spatial region, so each chunk is a few draw calls,
+// and chunks that are outside of the camera aren't drawn at all. Changing one item only uploads that item's part of its chunk.
+//
+//	static := glitch.NewStaticBatch(512)
+//	for _, tile := range tiles {
+//		tile.sprite.Draw(static, tile.Matrix())
+//		tile.id = static.LastID()
+//	}
+//
+//	// When a tile changes
+//	newSprite.Draw(static.Replace(tile.id), tile.Matrix())
+//
+//	// Every frame
+//	static.Draw(win, camera)
+//
+// Like Batch, only Meshes (and things that draw meshes, like Sprites) can be added, anything else panics. Meshes are referenced
+// rather than copied, so the geometry is rebuilt from them if a chunk ever needs to be compacted.
+type StaticBatch struct {
+	chunkSize float64
+	chunks    map[staticChunkKey]*staticChunk
+	order     []*staticChunk // Chunks in the order they were created, so that draws are deterministic
+	refs      []staticRef    // Indexed by StaticID
+}
+
+type staticRef struct {
+	chunk *staticChunk // Nil if the item was removed
+	slot  int
+}
+
+type staticChunkKey struct {
+	material Material
+	x, y     int
+}
+
+type staticChunk struct {
+	key         staticChunkKey
+	pool        *BufferPool
+	items       []staticItem
+	free        []int // Item slots that can be reused
+	translucent bool
+	bounds      Box
+	boundsSet   bool
+	live        int  // The number of vertices in use
+	wasted      int  // The number of vertices in the buffers that belong to removed items
+	rebuild     bool // If set, the buffers are rebuilt from the items on the next draw
+	fillers     []bufferFiller
+}
+
+type staticItem struct {
+	mesh   *Mesh // Nil if the slot is free
+	matrix glMat4
+	mask   RGBA
+
+	// Where the item is in the chunk's buffers
+	buffer     *VertexBuffer
+	vertStart  int
+	indexStart int
+}
+
+// Creates a StaticBatch whose chunks each cover a chunkSize by chunkSize region
+func NewStaticBatch(chunkSize float64) *StaticBatch {
+	return &StaticBatch{
+		chunkSize: chunkSize,
+		chunks:    make(map[staticChunkKey]*staticChunk),
+	}
+}
+
+// Adds geometry to the batch. Use LastID to get the id that it was assigned
+func (b *StaticBatch) Add(filler GeometryFiller, matrix glMat4, mask RGBA, material Material, translucent bool) {
+	mesh := staticMesh(filler)
+
+	id := StaticID(len(b.refs))
+	b.refs = append(b.refs, staticRef{})
+	b.insert(id, mesh, matrix, mask, material, translucent)
+}
+
+// Returns the id of the last thing that was added
+func (b *StaticBatch) LastID() StaticID {
+	return StaticID(len(b.refs) - 1)
+}
+
+// Returns a BatchTarget that replaces the item with the geometry that is drawn to it. If the item stays in the same chunk and
+// has the same number of vertices and indices, then it is rewritten in place and only its range of the buffer is uploaded
+func (b *StaticBatch) Replace(id StaticID) BatchTarget {
+	return staticReplacer{b, id}
+}
+
+type staticReplacer struct {
+	batch *StaticBatch
+	id    StaticID
+}
+
+func (r staticReplacer) Add(filler GeometryFiller, matrix glMat4, mask RGBA, material Material, translucent bool) {
+	r.batch.replace(r.id, staticMesh(filler), matrix, mask, material, translucent)
+}
+
+// Chunks are filled, and compacted, from the cpu side geometry of meshes, so nothing else can be added
+func staticMesh(filler GeometryFiller) *Mesh {
+	mesh, ok := filler.(*Mesh)
+	if !ok {
+		panic(fmt.Sprintf("StaticBatch: cannot add geometry of type %T, only meshes can be added", filler))
+	}
+	if mesh.buffer != nil && len(mesh.positions) == 0 {
+		panic("StaticBatch: cannot add a mesh that only exists in a gpu buffer")
+	}
+	return mesh
+}
+
+// Removes an item from the batch. Its space in the buffer is reclaimed once enough of the chunk has been removed
+func (b *StaticBatch) Remove(id StaticID) {
+	ref := b.refs[id]
+	if ref.chunk == nil {
+		return
+	}
+	ref.chunk.remove(ref.slot)
+	b.refs[id] = staticRef{}
+}
+
+// Removes everything from the batch and deletes the chunks' buffers from the GPU
+func (b *StaticBatch) Clear() {
+	for _, chunk := range b.order {
+		chunk.pool.Destroy()
+	}
+	clear(b.chunks)
+	clear(b.order)
+	b.order = b.order[:0]
+	b.refs = b.refs[:0]
+}
+
+// Deletes the batch's buffers from the GPU. The batch is left empty and can still be used afterwards
+func (b *StaticBatch) Destroy() {
+	b.Clear()
+}
+
+// Draws every chunk that overlaps the camera. If camera is nil, then every chunk is drawn
+func (b *StaticBatch) Draw(target BatchTarget, camera *CameraOrtho) {
+	var view Rect
+	if camera != nil {
+		view = camera.ViewRect()
+	}
+
+	for _, chunk := range b.order {
+		if chunk.rebuild {
+			chunk.rebuildBuffers()
+		}
+		if chunk.live == 0 {
+			continue
+		}
+		if camera != nil && !chunk.bounds.Rect().Intersects(view) {
+			global.metric.Culled += chunk.pool.nextClean
+			continue
+		}
+
+		buffers := chunk.pool.buffers[:chunk.pool.nextClean]
+		chunk.fillers = chunk.fillers[:0]
+		for _, buffer := range buffers {
+			chunk.fillers = append(chunk.fillers, bufferFiller{buffer, chunk.bounds})
+		}
+		for i := range chunk.fillers {
+			target.Add(&chunk.fillers[i], glMat4Ident, White, chunk.key.material, chunk.translucent)
+		}
+	}
+}
+
+// Items are placed in the chunk that contains their center
+func (b *StaticBatch) chunkKey(material Material, bounds Box) staticChunkKey {
+	return staticChunkKey{
+		material: material,
+		x:        int(math.Floor((bounds.Min.X + bounds.Max.X) / 2 / b.chunkSize)),
+		y:        int(math.Floor((bounds.Min.Y + bounds.Max.Y) / 2 / b.chunkSize)),
+	}
+}
+
+func (b *StaticBatch) insert(id StaticID, mesh *Mesh, matrix glMat4, mask RGBA, material Material, translucent bool) {
+	bounds := transformBox(mesh.Bounds(), matrix.Mat4())
+	key := b.chunkKey(material, bounds)
+
+	chunk, ok := b.chunks[key]
+	if !ok {
+		chunk = &staticChunk{
+			key:  key,
+			pool: newBufferPool(material.shader, staticBatchSize, 1),
+		}
+		b.chunks[key] = chunk
+		b.order = append(b.order, chunk)
+	}
+
+	var slot int
+	if len(chunk.free) > 0 {
+		slot = chunk.free[len(chunk.free)-1]
+		chunk.free = chunk.free[:len(chunk.free)-1]
+	} else {
+		slot = len(chunk.items)
+		chunk.items = append(chunk.items, staticItem{})
+	}
+	chunk.items[slot] = staticItem{
+		mesh:   mesh,
+		matrix: matrix,
+		mask:   mask,
+	}
+	chunk.live += len(mesh.positions)
+	chunk.translucent = chunk.translucent || translucent || mask.A != 1
+	chunk.addBounds(bounds)
+
+	if !chunk.rebuild {
+		chunk.fill(slot)
+	}
+
+	b.refs[id] = staticRef{chunk, slot}
+}
+
+func (b *StaticBatch) replace(id StaticID, mesh *Mesh, matrix glMat4, mask RGBA, material Material, translucent bool) {
+	ref := b.refs[id]
+	if ref.chunk == nil {
+		b.insert(id, mesh, matrix, mask, material, translucent)
+		return
+	}
+
+	chunk := ref.chunk
+	item := &chunk.items[ref.slot]
+	bounds := transformBox(mesh.Bounds(), matrix.Mat4())
+	sameChunk := chunk.key == b.chunkKey(material, bounds)
+	oldMode, oldIndices := item.mesh.listIndices()
+	mode, indices := mesh.listIndices()
+	sameSize := len(item.mesh.positions) == len(mesh.positions) && len(oldIndices) == len(indices) && oldMode == mode
+
+	if !sameChunk || !sameSize || chunk.rebuild {
+		chunk.remove(ref.slot)
+		b.insert(id, mesh, matrix, mask, material, translucent)
+		return
+	}
+
+	item.mesh = mesh
+	item.matrix = matrix
+	item.mask = mask
+	chunk.translucent = chunk.translucent || translucent || mask.A != 1
+	chunk.addBounds(bounds)
+
+	shader := chunk.key.material.shader
+	item.buffer.rewrite(item.vertStart, len(mesh.positions), item.indexStart, indices, shader.tmpBuffers)
+	batchToBuffers(shader, mesh, matrix, mask)
+}
+
+// Appends an item's geometry to the chunk's buffers
+func (c *staticChunk) fill(slot int) {
+	item := &c.items[slot]
+	shader := c.key.material.shader
+	numVerts := len(item.mesh.positions)
+
+	mode, indices := item.mesh.listIndices()
+
+	item.buffer = c.pool.ReservePrimitive(mode, indices, numVerts, shader.tmpBuffers)
+	batchToBuffers(shader, item.mesh, item.matrix, item.mask)
+
+	item.vertStart = int(item.buffer.numVerts) - numVerts
+	item.indexStart = len(item.buffer.indices) - len(indices)
+}
+
+func (c *staticChunk) remove(slot int) {
+	item := &c.items[slot]
+	numVerts := len(item.mesh.positions)
+
+	mode, indices := item.mesh.listIndices()
+	if mode == PrimitivePoints {
+		// Points can't be collapsed, every index is still drawn. So the buffers are rebuilt instead
+		c.rebuild = true
+	} else if !c.rebuild {
+		// Collapse the item's primitives so that it stops drawing without touching the rest of the buffer
+		degenerate := make([]uint32, len(indices))
+		item.buffer.rewrite(item.vertStart, 0, item.indexStart, degenerate, c.key.material.shader.tmpBuffers)
+	}
+
+	c.live -= numVerts
+	c.wasted += numVerts
+	c.items[slot] = staticItem{}
+	c.free = append(c.free, slot)
+
+	// Compact once more of the buffer is wasted than used
+	if c.wasted > c.live {
+		c.rebuild = true
+	}
+}
+
+func (c *staticChunk) addBounds(bounds Box) {
+	if c.boundsSet {
+		c.bounds = c.bounds.Union(bounds)
+	} else {
+		c.boundsSet = true
+		c.bounds = bounds
+	}
+}
+
+// Refills the buffers with only the live items, which reclaims the space of removed ones and shrinks the bounds
+func (c *staticChunk) rebuildBuffers() {
+	c.pool.Clear()
+	c.rebuild = false
+	c.wasted = 0
+	c.boundsSet = false
+	c.bounds = Box{}
+	for slot := range c.items {
+		item := &c.items[slot]
+		if item.mesh == nil {
+			continue
+		}
+		c.addBounds(transformBox(item.mesh.Bounds(), item.matrix.Mat4()))
+		c.fill(slot)
+	}
+}
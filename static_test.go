@@ -0,0 +1,160 @@
+//go:build headless
+// +build headless
+
+package glitch
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestHeadlessStaticBatch(t *testing.T) {
+	win, camera := newTestWindow(t, 16, 16, WindowConfig{})
+
+	white := NewRGBATexture(4, 4, color.RGBA{255, 255, 255, 255}, false)
+	sprite := NewSprite(white, white.Bounds())
+
+	// Four tiles, each in its own chunk
+	static := NewStaticBatch(8)
+	ids := make([]StaticID, 0)
+	for i := 0; i < 4; i++ {
+		mat := Mat4Ident
+		mat.Translate(float64(4+i%2*8), float64(4+i/2*8), 0)
+		sprite.DrawColorMask(static, mat, RGBA{1, 0, 0, 1})
+		ids = append(ids, static.LastID())
+	}
+
+	draw := func() (*image.RGBA, Metrics) {
+		Clear(win, RGBA{})
+		GetMetrics()
+		static.Draw(win, camera)
+		win.Update()
+		return win.Image(), GetMetrics()
+	}
+
+	img, metrics := draw()
+	if metrics.Draw != 4 {
+		t.Errorf("expected one draw per chunk, got %d", metrics.Draw)
+	}
+	if got := img.RGBAAt(12, 16-1-12); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("expected a red tile, got %v", got)
+	}
+
+	// Replacing a tile rewrites it in place
+	mat := Mat4Ident
+	mat.Translate(12, 12, 0)
+	sprite.DrawColorMask(static.Replace(ids[3]), mat, RGBA{0, 1, 0, 1})
+	img, _ = draw()
+	if got := img.RGBAAt(12, 16-1-12); got != (color.RGBA{0, 255, 0, 255}) {
+		t.Errorf("expected the replaced tile to be green, got %v", got)
+	}
+	if got := img.RGBAAt(4, 16-1-4); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("expected the other tiles to be unchanged, got %v", got)
+	}
+
+	// Removed tiles stop drawing
+	static.Remove(ids[0])
+	img, _ = draw()
+	if got := img.RGBAAt(4, 16-1-4); got != (color.RGBA{}) {
+		t.Errorf("expected the removed tile to be gone, got %v", got)
+	}
+
+	// Chunks outside of the camera are culled
+	camera.SetView2D(8, 8, 1, 1)
+	_, metrics = draw()
+	if metrics.Draw != 1 {
+		t.Errorf("expected only the chunk in view to draw, got %d", metrics.Draw)
+	}
+	camera.SetView2D(0, 0, 1, 1)
+}
+
+func TestHeadlessStaticBatchRelease(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	white := NewRGBATexture(4, 4, color.RGBA{255, 255, 255, 255}, false)
+	defer white.Destroy()
+	sprite := NewSprite(white, white.Bounds())
+
+	static := NewStaticBatch(8)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected geometry that isn't a mesh to panic")
+			}
+		}()
+		static.Add(&bufferFiller{}, glMat4Ident, White, sprite.material, false)
+	}()
+
+	SetLeakTracking(true)
+	defer SetLeakTracking(false)
+
+	sprite.Draw(static, Mat4Ident)
+	static.Draw(win, nil)
+	if len(TrackedResources()) == 0 {
+		t.Fatalf("expected the chunk's buffers to be tracked")
+	}
+	static.Clear()
+	if left := TrackedResources(); len(left) != 0 {
+		t.Errorf("expected clearing to release the chunk's buffers, got %d resources", len(left))
+	}
+
+	sprite.Draw(static, Mat4Ident)
+	static.Destroy()
+	if left := TrackedResources(); len(left) != 0 {
+		t.Errorf("expected destroy to release the chunk's buffers, got %d resources", len(left))
+	}
+	win.Update()
+}
+
+func TestHeadlessStaticBatchRotated(t *testing.T) {
+	win, camera := newTestWindow(t, 16, 16, WindowConfig{})
+
+	white := NewRGBATexture(4, 4, color.RGBA{255, 255, 255, 255}, false)
+	defer white.Destroy()
+	sprite := NewSprite(white, white.Bounds())
+
+	// A diamond centered left of the camera with only its right tip in view. The chunk bounds must cover the rotated
+	// corners, or the chunk is culled even though the tip is visible
+	diamond := Mat4Ident
+	diamond.Scale(4, 4, 1).RotateZ(math.Pi/4).Translate(-6, 8, 0)
+	offscreen := Mat4Ident
+	offscreen.Translate(-40, 8, 0)
+
+	tipDrawn := func(static *StaticBatch) bool {
+		Clear(win, RGBA{})
+		static.Draw(win, camera)
+		win.Update()
+		return win.Image().RGBAAt(2, 16-1-8) == color.RGBA{255, 255, 255, 255}
+	}
+
+	// Added directly
+	static := NewStaticBatch(64)
+	defer static.Destroy()
+	sprite.Draw(static, diamond)
+	if !tipDrawn(static) {
+		t.Errorf("expected the added diamond to be drawn")
+	}
+
+	// Replaced in place over an offscreen sprite
+	static.Clear()
+	sprite.Draw(static, offscreen)
+	sprite.Draw(static.Replace(static.LastID()), diamond)
+	if !tipDrawn(static) {
+		t.Errorf("expected the replaced diamond to be drawn")
+	}
+
+	// Kept when removing the other sprites rebuilds the chunk
+	static.Clear()
+	sprite.Draw(static, offscreen)
+	first := static.LastID()
+	sprite.Draw(static, offscreen)
+	second := static.LastID()
+	sprite.Draw(static, diamond)
+	static.Remove(first)
+	static.Remove(second)
+	if !tipDrawn(static) {
+		t.Errorf("expected the diamond to be drawn after the chunk is rebuilt")
+	}
+}
@@ -118,6 +118,14 @@ type AtlasConfig struct {
 }
 
 func NewAtlas(face font.Face, runes []rune, config AtlasConfig) *Atlas {
+	atlas, img := newAtlasImage(face, runes, config)
+	atlas.texture = NewTexture(img, config.Smooth)
+	atlas.defaultMaterial = DefaultMaterial(atlas.texture)
+	return atlas
+}
+
+// Draws the glyphs into the atlas image without creating the texture, so that it can run off of the main thread
+func newAtlasImage(face font.Face, runes []rune, config AtlasConfig) (*Atlas, *image.RGBA) {
 	metrics := face.Metrics()
 	// fmt.Println("Metrics: ", fixedToFloat(metrics.Height), fixedToFloat(metrics.Ascent), fixedToFloat(metrics.Descent))
 	atlas := &Atlas{
@@ -316,11 +324,8 @@ func NewAtlas(face font.Face, runes []rune, config AtlasConfig) *Atlas {
 	// png.Encode(outputFile, img)
 	// outputFile.Close()
 
-	atlas.texture = NewTexture(img, config.Smooth)
-	atlas.defaultMaterial = DefaultMaterial(atlas.texture)
-
 	// fmt.Println("TextAtlas: ", atlas.texture.width, atlas.texture.height)
-	return atlas
+	return atlas, img
 }
 
 func (a *Atlas) Material() *Material {
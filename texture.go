@@ -1,9 +1,11 @@
 package glitch
 
 import (
+	"encoding/binary"
 	"image"
 	"image/color"
 	"image/draw"
+	"math"
 	"runtime"
 
 	"github.com/unitoftime/flow/glm"
@@ -53,7 +55,17 @@ func NewTransparentTexture64() *Texture {
 type Texture struct {
 	texture       gl.Texture
 	width, height int
-	smooth        bool
+	config        TextureConfig
+
+	internalFormat gl.Enum
+	format, ty     gl.Enum // How pixels are passed to SetPixels
+	levels         int     // The mip levels uploaded from a compressed file, zero if the texture isn't compressed
+	mipmapped      bool
+	anisotropic    bool // Set if anisotropy was turned on, so that turning it off resets it
+	straightAlpha  bool // Set for compressed textures whose blocks store straight alpha, see NewCompressedTexture
+	bytes          int  // The memory counted for this texture in the metrics
+	destroyed      bool // Set once the GL texture has been deleted
+	trackID        uint64
 }
 
 func toRgba(img image.Image) *image.RGBA {
@@ -74,7 +86,7 @@ func NewEmptyTexture(width, height int, smooth bool) *Texture {
 	t := &Texture{
 		width:  width,
 		height: height,
-		smooth: smooth,
+		config: smoothConfig(smooth),
 	}
 
 	t.initialize(nil)
@@ -82,6 +94,11 @@ func NewEmptyTexture(width, height int, smooth bool) *Texture {
 }
 
 func NewTexture(img image.Image, smooth bool) *Texture {
+	return NewTextureWithConfig(img, smoothConfig(smooth))
+}
+
+// Creates a texture with control over its filtering, wrapping and mipmaps
+func NewTextureWithConfig(img image.Image, cfg TextureConfig) *Texture {
 	// We can only use RGBA images right now.
 	rgba := toRgba(img)
 
@@ -90,7 +107,7 @@ func NewTexture(img image.Image, smooth bool) *Texture {
 	t := &Texture{
 		width:  width,
 		height: height,
-		smooth: smooth,
+		config: cfg,
 	}
 
 	t.initialize(rgba.Pix)
@@ -98,47 +115,385 @@ func NewTexture(img image.Image, smooth bool) *Texture {
 	return t
 }
 
-func (t *Texture) initialize(pixels []uint8) {
+// The format that a texture's pixels are stored in on the GPU. Formats with fewer than four channels sample the
+// missing color channels as 0 and alpha as 1, so an R8 texture samples as (r, 0, 0, 1)
+type TextureFormat uint8
+
+const (
+	TextureRGBA8   TextureFormat = iota // 8 bits per channel, which is what NewTexture uses
+	TextureR8                           // A single 8 bit channel, ie font SDFs and masks
+	TextureRG8                          // Two 8 bit channels
+	TextureR16F                         // A single half float channel, ie 16 bit heightmaps
+	TextureR32F                         // A single float channel
+	TextureRGBA16F                      // A half float per channel, for values outside of [0, 1] (ie HDR lightmaps)
+	TextureRGBA32F                      // A float per channel
+)
+
+// Half floats are uploaded as HALF_FLOAT rather than FLOAT because webgl only accepts that pairing
+var textureFormats = [...]frameFormat{
+	TextureRGBA8:   {gl.RGBA, gl.RGBA, gl.UNSIGNED_BYTE},
+	TextureR8:      {gl.R8, gl.RED, gl.UNSIGNED_BYTE},
+	TextureRG8:     {gl.RG8, gl.RG, gl.UNSIGNED_BYTE},
+	TextureR16F:    {gl.R16F, gl.RED, gl.HALF_FLOAT},
+	TextureR32F:    {gl.R32F, gl.RED, gl.FLOAT},
+	TextureRGBA16F: {gl.RGBA16F, gl.RGBA, gl.HALF_FLOAT},
+	TextureRGBA32F: {gl.RGBA32F, gl.RGBA, gl.FLOAT},
+}
+
+// Returns the number of channels in the format
+func (f TextureFormat) Channels() int {
+	return formatChannels(textureFormats[f].format)
+}
+
+func (f TextureFormat) float() bool {
+	ty := textureFormats[f].ty
+	return ty == gl.FLOAT || ty == gl.HALF_FLOAT
+}
+
+// Creates a texture that stores img in a specific format. The image is converted channel by channel, so an
+// image.Gray or image.Alpha fills the red channel of an R8 texture, and an image.Gray16 keeps all 16 bits in an R32F
+// texture (R16F keeps 11). Converting to an 8 bit format keeps the top 8 bits of each channel
+func NewTextureFormat(img image.Image, format TextureFormat, cfg TextureConfig) *Texture {
+	if format == TextureRGBA8 {
+		return NewTextureWithConfig(img, cfg)
+	}
+
+	t := &Texture{
+		width:  img.Bounds().Dx(),
+		height: img.Bounds().Dy(),
+		config: cfg,
+	}
+	f := textureFormats[format]
+	t.initializeFormat(f.internal, f.format, f.ty, imagePixels(img, format))
+	return t
+}
+
+// Creates a single channel texture from a grayscale image, ie a font SDF or a mask
+func NewGrayTexture(img *image.Gray, cfg TextureConfig) *Texture {
+	return NewTextureFormat(img, TextureR8, cfg)
+}
+
+// Creates a texture from raw floats, ie an HDR lightmap. The format must be one of the float formats, and pix holds
+// its channels for each pixel, with the rows ordered top to bottom like an image
+func NewFloatTexture(width, height int, format TextureFormat, pix []float32, cfg TextureConfig) *Texture {
+	if !format.float() {
+		panic("NewFloatTexture: format isn't a float format")
+	}
+	channels := format.Channels()
+	if len(pix) != width*height*channels {
+		panic("NewFloatTexture: wrong number of floats")
+	}
+
+	f := textureFormats[format]
+	pixSize := texturePixelBytes(f.format, f.ty)
+	stride := alignRow(width * pixSize)
+	pixels := make([]uint8, stride*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := (y*width + x) * channels
+			putTexel(pixels[y*stride+x*pixSize:], f.ty, pix[i:i+channels])
+		}
+	}
+
+	t := &Texture{
+		width:  width,
+		height: height,
+		config: cfg,
+	}
+	t.initializeFormat(f.internal, f.format, f.ty, pixels)
+	return t
+}
+
+// Converts an image into the pixels of a texture format
+func imagePixels(img image.Image, format TextureFormat) []uint8 {
+	f := textureFormats[format]
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	pixSize := texturePixelBytes(f.format, f.ty)
+	stride := alignRow(width * pixSize)
+	pixels := make([]uint8, stride*height)
+
+	// Fast path for font SDFs and masks
+	if gray, ok := img.(*image.Gray); ok && format == TextureR8 {
+		for y := 0; y < height; y++ {
+			start := gray.PixOffset(bounds.Min.X, bounds.Min.Y+y)
+			copy(pixels[y*stride:], gray.Pix[start:start+width])
+		}
+		return pixels
+	}
+
+	channels := format.Channels()
+	var texel [4]float32
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			dst := pixels[y*stride+x*pixSize:]
+			if f.ty == gl.UNSIGNED_BYTE {
+				texel8 := [4]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+				copy(dst, texel8[:channels])
+				continue
+			}
+			texel = [4]float32{float32(r) / 0xFFFF, float32(g) / 0xFFFF, float32(b) / 0xFFFF, float32(a) / 0xFFFF}
+			putTexel(dst, f.ty, texel[:channels])
+		}
+	}
+	return pixels
+}
+
+// Writes the float channels of a texel in the byte layout of a pixel type
+func putTexel(dst []uint8, ty gl.Enum, channels []float32) {
+	for c, v := range channels {
+		switch ty {
+		case gl.FLOAT:
+			binary.NativeEndian.PutUint32(dst[c*4:], math.Float32bits(v))
+		case gl.HALF_FLOAT:
+			binary.NativeEndian.PutUint16(dst[c*2:], float16Bits(v))
+		default:
+			dst[c] = uint8(math.Round(float64(min(max(v, 0), 1)) * 255))
+		}
+	}
+}
+
+// Converts a float to the bits of a half float, rounding to the nearest even value
+func float16Bits(f float32) uint16 {
+	b := math.Float32bits(f)
+	sign := uint16(b>>16) & 0x8000
+	exp := int(b>>23&0xFF) - 127 + 15
+	mant := b & 0x7FFFFF
+
+	switch {
+	case b&0x7FFFFFFF > 0x7F800000:
+		return sign | 0x7E00 // NaN
+	case exp >= 0x1F:
+		return sign | 0x7C00 // Too large, so it becomes infinity
+	case exp <= 0:
+		// Subnormal, or too small and rounds to zero
+		if exp < -10 {
+			return sign
+		}
+		mant |= 0x800000
+		shift := uint(14 - exp)
+		half := mant >> shift
+		rem := mant & (1<<shift - 1)
+		halfway := uint32(1) << (shift - 1)
+		if rem > halfway || (rem == halfway && half&1 == 1) {
+			half++
+		}
+		return sign | uint16(half)
+	}
+
+	half := uint16(exp)<<10 | uint16(mant>>13)
+	rem := mant & 0x1FFF
+	if rem > 0x1000 || (rem == 0x1000 && half&1 == 1) {
+		half++ // Carrying into the exponent is correct, it rounds up to the next power of two
+	}
+	return sign | half
+}
+
+// Pixel rows are padded to GL's default unpack alignment of 4 bytes
+func alignRow(rowBytes int) int {
+	return (rowBytes + 3) &^ 3
+}
+
+// Copies tightly packed rows into padded rows if they don't line up with the unpack alignment
+func padRows(pixels []uint8, rowBytes, height int) []uint8 {
+	stride := alignRow(rowBytes)
+	if stride == rowBytes {
+		return pixels
+	}
+	padded := make([]uint8, stride*height)
+	for row := 0; row < height; row++ {
+		copy(padded[row*stride:], pixels[row*rowBytes:(row+1)*rowBytes])
+	}
+	return padded
+}
+
+func formatChannels(format gl.Enum) int {
+	switch format {
+	case gl.RED:
+		return 1
+	case gl.RG:
+		return 2
+	}
+	return 4
+}
+
+// Returns the number of bytes in a pixel of client data
+func texturePixelBytes(format, ty gl.Enum) int {
+	size := 1
+	switch ty {
+	case gl.HALF_FLOAT:
+		size = 2
+	case gl.FLOAT:
+		size = 4
+	}
+	return formatChannels(format) * size
+}
+
+type TextureFilter uint8
+
+const (
+	FilterNearest TextureFilter = iota // Pixelated, which is what pixel art wants
+	FilterLinear                       // Blends between the nearest texels
+)
+
+// How texture coordinates outside of [0, 1] are sampled
+type TextureWrap uint8
+
+const (
+	WrapClamp          TextureWrap = iota // Stretch the edge texels
+	WrapRepeat                            // Tile the texture
+	WrapMirroredRepeat                    // Tile the texture, flipping every other tile
+)
+
+// Describes how a texture is sampled. A tiling background can be drawn as a single quad by using WrapRepeat
+// and scaling its UVs past 1, rather than drawing a quad for each tile. That needs a shader with float texture
+// coordinates: AttrUShort2Norm coordinates (ie shaders.SpritePackedShader) are clamped to [0, 1] so they never repeat
+type TextureConfig struct {
+	MinFilter TextureFilter // Used when the texture is drawn smaller than its size
+	MagFilter TextureFilter // Used when the texture is drawn larger than its size
+	WrapS     TextureWrap   // Horizontal
+	WrapT     TextureWrap   // Vertical
+
+	// Generates mipmaps when the texture is created, and again whenever its pixels are set. Mipmaps stop textures from
+	// shimmering when they are drawn much smaller than their size. MipmapFilter chooses whether to blend between mip levels
+	Mipmaps      bool
+	MipmapFilter TextureFilter
+
+	// Sharpens textures that are drawn at steep angles, ie a ground plane in 3D. It is clamped to what the GPU supports,
+	// and values of 1 or less disable it. 16 is the usual maximum
+	Anisotropy float64
+}
+
+// The config that the smooth flag of NewTexture maps to
+func smoothConfig(smooth bool) TextureConfig {
+	if smooth {
+		return TextureConfig{MinFilter: FilterLinear, MagFilter: FilterLinear}
+	}
+	return TextureConfig{}
+}
+
+func (c TextureConfig) minFilter() int {
+	if !c.Mipmaps {
+		return c.MinFilter.glEnum()
+	}
+	switch {
+	case c.MinFilter == FilterNearest && c.MipmapFilter == FilterNearest:
+		return gl.NEAREST_MIPMAP_NEAREST
+	case c.MinFilter == FilterNearest:
+		return gl.NEAREST_MIPMAP_LINEAR
+	case c.MipmapFilter == FilterNearest:
+		return gl.LINEAR_MIPMAP_NEAREST
+	}
+	return gl.LINEAR_MIPMAP_LINEAR
+}
+
+func (f TextureFilter) glEnum() int {
+	if f == FilterLinear {
+		return gl.LINEAR
+	}
+	return gl.NEAREST
+}
+
+func (w TextureWrap) glEnum() int {
+	switch w {
+	case WrapRepeat:
+		return gl.REPEAT
+	case WrapMirroredRepeat:
+		return gl.MIRRORED_REPEAT
+	}
+	return gl.CLAMP_TO_EDGE
+}
+
+// Returns the config that the texture is sampled with
+func (t *Texture) Config() TextureConfig {
+	return t.config
+}
+
+// Changes how the texture is sampled. Mipmaps are generated if they are turned on. Compressed textures can't generate
+// mipmaps, so they only use the mip levels that were in their file
+func (t *Texture) SetConfig(cfg TextureConfig) {
+	global.flush() // Batched draws sample with whatever is set when they are drawn
+	if t.levels > 0 {
+		cfg.Mipmaps = cfg.Mipmaps && t.levels > 1
+	}
+	t.config = cfg
 	mainthread.Call(func() {
-		t.texture = gl.CreateTexture()
 		gl.BindTexture(gl.TEXTURE_2D, t.texture)
+		t.mainthreadApplyConfig()
+		if cfg.Mipmaps && t.levels == 0 {
+			gl.GenerateMipmap(gl.TEXTURE_2D)
+		}
+	})
+	state.invalidateTexture()
+	t.setMipmapped(cfg.Mipmaps || t.levels > 1)
+}
 
-		gl.TexImage2DFull(gl.TEXTURE_2D, 0, gl.RGBA, t.width, t.height, gl.RGBA, gl.UNSIGNED_BYTE, pixels)
+// Sets the sampling parameters of the bound texture
+func (t *Texture) mainthreadApplyConfig() {
+	// Note: webgl doesn't support CLAMP_TO_BORDER, so it isn't offered
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, t.config.WrapS.glEnum())
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, t.config.WrapT.glEnum())
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, t.config.minFilter())
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, t.config.MagFilter.glEnum())
+
+	anisotropy := float32(1)
+	if t.config.Anisotropy > 1 {
+		anisotropy = min(float32(t.config.Anisotropy), gl.MaxAnisotropy())
+	}
+	if anisotropy > 1 || t.anisotropic {
+		gl.TexParameterf(gl.TEXTURE_2D, gl.TEXTURE_MAX_ANISOTROPY, anisotropy)
+		t.anisotropic = anisotropy > 1
+	}
+}
 
-		// TODO - webgl doesn't support CLAMP_TO_BORDER
-		// GL_CLAMP_TO_EDGE: The coordinate will simply be clamped between 0 and 1.
-		// GL_CLAMP_TO_BORDER: The coordinates that fall outside the range will be given a specified border color.
+func (t *Texture) setMipmapped(mipmapped bool) {
+	if t.mipmapped != mipmapped {
+		t.mipmapped = mipmapped
+		t.updateMemory()
+	}
+}
 
-		// TODO - Do I need this all the time? Probably not, especially when I'm doing texture atlases
-		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
-		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+func (t *Texture) initialize(pixels []uint8) {
+	t.initializeFormat(gl.RGBA, gl.RGBA, gl.UNSIGNED_BYTE, pixels)
+}
 
-		// gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
-		// gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
+// Creates the texture with a specific internal format. Pixels may be nil to allocate the texture without uploading anything
+func (t *Texture) initializeFormat(internalFormat, format, ty gl.Enum, pixels []uint8) {
+	global.flush()
+	t.internalFormat = internalFormat
+	t.format = format
+	t.ty = ty
+	t.updateMemory()
+	mainthread.Call(func() {
+		t.texture = gl.CreateTexture()
+		gl.BindTexture(gl.TEXTURE_2D, t.texture)
 
-		// TODO - pass smooth in as a parameter
-		if t.smooth {
-			gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
-			gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-		} else {
-			gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
-			gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+		gl.TexImage2DFull(gl.TEXTURE_2D, 0, internalFormat, t.width, t.height, format, ty, pixels)
+		t.mainthreadApplyConfig()
+		if t.config.Mipmaps && pixels != nil {
+			gl.GenerateMipmap(gl.TEXTURE_2D)
 		}
 	})
+	state.invalidateTexture()
+	t.setMipmapped(t.config.Mipmaps)
 
-	runtime.SetFinalizer(t, (*Texture).delete)
+	runtime.SetFinalizer(t, (*Texture).finalize)
+	t.trackID = trackResource("Texture")
 }
 
-// TODO: This needs to be combined into the NewTexture function, or this needs to bind the texture
+// Generates mipmaps and switches the texture to trilinear filtering. Prefer setting TextureConfig.Mipmaps, which also
+// keeps the mipmaps up to date when the texture's pixels are set
 func (t *Texture) GenerateMipmap() {
-	mainthread.Call(func() {
-		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
-		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-		gl.GenerateMipmap(gl.TEXTURE_2D)
-	})
+	cfg := t.config
+	cfg.Mipmaps = true
+	cfg.MinFilter = FilterLinear
+	cfg.MagFilter = FilterLinear
+	cfg.MipmapFilter = FilterLinear
+	t.SetConfig(cfg)
 }
 
-// Sets the texture to be this image.
+// Sets the texture to be this image. The image is converted to the texture's format
 // Texture size must match img size or this will panic!
 // TODO - Should I just try and set it? or do nothing?
 func (t *Texture) SetImage(img image.Image) {
@@ -150,21 +505,42 @@ func (t *Texture) SetImage(img image.Image) {
 		panic("SetImage: img bounds are not equal to texture bounds!")
 	}
 
-	rgba := toRgba(img)
-	pixels := rgba.Pix
-	t.SetPixels(0, 0, t.width, t.height, pixels)
+	if t.format == gl.RGBA && t.ty == gl.UNSIGNED_BYTE {
+		rgba := toRgba(img)
+		pixels := rgba.Pix
+		t.SetPixels(0, 0, t.width, t.height, pixels)
+		return
+	}
+
+	for format, f := range textureFormats {
+		if f.format == t.format && f.ty == t.ty {
+			t.setPixels(0, 0, t.width, t.height, imagePixels(img, TextureFormat(format)))
+			return
+		}
+	}
+	panic("SetImage: the texture's format can't be set from an image")
 }
 
-// Sets the pixels of a section of a texture
+// Sets the pixels of a section of a texture. Pixels are in the texture's format, so an R8 texture takes one byte per
+// pixel and an RGBA32F texture takes four native endian floats per pixel
 func (t *Texture) SetPixels(x, y, w, h int, pixels []uint8) {
-	if len(pixels) != w*h*4 {
+	if t.levels > 0 {
+		panic("set pixels: compressed textures can't be written to")
+	}
+	pixSize := texturePixelBytes(t.format, t.ty)
+	if len(pixels) != w*h*pixSize {
 		panic("set pixels: wrong number of pixels")
 	}
 
+	t.setPixels(x, y, w, h, padRows(pixels, w*pixSize, h))
+}
+
+// Uploads pixels whose rows are already padded to the unpack alignment
+func (t *Texture) setPixels(x, y, w, h int, pixels []uint8) {
 	// TODO: This is a little inefficient. But I can't messup the global bound texture state
-	lastTexture := state.texture
-	defer state.bindTexture(lastTexture)
-	state.bindTexture(t)
+	lastTexture := state.textures[0]
+	defer state.bindTexture(0, lastTexture)
+	state.bindTextureForWrite(t)
 
 	mainthread.Call(func() {
 		gl.TexSubImage2D(
@@ -174,10 +550,13 @@ func (t *Texture) SetPixels(x, y, w, h int, pixels []uint8) {
 			y,
 			w,
 			h,
-			gl.RGBA,
-			gl.UNSIGNED_BYTE,
+			t.format,
+			t.ty,
 			pixels,
 		)
+		if t.config.Mipmaps {
+			gl.GenerateMipmap(gl.TEXTURE_2D)
+		}
 	})
 }
 
@@ -185,13 +564,68 @@ func (t *Texture) Bounds() Rect {
 	return glm.R(0, 0, float64(t.width), float64(t.height))
 }
 
+// Returns true if the texture's colors aren't premultiplied by their alpha, so it should be drawn with
+// BlendModeNonPremultiplied. This is only the case for compressed textures that the GPU samples directly
+func (t *Texture) StraightAlpha() bool {
+	return t.straightAlpha
+}
+
 // func (t *Texture) bind(position int) {
 // 	// TODO - maybe allow for more than 15 if the platform supports it? TODO - max texture units
 // 	state.bindTexture(t)
 // }
 
-func (t *Texture) delete() {
-	mainthread.CallNonBlock(func() {
-		gl.DeleteTexture(t.texture)
+// Recomputes how much memory the texture uses, for the metrics
+func (t *Texture) updateMemory() {
+	bytes := t.width * t.height * textureFormatBytes(t.internalFormat)
+	if t.levels > 0 {
+		bytes = compressedSize(t.internalFormat, t.width, t.height)
+	}
+	if t.mipmapped {
+		bytes += bytes / 3 // The smaller mip levels add up to about a third of the texture
+	}
+	textureMemory.Add(int64(bytes - t.bytes))
+	t.bytes = bytes
+}
+
+// Returns the number of bytes per pixel of an internal format
+func textureFormatBytes(internalFormat gl.Enum) int {
+	switch internalFormat {
+	case gl.R8:
+		return 1
+	case gl.RG8, gl.R16F:
+		return 2
+	case gl.RGBA16F:
+		return 8
+	case gl.RGBA32F:
+		return 16
+	}
+	return 4
+}
+
+// Deletes the texture from the GPU. The texture can't be drawn after it is destroyed, and destroying it again does nothing
+func (t *Texture) Destroy() {
+	runtime.SetFinalizer(t, nil)
+	global.flush() // Batched draws might still use the texture
+	t.release(mainthread.Call)
+}
+
+// Finalizers run on their own goroutine, so the deletion is queued rather than waited for
+func (t *Texture) finalize() {
+	t.release(mainthread.CallNonBlock)
+}
+
+func (t *Texture) release(call func(func())) {
+	if t.destroyed {
+		return
+	}
+	t.destroyed = true
+	untrackResource(t.trackID)
+	textureMemory.Add(int64(-t.bytes))
+	t.bytes = 0
+
+	texture := t.texture
+	call(func() {
+		gl.DeleteTexture(texture)
 	})
 }
@@ -0,0 +1,160 @@
+//go:build headless
+// +build headless
+
+package glitch
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/unitoftime/flow/glm"
+	"github.com/unitoftime/glitch/internal/gl"
+	"github.com/unitoftime/glitch/internal/mainthread"
+)
+
+func TestHeadlessTextureConfig(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	red := color.RGBA{255, 0, 0, 255}
+	green := color.RGBA{0, 255, 0, 255}
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.SetRGBA(0, 0, red)
+	img.SetRGBA(1, 0, green)
+	tex := NewTextureWithConfig(img, TextureConfig{WrapS: WrapRepeat, WrapT: WrapRepeat, Anisotropy: 32})
+	defer tex.Destroy()
+
+	// One quad covers the window with the texture repeated 4 times across, so each texel is 2 pixels wide
+	mesh := NewQuadMesh(win.Bounds(), glm.R(0, 0, 4, 1))
+	Clear(win, RGBA{})
+	win.Add(mesh, glMat4Ident, White, DefaultMaterial(tex), false)
+	for x := 0; x < 16; x++ {
+		want := red
+		if (x/2)%2 == 1 {
+			want = green
+		}
+		if got := win.Image().RGBAAt(x, 8); got != want {
+			t.Errorf("x=%d: expected %v, got %v", x, want, got)
+		}
+	}
+	win.Update()
+
+	var params [1]int32
+	mainthread.Call(func() {
+		gl.BindTexture(gl.TEXTURE_2D, tex.texture)
+		gl.GetTexParameteriv(params[:], gl.TEXTURE_2D, gl.TEXTURE_MAX_ANISOTROPY)
+	})
+	state.invalidateTexture()
+	if params[0] != 16 {
+		t.Errorf("expected the anisotropy to be clamped to 16, got %d", params[0])
+	}
+
+	memory := tex.bytes
+	tex.SetConfig(TextureConfig{MinFilter: FilterLinear, MagFilter: FilterLinear, Mipmaps: true})
+	if tex.bytes <= memory {
+		t.Errorf("expected mipmaps to add to the texture memory")
+	}
+	mainthread.Call(func() {
+		gl.BindTexture(gl.TEXTURE_2D, tex.texture)
+		gl.GetTexParameteriv(params[:], gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER)
+	})
+	state.invalidateTexture()
+	if params[0] != gl.LINEAR_MIPMAP_NEAREST {
+		t.Errorf("expected the mipmapped min filter, got 0x%x", params[0])
+	}
+}
+
+func TestHeadlessTextureFormats(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	// Draws the texture over the whole window and returns the color of the texel at x, y (top down)
+	mesh := NewQuadMesh(win.Bounds(), glm.R(0, 0, 1, 1))
+	draw := func(tex *Texture) *image.RGBA {
+		Clear(win, RGBA{})
+		win.Add(mesh, glMat4Ident, White, DefaultMaterial(tex), false)
+		return win.Image()
+	}
+	texel := func(img *image.RGBA, tex *Texture, x, y int) color.RGBA {
+		sx, sy := 16/tex.width, 16/tex.height
+		return img.RGBAAt(x*sx+sx/2, y*sy+sy/2)
+	}
+
+	// Odd widths need their single channel rows padded
+	gray := image.NewGray(image.Rect(0, 0, 3, 2))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(i * 40)
+	}
+	grayTex := NewGrayTexture(gray, TextureConfig{})
+	defer grayTex.Destroy()
+	reference := NewTexture(gray, false)
+	defer reference.Destroy()
+	if grayTex.bytes != 6 {
+		t.Errorf("expected an R8 texture to use a byte per pixel, got %d", grayTex.bytes)
+	}
+	want := draw(reference)
+	got := draw(grayTex)
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			w, g := texel(want, grayTex, x, y), texel(got, grayTex, x, y)
+			if g != (color.RGBA{w.R, 0, 0, 255}) {
+				t.Errorf("%d,%d: expected the red channel %d, got %v", x, y, w.R, g)
+			}
+		}
+	}
+	grayTex.SetPixels(0, 0, 3, 1, []uint8{255, 255, 255})
+	if got := texel(draw(grayTex), grayTex, 2, 0); got.R != 255 {
+		t.Errorf("expected set pixels to write the red channel, got %v", got)
+	}
+
+	floatTex := NewFloatTexture(2, 1, TextureRGBA16F, []float32{0.5, 0.25, 1, 1, 4, 0, 0, 1}, TextureConfig{})
+	defer floatTex.Destroy()
+	img := draw(floatTex)
+	if got := texel(img, floatTex, 0, 0); got != (color.RGBA{128, 64, 255, 255}) {
+		t.Errorf("expected the half floats to be uploaded, got %v", got)
+	}
+	if got := texel(img, floatTex, 1, 0); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("expected values past 1 to be clamped when drawn, got %v", got)
+	}
+	for f, want := range map[float32]uint16{1: 0x3C00, -2: 0xC000, 65504: 0x7BFF, 1e6: 0x7C00, 0x1p-24: 0x0001, 1e-9: 0} {
+		if got := float16Bits(f); got != want {
+			t.Errorf("float16Bits(%v): expected 0x%04x, got 0x%04x", f, want, got)
+		}
+	}
+}
+
+func TestHeadlessSetPixelsAfterSamplers(t *testing.T) {
+	win, _ := newTestWindow(t, 16, 16, WindowConfig{})
+
+	main := solidTexture(255, 0, 0)
+	defer main.Destroy()
+	mask := solidTexture(0, 255, 0)
+	defer mask.Destroy()
+	material := NewMaterial(newMaskShader(t))
+	material.SetTexture(main)
+	material.SetSampler("mask", mask)
+
+	// Binding the material leaves the mask's unit active, while the main texture is still bound to unit 0
+	mesh := NewQuadMesh(win.Bounds(), glm.R(0, 0, 1, 1))
+	Clear(win, RGBA{})
+	win.Add(mesh, glMat4Ident, White, material, false)
+	win.Image()
+
+	blue := make([]uint8, 4*4*4)
+	for i := 0; i < len(blue); i += 4 {
+		blue[i+2], blue[i+3] = 255, 255
+	}
+	main.SetPixels(0, 0, 4, 4, blue)
+
+	draw := func(tex *Texture) color.RGBA {
+		Clear(win, RGBA{})
+		win.Add(mesh, glMat4Ident, White, DefaultMaterial(tex), false)
+		return win.Image().RGBAAt(8, 8)
+	}
+	if got := draw(main); got != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("expected the pixels to be written to the texture, got %v", got)
+	}
+	if got := draw(mask); got != (color.RGBA{0, 255, 0, 255}) {
+		t.Errorf("expected the texture on the active unit to be untouched, got %v", got)
+	}
+	win.Update()
+}
@@ -0,0 +1,513 @@
+package glitch
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// A TextureAtlas packs many images into a few large textures, called pages. Sprites on the same page share a material,
+// so they are drawn in the same batch, where sprites that each have their own texture would break the batch every draw.
+//
+//	atlas := glitch.NewTextureAtlas(glitch.TextureAtlasConfig{Extrude: 1})
+//	err := atlas.AddImages(images)
+//	player, _ := atlas.Sprite("player")
+//
+//	// Images can still be added once the pages have been uploaded
+//	icon, err := atlas.Add("icon", img)
+//
+// Images are packed with a skyline packer, which packs images of similar heights tightly and can add images later without
+// repacking anything. Use Save and LoadTextureAtlas to build an atlas once and load it at startup instead
+type TextureAtlas struct {
+	cfg     TextureAtlasConfig
+	pages   []*atlasPage
+	regions map[string]atlasRegion
+	names   []string // Region names in the order that they were added
+	sprites map[string]*Sprite
+}
+
+type TextureAtlasConfig struct {
+	PageWidth, PageHeight int // The size of each page. Zero defaults to 2048
+
+	// Transparent pixels left between images, so that sampling past the edge of an image reads nothing
+	Padding int
+
+	// Repeats the edge pixels of each image outwards, so that sampling just past the edge reads the edge. This prevents seams
+	// between tiles when they are drawn at fractional positions or are filtered
+	Extrude int
+
+	Smooth bool // Sample the pages linearly rather than nearest
+}
+
+type atlasPage struct {
+	img     *image.RGBA
+	texture *Texture // Nil until the page is uploaded
+	sky     skyline
+}
+
+type atlasRegion struct {
+	page   int
+	bounds image.Rectangle // Excludes the padding and extrusion
+}
+
+const defaultAtlasPageSize = 2048
+
+func NewTextureAtlas(cfg TextureAtlasConfig) *TextureAtlas {
+	if cfg.PageWidth <= 0 {
+		cfg.PageWidth = defaultAtlasPageSize
+	}
+	if cfg.PageHeight <= 0 {
+		cfg.PageHeight = defaultAtlasPageSize
+	}
+	return &TextureAtlas{
+		cfg:     cfg,
+		regions: make(map[string]atlasRegion),
+		sprites: make(map[string]*Sprite),
+	}
+}
+
+// Packs the images and uploads the pages. Images are packed tallest first, which packs much tighter than adding them one at a time
+func (a *TextureAtlas) AddImages(images map[string]image.Image) error {
+	names := make([]string, 0, len(images))
+	for name := range images {
+		if _, ok := a.regions[name]; ok {
+			return fmt.Errorf("glitch: %q is already in the atlas", name)
+		}
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(x, y string) int {
+		bx, by := images[x].Bounds(), images[y].Bounds()
+		if c := cmp.Compare(by.Dy(), bx.Dy()); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(by.Dx(), bx.Dx()); c != 0 {
+			return c
+		}
+		return strings.Compare(x, y)
+	})
+
+	dirty := make([]bool, len(a.pages))
+	for _, name := range names {
+		region, err := a.pack(name, images[name])
+		if err != nil {
+			return err
+		}
+		for len(dirty) < len(a.pages) {
+			dirty = append(dirty, false)
+		}
+		dirty[region.page] = true
+	}
+
+	for i, page := range a.pages {
+		if !dirty[i] {
+			continue
+		}
+		if page.texture == nil {
+			page.texture = NewTexture(page.img, a.cfg.Smooth)
+		} else {
+			page.texture.SetImage(page.img)
+		}
+	}
+	return nil
+}
+
+// Packs a single image and uploads only its part of the page
+func (a *TextureAtlas) Add(name string, img image.Image) (*Sprite, error) {
+	if _, ok := a.regions[name]; ok {
+		return nil, fmt.Errorf("glitch: %q is already in the atlas", name)
+	}
+	region, err := a.pack(name, img)
+	if err != nil {
+		return nil, err
+	}
+
+	page := a.pages[region.page]
+	if page.texture == nil {
+		page.texture = NewTexture(page.img, a.cfg.Smooth)
+	} else {
+		cell := a.cell(region.bounds)
+		sub := image.NewRGBA(image.Rect(0, 0, cell.Dx(), cell.Dy()))
+		draw.Draw(sub, sub.Bounds(), page.img, cell.Min, draw.Src)
+		page.texture.SetPixels(cell.Min.X, cell.Min.Y, cell.Dx(), cell.Dy(), sub.Pix)
+	}
+
+	sprite, _ := a.Sprite(name)
+	return sprite, nil
+}
+
+// Returns the sprite for an image in the atlas. Sprites are created once and then shared
+func (a *TextureAtlas) Sprite(name string) (*Sprite, bool) {
+	if sprite, ok := a.sprites[name]; ok {
+		return sprite, true
+	}
+	region, ok := a.regions[name]
+	if !ok {
+		return nil, false
+	}
+	texture := a.pages[region.page].texture
+	if texture == nil {
+		return nil, false // Added, but not uploaded yet
+	}
+
+	b := region.bounds
+	sprite := NewSprite(texture, Rect{
+		Min: Vec2{float64(b.Min.X), float64(b.Min.Y)},
+		Max: Vec2{float64(b.Max.X), float64(b.Max.Y)},
+	})
+	a.sprites[name] = sprite
+	return sprite, true
+}
+
+// Returns the names of the images in the order that they were added
+func (a *TextureAtlas) Names() []string {
+	return a.names
+}
+
+func (a *TextureAtlas) Pages() []*Texture {
+	ret := make([]*Texture, len(a.pages))
+	for i, page := range a.pages {
+		ret[i] = page.texture
+	}
+	return ret
+}
+
+// Returns a copy of the pixels of a page
+func (a *TextureAtlas) PageImage(i int) *image.RGBA {
+	src := a.pages[i].img
+	img := image.NewRGBA(src.Bounds())
+	copy(img.Pix, src.Pix)
+	return img
+}
+
+// Destroys the textures of every page. Sprites from the atlas can't be drawn afterwards
+func (a *TextureAtlas) Destroy() {
+	for _, page := range a.pages {
+		if page.texture != nil {
+			page.texture.Destroy()
+		}
+	}
+}
+
+// The area that an image takes up on its page, including its extrusion and padding
+func (a *TextureAtlas) cell(bounds image.Rectangle) image.Rectangle {
+	border := a.cfg.Extrude
+	return image.Rect(
+		bounds.Min.X-border, bounds.Min.Y-border,
+		bounds.Max.X+border+a.cfg.Padding, bounds.Max.Y+border+a.cfg.Padding,
+	)
+}
+
+// Finds space for the image, adding a page if none of the pages have room, and draws it into the page
+func (a *TextureAtlas) pack(name string, img image.Image) (atlasRegion, error) {
+	size := img.Bounds().Size()
+	cellW := size.X + 2*a.cfg.Extrude + a.cfg.Padding
+	cellH := size.Y + 2*a.cfg.Extrude + a.cfg.Padding
+	if cellW > a.cfg.PageWidth || cellH > a.cfg.PageHeight {
+		return atlasRegion{}, fmt.Errorf("glitch: %q is %dx%d, which doesn't fit on a %dx%d atlas page", name, size.X, size.Y, a.cfg.PageWidth, a.cfg.PageHeight)
+	}
+
+	pageIdx := -1
+	var pos image.Point
+	for i, page := range a.pages {
+		if p, ok := page.sky.insert(cellW, cellH); ok {
+			pageIdx, pos = i, p
+			break
+		}
+	}
+	if pageIdx < 0 {
+		page := &atlasPage{
+			img: image.NewRGBA(image.Rect(0, 0, a.cfg.PageWidth, a.cfg.PageHeight)),
+			sky: newSkyline(a.cfg.PageWidth, a.cfg.PageHeight),
+		}
+		a.pages = append(a.pages, page)
+		pageIdx = len(a.pages) - 1
+		pos, _ = page.sky.insert(cellW, cellH)
+	}
+
+	origin := pos.Add(image.Pt(a.cfg.Extrude, a.cfg.Extrude))
+	region := atlasRegion{
+		page:   pageIdx,
+		bounds: image.Rectangle{origin, origin.Add(size)},
+	}
+	page := a.pages[pageIdx]
+	draw.Draw(page.img, region.bounds, img, img.Bounds().Min, draw.Src)
+	extrude(page.img, region.bounds, a.cfg.Extrude)
+
+	a.regions[name] = region
+	a.names = append(a.names, name)
+	return region, nil
+}
+
+// Copies the edge pixels of the rectangle outwards by n pixels, including into the corners
+func extrude(img *image.RGBA, r image.Rectangle, n int) {
+	if n <= 0 || r.Empty() {
+		return
+	}
+	for y := r.Min.Y - n; y < r.Max.Y+n; y++ {
+		sy := min(max(y, r.Min.Y), r.Max.Y-1)
+		for x := r.Min.X - n; x < r.Max.X+n; x++ {
+			if image.Pt(x, y).In(r) {
+				continue
+			}
+			sx := min(max(x, r.Min.X), r.Max.X-1)
+			img.SetRGBA(x, y, img.RGBAAt(sx, sy))
+		}
+	}
+}
+
+// --------------------------------------------------------------------------------
+
+// A bottom left skyline packer. The skyline is the top edge of everything packed so far, stored as horizontal segments
+type skyline struct {
+	width, height int
+	nodes         []skylineNode
+}
+
+type skylineNode struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+}
+
+func newSkyline(width, height int) skyline {
+	return skyline{
+		width:  width,
+		height: height,
+		nodes:  []skylineNode{{0, 0, width}},
+	}
+}
+
+// Finds the position where a w by h rectangle sits lowest, with ties going to the leftmost position, and raises the skyline over it
+func (s *skyline) insert(w, h int) (image.Point, bool) {
+	best, bestY, bestX := -1, 0, 0
+	for i := range s.nodes {
+		y, ok := s.fit(i, w, h)
+		if !ok {
+			continue
+		}
+		if best < 0 || y < bestY || (y == bestY && s.nodes[i].X < bestX) {
+			best, bestY, bestX = i, y, s.nodes[i].X
+		}
+	}
+	if best < 0 {
+		return image.Point{}, false
+	}
+
+	s.nodes = slices.Insert(s.nodes, best, skylineNode{bestX, bestY + h, w})
+
+	// Shrink or remove the segments that are now underneath the new one
+	for i := best + 1; i < len(s.nodes); i++ {
+		prev := s.nodes[i-1]
+		node := &s.nodes[i]
+		overlap := prev.X + prev.W - node.X
+		if overlap <= 0 {
+			break
+		}
+		node.X += overlap
+		node.W -= overlap
+		if node.W > 0 {
+			break
+		}
+		s.nodes = slices.Delete(s.nodes, i, i+1)
+		i--
+	}
+
+	// Merge neighbouring segments at the same height
+	for i := 1; i < len(s.nodes); i++ {
+		if s.nodes[i-1].Y == s.nodes[i].Y {
+			s.nodes[i-1].W += s.nodes[i].W
+			s.nodes = slices.Delete(s.nodes, i, i+1)
+			i--
+		}
+	}
+	return image.Pt(bestX, bestY), true
+}
+
+// Returns the height that a w by h rectangle would sit at if its left edge was at node i
+func (s *skyline) fit(i, w, h int) (int, bool) {
+	x := s.nodes[i].X
+	if x+w > s.width {
+		return 0, false
+	}
+	y := 0
+	remaining := w
+	for j := i; remaining > 0; j++ {
+		if j >= len(s.nodes) {
+			return 0, false
+		}
+		y = max(y, s.nodes[j].Y)
+		if y+h > s.height {
+			return 0, false
+		}
+		remaining -= s.nodes[j].W
+	}
+	return y, true
+}
+
+// --------------------------------------------------------------------------------
+
+type textureAtlasData struct {
+	Version    int                      `json:"version"`
+	PageWidth  int                      `json:"pageWidth"`
+	PageHeight int                      `json:"pageHeight"`
+	Padding    int                      `json:"padding"`
+	Extrude    int                      `json:"extrude"`
+	Smooth     bool                     `json:"smooth"`
+	Pages      []textureAtlasPageData   `json:"pages"`
+	Regions    []textureAtlasRegionData `json:"regions"`
+}
+
+type textureAtlasPageData struct {
+	File    string        `json:"file"` // Relative to the layout file
+	Skyline []skylineNode `json:"skyline"`
+}
+
+type textureAtlasRegionData struct {
+	Name string `json:"name"`
+	Page int    `json:"page"`
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+	W    int    `json:"w"`
+	H    int    `json:"h"`
+}
+
+const textureAtlasVersion = 1
+
+// Writes the layout to a JSON file at path, and each page to a PNG next to it named after the layout, ie atlas_0.png for atlas.json.
+// The skyline of each page is saved too, so images can still be added to a loaded atlas
+func (a *TextureAtlas) Save(layoutPath string) error {
+	dir := filepath.Dir(layoutPath)
+	stem := strings.TrimSuffix(filepath.Base(layoutPath), filepath.Ext(layoutPath))
+
+	data := textureAtlasData{
+		Version:    textureAtlasVersion,
+		PageWidth:  a.cfg.PageWidth,
+		PageHeight: a.cfg.PageHeight,
+		Padding:    a.cfg.Padding,
+		Extrude:    a.cfg.Extrude,
+		Smooth:     a.cfg.Smooth,
+	}
+	for i, page := range a.pages {
+		file := fmt.Sprintf("%s_%d.png", stem, i)
+		if err := writePNG(filepath.Join(dir, file), page.img); err != nil {
+			return err
+		}
+		data.Pages = append(data.Pages, textureAtlasPageData{
+			File:    file,
+			Skyline: page.sky.nodes,
+		})
+	}
+	for _, name := range a.names {
+		r := a.regions[name]
+		data.Regions = append(data.Regions, textureAtlasRegionData{
+			Name: name,
+			Page: r.page,
+			X:    r.bounds.Min.X,
+			Y:    r.bounds.Min.Y,
+			W:    r.bounds.Dx(),
+			H:    r.bounds.Dy(),
+		})
+	}
+
+	b, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(layoutPath, b, 0644)
+}
+
+func writePNG(name string, img image.Image) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = png.Encode(f, img)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Loads an atlas that was written by Save and uploads its pages
+func LoadTextureAtlas(layoutPath string) (*TextureAtlas, error) {
+	return LoadTextureAtlasFS(os.DirFS(filepath.Dir(layoutPath)), filepath.Base(layoutPath))
+}
+
+// Like LoadTextureAtlas, but reads the layout and pages from fsys
+func LoadTextureAtlasFS(fsys fs.FS, layoutPath string) (*TextureAtlas, error) {
+	b, err := fs.ReadFile(fsys, layoutPath)
+	if err != nil {
+		return nil, err
+	}
+	var data textureAtlasData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, err
+	}
+	if data.Version != textureAtlasVersion {
+		return nil, fmt.Errorf("glitch: unsupported texture atlas version %d", data.Version)
+	}
+
+	a := NewTextureAtlas(TextureAtlasConfig{
+		PageWidth:  data.PageWidth,
+		PageHeight: data.PageHeight,
+		Padding:    data.Padding,
+		Extrude:    data.Extrude,
+		Smooth:     data.Smooth,
+	})
+	for _, p := range data.Pages {
+		img, err := readPNG(fsys, path.Join(path.Dir(layoutPath), p.File))
+		if err != nil {
+			return nil, err
+		}
+		if img.Bounds() != image.Rect(0, 0, a.cfg.PageWidth, a.cfg.PageHeight) {
+			return nil, fmt.Errorf("glitch: atlas page %s is %v, expected %dx%d", p.File, img.Bounds().Size(), a.cfg.PageWidth, a.cfg.PageHeight)
+		}
+		sky := newSkyline(a.cfg.PageWidth, a.cfg.PageHeight)
+		if len(p.Skyline) > 0 {
+			sky.nodes = p.Skyline
+		}
+		a.pages = append(a.pages, &atlasPage{img: img, sky: sky})
+	}
+	for _, r := range data.Regions {
+		if r.Page < 0 || r.Page >= len(a.pages) {
+			return nil, fmt.Errorf("glitch: atlas region %q is on page %d, which doesn't exist", r.Name, r.Page)
+		}
+		a.regions[r.Name] = atlasRegion{
+			page:   r.Page,
+			bounds: image.Rect(r.X, r.Y, r.X+r.W, r.Y+r.H),
+		}
+		a.names = append(a.names, r.Name)
+	}
+
+	for _, page := range a.pages {
+		page.texture = NewTexture(page.img, a.cfg.Smooth)
+	}
+	return a, nil
+}
+
+func readPNG(fsys fs.FS, name string) (*image.RGBA, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("glitch: decoding %s: %w", name, err)
+	}
+
+	// The pixels are kept so that images can be added later, so always copy them into a page of their own
+	rgba := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return rgba, nil
+}
@@ -0,0 +1,99 @@
+//go:build headless
+// +build headless
+
+package glitch
+
+import (
+	"image"
+	"image/color"
+	"slices"
+	"testing"
+
+	"github.com/unitoftime/flow/glm"
+)
+
+func TestHeadlessTextureAtlas(t *testing.T) {
+	newTestWindow(t, 16, 16, WindowConfig{})
+
+	solid := func(w, h int, c color.RGBA) image.Image {
+		img := image.NewRGBA(image.Rect(0, 0, w, h))
+		for i := 0; i < len(img.Pix); i += 4 {
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+		}
+		return img
+	}
+	red := color.RGBA{255, 0, 0, 255}
+	green := color.RGBA{0, 255, 0, 255}
+
+	atlas := NewTextureAtlas(TextureAtlasConfig{PageWidth: 64, PageHeight: 64, Padding: 1, Extrude: 1})
+	defer atlas.Destroy()
+	images := map[string]image.Image{
+		"red":   solid(8, 8, red),
+		"green": solid(12, 6, green),
+		"blue":  solid(20, 20, color.RGBA{0, 0, 255, 255}),
+	}
+	if err := atlas.AddImages(images); err != nil {
+		t.Fatal(err)
+	}
+	if len(atlas.Pages()) != 1 {
+		t.Fatalf("expected everything to fit on one page, got %d", len(atlas.Pages()))
+	}
+
+	// No two images overlap, including their extrusion and padding
+	for i, a := range atlas.Names() {
+		for _, b := range atlas.Names()[i+1:] {
+			ra, rb := atlas.regions[a], atlas.regions[b]
+			if atlas.cell(ra.bounds).Overlaps(atlas.cell(rb.bounds)) {
+				t.Errorf("%s at %v overlaps %s at %v", a, ra.bounds, b, rb.bounds)
+			}
+		}
+	}
+
+	sprite, ok := atlas.Sprite("red")
+	if !ok {
+		t.Fatal("expected the red sprite")
+	}
+	bounds := atlas.regions["red"].bounds
+	wantUV := glm.R(float64(bounds.Min.X)/64, float64(bounds.Min.Y)/64, float64(bounds.Max.X)/64, float64(bounds.Max.Y)/64)
+	if sprite.uvBounds != wantUV || sprite.texture != atlas.Pages()[0] {
+		t.Errorf("expected the uv bounds %v, got %v", wantUV, sprite.uvBounds)
+	}
+	page := atlas.PageImage(0)
+	if got := page.RGBAAt(bounds.Min.X-1, bounds.Min.Y-1); got != red {
+		t.Errorf("expected the corner to be extruded, got %v", got)
+	}
+	if got := page.RGBAAt(bounds.Max.X+1, bounds.Min.Y); got == red {
+		t.Errorf("expected padding past the extrusion")
+	}
+
+	// Runtime adds go onto a new page once the first one is full
+	added, err := atlas.Add("big", solid(60, 60, green))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(atlas.Pages()) != 2 || added.texture != atlas.Pages()[1] {
+		t.Errorf("expected the big image to go on a second page")
+	}
+	if _, err := atlas.Add("huge", solid(80, 80, green)); err == nil {
+		t.Error("expected an image larger than a page to fail")
+	}
+
+	layout := t.TempDir() + "/atlas.json"
+	if err := atlas.Save(layout); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadTextureAtlas(layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Destroy()
+	if !slices.Equal(loaded.Names(), atlas.Names()) || len(loaded.Pages()) != 2 {
+		t.Fatalf("expected the loaded atlas to match, got %v", loaded.Names())
+	}
+	if got := loaded.PageImage(0).RGBAAt(bounds.Min.X, bounds.Min.Y); got != red {
+		t.Errorf("expected the loaded page to keep its pixels, got %v", got)
+	}
+	if _, err := loaded.Add("small", solid(4, 4, red)); err != nil {
+		t.Errorf("expected images to fit in the loaded skyline, got %v", err)
+	}
+}
@@ -150,6 +150,8 @@ type uiGlobals struct {
 
 	dragItemLayer int8
 
+	clipStack []glitch.Rect // The intersected clip rects pushed with PushClip
+
 	// lastRect glitch.Rect
 
 	// New Way
@@ -222,6 +224,41 @@ func Layer() int8 {
 	return global.sorter.Layer()
 }
 
+// Clips everything drawn after this call to rect, until the matching PopClip. Nested clips are
+// intersected with each other. Widgets outside of the clip can't be hovered or clicked
+func PushClip(rect glitch.Rect) {
+	if len(global.clipStack) > 0 {
+		rect = intersectRect(rect, global.clipStack[len(global.clipStack)-1])
+	}
+	global.clipStack = append(global.clipStack, rect)
+	global.sorter.SetScissor(rect)
+}
+
+// Removes the most recently pushed clip rect
+func PopClip() {
+	if len(global.clipStack) == 0 {
+		panic("ui: PopClip called without a matching PushClip")
+	}
+	global.clipStack = global.clipStack[:len(global.clipStack)-1]
+
+	if len(global.clipStack) == 0 {
+		global.sorter.DisableScissor()
+	} else {
+		global.sorter.SetScissor(global.clipStack[len(global.clipStack)-1])
+	}
+}
+
+// Returns the overlapping region of two rects, which has zero size if they don't overlap
+func intersectRect(a, b glitch.Rect) glitch.Rect {
+	r := glm.R(
+		max(a.Min.X, b.Min.X), max(a.Min.Y, b.Min.Y),
+		min(a.Max.X, b.Max.X), min(a.Max.Y, b.Max.Y),
+	)
+	r.Max.X = max(r.Min.X, r.Max.X)
+	r.Max.Y = max(r.Min.Y, r.Max.Y)
+	return r
+}
+
 func SetDragData(data any) {
 	global.dragData = data
 }
@@ -258,6 +295,9 @@ func Clear() {
 	global.unionBoundsSet = false
 	global.allBounds = global.allBounds[:0]
 
+	global.clipStack = global.clipStack[:0]
+	global.sorter.DisableScissor()
+
 	// New
 	global.hotId = global.lastHotId
 	global.lastHotId = invalidId
@@ -286,6 +326,9 @@ func mouseCheck(rect glitch.Rect, point glitch.Vec2) bool {
 	// if global.mouseCaught {
 	// 	return false
 	// }
+	if len(global.clipStack) > 0 && !global.clipStack[len(global.clipStack)-1].Contains(point) {
+		return false
+	}
 	if rect.Contains(point) {
 		global.mouseCaught = true
 		return true
@@ -655,12 +655,25 @@ func DragSlot(label string, rect glitch.Rect, style Style) bool {
 	return (resp.droppedId != invalidId)
 }
 
+// Note: Use BeginScroll instead if the scrolled content should be clipped to hoverRect
 func Scrollbar(idx *int, total int, rect, hoverRect glitch.Rect) {
 	val := float64(*idx)
 	SliderV(&val, 0, float64(total), 1, rect, hoverRect)
 	*idx = int(math.Round(val))
 }
 
+// Draws a Scrollbar and then clips everything drawn until the matching EndScroll to hoverRect,
+// so that content that is scrolled out of the region is hidden
+func BeginScroll(idx *int, total int, rect, hoverRect glitch.Rect) {
+	Scrollbar(idx, total, rect, hoverRect)
+	PushClip(hoverRect)
+}
+
+// Ends the scroll region started with BeginScroll
+func EndScroll() {
+	PopClip()
+}
+
 func ScrollbarReverse(idx *int, total int, rect, hoverRect glitch.Rect) {
 	val := -float64(*idx)
 	SliderV(&val, -float64(total), 0, 1, rect, hoverRect)
@@ -848,6 +861,10 @@ func TextInput(label string, str *string, rect glitch.Rect, style Style) {
 	if drawStr == "" {
 		drawStr = " "
 	}
+
+	// Long strings overflow the box, so the text and cursor are clipped to it
+	PushClip(rect)
+	defer PopClip()
 	textResp := doWidget(id, drawStr, mask, style, rect)
 
 	if isActive {
@@ -928,6 +945,8 @@ func MultiText(label string, rect glitch.Rect, textStyle TextStyle) glitch.Rect
 	// id := getId(label)
 	text := removeDedup(label)
 	// doWidget(id, text, mask, style, rect)
+	PushClip(rect)
+	defer PopClip()
 	return drawText(text, rect, textStyle)
 }
 
@@ -2,6 +2,8 @@ package glitch
 
 import (
 	"fmt"
+	"image"
+	"math"
 	"time"
 
 	"github.com/unitoftime/flow/glm"
@@ -17,6 +19,7 @@ type WindowConfig struct {
 	Vsync       bool
 	// Resizable bool
 	Samples int
+	Stencil bool // Requests an 8 bit stencil buffer. Desktop drivers usually provide one by default, but browsers don't
 }
 
 type Window struct {
@@ -67,6 +70,8 @@ type Window struct {
 	mouseRepeatPeriod time.Duration // amount of time in between consecutive repeats after a repeat has started
 
 	lastUpdateTime time.Time
+
+	trackedFrames []trackedFrame
 }
 
 type repeatData struct {
@@ -107,6 +112,9 @@ func NewWindow(width, height int, title string, config WindowConfig) (*Window, e
 		if config.Samples > 0 {
 			glfw.WindowHint(glfw.Samples, config.Samples)
 		}
+		if config.Stencil {
+			glfw.WindowHint(glfw.StencilBits, 8)
+		}
 		glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
 		glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True) // Compatibility - For Mac only?
 
@@ -144,6 +152,7 @@ func NewWindow(width, height int, title string, config WindowConfig) (*Window, e
 		gl.Enable(gl.BLEND) // TODO: Will this ever need to be disabled?
 		// gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA); // Non premult
 		gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA) // Premult
+		gl.EnableProgramPointSize() // So that point shaders can set their size
 
 		if config.Vsync {
 			glfw.SwapInterval(1)
@@ -246,6 +255,7 @@ func NewWindow(width, height int, title string, config WindowConfig) (*Window, e
 		// TODO - using gl.Finish is bad? https://www.khronos.org/opengl/wiki/Swap_Interval
 		// gl.Flush()
 		// gl.Finish()
+		global.gpuTimer.mainthreadEndFrame()
 		win.window.SwapBuffers()
 		glfw.PollEvents()
 
@@ -286,6 +296,9 @@ func (w *Window) Update() {
 	global.finish()
 
 	mainthread.Call(w.mainthreadUpdate)
+	global.endFrame(dt)
+	w.resizeTrackedFrames()
+	uploadAssets()
 
 	w.input = w.tmpInput
 	w.tmpInput.scroll.X = 0
@@ -375,17 +388,97 @@ func (w *Window) Closed() bool {
 	return w.closed
 }
 
+// Closes the window. If leak tracking is enabled, every tracked resource that hasn't been destroyed is logged, see SetLeakTracking
 func (w *Window) Close() {
 	w.closed = true
 	mainthread.Call(func() {
 		w.window.SetShouldClose(true)
 	})
+	reportLeaks()
 }
 
 func (w *Window) Bounds() Rect {
 	return glm.R(0, 0, float64(w.width), float64(w.height))
 }
 
+// Describes how a tracked frame is sized relative to its window
+type FrameSizing struct {
+	// Multiplies the size of the frame, ie 0.5 for a half resolution frame. Zero is treated as 1
+	Scale float64
+
+	// If true, the frame is sized in the window's logical coordinates rather than in framebuffer pixels. On a HiDPI
+	// display with a content scale of 2, the frame will be half the size of the framebuffer
+	Logical bool
+}
+
+type trackedFrame struct {
+	frame  *Frame
+	sizing FrameSizing
+}
+
+// Creates a frame that is resized to match the window every Update. See TrackFrame
+func (w *Window) NewTrackedFrame(cfg FrameConfig, sizing FrameSizing) (*Frame, error) {
+	frame, err := NewFrameWithConfig(w.frameBounds(sizing), cfg)
+	if err != nil {
+		return nil, err
+	}
+	w.TrackFrame(frame, sizing)
+	return frame, nil
+}
+
+// Resizes the frame to match the window every Update. The window holds a reference to the frame
+// until UntrackFrame is called, so tracked frames are never garbage collected
+func (w *Window) TrackFrame(frame *Frame, sizing FrameSizing) {
+	for i := range w.trackedFrames {
+		if w.trackedFrames[i].frame == frame {
+			w.trackedFrames[i].sizing = sizing
+			return
+		}
+	}
+	w.trackedFrames = append(w.trackedFrames, trackedFrame{frame, sizing})
+	frame.Resize(w.frameBounds(sizing))
+}
+
+func (w *Window) UntrackFrame(frame *Frame) {
+	for i := range w.trackedFrames {
+		if w.trackedFrames[i].frame == frame {
+			w.trackedFrames = append(w.trackedFrames[:i], w.trackedFrames[i+1:]...)
+			return
+		}
+	}
+}
+
+func (w *Window) resizeTrackedFrames() {
+	if w.width <= 0 || w.height <= 0 {
+		return // Minimized, so keep the last size rather than creating empty textures
+	}
+	for _, t := range w.trackedFrames {
+		t.frame.Resize(w.frameBounds(t.sizing))
+	}
+}
+
+func (w *Window) frameBounds(sizing FrameSizing) Rect {
+	width, height := float64(w.width), float64(w.height)
+	if sizing.Logical {
+		var sx, sy float32
+		mainthread.Call(func() {
+			sx, sy = w.window.GetContentScale()
+		})
+		if sx > 0 && sy > 0 {
+			width /= float64(sx)
+			height /= float64(sy)
+		}
+	}
+
+	scale := sizing.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	width = max(1, math.Round(width*scale))
+	height = max(1, math.Round(height*scale))
+	return glm.R(0, 0, width, height)
+}
+
 func (w *Window) MousePosition() (float64, float64) {
 	// w.mainthreadCacheMousePosition() // This would decrease cursor lag a *little* bit
 	return w.mousePosition.X, w.mousePosition.Y
@@ -444,14 +537,10 @@ func (w *Window) Bind() {
 }
 
 // Reads a rectangle of the window's frame as a collection of bytes
-// func (w *Window) ReadFrame(rect Rect, dst []byte) {
-// 	mainthreadCall(func() {
-// 		gl.BindFramebuffer(gl.FRAMEBUFFER, gl.NoFramebuffer)
-// 		// TODO Note: https://docs.gl/es3/glReadPixels#:~:text=glReadPixels%20returns%20pixel%20data%20from,parameters%20are%20set%20with%20glPixelStorei.
-// 		// Format and Type Enums define the expected pixel format and type to return to the byte buffer. Right now I have that hardcoded to gl.RGBA and gl.UNSIGNED_BYTE, respectively
-// 		gl.ReadPixels(dst, int(rect.Min[0]), int(rect.Min[1]), int(rect.W()), int(rect.H()), gl.RGBA, gl.UNSIGNED_BYTE)
-// 	})
-// }
+// Reads the window's backbuffer into an image. Call this after drawing and before Update, because the backbuffer contents are undefined once it has been swapped
+func (w *Window) Image() *image.RGBA {
+	return readImage(w, w.width, w.height)
+}
 
 func (w *Window) Pressed(key Key) bool {
 	if key == KeyUnknown {